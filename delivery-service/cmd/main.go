@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
-	"os"
+	"path/filepath"
 
-	"github.com/gorilla/mux"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"platform/config"
+	"platform/health"
+	"platform/httputil"
+	"platform/idempotency"
+	"platform/logging"
+	"platform/server"
+	"platform/storage"
+	"platform/tracing"
 
+	_ "delivery-service/docs"
+	"delivery-service/internal/client"
 	"delivery-service/internal/handlers"
 	"delivery-service/internal/repository"
-	_ "delivery-service/docs"
+	"delivery-service/internal/tracking"
 )
 
 // @title Delivery Service API
@@ -19,45 +29,206 @@ import (
 // @host localhost:8084
 // @basePath /api
 
+const (
+	jsonPath        = "./data/deliveries.json"
+	idempotencyPath = "./data/idempotency_keys.json"
+)
+
 func main() {
-	router := mux.NewRouter()
+	if err := logging.Setup("delivery-service"); err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+
+	migrateDown := flag.Int("migrate-down", -1, "откатить схему SQLite до указанной версии и выйти")
+	flag.Parse()
+
+	if *migrateDown >= 0 {
+		if err := rollbackSQLite(*migrateDown); err != nil {
+			log.Fatalf("Failed to roll back migrations: %v", err)
+		}
+		log.Printf("SQLite schema rolled back to version %d", *migrateDown)
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "delivery-service")
+	if err != nil {
+		log.Fatalf("Invalid tracing config: %v", err)
+	}
+
+	srv := server.New("delivery-service", "8084")
+	// Функции остановки вызываются в обратном порядке: span выгружаются последними
+	srv.OnShutdown(shutdownTracing)
+	// Пробы отвечают уже во время загрузки хранилища; /health/startup - 503 до srv.Run
+	if err := srv.Listen(); err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	backend := config.String("STORAGE_BACKEND", "json")
 
-	// Инициализируем JSON репозиторий
-	repo, err := repository.NewJSONDeliveryRepository("./data/deliveries.json")
+	// Инициализируем репозиторий выбранного бэкенда
+	repo, err := newRepository(backend)
 	if err != nil {
 		log.Fatalf("Failed to initialize repository: %v", err)
 	}
+	// Каждый вызов хранилища - span в трассе запроса
+	repo = repository.NewTracedDeliveryRepository(repo)
+	srv.OnShutdown(func(context.Context) error { return repo.Close() })
+	if dir := storageDir(backend); dir != "" {
+		srv.Health.Add("storage", health.Dir(dir))
+	}
+
+	ids, err := newTrackingGenerator()
+	if err != nil {
+		log.Fatalf("Invalid tracking ID format: %v", err)
+	}
+	carriers, err := tracking.ParseCarriers(config.String("TRACKING_CARRIERS", ""))
+	if err != nil {
+		log.Fatalf("Invalid TRACKING_CARRIERS: %v", err)
+	}
+
+	users, err := httputil.UsersClientFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid users-service client config: %v", err)
+	}
+
+	orders := newOrdersClient()
+	payments := newPaymentsClient()
+	srv.OnShutdown(func(context.Context) error {
+		users.Close()
+		orders.Close()
+		payments.Close()
+		return nil
+	})
+	// Соседи проверяются по /health/live: их собственные зависимости не должны
+	// снимать готовность этого сервиса
+	if users.Required() {
+		srv.Health.Add("users-service", users.HealthCheck())
+	} else {
+		srv.Health.AddOptional("users-service", users.HealthCheck())
+	}
+	srv.Health.Add("orders-service", orders.HealthCheck())
+	srv.Health.Add("payments-service", payments.HealthCheck())
 
-	handler := handlers.NewDeliveryHandler(repo)
+	handler := handlers.NewDeliveryHandler(repo, ids, carriers, users, orders, payments)
+
+	keys, err := newIdempotencyStore(backend)
+	if err != nil {
+		log.Fatalf("Failed to initialize idempotency keys: %v", err)
+	}
+	srv.OnShutdown(func(context.Context) error { return keys.Close() })
 
 	// API endpoints
-	api := router.PathPrefix("/api").Subrouter()
+	api := srv.API
+	api.Use(idempotency.Middleware(keys))
 
 	api.HandleFunc("/deliveries", handler.CreateDelivery).Methods("POST")
 	api.HandleFunc("/deliveries", handler.GetAllDeliveries).Methods("GET")
 	api.HandleFunc("/deliveries/{id}", handler.GetDeliveryByID).Methods("GET")
 	api.HandleFunc("/deliveries/user/{userId}", handler.GetDeliveriesByUserID).Methods("GET")
 	api.HandleFunc("/deliveries/{id}", handler.UpdateDelivery).Methods("PUT")
+	api.HandleFunc("/deliveries/{id}/events", handler.AddTrackingEvent).Methods("POST")
 	api.HandleFunc("/deliveries/user/{userId}", handler.DeleteDeliveriesByUserID).Methods("DELETE")
 
-	// Swagger
-	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8084/swagger/doc.json"),
-	))
+	// Публичное отслеживание для страниц покупателя
+	api.HandleFunc("/tracking/{trackingId}", handler.GetTracking).Methods("GET")
 
-	// Health check
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+	if err := srv.Run(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+// newRepository выбирает бэкенд хранилища: json (по умолчанию), memory или sqlite
+func newRepository(backend string) (repository.DeliveryRepository, error) {
+	switch backend {
+	case "json":
+		repo, err := repository.NewJSONDeliveryRepository(jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "memory":
+		return repository.NewMemoryDeliveryRepository(), nil
+	case "sqlite":
+		repo, err := repository.NewSQLiteDeliveryRepository(sqlitePath())
+		if err != nil {
+			return nil, err
+		}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8084"
+		// Однократно переносим данные, накопленные в JSON-хранилище
+		imported, err := repo.ImportJSON(context.Background(), jsonPath)
+		if err != nil {
+			repo.Close()
+			return nil, fmt.Errorf("import %s: %w", jsonPath, err)
+		}
+		if imported > 0 {
+			log.Printf("Imported %d deliveries from %s", imported, jsonPath)
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
 	}
+}
 
-	log.Printf("Starting delivery-service on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// newTrackingGenerator настраивает формат трек-номеров:
+// TRACKING_ID_PREFIX (по умолчанию TRK) и TRACKING_ID_DIGITS (по умолчанию 10)
+func newTrackingGenerator() (*tracking.Generator, error) {
+	format := tracking.DefaultFormat
+	format.Prefix = config.String("TRACKING_ID_PREFIX", format.Prefix)
+	digits, err := config.Int("TRACKING_ID_DIGITS", format.Digits)
+	if err != nil {
+		return nil, err
 	}
+	format.Digits = digits
+	return tracking.NewGenerator(format)
+}
+
+// newOrdersClient читает заказы из ORDERS_SERVICE_URL
+func newOrdersClient() *client.OrdersServiceClient {
+	return client.NewOrdersServiceClient(config.String("ORDERS_SERVICE_URL", "http://orders-service:8082"))
+}
+
+// newPaymentsClient проверяет оплату заказов в PAYMENTS_SERVICE_URL
+func newPaymentsClient() *client.PaymentsServiceClient {
+	return client.NewPaymentsServiceClient(config.String("PAYMENTS_SERVICE_URL", "http://payments-service:8083"))
+}
+
+// newIdempotencyStore хранит ответы на запросы с Idempotency-Key: в памяти для
+// memory-бэкенда, иначе в idempotencyPath. IDEMPOTENCY_KEY_TTL - сколько хранится ключ, например 48h
+func newIdempotencyStore(backend string) (*idempotency.Store, error) {
+	ttl, err := config.Duration("IDEMPOTENCY_KEY_TTL", idempotency.DefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend == "memory" {
+		return idempotency.NewMemoryStore(ttl), nil
+	}
+	return idempotency.NewStore(idempotencyPath, ttl)
+}
+
+// storageDir - каталог файлов бэкенда, его проверяет /health/ready; у memory файлов нет
+func storageDir(backend string) string {
+	switch backend {
+	case "memory":
+		return ""
+	case "sqlite":
+		return filepath.Dir(sqlitePath())
+	default:
+		return filepath.Dir(jsonPath)
+	}
+}
+
+func sqlitePath() string {
+	return config.String("SQLITE_PATH", "./data/deliveries.db")
+}
+
+// rollbackSQLite откатывает схему базы до версии target
+func rollbackSQLite(target int) error {
+	db, err := storage.OpenSQLite(sqlitePath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return storage.MigrateDown(context.Background(), db, repository.Migrations(), target)
 }
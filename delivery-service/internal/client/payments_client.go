@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"platform/health"
+	"platform/metrics"
+	"platform/money"
+	"platform/pagination"
+	"platform/problem"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+// ErrPaymentsUnavailable - payments-service не ответил или ответил ошибкой
+var ErrPaymentsUnavailable = problem.New(problem.Unavailable, "payments_unavailable", "payments-service unavailable")
+
+// Статусы платежа, при которых деньги списаны
+const (
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+)
+
+// Payment - поля платежа, нужные для проверки оплаты заказа
+type Payment struct {
+	ID             int64       `json:"id"`
+	OrderID        int64       `json:"orderId"`
+	CapturedAmount money.Money `json:"capturedAmount"`
+	RefundedAmount money.Money `json:"refundedAmount"`
+	Status         string      `json:"status"`
+}
+
+// PaymentsServiceClient читает платежи заказа через GET /api/payments?orderId=
+type PaymentsServiceClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewPaymentsServiceClient создаёт клиента payments-service с адресом baseURL
+func NewPaymentsServiceClient(baseURL string) *PaymentsServiceClient {
+	return &PaymentsServiceClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Transport: &metrics.Transport{Client: "PaymentsServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   3 * time.Second,
+		},
+	}
+}
+
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *PaymentsServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// HealthCheck - проверка готовности: отвечает ли payments-service на /health/live
+func (c *PaymentsServiceClient) HealthCheck() health.Check {
+	return health.HTTP(c.baseURL + "/health/live")
+}
+
+// PaidAmount возвращает, сколько по заказу списано в валюте currency и не
+// возвращено, или ErrPaymentsUnavailable
+func (c *PaymentsServiceClient) PaidAmount(ctx context.Context, orderID int64, currency string) (money.Money, error) {
+	url := fmt.Sprintf("%s/api/payments?orderId=%d&limit=%d", c.baseURL, orderID, pagination.MaxListLimit)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return money.Money{}, fmt.Errorf("%w: %v", ErrPaymentsUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return money.Money{}, fmt.Errorf("%w: status %d", ErrPaymentsUnavailable, resp.StatusCode)
+	}
+
+	var page struct {
+		Items []Payment `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return money.Money{}, fmt.Errorf("%w: unexpected response from %s", ErrPaymentsUnavailable, url)
+	}
+
+	paid := money.Zero(currency)
+	for _, payment := range page.Items {
+		if payment.OrderID != orderID || payment.CapturedAmount.Currency != currency ||
+			(payment.Status != PaymentStatusCaptured && payment.Status != PaymentStatusPartiallyRefunded) {
+			continue
+		}
+		net, err := payment.CapturedAmount.Sub(payment.RefundedAmount)
+		if err == nil {
+			paid, err = paid.Add(net)
+		}
+		if err != nil {
+			return money.Money{}, fmt.Errorf("payment %d: %w", payment.ID, err)
+		}
+	}
+	return paid, nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"platform/money"
+)
+
+func TestPaidAmount(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("orderId") {
+		case "1":
+			w.Write([]byte(`{"items":[
+				{"id":1,"orderId":1,"capturedAmount":{"minor":6000,"currency":"RUB"},"refundedAmount":{"minor":1000,"currency":"RUB"},"status":"partially_refunded"},
+				{"id":2,"orderId":1,"capturedAmount":{"minor":5000,"currency":"RUB"},"refundedAmount":{"minor":0,"currency":"RUB"},"status":"captured"},
+				{"id":3,"orderId":1,"capturedAmount":{"minor":9000,"currency":"RUB"},"refundedAmount":{"minor":9000,"currency":"RUB"},"status":"refunded"},
+				{"id":4,"orderId":1,"capturedAmount":{"minor":0,"currency":"RUB"},"refundedAmount":{"minor":0,"currency":"RUB"},"status":"authorized"}
+			],"total":4}`))
+		case "2":
+			w.Write([]byte(`{"items":[],"total":0}`))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	payments := NewPaymentsServiceClient(server.URL)
+
+	paid, err := payments.PaidAmount(ctx, 1, "RUB")
+	if err != nil || !paid.Equal(money.New(10000, "RUB")) {
+		t.Fatalf("PaidAmount = %s, %v; want 100.00 RUB", paid, err)
+	}
+	paid, err = payments.PaidAmount(ctx, 2, "RUB")
+	if err != nil || !paid.IsZero() {
+		t.Fatalf("PaidAmount without payments = %s, %v", paid, err)
+	}
+	if _, err := payments.PaidAmount(ctx, 3, "RUB"); !errors.Is(err, ErrPaymentsUnavailable) {
+		t.Fatalf("server error: error %v, want ErrPaymentsUnavailable", err)
+	}
+}
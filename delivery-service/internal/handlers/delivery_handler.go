@@ -2,25 +2,52 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"platform/httputil"
+	"platform/pagination"
+	"platform/problem"
+	"platform/validation"
+
+	"delivery-service/internal/metrics"
+	"delivery-service/internal/models"
 	"delivery-service/internal/repository"
+	"delivery-service/internal/tracking"
+)
+
+// carrierTokenHeader - токен интеграции перевозчика, которой можно присылать свой трек-номер
+const carrierTokenHeader = "X-Carrier-Token"
+
+// trackingIDAttempts - сколько раз сгенерировать номер заново, если он совпал с выданным
+const trackingIDAttempts = 5
+
+var (
+	errCarrierOnly        = problem.New(problem.Forbidden, "carrier_only", "tracking ID accepted only from configured carriers")
+	errTrackingIDNotFound = problem.New(problem.NotFound, "tracking_id_not_found", "tracking ID not found")
 )
 
 // DeliveryHandler обработчик доставок
 type DeliveryHandler struct {
-	repo *repository.JSONDeliveryRepository
+	repo     repository.DeliveryRepository
+	ids      *tracking.Generator
+	carriers tracking.Carriers
+	users    UserVerifier
+	orders   OrderLookup
+	payments PaymentLookup
 }
 
-// CreateDeliveryRequest структура для создания доставки
+// CreateDeliveryRequest структура для создания доставки.
+// trackingId принимается только от перевозчика с X-Carrier-Token, иначе номер генерирует сервис.
 type CreateDeliveryRequest struct {
-	UserID     int64  `json:"userId" binding:"required"`
-	OrderID    int64  `json:"orderId" binding:"required"`
+	UserID     int64  `json:"userId" binding:"required,min=1"`
+	OrderID    int64  `json:"orderId" binding:"required,min=1"`
 	Address    string `json:"address" binding:"required"`
-	TrackingID string `json:"trackingId" binding:"required"`
+	TrackingID string `json:"trackingId,omitempty"`
 }
 
 // UpdateDeliveryRequest структура для обновления доставки
@@ -28,51 +55,142 @@ type UpdateDeliveryRequest struct {
 	Status string `json:"status" binding:"required"`
 }
 
-// NewDeliveryHandler создаёт новый обработчик
-func NewDeliveryHandler(repo *repository.JSONDeliveryRepository) *DeliveryHandler {
+// TrackingEventRequest структура для добавления события доставки.
+// Без at событие считается произошедшим в момент запроса.
+type TrackingEventRequest struct {
+	Status   string    `json:"status" binding:"required"`
+	Location string    `json:"location"`
+	Note     string    `json:"note"`
+	At       time.Time `json:"at"`
+}
+
+// NewDeliveryHandler создаёт новый обработчик. ids выдаёт трек-номера,
+// carriers - интеграции, которым разрешено присылать свои, users проверяет получателя,
+// orders - что заказ его, payments - что он оплачен.
+func NewDeliveryHandler(repo repository.DeliveryRepository, ids *tracking.Generator, carriers tracking.Carriers, users UserVerifier, orders OrderLookup, payments PaymentLookup) *DeliveryHandler {
 	return &DeliveryHandler{
-		repo: repo,
+		repo:     repo,
+		ids:      ids,
+		carriers: carriers,
+		users:    users,
+		orders:   orders,
+		payments: payments,
 	}
 }
 
 // CreateDelivery создаёт доставку
 // @Summary Создать доставку
-// @Description Создать новую доставку
+// @Description Создать новую доставку. Трек-номер с контрольной цифрой выдаёт сервис;
+// @Description свой trackingId может передать только перевозчик с X-Carrier-Token.
+// @Description Пользователь проверяется в users-service, заказ - в orders-service:
+// @Description он должен принадлежать пользователю и быть оплачен: processing или completed,
+// @Description а в payments-service списана и не возвращена вся сумма заказа.
 // @Tags deliveries
 // @Accept json
 // @Produce json
 // @Param delivery body CreateDeliveryRequest true "Данные доставки"
+// @Param X-Carrier-Token header string false "Токен интеграции перевозчика"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {string} string "Invalid request"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 403 {object} problem.Problem "Tracking ID accepted only from configured carriers"
+// @Failure 409 {object} problem.Problem "Tracking ID already exists or order is not paid"
+// @Failure 422 {object} problem.Problem "User or order not found, or order of another user"
+// @Failure 503 {object} problem.Problem "Users, orders or payments service unavailable"
 // @Router /deliveries [post]
 func (h *DeliveryHandler) CreateDelivery(w http.ResponseWriter, r *http.Request) {
 	var req CreateDeliveryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	if req.TrackingID != "" {
+		if _, ok := h.carriers.Authenticate(r.Header.Get(carrierTokenHeader)); !ok {
+			problem.Write(w, r, errCarrierOnly)
+			return
+		}
+		if err := h.ids.CheckForeign(req.TrackingID); err != nil {
+			problem.Write(w, r, err)
+			return
+		}
+	}
+
+	if err := h.users.VerifyUser(r.Context(), req.UserID); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	order, ok := lookupOrder(w, r, h.orders, req.UserID, req.OrderID)
+	if !ok {
+		return
+	}
+	if !checkPaid(w, r, h.payments, order) {
 		return
 	}
 
-	delivery, err := h.repo.CreateDelivery(req.UserID, req.OrderID, req.Address, req.TrackingID)
+	var (
+		delivery *models.Delivery
+		err      error
+	)
+	if req.TrackingID != "" {
+		delivery, err = h.repo.CreateDelivery(r.Context(), req.UserID, req.OrderID, req.Address, req.TrackingID)
+	} else {
+		delivery, err = h.createWithGeneratedID(r, req)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
+	metrics.DeliveryStatus(delivery.Status)
 
+	httputil.SetETag(w, delivery.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(delivery)
 }
 
-// GetAllDeliveries получает все доставки
-// @Summary Получить все доставки
-// @Description Получить список всех доставок
+// createWithGeneratedID создаёт доставку с новым номером; при совпадении с уже
+// выданным номер генерируется заново
+func (h *DeliveryHandler) createWithGeneratedID(r *http.Request, req CreateDeliveryRequest) (*models.Delivery, error) {
+	for attempt := 1; ; attempt++ {
+		trackingID, err := h.ids.New()
+		if err != nil {
+			return nil, err
+		}
+
+		delivery, err := h.repo.CreateDelivery(r.Context(), req.UserID, req.OrderID, req.Address, trackingID)
+		if !errors.Is(err, repository.ErrTrackingIDTaken) || attempt == trackingIDAttempts {
+			return delivery, err
+		}
+	}
+}
+
+// GetAllDeliveries получает страницу доставок
+// @Summary Получить доставки
+// @Description Получить страницу доставок с сортировкой, фильтрами и курсором
 // @Tags deliveries
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param status query string false "Статус"
+// @Param userId query int64 false "ID пользователя"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /deliveries [get]
 func (h *DeliveryHandler) GetAllDeliveries(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode([]interface{}{})
+	q, err := pagination.ParseListQuery(r.URL.Query(), pagination.FilterStatus, pagination.FilterUserID)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	h.writeList(w, r, q)
 }
 
 // GetDeliveryByID получает доставку по ID
@@ -81,51 +199,80 @@ func (h *DeliveryHandler) GetAllDeliveries(w http.ResponseWriter, r *http.Reques
 // @Tags deliveries
 // @Produce json
 // @Param id path int64 true "ID доставки"
+// @Param If-None-Match header string false "ETag известной клиенту версии"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "Delivery not found"
+// @Success 304 "Not modified"
+// @Failure 404 {object} problem.Problem "Delivery not found"
 // @Router /deliveries/{id} [get]
 func (h *DeliveryHandler) GetDeliveryByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
-	delivery, err := h.repo.GetDeliveryByID(id)
+	delivery, err := h.repo.GetDeliveryByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, err)
 		return
 	}
 
+	if httputil.NotModified(w, r, delivery.Version) {
+		return
+	}
+
+	httputil.SetETag(w, delivery.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(delivery)
 }
 
 // GetDeliveriesByUserID получает доставки пользователя
 // @Summary Получить доставки пользователя
-// @Description Получить все доставки конкретного пользователя
+// @Description Получить страницу доставок конкретного пользователя; параметры те же, что у списка
 // @Tags deliveries
 // @Produce json
 // @Param userId path int64 true "ID пользователя"
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param status query string false "Статус"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /deliveries/user/{userId} [get]
 func (h *DeliveryHandler) GetDeliveriesByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidUserID)
+		return
+	}
+
+	q, err := pagination.ParseListQuery(r.URL.Query(), pagination.FilterStatus)
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
+	q.UserID = userID
 
-	deliveries, err := h.repo.GetDeliveriesByUserID(userID)
+	h.writeList(w, r, q)
+}
+
+// writeList отдаёт страницу доставок по разобранному запросу списка
+func (h *DeliveryHandler) writeList(w http.ResponseWriter, r *http.Request, q pagination.ListQuery) {
+	page, err := h.repo.ListDeliveries(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(deliveries)
+	json.NewEncoder(w).Encode(page)
 }
 
 // UpdateDelivery обновляет доставку
@@ -136,48 +283,165 @@ func (h *DeliveryHandler) GetDeliveriesByUserID(w http.ResponseWriter, r *http.R
 // @Produce json
 // @Param id path int64 true "ID доставки"
 // @Param delivery body UpdateDeliveryRequest true "Новый статус"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "Delivery not found"
+// @Failure 404 {object} problem.Problem "Delivery not found"
+// @Failure 412 {object} problem.Problem "Precondition failed"
 // @Router /deliveries/{id} [put]
 func (h *DeliveryHandler) UpdateDelivery(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
 	var req UpdateDeliveryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, err := httputil.ExpectedVersion(r, h.deliveryVersion(r, id))
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	delivery, err := h.repo.UpdateDeliveryStatus(r.Context(), id, req.Status, version)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+	metrics.DeliveryStatus(delivery.Status)
+
+	httputil.SetETag(w, delivery.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// AddTrackingEvent добавляет событие в хронологию доставки
+// @Summary Добавить событие доставки
+// @Description Дописать событие (статус, место, комментарий, время) в хронологию доставки.
+// @Description Статус доставки становится статусом самого позднего события.
+// @Tags deliveries
+// @Accept json
+// @Produce json
+// @Param id path int64 true "ID доставки"
+// @Param event body TrackingEventRequest true "Событие"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 201 {object} models.Delivery
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 404 {object} problem.Problem "Delivery not found"
+// @Failure 412 {object} problem.Problem "Precondition failed"
+// @Router /deliveries/{id}/events [post]
+func (h *DeliveryHandler) AddTrackingEvent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	var req TrackingEventRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, err := httputil.ExpectedVersion(r, h.deliveryVersion(r, id))
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	delivery, err := h.repo.UpdateDeliveryStatus(id, req.Status)
+	event := models.TrackingEvent{Status: req.Status, Location: req.Location, Note: req.Note, At: req.At}
+	delivery, err := h.repo.AddTrackingEvent(r.Context(), id, event, version)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
+	metrics.DeliveryStatus(delivery.Status)
 
+	httputil.SetETag(w, delivery.Version)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(delivery)
 }
 
+// GetTracking отдаёт хронологию доставки по трек-номеру
+// @Summary Отследить доставку
+// @Description Публичная хронология доставки по трек-номеру, без данных пользователя и адреса.
+// @Description Ненайденный номер в формате сервиса с неверной контрольной цифрой - 400, а не 404.
+// @Tags tracking
+// @Produce json
+// @Param trackingId path string true "Трек-номер"
+// @Param If-None-Match header string false "ETag известной клиенту версии"
+// @Success 200 {object} models.Tracking
+// @Success 304 "Not modified"
+// @Failure 400 {object} problem.Problem "Invalid tracking ID"
+// @Failure 404 {object} problem.Problem "Tracking ID not found"
+// @Router /tracking/{trackingId} [get]
+func (h *DeliveryHandler) GetTracking(w http.ResponseWriter, r *http.Request) {
+	trackingID := mux.Vars(r)["trackingId"]
+
+	delivery, err := h.repo.GetDeliveryByTrackingID(r.Context(), trackingID)
+	if errors.Is(err, repository.ErrDeliveryNotFound) {
+		// Контрольная цифра проверяется только у ненайденного номера: номера,
+		// выданные до её появления, с нашим префиксом, но без неё, находятся как есть.
+		// Опечатка в номере своего формата - 400, чтобы клиент перепроверил номер.
+		if err := h.ids.Check(trackingID); errors.Is(err, tracking.ErrInvalidTrackingID) {
+			problem.Write(w, r, err)
+			return
+		}
+		problem.Write(w, r, errTrackingIDNotFound)
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	if httputil.NotModified(w, r, delivery.Version) {
+		return
+	}
+
+	httputil.SetETag(w, delivery.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery.Tracking())
+}
+
+// deliveryVersion читает текущую версию доставки для проверки If-Match
+func (h *DeliveryHandler) deliveryVersion(r *http.Request, id int64) func() (int64, error) {
+	return func() (int64, error) {
+		delivery, err := h.repo.GetDeliveryByID(r.Context(), id)
+		if err != nil {
+			return 0, err
+		}
+		return delivery.Version, nil
+	}
+}
+
 // DeleteDeliveriesByUserID удаляет доставки пользователя
 // @Summary Удалить доставки пользователя
 // @Description Удалить все доставки пользователя
 // @Tags deliveries
 // @Param userId path int64 true "ID пользователя"
 // @Success 204
+// @Failure 500 {object} problem.Problem "Storage error"
 // @Router /deliveries/user/{userId} [delete]
 func (h *DeliveryHandler) DeleteDeliveriesByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidUserID)
+		return
+	}
+
+	// Ошибку отдаём наружу: на этот ответ опирается сага удаления пользователя
+	if err := h.repo.DeleteDeliveriesByUserID(r.Context(), userID); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	h.repo.DeleteDeliveriesByUserID(userID)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"platform/problem"
+
+	"delivery-service/internal/models"
+	"delivery-service/internal/repository"
+	"delivery-service/internal/tracking"
+)
+
+const testDelivery = `{"userId": 1, "orderId": 1, "address": "Lenina 1, Moscow"}`
+
+func TestGetTrackingHidesUserAndAddress(t *testing.T) {
+	router := newTestRouter(t, nil)
+
+	w := serve(router, http.MethodPost, "/deliveries", testDelivery, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", w.Code, w.Body)
+	}
+	var delivery models.Delivery
+	decode(t, w, &delivery)
+
+	w = serve(router, http.MethodPost, "/deliveries/1/events", `{"status": "in_transit", "location": "Tver"}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("event: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = serve(router, http.MethodGet, "/tracking/"+delivery.TrackingID, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("tracking: status %d, body %s", w.Code, w.Body)
+	}
+	var view map[string]interface{}
+	decode(t, w, &view)
+	for _, field := range []string{"id", "userId", "orderId", "address"} {
+		if _, ok := view[field]; ok {
+			t.Fatalf("tracking view exposes %s: %v", field, view)
+		}
+	}
+	events, _ := view["events"].([]interface{})
+	if view["trackingId"] != delivery.TrackingID || len(events) == 0 {
+		t.Fatalf("tracking view %v", view)
+	}
+	last, _ := events[len(events)-1].(map[string]interface{})
+	if last["status"] != "in_transit" || last["location"] != "Tver" {
+		t.Fatalf("last event %v", last)
+	}
+}
+
+func TestCreateDeliveryCarrierTrackingID(t *testing.T) {
+	router := newTestRouter(t, tracking.Carriers{"secret": "dhl"})
+	body := `{"userId": 1, "orderId": 1, "address": "Lenina 1, Moscow", "trackingId": "DHL-42"}`
+
+	for _, token := range []string{"", "wrong"} {
+		w := serve(router, http.MethodPost, "/deliveries", body, map[string]string{carrierTokenHeader: token})
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("token %q: status %d, want 403", token, w.Code)
+		}
+		var p problem.Problem
+		decode(t, w, &p)
+		if p.Code != "carrier_only" {
+			t.Fatalf("token %q: problem %+v", token, p)
+		}
+	}
+
+	w := serve(router, http.MethodPost, "/deliveries", body, map[string]string{carrierTokenHeader: "secret"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("carrier: status %d, body %s", w.Code, w.Body)
+	}
+
+	// Повтор номера - конфликт, а не вторая доставка с тем же номером
+	w = serve(router, http.MethodPost, "/deliveries", body, map[string]string{carrierTokenHeader: "secret"})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("duplicate: status %d, want 409", w.Code)
+	}
+
+	w = serve(router, http.MethodGet, "/tracking/TRK00000000001", "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad check digit: status %d, want 400", w.Code)
+	}
+}
+
+func TestGetTrackingFindsLegacyTrackingID(t *testing.T) {
+	// Номер выдан до появления контрольной цифры: префикс наш, цифра неверна
+	const legacy = "TRK00000000001"
+	repo := repository.NewMemoryDeliveryRepository()
+	if _, err := repo.CreateDelivery(context.Background(), 1, 1, "Lenina 1, Moscow", legacy); err != nil {
+		t.Fatalf("CreateDelivery: %v", err)
+	}
+	router := newRepoRouter(t, repo, nil)
+
+	w := serve(router, http.MethodGet, "/tracking/"+legacy, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("legacy tracking ID: status %d, body %s", w.Code, w.Body)
+	}
+	var view models.Tracking
+	decode(t, w, &view)
+	if view.TrackingID != legacy {
+		t.Fatalf("tracking view %+v", view)
+	}
+
+	if w := serve(router, http.MethodGet, "/tracking/TRK00000000002", "", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown ID with bad check digit: status %d, want 400", w.Code)
+	}
+}
+
+func TestCreateDeliveryReportsFields(t *testing.T) {
+	w := serve(newTestRouter(t, nil), http.MethodPost, "/deliveries", `{"userId": 1, "orderId": "1"}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+	var p problem.Problem
+	decode(t, w, &p)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "orderId" || p.Errors[0].Rule != "type" {
+		t.Fatalf("wrong type: errors %+v", p.Errors)
+	}
+
+	w = serve(newTestRouter(t, nil), http.MethodPost, "/deliveries", `{"userId": 1, "orderId": 1}`, nil)
+	p = problem.Problem{}
+	decode(t, w, &p)
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "address" || p.Errors[0].Rule != "required" {
+		t.Fatalf("missing address: status %d, errors %+v", w.Code, p.Errors)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"platform/money"
+
+	"delivery-service/internal/client"
+	"delivery-service/internal/repository"
+	"delivery-service/internal/tracking"
+)
+
+// fakeUsers - любой пользователь существует
+type fakeUsers struct{}
+
+func (fakeUsers) VerifyUser(context.Context, int64) error { return nil }
+
+// fakeOrders - оформленные заказы пользователя 1 на 100 RUB
+type fakeOrders struct{}
+
+func (fakeOrders) GetOrder(_ context.Context, orderID int64) (*client.Order, error) {
+	return &client.Order{ID: orderID, UserID: 1, TotalAmount: money.New(10000, "RUB"), Status: client.OrderStatusProcessing}, nil
+}
+
+// fakePayments - каждый заказ оплачен целиком
+type fakePayments struct{}
+
+func (fakePayments) PaidAmount(_ context.Context, _ int64, currency string) (money.Money, error) {
+	return money.New(10000, currency), nil
+}
+
+// newTestRouter - маршруты как в cmd/main.go поверх репозитория в памяти
+func newTestRouter(t *testing.T, carriers tracking.Carriers) *mux.Router {
+	t.Helper()
+	return newRepoRouter(t, repository.NewMemoryDeliveryRepository(), carriers)
+}
+
+// newRepoRouter - роутер обработчика поверх готового репозитория repo
+func newRepoRouter(t *testing.T, repo repository.DeliveryRepository, carriers tracking.Carriers) *mux.Router {
+	t.Helper()
+	ids, err := tracking.NewGenerator(tracking.DefaultFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewDeliveryHandler(repo, ids, carriers, fakeUsers{}, fakeOrders{}, fakePayments{})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/deliveries", handler.CreateDelivery).Methods("POST")
+	r.HandleFunc("/deliveries/{id}/events", handler.AddTrackingEvent).Methods("POST")
+	r.HandleFunc("/tracking/{trackingId}", handler.GetTracking).Methods("GET")
+	return r
+}
+
+// serve выполняет запрос к router; header - дополнительные заголовки
+func serve(router http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// decode разбирает JSON-ответ в v; тело не JSON - ошибка теста
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decode response %d: %v", w.Code, err)
+	}
+}
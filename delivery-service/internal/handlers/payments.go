@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"platform/money"
+	"platform/problem"
+
+	"delivery-service/internal/client"
+)
+
+// errOrderNotPaid - заказ не оплачен: статус заказа не processing/completed
+// или списанных и не возвращённых платежей меньше суммы заказа
+var errOrderNotPaid = problem.New(problem.Conflict, "order_not_paid", "order is not paid")
+
+// PaymentLookup считает оплату заказа в payments-service (client.PaymentsServiceClient)
+type PaymentLookup interface {
+	PaidAmount(ctx context.Context, orderID int64, currency string) (money.Money, error)
+}
+
+// checkPaid проверяет, что заказ оплачен целиком: статус processing или completed
+// ставит и обычный PUT, поэтому сумма сверяется с платежами. При ошибке
+// отвечает сам: 409 - не оплачен, 503 - payments-service недоступен.
+func checkPaid(w http.ResponseWriter, r *http.Request, payments PaymentLookup, order *client.Order) bool {
+	if order.Status != client.OrderStatusProcessing && order.Status != client.OrderStatusCompleted {
+		problem.Write(w, r, problem.Errorf(problem.Conflict, "order_not_paid", "%w: order %d is %s", errOrderNotPaid, order.ID, order.Status))
+		return false
+	}
+
+	paid, err := payments.PaidAmount(r.Context(), order.ID, order.TotalAmount.Currency)
+	if err != nil {
+		problem.Write(w, r, err)
+		return false
+	}
+	if cmp, err := paid.Cmp(order.TotalAmount); err != nil || cmp < 0 {
+		problem.Write(w, r, problem.Errorf(problem.Conflict, "order_not_paid", "%w: order %d paid %s of %s", errOrderNotPaid, order.ID, paid, order.TotalAmount))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+// Package metrics - доменные метрики delivery-service. Метрики HTTP, клиентов
+// и хранилища собирает platform/metrics, отдаются все вместе на /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var deliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "deliveries_total",
+	Help: "Deliveries created and updated, by resulting status.",
+}, []string{"status"})
+
+// DeliveryStatus учитывает доставку, созданную или обновлённую до статуса status
+func DeliveryStatus(status string) {
+	deliveries.WithLabelValues(status).Inc()
+}
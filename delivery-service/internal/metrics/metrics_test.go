@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeliveryStatus(t *testing.T) {
+	DeliveryStatus("pending")
+	DeliveryStatus("pending")
+	DeliveryStatus("delivered")
+
+	if got := testutil.ToFloat64(deliveries.WithLabelValues("pending")); got != 2 {
+		t.Errorf("pending deliveries %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(deliveries.WithLabelValues("delivered")); got != 1 {
+		t.Errorf("delivered deliveries %v, want 1", got)
+	}
+}
@@ -1,14 +1,73 @@
 package models
 
-import "time"
+import (
+	"sort"
+	"time"
+)
+
+// TrackingEvent - событие в истории доставки: скан на складе, передача курьеру и т.п.
+type TrackingEvent struct {
+	Status   string    `json:"status"`
+	Location string    `json:"location,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	At       time.Time `json:"at"`
+}
 
 type Delivery struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"userId"`
-	OrderID    int64     `json:"orderId"`
-	Address    string    `json:"address"`
-	Status     string    `json:"status"` // pending, shipped, delivered
-	TrackingID string    `json:"trackingId"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID         int64           `json:"id"`
+	UserID     int64           `json:"userId"`
+	OrderID    int64           `json:"orderId"`
+	Address    string          `json:"address"`
+	Status     string          `json:"status"`  // pending, shipped, delivered
+	Version    int64           `json:"version"` // растёт при каждом изменении, отдаётся как ETag
+	TrackingID string          `json:"trackingId"`
+	Events     []TrackingEvent `json:"events,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+// AddEvent вставляет событие в хронологию по времени At. Статус доставки
+// становится статусом самого позднего события: перевозчик может прислать
+// сканы не по порядку.
+func (d *Delivery) AddEvent(event TrackingEvent, now time.Time) {
+	if event.At.IsZero() {
+		event.At = now
+	}
+
+	i := sort.Search(len(d.Events), func(i int) bool { return d.Events[i].At.After(event.At) })
+	d.Events = append(d.Events, TrackingEvent{})
+	copy(d.Events[i+1:], d.Events[i:])
+	d.Events[i] = event
+
+	d.Status = d.Events[len(d.Events)-1].Status
+	d.UpdatedAt = now
+}
+
+// Tracking - публичное представление доставки для страницы отслеживания,
+// без пользователя, заказа и адреса
+type Tracking struct {
+	TrackingID string          `json:"trackingId"`
+	Status     string          `json:"status"`
+	Events     []TrackingEvent `json:"events"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+// Tracking возвращает публичное представление доставки
+func (d *Delivery) Tracking() *Tracking {
+	events := append([]TrackingEvent{}, d.Events...)
+	return &Tracking{
+		TrackingID: d.TrackingID,
+		Status:     d.Status,
+		Events:     events,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
+}
+
+// Clone возвращает независимую копию доставки
+func (d *Delivery) Clone() *Delivery {
+	clone := *d
+	clone.Events = append([]TrackingEvent(nil), d.Events...)
+	return &clone
 }
@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"platform/pagination"
+
+	"delivery-service/internal/models"
+)
+
+// Общий набор проверок, который обязан проходить каждый бэкенд DeliveryRepository
+
+func TestJSONDeliveryRepositoryConformance(t *testing.T) {
+	runDeliveryRepositoryConformance(t, func(t *testing.T) DeliveryRepository {
+		repo, err := NewJSONDeliveryRepository(filepath.Join(t.TempDir(), "deliveries.json"))
+		if err != nil {
+			t.Fatalf("NewJSONDeliveryRepository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestMemoryDeliveryRepositoryConformance(t *testing.T) {
+	runDeliveryRepositoryConformance(t, func(t *testing.T) DeliveryRepository {
+		return NewMemoryDeliveryRepository()
+	})
+}
+
+func TestTracedDeliveryRepositoryConformance(t *testing.T) {
+	runDeliveryRepositoryConformance(t, func(t *testing.T) DeliveryRepository {
+		return NewTracedDeliveryRepository(NewMemoryDeliveryRepository())
+	})
+}
+
+func TestSQLiteDeliveryRepositoryConformance(t *testing.T) {
+	runDeliveryRepositoryConformance(t, func(t *testing.T) DeliveryRepository {
+		repo, err := NewSQLiteDeliveryRepository(filepath.Join(t.TempDir(), "deliveries.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteDeliveryRepository: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestJSONDeliveryRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deliveries.json")
+
+	repo, err := NewJSONDeliveryRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONDeliveryRepository: %v", err)
+	}
+	first, _ := repo.CreateDelivery(ctx, 1, 10, "Main St 1", "TRACK1")
+	repo.CreateDelivery(ctx, 2, 11, "Main St 2", "TRACK2")
+	repo.UpdateDeliveryStatus(ctx, first.ID, "shipped", 0)
+	repo.DeleteDeliveriesByUserID(ctx, 2)
+
+	reopened, err := NewJSONDeliveryRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := reopened.GetDeliveryByID(ctx, first.ID)
+	if err != nil || got.Status != "shipped" || got.Version != 2 {
+		t.Fatalf("got %+v, %v after reopen", got, err)
+	}
+	if deliveries, _ := reopened.GetDeliveriesByUserID(ctx, 2); len(deliveries) != 0 {
+		t.Fatalf("deleted deliveries are back after reopen")
+	}
+}
+
+func TestJSONDeliveryRepositoryKeepsStateOnStorageError(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewJSONDeliveryRepository(filepath.Join(t.TempDir(), "deliveries.json"))
+	if err != nil {
+		t.Fatalf("NewJSONDeliveryRepository: %v", err)
+	}
+	delivery, _ := repo.CreateDelivery(ctx, 1, 10, "Main St 1", "TRACK1")
+	// Закрытое хранилище отклоняет запись в журнал
+	repo.storage.Close()
+
+	if _, err := repo.CreateDelivery(ctx, 1, 11, "Main St 2", "TRACK2"); err == nil {
+		t.Fatal("CreateDelivery succeeded with failing storage")
+	}
+	// Номер несохранённой доставки свободен
+	if _, err := repo.GetDeliveryByTrackingID(ctx, "TRACK2"); err == nil {
+		t.Fatal("unsaved delivery is visible by tracking ID")
+	}
+	if _, err := repo.UpdateDeliveryStatus(ctx, delivery.ID, "shipped", 0); err == nil {
+		t.Fatal("UpdateDeliveryStatus succeeded with failing storage")
+	}
+	if err := repo.DeleteDeliveriesByUserID(ctx, 1); err == nil {
+		t.Fatal("DeleteDeliveriesByUserID succeeded with failing storage")
+	}
+
+	got, err := repo.GetDeliveryByID(ctx, delivery.ID)
+	if err != nil || got.Status != "pending" || got.Version != 1 {
+		t.Fatalf("got %+v, %v; want the delivery as persisted", got, err)
+	}
+}
+
+func runDeliveryRepositoryConformance(t *testing.T, newRepo func(t *testing.T) DeliveryRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+
+		delivery, err := repo.CreateDelivery(ctx, 7, 3, "Main St 1", "TRACK1")
+		if err != nil {
+			t.Fatalf("CreateDelivery: %v", err)
+		}
+		if delivery.ID == 0 || delivery.UserID != 7 || delivery.OrderID != 3 || delivery.Status != "pending" || delivery.TrackingID != "TRACK1" {
+			t.Fatalf("unexpected delivery %+v", delivery)
+		}
+
+		got, err := repo.GetDeliveryByID(ctx, delivery.ID)
+		if err != nil {
+			t.Fatalf("GetDeliveryByID: %v", err)
+		}
+		if got.ID != delivery.ID || got.Address != "Main St 1" || got.CreatedAt.IsZero() {
+			t.Fatalf("unexpected delivery %+v", got)
+		}
+	})
+
+	t.Run("IDsAreUnique", func(t *testing.T) {
+		repo := newRepo(t)
+
+		a, _ := repo.CreateDelivery(ctx, 1, 1, "a", "T1")
+		b, _ := repo.CreateDelivery(ctx, 1, 1, "b", "T2")
+		if a.ID == b.ID {
+			t.Fatalf("duplicate id %d", a.ID)
+		}
+	})
+
+	t.Run("TrackingIDsAreUnique", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateDelivery(ctx, 1, 1, "a", "TRACK1")
+		if _, err := repo.CreateDelivery(ctx, 2, 2, "b", "TRACK1"); !errors.Is(err, ErrTrackingIDTaken) {
+			t.Fatalf("duplicate tracking ID: error %v, want ErrTrackingIDTaken", err)
+		}
+
+		// Доставки без номера не конфликтуют друг с другом
+		if _, err := repo.CreateDelivery(ctx, 1, 2, "c", ""); err != nil {
+			t.Fatalf("first delivery without tracking ID: %v", err)
+		}
+		if _, err := repo.CreateDelivery(ctx, 1, 3, "d", ""); err != nil {
+			t.Fatalf("second delivery without tracking ID: %v", err)
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetDeliveryByID(ctx, 42); err == nil {
+			t.Fatal("expected error for missing delivery")
+		}
+	})
+
+	t.Run("GetByUserID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateDelivery(ctx, 1, 1, "a", "T1")
+		repo.CreateDelivery(ctx, 2, 2, "b", "T2")
+		repo.CreateDelivery(ctx, 1, 3, "c", "T3")
+
+		deliveries, err := repo.GetDeliveriesByUserID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetDeliveriesByUserID: %v", err)
+		}
+		if len(deliveries) != 2 {
+			t.Fatalf("got %d deliveries, want 2", len(deliveries))
+		}
+		for _, delivery := range deliveries {
+			if delivery.UserID != 1 {
+				t.Fatalf("delivery %d belongs to user %d", delivery.ID, delivery.UserID)
+			}
+		}
+
+		none, err := repo.GetDeliveriesByUserID(ctx, 3)
+		if err != nil || len(none) != 0 {
+			t.Fatalf("got %v, %v for user without deliveries", none, err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for i, userID := range []int64{1, 2, 1, 1, 2} {
+			if _, err := repo.CreateDelivery(ctx, userID, int64(i+1), "a", ""); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+		if _, err := repo.UpdateDeliveryStatus(ctx, 3, "shipped", 0); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		q := pagination.ListQuery{Limit: 2, Sort: pagination.SortByID, UserID: 1}
+		var got []int64
+		for pages := 0; ; pages++ {
+			if pages > 2 {
+				t.Fatalf("too many pages: %v", got)
+			}
+			page, err := repo.ListDeliveries(ctx, q)
+			if err != nil {
+				t.Fatalf("ListDeliveries: %v", err)
+			}
+			if page.Total != 3 {
+				t.Fatalf("total %d, want 3", page.Total)
+			}
+			for _, delivery := range page.Items {
+				got = append(got, delivery.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			last := page.Items[len(page.Items)-1]
+			q.After = &pagination.Cursor{Sort: q.Sort, ID: last.ID}
+		}
+		if len(got) != 3 || got[0] != 1 || got[1] != 3 || got[2] != 4 {
+			t.Fatalf("pages %v, want [1 3 4]", got)
+		}
+
+		page, err := repo.ListDeliveries(ctx, pagination.ListQuery{Limit: 10, Sort: pagination.SortByUpdatedAt, Desc: true, Status: "shipped"})
+		if err != nil {
+			t.Fatalf("ListDeliveries: %v", err)
+		}
+		if page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID != 3 || page.NextCursor != "" {
+			t.Fatalf("page by status %+v", page)
+		}
+	})
+
+	t.Run("UpdateStatus", func(t *testing.T) {
+		repo := newRepo(t)
+
+		delivery, _ := repo.CreateDelivery(ctx, 1, 1, "a", "T1")
+		updated, err := repo.UpdateDeliveryStatus(ctx, delivery.ID, "shipped", 0)
+		if err != nil {
+			t.Fatalf("UpdateDeliveryStatus: %v", err)
+		}
+		if updated.Status != "shipped" {
+			t.Fatalf("status %q, want shipped", updated.Status)
+		}
+
+		got, _ := repo.GetDeliveryByID(ctx, delivery.ID)
+		if got.Status != "shipped" {
+			t.Fatalf("status %q not stored", got.Status)
+		}
+
+		if _, err := repo.UpdateDeliveryStatus(ctx, 42, "shipped", 0); err == nil {
+			t.Fatal("expected error for missing delivery")
+		}
+	})
+
+	t.Run("TrackingEvents", func(t *testing.T) {
+		repo := newRepo(t)
+
+		delivery, _ := repo.CreateDelivery(ctx, 1, 1, "addr", "TRACK1")
+		base := time.Date(2025, 12, 5, 10, 0, 0, 0, time.UTC)
+
+		shipped, err := repo.AddTrackingEvent(ctx, delivery.ID,
+			models.TrackingEvent{Status: "shipped", Location: "Moscow", Note: "handed to carrier", At: base}, 0)
+		if err != nil {
+			t.Fatalf("AddTrackingEvent: %v", err)
+		}
+		if shipped.Status != "shipped" || shipped.Version != 2 || len(shipped.Events) != 1 {
+			t.Fatalf("after first event: %+v", shipped)
+		}
+
+		repo.AddTrackingEvent(ctx, delivery.ID, models.TrackingEvent{Status: "delivered", At: base.Add(2 * time.Hour)}, 0)
+		// Скан, пришедший с опозданием, встаёт на своё место и не откатывает статус
+		repo.AddTrackingEvent(ctx, delivery.ID, models.TrackingEvent{Status: "in_transit", Location: "Tver", At: base.Add(time.Hour)}, 0)
+
+		got, _ := repo.GetDeliveryByID(ctx, delivery.ID)
+		if got.Status != "delivered" || got.Version != 4 {
+			t.Fatalf("status %q version %d, want delivered 4", got.Status, got.Version)
+		}
+		want := []string{"shipped", "in_transit", "delivered"}
+		if len(got.Events) != len(want) {
+			t.Fatalf("events %+v, want %v", got.Events, want)
+		}
+		for i, event := range got.Events {
+			if event.Status != want[i] {
+				t.Fatalf("events[%d] = %+v, want %s", i, event, want[i])
+			}
+		}
+		if first := got.Events[0]; first.Location != "Moscow" || first.Note != "handed to carrier" || !first.At.Equal(base) {
+			t.Fatalf("first event stored as %+v", first)
+		}
+
+		// Смена статуса через UpdateDeliveryStatus тоже попадает в хронологию
+		returned, _ := repo.UpdateDeliveryStatus(ctx, delivery.ID, "returned", 0)
+		if last := returned.Events[len(returned.Events)-1]; last.Status != "returned" || last.At.IsZero() {
+			t.Fatalf("status update event %+v", last)
+		}
+
+		if _, err := repo.AddTrackingEvent(ctx, 42, models.TrackingEvent{Status: "shipped"}, 0); err == nil {
+			t.Fatal("expected error for missing delivery")
+		}
+		if _, err := repo.AddTrackingEvent(ctx, delivery.ID, models.TrackingEvent{Status: "lost"}, 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale event error %v, want ErrVersionMismatch", err)
+		}
+	})
+
+	t.Run("GetByTrackingID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateDelivery(ctx, 1, 1, "a", "TRACK1")
+		second, _ := repo.CreateDelivery(ctx, 2, 2, "b", "TRACK2")
+		repo.CreateDelivery(ctx, 3, 3, "c", "")
+
+		got, err := repo.GetDeliveryByTrackingID(ctx, "TRACK2")
+		if err != nil || got.ID != second.ID {
+			t.Fatalf("GetDeliveryByTrackingID = %+v, %v; want delivery %d", got, err, second.ID)
+		}
+		if _, err := repo.GetDeliveryByTrackingID(ctx, "MISSING"); err == nil {
+			t.Fatal("expected error for unknown tracking ID")
+		}
+		if _, err := repo.GetDeliveryByTrackingID(ctx, ""); err == nil {
+			t.Fatal("empty tracking ID matched a delivery")
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repo := newRepo(t)
+
+		delivery, _ := repo.CreateDelivery(ctx, 1, 1, "addr", "TRACK1")
+		if delivery.Version != 1 {
+			t.Fatalf("new delivery version %d, want 1", delivery.Version)
+		}
+
+		updated, err := repo.UpdateDeliveryStatus(ctx, delivery.ID, "shipped", 1)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("conditional update = %+v, %v; want version 2", updated, err)
+		}
+
+		// Устаревшая версия не перезаписывает чужое изменение
+		if _, err := repo.UpdateDeliveryStatus(ctx, delivery.ID, "delivered", 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale update error %v, want ErrVersionMismatch", err)
+		}
+		if got, _ := repo.GetDeliveryByID(ctx, delivery.ID); got.Status != "shipped" || got.Version != 2 {
+			t.Fatalf("delivery changed by stale write: %+v", got)
+		}
+	})
+
+	t.Run("ConcurrentConditionalUpdates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		delivery, _ := repo.CreateDelivery(ctx, 1, 1, "addr", "TRACK1")
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.UpdateDeliveryStatus(ctx, delivery.ID, "shipped", delivery.Version)
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				} else if !errors.Is(err, ErrVersionMismatch) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 1 {
+			t.Fatalf("%d updates of the same version succeeded, want 1", succeeded)
+		}
+	})
+
+	t.Run("DeleteByUserID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateDelivery(ctx, 1, 1, "a", "T1")
+		repo.CreateDelivery(ctx, 1, 2, "b", "T2")
+		kept, _ := repo.CreateDelivery(ctx, 2, 3, "c", "T3")
+
+		if err := repo.DeleteDeliveriesByUserID(ctx, 1); err != nil {
+			t.Fatalf("DeleteDeliveriesByUserID: %v", err)
+		}
+		if deliveries, _ := repo.GetDeliveriesByUserID(ctx, 1); len(deliveries) != 0 {
+			t.Fatalf("%d deliveries left for deleted user", len(deliveries))
+		}
+		if _, err := repo.GetDeliveryByID(ctx, kept.ID); err != nil {
+			t.Fatalf("other user's delivery removed: %v", err)
+		}
+		if err := repo.DeleteDeliveriesByUserID(ctx, 1); err != nil {
+			t.Fatalf("DeleteDeliveriesByUserID without deliveries: %v", err)
+		}
+	})
+}
@@ -1,18 +1,27 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
+	"platform/pagination"
+	"platform/storage"
+
 	"delivery-service/internal/models"
-	"delivery-service/internal/utils"
 )
 
-// JSONDeliveryRepository - репозиторий с JSON-хранилищем
+// JSONDeliveryRepository - репозиторий с JSON-хранилищем.
+//
+// Блокировки: mu защищает карту и nextID (чтение под RLock, изменения под
+// Lock), persistMu упорядочивает запись в журнал. Изменение захватывает
+// persistMu до того, как отпустить mu, поэтому записи попадают в журнал в
+// том же порядке, что и в карту, а читатели не ждут fsync. Наружу отдаются
+// только копии доставок.
 type JSONDeliveryRepository struct {
 	mu         sync.RWMutex
-	storage    *utils.FileStorage
+	persistMu  sync.Mutex
+	storage    *storage.FileStorage
 	deliveries map[int64]*models.Delivery
 	nextID     int64
 	filePath   string
@@ -21,7 +30,7 @@ type JSONDeliveryRepository struct {
 // NewJSONDeliveryRepository создаёт новый репозиторий
 func NewJSONDeliveryRepository(filePath string) (*JSONDeliveryRepository, error) {
 	repo := &JSONDeliveryRepository{
-		storage:    utils.NewFileStorage(filePath),
+		storage:    storage.NewFileStorage(filePath),
 		deliveries: make(map[int64]*models.Delivery),
 		nextID:     1,
 		filePath:   filePath,
@@ -40,18 +49,26 @@ func NewJSONDeliveryRepository(filePath string) (*JSONDeliveryRepository, error)
 
 // LoadFromFile загружает доставки из JSON
 func (r *JSONDeliveryRepository) LoadFromFile() error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+	if r.storage == nil {
+		return nil
+	}
 
 	var deliveries []*models.Delivery
 	if err := r.storage.LoadJSON(&deliveries); err != nil {
 		return err
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.deliveries = make(map[int64]*models.Delivery)
 	r.nextID = 1
 
 	for _, delivery := range deliveries {
+		// Записи, сохранённые до появления версий, начинают с первой
+		if delivery.Version == 0 {
+			delivery.Version = 1
+		}
 		r.deliveries[delivery.ID] = delivery
 		if delivery.ID >= r.nextID {
 			r.nextID = delivery.ID + 1
@@ -61,112 +78,257 @@ func (r *JSONDeliveryRepository) LoadFromFile() error {
 	return nil
 }
 
-// SaveToFile сохраняет доставки в JSON
+// SaveToFile сохраняет полный снимок доставок в JSON
 func (r *JSONDeliveryRepository) SaveToFile() error {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+	if r.storage == nil {
+		return nil
+	}
 
+	r.mu.RLock()
 	deliveries := make([]*models.Delivery, 0, len(r.deliveries))
 	for _, delivery := range r.deliveries {
-		deliveries = append(deliveries, delivery)
+		deliveries = append(deliveries, delivery.Clone())
 	}
 
+	// Держим очередь журнала до конца записи снимка: иначе более позднее
+	// изменение попадёт в журнал и будет стёрто при его очистке
+	r.persistMu.Lock()
+	r.mu.RUnlock()
+	defer r.persistMu.Unlock()
+
 	return r.storage.SaveJSON(deliveries)
 }
 
-// CreateDelivery создаёт новую доставку (с проверкой пользователя)
-func (r *JSONDeliveryRepository) CreateDelivery(userID, orderID int64, address, trackingID string) (*models.Delivery, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+// Close дожидается записи в журнал и сворачивает его в снимок; изменения
+// после этого не сохраняются (storage.ErrClosed)
+func (r *JSONDeliveryRepository) Close() error {
+	if r.storage == nil {
+		return nil
+	}
+
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	return r.storage.Close()
+}
+
+// unlockAndPersist отпускает mu и дописывает updated в журнал. Если запись
+// не удалась, в карту возвращается previous (nil - доставки не было), чтобы
+// память не расходилась с журналом.
+func (r *JSONDeliveryRepository) unlockAndPersist(previous, updated *models.Delivery) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
+
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	err := r.storage.Put(updated.ID, updated)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	// mu берётся после persistMu: изменения захватывают их в обратном порядке.
+	// Запись, которую успели изменить снова, не откатывается.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.deliveries[updated.ID]; ok && current.Version == updated.Version {
+		if previous == nil {
+			delete(r.deliveries, updated.ID)
+		} else {
+			r.deliveries[updated.ID] = previous
+		}
+	}
+	return err
+}
+
+// unlockAndPersistDelete отпускает mu и дописывает удаление в журнал; если
+// запись не удалась, удалённые доставки возвращаются в карту
+func (r *JSONDeliveryRepository) unlockAndPersistDelete(deleted ...*models.Delivery) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
 
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	ids := make([]int64, len(deleted))
+	for i, delivery := range deleted {
+		ids[i] = delivery.ID
+	}
+	err := r.storage.Delete(ids...)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, delivery := range deleted {
+		r.deliveries[delivery.ID] = delivery
+	}
+	return err
+}
+
+// CreateDelivery создаёт новую доставку (с проверкой пользователя)
+func (r *JSONDeliveryRepository) CreateDelivery(ctx context.Context, userID, orderID int64, address, trackingID string) (*models.Delivery, error) {
+	now := time.Now()
 	delivery := &models.Delivery{
-		ID:         r.nextID,
 		UserID:     userID,
 		OrderID:    orderID,
 		Address:    address,
 		Status:     "pending",
 		TrackingID: trackingID,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		Version:    1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 
+	r.mu.Lock()
+	if r.trackingIDTaken(trackingID) {
+		r.mu.Unlock()
+		return nil, ErrTrackingIDTaken
+	}
+	delivery.ID = r.nextID
 	r.deliveries[delivery.ID] = delivery
 	r.nextID++
+	created := delivery.Clone()
 
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(nil, created); err != nil {
 		return nil, err
 	}
 
-	return delivery, nil
+	return created, nil
+}
+
+// trackingIDTaken проверяет, выдан ли номер. Вызывается под mu.
+func (r *JSONDeliveryRepository) trackingIDTaken(trackingID string) bool {
+	if trackingID == "" {
+		return false
+	}
+	for _, delivery := range r.deliveries {
+		if delivery.TrackingID == trackingID {
+			return true
+		}
+	}
+	return false
 }
 
 // GetDeliveryByID получает доставку по ID
-func (r *JSONDeliveryRepository) GetDeliveryByID(id int64) (*models.Delivery, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONDeliveryRepository) GetDeliveryByID(ctx context.Context, id int64) (*models.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	delivery, exists := r.deliveries[id]
 	if !exists {
-		return nil, fmt.Errorf("delivery not found")
+		return nil, ErrDeliveryNotFound
 	}
 
-	return delivery, nil
+	return delivery.Clone(), nil
+}
+
+// ListDeliveries возвращает страницу доставок
+func (r *JSONDeliveryRepository) ListDeliveries(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Delivery], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := make([]*models.Delivery, 0, len(r.deliveries))
+	for _, delivery := range r.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+
+	page := pagination.Paginate(deliveries, q, deliveryListKey)
+	for i, delivery := range page.Items {
+		page.Items[i] = delivery.Clone()
+	}
+	return page, nil
 }
 
 // GetDeliveriesByUserID получает все доставки пользователя
-func (r *JSONDeliveryRepository) GetDeliveriesByUserID(userID int64) ([]*models.Delivery, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONDeliveryRepository) GetDeliveriesByUserID(ctx context.Context, userID int64) ([]*models.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	var userDeliveries []*models.Delivery
 	for _, delivery := range r.deliveries {
 		if delivery.UserID == userID {
-			userDeliveries = append(userDeliveries, delivery)
+			userDeliveries = append(userDeliveries, delivery.Clone())
 		}
 	}
 
 	return userDeliveries, nil
 }
 
+// GetDeliveryByTrackingID ищет доставку по трек-номеру
+func (r *JSONDeliveryRepository) GetDeliveryByTrackingID(ctx context.Context, trackingID string) (*models.Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// В данных, записанных до проверки уникальности, номера могут повторяться: берём самую раннюю доставку
+	var found *models.Delivery
+	for _, delivery := range r.deliveries {
+		if trackingID != "" && delivery.TrackingID == trackingID && (found == nil || delivery.ID < found.ID) {
+			found = delivery
+		}
+	}
+	if found == nil {
+		return nil, ErrDeliveryNotFound
+	}
+
+	return found.Clone(), nil
+}
+
 // UpdateDeliveryStatus обновляет статус доставки
-func (r *JSONDeliveryRepository) UpdateDeliveryStatus(id int64, status string) (*models.Delivery, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONDeliveryRepository) UpdateDeliveryStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*models.Delivery, error) {
+	return r.AddTrackingEvent(ctx, id, models.TrackingEvent{Status: status}, expectedVersion)
+}
+
+// AddTrackingEvent добавляет событие в хронологию доставки
+func (r *JSONDeliveryRepository) AddTrackingEvent(ctx context.Context, id int64, event models.TrackingEvent, expectedVersion int64) (*models.Delivery, error) {
+	r.mu.Lock()
 
 	delivery, exists := r.deliveries[id]
 	if !exists {
-		return nil, fmt.Errorf("delivery not found")
+		r.mu.Unlock()
+		return nil, ErrDeliveryNotFound
 	}
+	if expectedVersion != 0 && delivery.Version != expectedVersion {
+		r.mu.Unlock()
+		return nil, ErrVersionMismatch
+	}
+	previous := delivery.Clone()
 
-	delivery.Status = status
-	delivery.UpdatedAt = time.Now()
+	delivery.AddEvent(event, time.Now())
+	delivery.Version++
+	updated := delivery.Clone()
 
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(previous, updated); err != nil {
 		return nil, err
 	}
 
-	return delivery, nil
+	return updated, nil
 }
 
 // DeleteDeliveriesByUserID удаляет все доставки пользователя
-func (r *JSONDeliveryRepository) DeleteDeliveriesByUserID(userID int64) error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONDeliveryRepository) DeleteDeliveriesByUserID(ctx context.Context, userID int64) error {
+	r.mu.Lock()
 
-	deliveriesToDelete := []int64{}
-	for id, delivery := range r.deliveries {
+	deliveriesToDelete := []*models.Delivery{}
+	for _, delivery := range r.deliveries {
 		if delivery.UserID == userID {
-			deliveriesToDelete = append(deliveriesToDelete, id)
+			deliveriesToDelete = append(deliveriesToDelete, delivery)
 		}
 	}
 
-	for _, id := range deliveriesToDelete {
-		delete(r.deliveries, id)
+	for _, delivery := range deliveriesToDelete {
+		delete(r.deliveries, delivery.ID)
 	}
 
-	if len(deliveriesToDelete) > 0 {
-		return r.SaveToFile()
+	if len(deliveriesToDelete) == 0 {
+		r.mu.Unlock()
+		return nil
 	}
 
-	return nil
+	return r.unlockAndPersistDelete(deliveriesToDelete...)
 }
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"platform/pagination"
+
+	"delivery-service/internal/models"
+)
+
+func deliveryListKey(delivery *models.Delivery) pagination.ListKey {
+	return pagination.ListKey{
+		ID:        delivery.ID,
+		UserID:    delivery.UserID,
+		Status:    delivery.Status,
+		CreatedAt: delivery.CreatedAt,
+		UpdatedAt: delivery.UpdatedAt,
+	}
+}
@@ -0,0 +1,18 @@
+package repository
+
+import "delivery-service/internal/models"
+
+// MemoryDeliveryRepository - репозиторий без записи на диск, для тестов и локального запуска
+type MemoryDeliveryRepository struct {
+	JSONDeliveryRepository
+}
+
+// NewMemoryDeliveryRepository создаёт пустой репозиторий в памяти
+func NewMemoryDeliveryRepository() *MemoryDeliveryRepository {
+	return &MemoryDeliveryRepository{
+		JSONDeliveryRepository: JSONDeliveryRepository{
+			deliveries: make(map[int64]*models.Delivery),
+			nextID:     1,
+		},
+	}
+}
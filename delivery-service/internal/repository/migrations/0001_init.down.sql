@@ -0,0 +1,4 @@
+DROP TABLE json_imports;
+DROP INDEX idx_deliveries_tracking_id;
+DROP INDEX idx_deliveries_user_id;
+DROP TABLE deliveries;
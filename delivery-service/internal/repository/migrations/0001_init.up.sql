@@ -0,0 +1,20 @@
+CREATE TABLE deliveries (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     INTEGER NOT NULL,
+    order_id    INTEGER NOT NULL,
+    address     TEXT    NOT NULL,
+    status      TEXT    NOT NULL,
+    tracking_id TEXT    NOT NULL DEFAULT '',
+    created_at  TEXT    NOT NULL,
+    updated_at  TEXT    NOT NULL
+);
+
+CREATE INDEX idx_deliveries_user_id ON deliveries (user_id);
+CREATE INDEX idx_deliveries_tracking_id ON deliveries (tracking_id);
+
+-- Какие JSON-файлы уже перенесены импортёром
+CREATE TABLE json_imports (
+    source      TEXT PRIMARY KEY,
+    records     INTEGER NOT NULL,
+    imported_at TEXT    NOT NULL
+);
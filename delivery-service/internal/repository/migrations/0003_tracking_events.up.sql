@@ -0,0 +1,2 @@
+-- Хронология доставки: JSON-массив {status, location, note, at}
+ALTER TABLE deliveries ADD COLUMN events TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+DROP INDEX idx_deliveries_tracking_id;
+CREATE INDEX idx_deliveries_tracking_id ON deliveries (tracking_id);
@@ -0,0 +1,9 @@
+-- Номера, выданные до проверки на повтор, могли совпасть: повторам, кроме
+-- самого раннего, дописывается -<id>, иначе уникальный индекс не создастся
+UPDATE deliveries SET tracking_id = tracking_id || '-' || id
+WHERE tracking_id <> ''
+  AND id NOT IN (SELECT MIN(id) FROM deliveries WHERE tracking_id <> '' GROUP BY tracking_id);
+
+-- Выданный номер не повторяется; пустой (номер ещё не выдан) - у многих доставок
+DROP INDEX idx_deliveries_tracking_id;
+CREATE UNIQUE INDEX idx_deliveries_tracking_id ON deliveries (tracking_id) WHERE tracking_id <> '';
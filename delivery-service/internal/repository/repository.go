@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+
+	"platform/pagination"
+	"platform/problem"
+	"platform/storage"
+
+	"delivery-service/internal/models"
+)
+
+var (
+	// ErrVersionMismatch - запись изменилась с тех пор, как клиент её прочитал
+	ErrVersionMismatch = storage.ErrVersionMismatch
+	// ErrTrackingIDTaken - трек-номер уже выдан другой доставке
+	ErrTrackingIDTaken = problem.New(problem.Conflict, "tracking_id_taken", "tracking ID already exists")
+	// ErrDeliveryNotFound - доставки с таким ID или трек-номером нет
+	ErrDeliveryNotFound = problem.New(problem.NotFound, "delivery_not_found", "delivery not found")
+)
+
+// DeliveryRepository - хранилище доставок, от которого зависят обработчики.
+// Реализации: JSONDeliveryRepository (файл), MemoryDeliveryRepository (память),
+// SQLiteDeliveryRepository (встроенная база).
+type DeliveryRepository interface {
+	// Непустой trackingID уникален во всём хранилище, повтор - ErrTrackingIDTaken
+	CreateDelivery(ctx context.Context, userID, orderID int64, address, trackingID string) (*models.Delivery, error)
+	GetDeliveryByID(ctx context.Context, id int64) (*models.Delivery, error)
+	GetDeliveriesByUserID(ctx context.Context, userID int64) ([]*models.Delivery, error)
+	// ListDeliveries возвращает страницу доставок по статусу, пользователю и датам
+	ListDeliveries(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Delivery], error)
+	GetDeliveryByTrackingID(ctx context.Context, trackingID string) (*models.Delivery, error)
+	// expectedVersion != 0 - изменить, только если версия доставки совпадает,
+	// иначе ErrVersionMismatch. Смена статуса тоже попадает в хронологию событий.
+	UpdateDeliveryStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*models.Delivery, error)
+	AddTrackingEvent(ctx context.Context, id int64, event models.TrackingEvent, expectedVersion int64) (*models.Delivery, error)
+	DeleteDeliveriesByUserID(ctx context.Context, userID int64) error
+	// Close дописывает незавершённые изменения и освобождает хранилище
+	Close() error
+}
+
+var (
+	_ DeliveryRepository = (*JSONDeliveryRepository)(nil)
+	_ DeliveryRepository = (*MemoryDeliveryRepository)(nil)
+	_ DeliveryRepository = (*SQLiteDeliveryRepository)(nil)
+)
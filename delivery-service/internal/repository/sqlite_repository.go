@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"platform/pagination"
+	"platform/storage"
+
+	"delivery-service/internal/models"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations - версионированные миграции схемы SQLite
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrationFiles, "migrations")
+	return sub
+}
+
+// SQLiteDeliveryRepository - репозиторий доставок во встроенной базе SQLite
+type SQLiteDeliveryRepository struct {
+	db *sql.DB
+}
+
+const deliveryColumns = `id, user_id, order_id, address, status, tracking_id, version, events, created_at, updated_at`
+
+// NewSQLiteDeliveryRepository открывает базу и применяет недостающие миграции
+func NewSQLiteDeliveryRepository(dsn string) (*SQLiteDeliveryRepository, error) {
+	db, err := storage.OpenSQLite(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.Migrate(context.Background(), db, Migrations()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteDeliveryRepository{db: db}, nil
+}
+
+// Close закрывает соединение с базой
+func (r *SQLiteDeliveryRepository) Close() error {
+	return r.db.Close()
+}
+
+// CreateDelivery создаёт новую доставку
+func (r *SQLiteDeliveryRepository) CreateDelivery(ctx context.Context, userID, orderID int64, address, trackingID string) (*models.Delivery, error) {
+	now := time.Now()
+	delivery := &models.Delivery{
+		UserID:     userID,
+		OrderID:    orderID,
+		Address:    address,
+		Status:     "pending",
+		TrackingID: trackingID,
+		Version:    1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	// Повтор выданного номера отклоняет уникальный индекс idx_deliveries_tracking_id
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO deliveries (user_id, order_id, address, status, tracking_id, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.UserID, delivery.OrderID, delivery.Address, delivery.Status, delivery.TrackingID, delivery.Version,
+		storage.FormatTime(delivery.CreatedAt), storage.FormatTime(delivery.UpdatedAt))
+	if storage.IsUniqueViolation(err) {
+		return nil, ErrTrackingIDTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// GetDeliveryByID получает доставку по ID
+func (r *SQLiteDeliveryRepository) GetDeliveryByID(ctx context.Context, id int64) (*models.Delivery, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM deliveries WHERE id = ?`, id)
+
+	delivery, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// ListDeliveries возвращает страницу доставок
+func (r *SQLiteDeliveryRepository) ListDeliveries(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Delivery], error) {
+	filter, filterArgs, page, pageArgs := storage.ListSQL(q)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM deliveries`+filter, filterArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM deliveries`+page, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(deliveries, q, total, deliveryListKey), nil
+}
+
+// GetDeliveriesByUserID получает все доставки пользователя
+func (r *SQLiteDeliveryRepository) GetDeliveriesByUserID(ctx context.Context, userID int64) ([]*models.Delivery, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM deliveries WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetDeliveryByTrackingID ищет доставку по трек-номеру
+func (r *SQLiteDeliveryRepository) GetDeliveryByTrackingID(ctx context.Context, trackingID string) (*models.Delivery, error) {
+	if trackingID == "" {
+		return nil, ErrDeliveryNotFound
+	}
+
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+deliveryColumns+` FROM deliveries WHERE tracking_id = ? ORDER BY id LIMIT 1`, trackingID)
+
+	delivery, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// UpdateDeliveryStatus обновляет статус доставки
+func (r *SQLiteDeliveryRepository) UpdateDeliveryStatus(ctx context.Context, id int64, status string, expectedVersion int64) (*models.Delivery, error) {
+	return r.AddTrackingEvent(ctx, id, models.TrackingEvent{Status: status}, expectedVersion)
+}
+
+// AddTrackingEvent добавляет событие в хронологию доставки
+func (r *SQLiteDeliveryRepository) AddTrackingEvent(ctx context.Context, id int64, event models.TrackingEvent, expectedVersion int64) (*models.Delivery, error) {
+	err := storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		delivery, err := scanDelivery(tx.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM deliveries WHERE id = ?`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeliveryNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && delivery.Version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		delivery.AddEvent(event, time.Now())
+		eventsJSON, err := json.Marshal(delivery.Events)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE deliveries SET status = ?, events = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+			delivery.Status, string(eventsJSON), storage.FormatTime(delivery.UpdatedAt), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetDeliveryByID(ctx, id)
+}
+
+// DeleteDeliveriesByUserID удаляет все доставки пользователя
+func (r *SQLiteDeliveryRepository) DeleteDeliveriesByUserID(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM deliveries WHERE user_id = ?`, userID)
+	return err
+}
+
+// ImportJSON один раз переносит доставки из JSON-хранилища в базу.
+// Повторный вызов для того же файла ничего не делает и возвращает 0.
+func (r *SQLiteDeliveryRepository) ImportJSON(ctx context.Context, filePath string) (int, error) {
+	var imported bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM json_imports WHERE source = ?)`, filePath).Scan(&imported)
+	if err != nil || imported {
+		return 0, err
+	}
+
+	var deliveries []*models.Delivery
+	if err := storage.NewFileStorage(filePath).LoadJSON(&deliveries); err != nil {
+		return 0, err
+	}
+
+	err = storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		issued := make(map[string]bool)
+		for _, delivery := range deliveries {
+			// Повторы номеров из старых данных переименовываются, как в миграции 0004
+			if delivery.TrackingID != "" {
+				if issued[delivery.TrackingID] {
+					delivery.TrackingID = fmt.Sprintf("%s-%d", delivery.TrackingID, delivery.ID)
+				}
+				issued[delivery.TrackingID] = true
+			}
+
+			eventsJSON, err := json.Marshal(delivery.Events)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO deliveries (`+deliveryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				delivery.ID, delivery.UserID, delivery.OrderID, delivery.Address, delivery.Status, delivery.TrackingID,
+				max(delivery.Version, 1), string(eventsJSON),
+				storage.FormatTime(delivery.CreatedAt), storage.FormatTime(delivery.UpdatedAt))
+			if err != nil {
+				return fmt.Errorf("import delivery %d: %w", delivery.ID, err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO json_imports (source, records, imported_at) VALUES (?, ?, ?)`,
+			filePath, len(deliveries), storage.FormatTime(time.Now()))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(deliveries), nil
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDelivery(row rowScanner) (*models.Delivery, error) {
+	var delivery models.Delivery
+	var events, createdAt, updatedAt string
+
+	err := row.Scan(&delivery.ID, &delivery.UserID, &delivery.OrderID, &delivery.Address, &delivery.Status,
+		&delivery.TrackingID, &delivery.Version, &events, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(events), &delivery.Events); err != nil {
+		return nil, fmt.Errorf("delivery %d: decode events: %w", delivery.ID, err)
+	}
+
+	if delivery.CreatedAt, err = storage.ParseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if delivery.UpdatedAt, err = storage.ParseTime(updatedAt); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"platform/storage"
+)
+
+func TestSQLiteMigrationsUpDown(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "deliveries.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := storage.LoadMigrations(Migrations())
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != latest {
+		t.Fatalf("schema version %d, want %d", v, latest)
+	}
+
+	// Повторный запуск ничего не делает
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+
+	if err := storage.MigrateDown(ctx, db, Migrations(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != 0 {
+		t.Fatalf("schema version %d after rollback, want 0", v)
+	}
+	var tables int
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'deliveries'`).Scan(&tables)
+	if tables != 0 {
+		t.Fatal("deliveries table survived rollback")
+	}
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate after rollback: %v", err)
+	}
+}
+
+func TestSQLiteImportJSON(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "deliveries.json")
+
+	source, err := NewJSONDeliveryRepository(jsonPath)
+	if err != nil {
+		t.Fatalf("NewJSONDeliveryRepository: %v", err)
+	}
+	first, _ := source.CreateDelivery(ctx, 1, 10, "Main St 1", "TRACK1")
+	source.CreateDelivery(ctx, 2, 11, "Main St 2", "TRACK2")
+	source.UpdateDeliveryStatus(ctx, first.ID, "shipped", 0)
+
+	repo, err := NewSQLiteDeliveryRepository(filepath.Join(dir, "deliveries.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteDeliveryRepository: %v", err)
+	}
+	defer repo.Close()
+
+	n, err := repo.ImportJSON(ctx, jsonPath)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportJSON = %d, %v; want 2", n, err)
+	}
+
+	got, err := repo.GetDeliveryByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetDeliveryByID: %v", err)
+	}
+	if got.Status != "shipped" || got.Version != 2 || len(got.Events) != 1 || got.TrackingID != "TRACK1" || got.Address != "Main St 1" || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("imported delivery %+v differs from %+v", got, first)
+	}
+
+	// Импорт однократный
+	if n, err := repo.ImportJSON(ctx, jsonPath); err != nil || n != 0 {
+		t.Fatalf("second ImportJSON = %d, %v; want 0", n, err)
+	}
+
+	// Новые записи не конфликтуют с перенесёнными ID
+	created, err := repo.CreateDelivery(ctx, 3, 12, "Main St 3", "TRACK3")
+	if err != nil || created.ID <= 2 {
+		t.Fatalf("CreateDelivery after import = %+v, %v", created, err)
+	}
+}
+
+func TestSQLiteUniqueTrackingIDMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deliveries.db")
+
+	db, err := storage.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if err := storage.MigrateDown(ctx, db, Migrations(), 3); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	// До уникального индекса номера могли повториться
+	now := storage.FormatTime(time.Now())
+	for _, trackingID := range []string{"TRACK1", "TRACK1", "", ""} {
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO deliveries (user_id, order_id, address, status, tracking_id, created_at, updated_at) VALUES (1, 1, 'a', 'pending', ?, ?, ?)`,
+			trackingID, now, now)
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	db.Close()
+
+	repo, err := NewSQLiteDeliveryRepository(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteDeliveryRepository: %v", err)
+	}
+	defer repo.Close()
+
+	first, err := repo.GetDeliveryByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetDeliveryByID: %v", err)
+	}
+	second, _ := repo.GetDeliveryByID(ctx, 2)
+	if first.TrackingID != "TRACK1" || second.TrackingID != "TRACK1-2" {
+		t.Fatalf("tracking IDs %q and %q, want TRACK1 and TRACK1-2", first.TrackingID, second.TrackingID)
+	}
+
+	if _, err := repo.CreateDelivery(ctx, 2, 2, "b", "TRACK1-2"); !errors.Is(err, ErrTrackingIDTaken) {
+		t.Fatalf("duplicate tracking ID: error %v, want ErrTrackingIDTaken", err)
+	}
+	if _, err := repo.CreateDelivery(ctx, 2, 2, "b", ""); err != nil {
+		t.Fatalf("another delivery without tracking ID: %v", err)
+	}
+}
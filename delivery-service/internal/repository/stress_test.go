@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"platform/storage/storagetest"
+)
+
+// Стресс-тесты рассчитаны на запуск с детектором гонок: go test -race ./...
+
+const stressDeliveries = 50
+
+func stressDeliveryBackends() map[string]func(t *testing.T) DeliveryRepository {
+	return map[string]func(t *testing.T) DeliveryRepository{
+		"json": func(t *testing.T) DeliveryRepository {
+			repo, err := NewJSONDeliveryRepository(filepath.Join(t.TempDir(), "deliveries.json"))
+			if err != nil {
+				t.Fatalf("NewJSONDeliveryRepository: %v", err)
+			}
+			return repo
+		},
+		"memory": func(t *testing.T) DeliveryRepository {
+			return NewMemoryDeliveryRepository()
+		},
+		"sqlite": func(t *testing.T) DeliveryRepository {
+			repo, err := NewSQLiteDeliveryRepository(filepath.Join(t.TempDir(), "deliveries.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteDeliveryRepository: %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+}
+
+func TestDeliveryRepositoryConcurrentAccess(t *testing.T) {
+	for name, newRepo := range stressDeliveryBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			var ids storagetest.IDs
+
+			storagetest.Run(t, stressDeliveries,
+				// Писатель: создаёт и обновляет доставки своего пользователя,
+				// заодно создаёт и удаляет доставки временного пользователя
+				func(w, i int) error {
+					userID := int64(w + 1)
+					delivery, err := repo.CreateDelivery(ctx, userID, int64(i), "addr", fmt.Sprintf("TRK-%d-%d", userID, i))
+					if err != nil {
+						return err
+					}
+					if err := ids.Add(delivery.ID); err != nil {
+						return err
+					}
+					if _, err := repo.UpdateDeliveryStatus(ctx, delivery.ID, "in_transit", 0); err != nil {
+						return err
+					}
+
+					if _, err := repo.CreateDelivery(ctx, userID+1000, int64(i), "addr", ""); err != nil {
+						return err
+					}
+					if i%5 == 0 {
+						return repo.DeleteDeliveriesByUserID(ctx, userID+1000)
+					}
+					return nil
+				},
+				// Читатель: параллельно листает доставки и читает отдельные записи
+				func(w, i int) error {
+					userID := int64(w + 1)
+					deliveries, err := repo.GetDeliveriesByUserID(ctx, userID)
+					if err != nil {
+						return err
+					}
+					for _, delivery := range deliveries {
+						if delivery.UserID != userID {
+							return fmt.Errorf("delivery %d of user %d listed for user %d", delivery.ID, delivery.UserID, userID)
+						}
+						repo.GetDeliveryByID(ctx, delivery.ID)
+					}
+					return nil
+				},
+			)
+
+			for w := 0; w < storagetest.Workers; w++ {
+				deliveries, _ := repo.GetDeliveriesByUserID(ctx, int64(w+1))
+				if len(deliveries) != stressDeliveries {
+					t.Fatalf("user %d: %d deliveries, want %d", w+1, len(deliveries), stressDeliveries)
+				}
+				for _, delivery := range deliveries {
+					if delivery.Status != "in_transit" {
+						t.Fatalf("delivery %d: status %q, want in_transit", delivery.ID, delivery.Status)
+					}
+				}
+
+				// После последнего удаления (i=45) остались доставки с i=46..49
+				scratch, _ := repo.GetDeliveriesByUserID(ctx, int64(w+1001))
+				if len(scratch) != 4 {
+					t.Fatalf("scratch user %d: %d deliveries, want 4", w+1001, len(scratch))
+				}
+			}
+		})
+	}
+}
+
+func TestJSONDeliveryRepositoryConcurrentWritesSurviveReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "deliveries.json")
+
+	repo, err := NewJSONDeliveryRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONDeliveryRepository: %v", err)
+	}
+	repo.storage.SetCompactEvery(7) // компактификация посреди параллельной записи
+
+	storagetest.Run(t, stressDeliveries,
+		func(w, i int) error {
+			delivery, err := repo.CreateDelivery(ctx, int64(w+1), int64(i), "addr", "")
+			if err != nil {
+				return err
+			}
+			repo.UpdateDeliveryStatus(ctx, delivery.ID, "delivered", 0)
+			return nil
+		},
+		// Полный снимок параллельно с журналом
+		func(w, i int) error {
+			if w > 0 || i >= 10 {
+				return nil
+			}
+			return repo.SaveToFile()
+		},
+	)
+
+	reopened, err := NewJSONDeliveryRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	for w := 0; w < storagetest.Workers; w++ {
+		deliveries, _ := reopened.GetDeliveriesByUserID(ctx, int64(w+1))
+		if len(deliveries) != stressDeliveries {
+			t.Fatalf("user %d: %d deliveries after reload, want %d", w+1, len(deliveries), stressDeliveries)
+		}
+		for _, delivery := range deliveries {
+			if delivery.Status != "delivered" {
+				t.Fatalf("delivery %d: status %q after reload", delivery.ID, delivery.Status)
+			}
+		}
+	}
+}
+
+func TestDeliveryRepositoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryDeliveryRepository()
+
+	created, _ := repo.CreateDelivery(ctx, 1, 1, "addr", "TRK-1")
+	created.Status = "hacked"
+	created.Address = "hacked"
+
+	got, _ := repo.GetDeliveryByID(ctx, created.ID)
+	if got.Status != "pending" || got.Address != "addr" {
+		t.Fatalf("stored delivery changed through returned pointer: %+v", got)
+	}
+
+	listed, _ := repo.GetDeliveriesByUserID(ctx, 1)
+	listed[0].Status = "hacked"
+	if got, _ := repo.GetDeliveryByID(ctx, created.ID); got.Status != "pending" {
+		t.Fatalf("stored delivery changed through listed pointer: %+v", got)
+	}
+}
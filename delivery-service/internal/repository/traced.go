@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"platform/pagination"
+	"platform/storage"
+	"platform/tracing"
+
+	"delivery-service/internal/models"
+)
+
+// TracedDeliveryRepository пишет span на каждый вызов хранилища next (storage.Traced)
+type TracedDeliveryRepository struct {
+	storage.Traced[DeliveryRepository]
+}
+
+var _ DeliveryRepository = (*TracedDeliveryRepository)(nil)
+
+// NewTracedDeliveryRepository оборачивает хранилище next трассировкой
+func NewTracedDeliveryRepository(next DeliveryRepository) *TracedDeliveryRepository {
+	return &TracedDeliveryRepository{storage.NewTraced("DeliveryRepository", next)}
+}
+
+func (r *TracedDeliveryRepository) CreateDelivery(ctx context.Context, userID, orderID int64, address, trackingID string) (delivery *models.Delivery, err error) {
+	ctx, span := r.Start(ctx, "CreateDelivery", attribute.Int64("user.id", userID), attribute.Int64("order.id", orderID))
+	defer tracing.End(span, &err)
+	return r.Next.CreateDelivery(ctx, userID, orderID, address, trackingID)
+}
+
+func (r *TracedDeliveryRepository) GetDeliveryByID(ctx context.Context, id int64) (delivery *models.Delivery, err error) {
+	ctx, span := r.Start(ctx, "GetDeliveryByID", attribute.Int64("delivery.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.GetDeliveryByID(ctx, id)
+}
+
+func (r *TracedDeliveryRepository) GetDeliveriesByUserID(ctx context.Context, userID int64) (deliveries []*models.Delivery, err error) {
+	ctx, span := r.Start(ctx, "GetDeliveriesByUserID", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.GetDeliveriesByUserID(ctx, userID)
+}
+
+func (r *TracedDeliveryRepository) ListDeliveries(ctx context.Context, q pagination.ListQuery) (page *pagination.Page[*models.Delivery], err error) {
+	ctx, span := r.Start(ctx, "ListDeliveries")
+	defer tracing.End(span, &err)
+	return r.Next.ListDeliveries(ctx, q)
+}
+
+func (r *TracedDeliveryRepository) GetDeliveryByTrackingID(ctx context.Context, trackingID string) (delivery *models.Delivery, err error) {
+	ctx, span := r.Start(ctx, "GetDeliveryByTrackingID")
+	defer tracing.End(span, &err)
+	return r.Next.GetDeliveryByTrackingID(ctx, trackingID)
+}
+
+func (r *TracedDeliveryRepository) UpdateDeliveryStatus(ctx context.Context, id int64, status string, expectedVersion int64) (delivery *models.Delivery, err error) {
+	ctx, span := r.Start(ctx, "UpdateDeliveryStatus", attribute.Int64("delivery.id", id), attribute.String("delivery.status", status))
+	defer tracing.End(span, &err)
+	return r.Next.UpdateDeliveryStatus(ctx, id, status, expectedVersion)
+}
+
+func (r *TracedDeliveryRepository) AddTrackingEvent(ctx context.Context, id int64, event models.TrackingEvent, expectedVersion int64) (delivery *models.Delivery, err error) {
+	ctx, span := r.Start(ctx, "AddTrackingEvent", attribute.Int64("delivery.id", id), attribute.String("delivery.status", event.Status))
+	defer tracing.End(span, &err)
+	return r.Next.AddTrackingEvent(ctx, id, event, expectedVersion)
+}
+
+func (r *TracedDeliveryRepository) DeleteDeliveriesByUserID(ctx context.Context, userID int64) (err error) {
+	ctx, span := r.Start(ctx, "DeleteDeliveriesByUserID", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.DeleteDeliveriesByUserID(ctx, userID)
+}
@@ -0,0 +1,168 @@
+// Package tracking выдаёт трек-номера доставок: префикс, случайные цифры и
+// контрольная цифра по алгоритму Луна, которая отсекает опечатки при поиске.
+package tracking
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"platform/problem"
+)
+
+var (
+	// ErrInvalidTrackingID - номер в нашем формате, но с ошибкой (длина, символы, контрольная цифра)
+	ErrInvalidTrackingID = problem.New(problem.Invalid, "invalid_tracking_id", "invalid tracking ID")
+	// ErrForeignTrackingID - номер не нашего формата: выдан перевозчиком или сохранён до генерации
+	ErrForeignTrackingID = problem.New(problem.Invalid, "foreign_tracking_id", "tracking ID is not in the generated format")
+)
+
+// Format - формат генерируемых номеров: Prefix, затем Digits случайных цифр и контрольная
+type Format struct {
+	Prefix string
+	Digits int
+}
+
+// DefaultFormat - TRK и 10 цифр, например TRK48213907563
+var DefaultFormat = Format{Prefix: "TRK", Digits: 10}
+
+// Validate проверяет, что по номеру формата однозначно видно, где кончается префикс
+func (f Format) Validate() error {
+	if f.Prefix == "" {
+		return fmt.Errorf("tracking ID prefix is empty")
+	}
+	for _, r := range f.Prefix {
+		if !(r >= 'A' && r <= 'Z' || r == '-') {
+			return fmt.Errorf("tracking ID prefix %q: only A-Z and '-' are allowed", f.Prefix)
+		}
+	}
+	if f.Digits < 6 || f.Digits > 30 {
+		return fmt.Errorf("tracking ID digits %d: want 6..30", f.Digits)
+	}
+	return nil
+}
+
+// Generator выдаёт и проверяет номера одного формата
+type Generator struct {
+	format Format
+}
+
+// NewGenerator создаёт генератор номеров формата format
+func NewGenerator(format Format) (*Generator, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	return &Generator{format: format}, nil
+}
+
+// New выдаёт новый номер. Цифры берутся из crypto/rand, чтобы номера нельзя
+// было перебрать по порядку; уникальность в хранилище проверяет репозиторий.
+func (g *Generator) New() (string, error) {
+	digits := make([]byte, g.format.Digits, g.format.Digits+1)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+
+	return g.format.Prefix + string(append(digits, checkDigit(digits))), nil
+}
+
+// Check проверяет номер. Номер с нашим префиксом обязан совпадать с форматом
+// и сходиться по контрольной цифре, иначе ErrInvalidTrackingID. Номер без
+// префикса - ErrForeignTrackingID.
+func (g *Generator) Check(id string) error {
+	digits, ok := strings.CutPrefix(id, g.format.Prefix)
+	if !ok {
+		return ErrForeignTrackingID
+	}
+	if len(digits) != g.format.Digits+1 {
+		return fmt.Errorf("%w: want %d digits after %q", ErrInvalidTrackingID, g.format.Digits+1, g.format.Prefix)
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return fmt.Errorf("%w: unexpected %q", ErrInvalidTrackingID, digits[i])
+		}
+	}
+	if checkDigit([]byte(digits[:len(digits)-1])) != digits[len(digits)-1] {
+		return fmt.Errorf("%w: check digit mismatch", ErrInvalidTrackingID)
+	}
+	return nil
+}
+
+// CheckForeign проверяет номер, присланный перевозчиком: он не должен занимать
+// наш префикс и должен быть пригоден для URL
+func (g *Generator) CheckForeign(id string) error {
+	if id == "" || len(id) > 64 {
+		return problem.New(problem.Invalid, "invalid_tracking_id", "tracking ID must be 1..64 characters")
+	}
+	if strings.HasPrefix(id, g.format.Prefix) {
+		return problem.Errorf(problem.Invalid, "reserved_tracking_id", "tracking ID prefix %q is reserved for generated IDs", g.format.Prefix)
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return problem.New(problem.Invalid, "invalid_tracking_id", "tracking ID may contain only ASCII letters, digits, '-' and '_'")
+		}
+	}
+	return nil
+}
+
+// checkDigit считает контрольную цифру Луна для строки цифр
+func checkDigit(digits []byte) byte {
+	sum := 0
+	double := true // справа налево, начиная с цифры перед контрольной
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// Carriers - интеграции перевозчиков, которым разрешено присылать свои номера.
+// Ключ - токен из заголовка X-Carrier-Token, значение - имя перевозчика.
+type Carriers map[string]string
+
+// ParseCarriers разбирает список вида "dhl:token1,ups:token2"
+func ParseCarriers(s string) (Carriers, error) {
+	carriers := make(Carriers)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(entry, ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("carrier %q: want name:token", entry)
+		}
+		if _, dup := carriers[token]; dup {
+			return nil, fmt.Errorf("carrier %q: token already used", name)
+		}
+		carriers[token] = name
+	}
+	return carriers, nil
+}
+
+// Authenticate возвращает перевозчика по токену
+func (c Carriers) Authenticate(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for known, name := range c {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
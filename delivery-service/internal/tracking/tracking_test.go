@@ -0,0 +1,98 @@
+package tracking
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedIDsPassCheck(t *testing.T) {
+	gen, err := NewGenerator(DefaultFormat)
+	if err != nil {
+		t.Fatalf("NewGenerator: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, err := gen.New()
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if !strings.HasPrefix(id, "TRK") || len(id) != len("TRK")+11 {
+			t.Fatalf("id %q does not match format", id)
+		}
+		if err := gen.Check(id); err != nil {
+			t.Fatalf("Check(%q): %v", id, err)
+		}
+		seen[id] = true
+	}
+	if len(seen) < 990 {
+		t.Fatalf("only %d distinct ids out of 1000", len(seen))
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	// Контрольный пример алгоритма Луна: 7992739871 -> 3
+	if got := checkDigit([]byte("7992739871")); got != '3' {
+		t.Fatalf("check digit %c, want 3", got)
+	}
+
+	gen, _ := NewGenerator(Format{Prefix: "TRK", Digits: 10})
+	tests := map[string]error{
+		"TRK79927398713": nil,
+		"TRK79927398710": ErrInvalidTrackingID, // неверная контрольная цифра
+		"TRK79927398731": ErrInvalidTrackingID, // переставлены соседние цифры
+		"TRK7992739871":  ErrInvalidTrackingID, // короче формата
+		"TRK7992739871X": ErrInvalidTrackingID,
+		"JD0123456789":   ErrForeignTrackingID,
+		"":               ErrForeignTrackingID,
+	}
+	for id, want := range tests {
+		if err := gen.Check(id); !errors.Is(err, want) {
+			t.Errorf("Check(%q) = %v, want %v", id, err, want)
+		}
+	}
+}
+
+func TestFormatValidate(t *testing.T) {
+	for _, bad := range []Format{{"", 10}, {"TR1", 10}, {"trk", 10}, {"TRK", 3}} {
+		if _, err := NewGenerator(bad); err == nil {
+			t.Errorf("format %+v accepted", bad)
+		}
+	}
+}
+
+func TestCheckForeign(t *testing.T) {
+	gen, _ := NewGenerator(DefaultFormat)
+
+	if err := gen.CheckForeign("JD014600006281"); err != nil {
+		t.Fatalf("carrier id rejected: %v", err)
+	}
+	for _, bad := range []string{"", "TRK123", "has space", "a/b", strings.Repeat("x", 65)} {
+		if err := gen.CheckForeign(bad); err == nil {
+			t.Errorf("CheckForeign(%q) accepted", bad)
+		}
+	}
+}
+
+func TestCarriers(t *testing.T) {
+	carriers, err := ParseCarriers(" dhl:s3cret , ups:other,")
+	if err != nil {
+		t.Fatalf("ParseCarriers: %v", err)
+	}
+	if name, ok := carriers.Authenticate("s3cret"); !ok || name != "dhl" {
+		t.Fatalf("Authenticate = %q, %v", name, ok)
+	}
+	if _, ok := carriers.Authenticate("wrong"); ok {
+		t.Fatal("unknown token accepted")
+	}
+	if _, ok := carriers.Authenticate(""); ok {
+		t.Fatal("empty token accepted")
+	}
+
+	for _, bad := range []string{"dhl", "dhl:", ":token", "a:t,b:t"} {
+		if _, err := ParseCarriers(bad); err == nil {
+			t.Errorf("ParseCarriers(%q) accepted", bad)
+		}
+	}
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
-	"os"
+	"path/filepath"
 
-	"github.com/gorilla/mux"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"platform/config"
+	"platform/health"
+	"platform/httputil"
+	"platform/idempotency"
+	"platform/logging"
+	"platform/server"
+	"platform/storage"
+	"platform/tracing"
 
+	_ "orders-service/docs"
+	"orders-service/internal/checkout"
+	"orders-service/internal/client"
 	"orders-service/internal/handlers"
 	"orders-service/internal/repository"
-	_ "orders-service/docs"
 )
 
 // @title Orders Service API
@@ -19,46 +29,192 @@ import (
 // @host localhost:8082
 // @basePath /api
 
+const (
+	jsonPath        = "./data/orders.json"
+	idempotencyPath = "./data/idempotency_keys.json"
+)
+
 func main() {
-	router := mux.NewRouter()
+	if err := logging.Setup("orders-service"); err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+
+	migrateDown := flag.Int("migrate-down", -1, "откатить схему SQLite до указанной версии и выйти")
+	flag.Parse()
+
+	if *migrateDown >= 0 {
+		if err := rollbackSQLite(*migrateDown); err != nil {
+			log.Fatalf("Failed to roll back migrations: %v", err)
+		}
+		log.Printf("SQLite schema rolled back to version %d", *migrateDown)
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "orders-service")
+	if err != nil {
+		log.Fatalf("Invalid tracing config: %v", err)
+	}
+
+	srv := server.New("orders-service", "8082")
+	// Функции остановки вызываются в обратном порядке: span выгружаются последними
+	srv.OnShutdown(shutdownTracing)
+	// Пробы отвечают уже во время загрузки хранилища; /health/startup - 503 до srv.Run
+	if err := srv.Listen(); err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	backend := config.String("STORAGE_BACKEND", "json")
 
-	// Инициализируем JSON репозиторий
-	repo, err := repository.NewJSONOrderRepository("./data/orders.json")
+	// Инициализируем репозиторий выбранного бэкенда
+	repo, err := newRepository(backend)
 	if err != nil {
 		log.Fatalf("Failed to initialize repository: %v", err)
 	}
+	// Каждый вызов хранилища - span в трассе запроса
+	repo = repository.NewTracedOrderRepository(repo)
+	srv.OnShutdown(func(context.Context) error { return repo.Close() })
+	if dir := storageDir(backend); dir != "" {
+		srv.Health.Add("storage", health.Dir(dir))
+	}
+
+	users, err := httputil.UsersClientFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid users-service client config: %v", err)
+	}
+
+	checkoutConfig, err := newCheckoutConfig()
+	if err != nil {
+		log.Fatalf("Invalid checkout config: %v", err)
+	}
+	// Ответ и остановка ждут выполняющиеся запросы, в том числе оформление, ждущее списания
+	if err := checkoutConfig.CheckTimeouts(srv.Timeouts.Write, srv.Timeouts.Shutdown); err != nil {
+		log.Fatalf("Invalid checkout config: %v (raise HTTP_WRITE_TIMEOUT and SHUTDOWN_TIMEOUT or lower CHECKOUT_PAYMENT_TIMEOUT)", err)
+	}
+	services := client.NewServiceClient(config.String("PAYMENTS_SERVICE_URL", "http://payments-service:8083"),
+		config.String("DELIVERY_SERVICE_URL", "http://delivery-service:8084"))
+	srv.OnShutdown(func(context.Context) error {
+		users.Close()
+		services.Close()
+		return nil
+	})
+	// Соседи проверяются по /health/live: их собственные зависимости не должны
+	// снимать готовность этого сервиса
+	if users.Required() {
+		srv.Health.Add("users-service", users.HealthCheck())
+	} else {
+		srv.Health.AddOptional("users-service", users.HealthCheck())
+	}
+	srv.Health.Add("payments-service", services.PaymentsHealthCheck())
+	srv.Health.Add("delivery-service", services.DeliveryHealthCheck())
+	checkouts := checkout.NewOrchestrator(repo, services, services, checkoutConfig)
+
+	handler := handlers.NewOrderHandler(repo, users, checkouts)
 
-	handler := handlers.NewOrderHandler(repo)
+	keys, err := newIdempotencyStore(backend)
+	if err != nil {
+		log.Fatalf("Failed to initialize idempotency keys: %v", err)
+	}
+	srv.OnShutdown(func(context.Context) error { return keys.Close() })
 
 	// API endpoints
-	api := router.PathPrefix("/api").Subrouter()
+	api := srv.API
+	api.Use(idempotency.Middleware(keys))
 
 	api.HandleFunc("/orders", handler.CreateOrder).Methods("POST")
 	api.HandleFunc("/orders", handler.GetAllOrders).Methods("GET")
 	api.HandleFunc("/orders/{id}", handler.GetOrderByID).Methods("GET")
+	api.HandleFunc("/orders/{id}/history", handler.GetOrderHistory).Methods("GET")
+	api.HandleFunc("/orders/{id}/checkout", handler.Checkout).Methods("POST")
 	api.HandleFunc("/orders/user/{userId}", handler.GetOrdersByUserID).Methods("GET")
 	api.HandleFunc("/orders/{id}", handler.UpdateOrder).Methods("PUT")
 	api.HandleFunc("/orders/{id}", handler.DeleteOrder).Methods("DELETE")
 	api.HandleFunc("/orders/user/{userId}", handler.DeleteOrdersByUserID).Methods("DELETE")
 
-	// Swagger
-	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8082/swagger/doc.json"),
-	))
+	if err := srv.Run(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+// newRepository выбирает бэкенд хранилища: json (по умолчанию), memory или sqlite
+func newRepository(backend string) (repository.OrderRepository, error) {
+	switch backend {
+	case "json":
+		repo, err := repository.NewJSONOrderRepository(jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "memory":
+		return repository.NewMemoryOrderRepository(), nil
+	case "sqlite":
+		repo, err := repository.NewSQLiteOrderRepository(sqlitePath())
+		if err != nil {
+			return nil, err
+		}
 
-	// Health check
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+		// Однократно переносим данные, накопленные в JSON-хранилище
+		imported, err := repo.ImportJSON(context.Background(), jsonPath)
+		if err != nil {
+			repo.Close()
+			return nil, fmt.Errorf("import %s: %w", jsonPath, err)
+		}
+		if imported > 0 {
+			log.Printf("Imported %d orders from %s", imported, jsonPath)
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8082"
+// newCheckoutConfig читает CHECKOUT_PAYMENT_TIMEOUT - сколько ждать списания платежа, например 45s
+func newCheckoutConfig() (checkout.Config, error) {
+	cfg := checkout.DefaultConfig
+	timeout, err := config.Duration("CHECKOUT_PAYMENT_TIMEOUT", cfg.PaymentTimeout)
+	if err != nil {
+		return cfg, err
 	}
+	cfg.PaymentTimeout = timeout
+	return cfg, nil
+}
 
-	log.Printf("Starting orders-service on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// newIdempotencyStore хранит ответы на запросы с Idempotency-Key: в памяти для
+// memory-бэкенда, иначе в idempotencyPath. IDEMPOTENCY_KEY_TTL - сколько хранится ключ, например 48h
+func newIdempotencyStore(backend string) (*idempotency.Store, error) {
+	ttl, err := config.Duration("IDEMPOTENCY_KEY_TTL", idempotency.DefaultTTL)
+	if err != nil {
+		return nil, err
 	}
+
+	if backend == "memory" {
+		return idempotency.NewMemoryStore(ttl), nil
+	}
+	return idempotency.NewStore(idempotencyPath, ttl)
+}
+
+// storageDir - каталог файлов бэкенда, его проверяет /health/ready; у memory файлов нет
+func storageDir(backend string) string {
+	switch backend {
+	case "memory":
+		return ""
+	case "sqlite":
+		return filepath.Dir(sqlitePath())
+	default:
+		return filepath.Dir(jsonPath)
+	}
+}
+
+func sqlitePath() string {
+	return config.String("SQLITE_PATH", "./data/orders.db")
+}
+
+// rollbackSQLite откатывает схему базы до версии target
+func rollbackSQLite(target int) error {
+	db, err := storage.OpenSQLite(sqlitePath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return storage.MigrateDown(context.Background(), db, repository.Migrations(), target)
 }
@@ -0,0 +1,372 @@
+// Package checkout оформляет заказ: создаёт платёж на сумму заказа, ждёт его
+// списания, переводит заказ в processing и создаёт доставку.
+//
+// Если шаг не удался, выполненные шаги компенсируются: платёж отменяется или
+// возвращается, заказ отменяется. Каждый шаг, включая компенсации, пишется в
+// журнал заказа (Order.Checkout).
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"platform/money"
+	"platform/problem"
+
+	"orders-service/internal/client"
+	"orders-service/internal/metrics"
+	"orders-service/internal/models"
+	"orders-service/internal/repository"
+)
+
+// actor - от чьего имени оформление меняет статус заказа
+const actor = "checkout"
+
+// createAttempts - сколько раз создавать платёж, пока исход создания неизвестен
+const createAttempts = 3
+
+var (
+	// ErrNotCheckoutable - оформить можно только заказ в статусе created
+	ErrNotCheckoutable = problem.New(problem.Conflict, "not_checkoutable", "order cannot be checked out")
+	// ErrInProgress - заказ уже оформляется
+	ErrInProgress = problem.New(problem.Conflict, "checkout_in_progress", "checkout already in progress")
+	// ErrPaymentDeclined - платёж не прошёл или не списан вовремя; заказ отменён
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrStepFailed - payments-service или delivery-service отказал; выполненные шаги компенсированы
+	ErrStepFailed = errors.New("checkout step failed")
+)
+
+// Payments - операции payments-service (client.ServiceClient)
+type Payments interface {
+	CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money, key string) (*client.Payment, error)
+	OrderPayments(ctx context.Context, orderID int64) ([]*client.Payment, error)
+	GetPayment(ctx context.Context, paymentID int64) (*client.Payment, error)
+	VoidPayment(ctx context.Context, paymentID int64) (*client.Payment, error)
+	RefundPayment(ctx context.Context, paymentID int64) (*client.Payment, error)
+	FailPayment(ctx context.Context, paymentID int64) (*client.Payment, error)
+}
+
+// Deliveries - операции delivery-service (client.ServiceClient)
+type Deliveries interface {
+	CreateDelivery(ctx context.Context, userID, orderID int64, address string) (*client.Delivery, error)
+}
+
+// Config - сколько ждать списания платежа
+type Config struct {
+	PaymentTimeout time.Duration // после него платёж отменяется, а заказ - тоже
+	PollInterval   time.Duration // как часто спрашивать статус платежа
+}
+
+// DefaultConfig - полминуты на списание, статус раз в полсекунды
+var DefaultConfig = Config{PaymentTimeout: 30 * time.Second, PollInterval: 500 * time.Millisecond}
+
+// StepsAllowance - сколько ответ на оформление и остановка сервиса ждут его
+// сверх PaymentTimeout: на создание платежа и доставки и на компенсации
+const StepsAllowance = 10 * time.Second
+
+// CheckTimeouts проверяет, что HTTP-сервер с таймаутом ответа write (0 - без
+// ограничения) и остановкой за shutdown дождётся оформления. Иначе клиент не
+// получит ответ на оформление, а хранилище закроется посреди оформления, и
+// списанный платёж останется без доставки и без возврата.
+func (c Config) CheckTimeouts(write, shutdown time.Duration) error {
+	need := c.PaymentTimeout + StepsAllowance
+	if write > 0 && write < need {
+		return fmt.Errorf("write timeout %s is shorter than payment timeout %s plus %s for the other checkout steps",
+			write, c.PaymentTimeout, StepsAllowance)
+	}
+	if shutdown < need {
+		return fmt.Errorf("shutdown timeout %s is shorter than payment timeout %s plus %s for the other checkout steps",
+			shutdown, c.PaymentTimeout, StepsAllowance)
+	}
+	return nil
+}
+
+// Orchestrator оформляет заказы и следит, чтобы заказ оформлялся не больше чем один раз одновременно
+type Orchestrator struct {
+	repo       repository.OrderRepository
+	payments   Payments
+	deliveries Deliveries
+	config     Config
+
+	mu     sync.Mutex
+	active map[int64]bool
+}
+
+// NewOrchestrator создаёт оформление заказов
+func NewOrchestrator(repo repository.OrderRepository, payments Payments, deliveries Deliveries, config Config) *Orchestrator {
+	return &Orchestrator{
+		repo:       repo,
+		payments:   payments,
+		deliveries: deliveries,
+		config:     config,
+		active:     make(map[int64]bool),
+	}
+}
+
+// Run оформляет заказ с доставкой по address. Возвращает заказ с журналом
+// оформления; при неудаче также ErrPaymentDeclined или ErrStepFailed.
+// ErrNotCheckoutable, ErrInProgress и repository.ErrVersionMismatch
+// возвращаются до первого шага, заказ тогда не меняется.
+func (o *Orchestrator) Run(ctx context.Context, order *models.Order, address string) (*models.Order, error) {
+	if order.Status != models.OrderStatusCreated {
+		return nil, fmt.Errorf("%w: status is %s", ErrNotCheckoutable, order.Status)
+	}
+	if !o.acquire(order.ID) {
+		return nil, ErrInProgress
+	}
+	defer o.release(order.ID)
+
+	// Отключение клиента не прерывает оформление: иначе списанный платёж
+	// остался бы без доставки и без возврата
+	ctx = context.WithoutCancel(ctx)
+
+	// Запись первого шага с прочитанной версией закрепляет заказ за этим оформлением
+	started, err := o.repo.AddCheckoutStep(ctx, order.ID, models.CheckoutStep{
+		Step:   models.CheckoutStepStart,
+		Status: models.CheckoutStepSucceeded,
+	}, order.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &run{
+		Orchestrator: o,
+		ctx:          ctx,
+		order:        started,
+		paymentKey:   fmt.Sprintf("checkout-%d-v%d", started.ID, started.Version),
+	}
+	err = r.execute(address)
+	switch {
+	case err == nil:
+		metrics.Checkout(metrics.CheckoutCompleted)
+	case errors.Is(err, ErrPaymentDeclined):
+		metrics.Checkout(metrics.CheckoutPaymentDeclined)
+	default:
+		metrics.Checkout(metrics.CheckoutFailed)
+	}
+	return r.order, err
+}
+
+func (o *Orchestrator) acquire(orderID int64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.active[orderID] {
+		return false
+	}
+	o.active[orderID] = true
+	return true
+}
+
+func (o *Orchestrator) release(orderID int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.active, orderID)
+}
+
+// run - одно оформление; order - последняя сохранённая версия заказа
+type run struct {
+	*Orchestrator
+	ctx        context.Context
+	order      *models.Order
+	paymentKey string // Idempotency-Key создания платежа: один на оформление
+}
+
+func (r *run) execute(address string) error {
+	payment, err := r.createPayment()
+	if err != nil {
+		r.record(models.CheckoutStep{Step: models.CheckoutStepCreatePayment}, err)
+		if unknownOutcome(err) {
+			// Платёж мог создаться: без компенсации он не дал бы оформить заказ заново
+			r.releasePayments()
+		}
+		return fmt.Errorf("%w: %w", ErrStepFailed, err)
+	}
+	r.record(models.CheckoutStep{Step: models.CheckoutStepCreatePayment, PaymentID: payment.ID}, nil)
+
+	status, err := r.awaitPayment(payment)
+	r.record(models.CheckoutStep{Step: models.CheckoutStepAwaitPayment, PaymentID: payment.ID}, err)
+	if err != nil {
+		r.rollback(payment.ID, status)
+		return err
+	}
+
+	if err := r.transition(models.OrderStatusProcessing); err != nil {
+		r.record(models.CheckoutStep{Step: models.CheckoutStepStartProcessing}, err)
+		r.rollback(payment.ID, status)
+		return fmt.Errorf("%w: %w", ErrStepFailed, err)
+	}
+	r.record(models.CheckoutStep{Step: models.CheckoutStepStartProcessing}, nil)
+
+	delivery, err := r.deliveries.CreateDelivery(r.ctx, r.order.UserID, r.order.ID, address)
+	if err != nil {
+		r.record(models.CheckoutStep{Step: models.CheckoutStepCreateDelivery}, err)
+		r.rollback(payment.ID, status)
+		return fmt.Errorf("%w: %w", ErrStepFailed, err)
+	}
+	r.record(models.CheckoutStep{Step: models.CheckoutStepCreateDelivery, DeliveryID: delivery.ID}, nil)
+
+	r.record(models.CheckoutStep{Step: models.CheckoutStepComplete, PaymentID: payment.ID, DeliveryID: delivery.ID}, nil)
+	return nil
+}
+
+// createPayment создаёт платёж с ключом paymentKey. Если исход неизвестен,
+// попытка повторяется с тем же ключом: уже созданный платёж payments-service
+// вернёт повторно, а не создаст второй.
+func (r *run) createPayment() (*client.Payment, error) {
+	var err error
+	for attempt := 1; attempt <= createAttempts; attempt++ {
+		var payment *client.Payment
+		payment, err = r.payments.CreatePayment(r.ctx, r.order.UserID, r.order.ID, r.order.TotalAmount, r.paymentKey)
+		if err == nil || !unknownOutcome(err) {
+			return payment, err
+		}
+		if attempt < createAttempts {
+			time.Sleep(r.config.PollInterval)
+		}
+	}
+	return nil, err
+}
+
+// unknownOutcome - по ошибке вызова нельзя сказать, выполнил ли его
+// payments-service: ответа нет, 5xx, 409 (запрос с тем же ключом ещё
+// выполняется) или ответ успеха не разобран. Прочие 4xx - отказ.
+func unknownOutcome(err error) bool {
+	var serviceErr *client.ServiceError
+	if !errors.As(err, &serviceErr) {
+		return false
+	}
+	status := serviceErr.Status
+	return status < 400 || status >= 500 || status == http.StatusConflict
+}
+
+// awaitPayment опрашивает платёж, пока его не спишут, не отклонят или не выйдет
+// время. Возвращает последний известный статус платежа.
+func (r *run) awaitPayment(payment *client.Payment) (string, error) {
+	status := payment.Status
+	deadline := time.Now().Add(r.config.PaymentTimeout)
+
+	var lastErr error
+	for {
+		current, err := r.payments.GetPayment(r.ctx, payment.ID)
+		if err == nil {
+			status, lastErr = current.Status, nil
+		} else {
+			// Сбой опроса не отклоняет платёж: пробуем до конца ожидания
+			lastErr = err
+		}
+
+		switch status {
+		case client.PaymentStatusCaptured:
+			return status, nil
+		case client.PaymentStatusPending, client.PaymentStatusAuthorized:
+		default:
+			return status, fmt.Errorf("%w: payment %d is %s", ErrPaymentDeclined, payment.ID, status)
+		}
+
+		if time.Now().After(deadline) {
+			if lastErr != nil {
+				return status, fmt.Errorf("%w: %w", ErrStepFailed, lastErr)
+			}
+			return status, fmt.Errorf("%w: payment %d not captured within %s, status %s",
+				ErrPaymentDeclined, payment.ID, r.config.PaymentTimeout, status)
+		}
+		time.Sleep(r.config.PollInterval)
+	}
+}
+
+// rollback компенсирует выполненные шаги: освобождает платёж и отменяет заказ.
+// Сбой компенсации остаётся в журнале заказа для ручного разбора.
+func (r *run) rollback(paymentID int64, status string) {
+	// Статус мог измениться с последнего опроса, поэтому перечитываем
+	if payment, err := r.payments.GetPayment(r.ctx, paymentID); err == nil {
+		status = payment.Status
+	}
+	r.releasePayment(paymentID, status)
+
+	err := r.transition(models.OrderStatusCancelled)
+	r.record(models.CheckoutStep{Step: models.CheckoutStepCancelOrder}, err)
+}
+
+// releasePayments компенсирует платежи заказа на всю его сумму после создания
+// с неизвестным исходом: такой платёж создаёт только оформление (при другом
+// платеже на всю сумму создание получило бы отказ 422), и без компенсации он
+// не дал бы оформить заказ заново. Заказ остаётся created.
+func (r *run) releasePayments() {
+	payments, err := r.payments.OrderPayments(r.ctx, r.order.ID)
+	r.record(models.CheckoutStep{Step: models.CheckoutStepFindPayments}, err)
+	for _, payment := range payments {
+		if payment.Amount.Equal(r.order.TotalAmount) {
+			r.releasePayment(payment.ID, payment.Status)
+		}
+	}
+}
+
+// releasePayment отменяет или возвращает платёж в статусе status
+func (r *run) releasePayment(paymentID int64, status string) {
+	var (
+		step      string
+		operation func(context.Context, int64) (*client.Payment, error)
+	)
+	switch status {
+	case client.PaymentStatusPending:
+		step, operation = models.CheckoutStepFailPayment, r.payments.FailPayment
+	case client.PaymentStatusAuthorized:
+		step, operation = models.CheckoutStepVoidPayment, r.payments.VoidPayment
+	case client.PaymentStatusCaptured, client.PaymentStatusPartiallyRefunded:
+		step, operation = models.CheckoutStepRefundPayment, r.payments.RefundPayment
+	}
+	if operation != nil {
+		_, err := operation(r.ctx, paymentID)
+		r.record(models.CheckoutStep{Step: step, PaymentID: paymentID}, err)
+	}
+}
+
+func (r *run) transition(status string) error {
+	updated, err := r.repo.UpdateOrderStatus(r.ctx, r.order.ID, status, actor, 0)
+	if err != nil {
+		return err
+	}
+	metrics.OrderStatus(updated.Status)
+	r.order = updated
+	return nil
+}
+
+// record пишет шаг в журнал заказа; err != nil - шаг не удался. Журнал
+// виден клиенту, поэтому в него попадает publicError, а полный текст - в журнал сервиса.
+func (r *run) record(step models.CheckoutStep, err error) {
+	step.Status = models.CheckoutStepSucceeded
+	if err != nil {
+		step.Status = models.CheckoutStepFailed
+		step.Error = publicError(err)
+		slog.WarnContext(r.ctx, "checkout step failed", "order_id", r.order.ID, "step", step.Step, "err", err)
+	}
+
+	updated, saveErr := r.repo.AddCheckoutStep(r.ctx, r.order.ID, step, 0)
+	if saveErr != nil {
+		// Оформление продолжается: потерянная запись журнала не должна оставить платёж без компенсации
+		slog.ErrorContext(r.ctx, "record checkout step", "order_id", r.order.ID, "step", step.Step, "err", saveErr)
+		return
+	}
+	r.order = updated
+}
+
+// publicError - текст ошибки шага для клиента: без адресов соседних сервисов
+// и сетевых подробностей
+func publicError(err error) string {
+	var serviceErr *client.ServiceError
+	switch {
+	case errors.As(err, &serviceErr):
+		return serviceErr.Public()
+	case errors.Is(err, ErrPaymentDeclined):
+		return err.Error() // текст оформления: номер и статус платежа
+	}
+	_, detail := problem.Public(err)
+	return detail
+}
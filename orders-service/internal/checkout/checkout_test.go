@@ -0,0 +1,360 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"platform/money"
+	"platform/server"
+
+	"orders-service/internal/client"
+	"orders-service/internal/models"
+	"orders-service/internal/repository"
+)
+
+var fastConfig = Config{PaymentTimeout: 50 * time.Millisecond, PollInterval: time.Millisecond}
+
+// fakePayments - платёж переходит в outcome после polls опросов; пустой outcome - остаётся pending.
+// Создание возвращает ошибки createErrs по одной на вызов; lostResponse - платёж при этом всё же создаётся.
+type fakePayments struct {
+	mu           sync.Mutex
+	createErrs   []error
+	lostResponse bool
+	keys         []string
+	outcome      string
+	polls        int
+	payment      *client.Payment
+	operations   []string
+}
+
+func (f *fakePayments) CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money, key string) (*client.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys = append(f.keys, key)
+	if len(f.createErrs) > 0 {
+		err := f.createErrs[0]
+		f.createErrs = f.createErrs[1:]
+		if f.lostResponse {
+			f.payment = &client.Payment{ID: 1, OrderID: orderID, Amount: amount, Status: client.PaymentStatusPending}
+		}
+		return nil, err
+	}
+	f.payment = &client.Payment{ID: 1, OrderID: orderID, Amount: amount, Status: client.PaymentStatusPending}
+	return f.snapshot(), nil
+}
+
+func (f *fakePayments) OrderPayments(ctx context.Context, orderID int64) ([]*client.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.payment == nil {
+		return nil, nil
+	}
+	return []*client.Payment{f.snapshot()}, nil
+}
+
+func (f *fakePayments) GetPayment(ctx context.Context, paymentID int64) (*client.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.polls > 0 {
+		f.polls--
+	} else if f.outcome != "" {
+		f.payment.Status = f.outcome
+	}
+	return f.snapshot(), nil
+}
+
+func (f *fakePayments) VoidPayment(ctx context.Context, paymentID int64) (*client.Payment, error) {
+	return f.apply("void", client.PaymentStatusVoided)
+}
+
+func (f *fakePayments) RefundPayment(ctx context.Context, paymentID int64) (*client.Payment, error) {
+	return f.apply("refund", client.PaymentStatusRefunded)
+}
+
+func (f *fakePayments) FailPayment(ctx context.Context, paymentID int64) (*client.Payment, error) {
+	return f.apply("fail", client.PaymentStatusFailed)
+}
+
+func (f *fakePayments) apply(op, status string) (*client.Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.operations = append(f.operations, op)
+	f.payment.Status = status
+	f.outcome = status
+	return f.snapshot(), nil
+}
+
+func (f *fakePayments) snapshot() *client.Payment {
+	payment := *f.payment
+	return &payment
+}
+
+type fakeDeliveries struct {
+	err error
+}
+
+func (f *fakeDeliveries) CreateDelivery(ctx context.Context, userID, orderID int64, address string) (*client.Delivery, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &client.Delivery{ID: 9, TrackingID: "TRK1", Status: "pending"}, nil
+}
+
+func newOrder(t *testing.T, repo repository.OrderRepository) *models.Order {
+	t.Helper()
+
+	order, err := repo.CreateOrder(context.Background(), 1, []models.LineItem{
+		{SKU: "BK-1", Name: "book", Quantity: 1, UnitPrice: money.New(10000, "RUB")},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return order
+}
+
+func steps(order *models.Order) []string {
+	var names []string
+	for _, step := range order.Checkout {
+		name := step.Step
+		if step.Status == models.CheckoutStepFailed {
+			name += "!"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func assertSteps(t *testing.T, order *models.Order, want ...string) {
+	t.Helper()
+
+	got := steps(order)
+	if len(got) != len(want) {
+		t.Fatalf("checkout steps %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("checkout steps %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCheckoutSucceeds(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	payments := &fakePayments{outcome: client.PaymentStatusCaptured, polls: 2}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if order.Status != models.OrderStatusProcessing {
+		t.Fatalf("order status %s, want processing", order.Status)
+	}
+	assertSteps(t, order, "start", "create_payment", "await_payment", "start_processing", "create_delivery", "complete")
+	if last := order.Checkout[len(order.Checkout)-1]; last.PaymentID != 1 || last.DeliveryID != 9 {
+		t.Fatalf("complete step %+v", last)
+	}
+
+	stored, _ := repo.GetOrderByID(context.Background(), order.ID)
+	if stored.Version != order.Version || len(stored.Checkout) != 6 {
+		t.Fatalf("stored order %+v differs from returned", stored)
+	}
+}
+
+func TestCheckoutPaymentDeclined(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	payments := &fakePayments{outcome: client.PaymentStatusFailed}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("Run error %v, want ErrPaymentDeclined", err)
+	}
+	if order.Status != models.OrderStatusCancelled {
+		t.Fatalf("order status %s, want cancelled", order.Status)
+	}
+	assertSteps(t, order, "start", "create_payment", "await_payment!", "cancel_order")
+	if len(payments.operations) != 0 {
+		t.Fatalf("declined payment was changed: %v", payments.operations)
+	}
+}
+
+func TestCheckoutPaymentTimeout(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	payments := &fakePayments{}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("Run error %v, want ErrPaymentDeclined", err)
+	}
+	assertSteps(t, order, "start", "create_payment", "await_payment!", "fail_payment", "cancel_order")
+	if len(payments.operations) != 1 || payments.operations[0] != "fail" {
+		t.Fatalf("payment operations %v, want fail", payments.operations)
+	}
+}
+
+func TestCheckoutDeliveryFailureRefunds(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	payments := &fakePayments{outcome: client.PaymentStatusCaptured}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{err: &client.ServiceError{
+		Service: "delivery-service",
+		Status:  503,
+		Err:     errors.New(`status 503: upstream http://10.0.0.7:8084 refused`),
+	}}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if !errors.Is(err, ErrStepFailed) {
+		t.Fatalf("Run error %v, want ErrStepFailed", err)
+	}
+	if order.Status != models.OrderStatusCancelled {
+		t.Fatalf("order status %s, want cancelled", order.Status)
+	}
+	assertSteps(t, order, "start", "create_payment", "await_payment", "start_processing",
+		"create_delivery!", "refund_payment", "cancel_order")
+	if len(payments.operations) != 1 || payments.operations[0] != "refund" {
+		t.Fatalf("payment operations %v, want refund", payments.operations)
+	}
+	if failed := order.Checkout[4]; failed.Error != "delivery-service: status 503" {
+		t.Fatalf("failed step %+v, want the delivery error without the address", failed)
+	}
+}
+
+// errPaymentsDown - payments-service не ответил: создан ли платёж, неизвестно
+var errPaymentsDown = &client.ServiceError{
+	Service: "payments-service",
+	Err:     errors.New(`Post "http://payments-service:8083/api/payments": dial tcp: connection refused`),
+}
+
+func TestCheckoutCreatePaymentFailureKeepsOrder(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	payments := &fakePayments{createErrs: []error{errPaymentsDown, errPaymentsDown, errPaymentsDown}}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if !errors.Is(err, ErrStepFailed) {
+		t.Fatalf("Run error %v, want ErrStepFailed", err)
+	}
+	if order.Status != models.OrderStatusCreated {
+		t.Fatalf("order status %s, want created", order.Status)
+	}
+	assertSteps(t, order, "start", "create_payment!", "find_payments")
+	if failed := order.Checkout[1]; failed.Error != "payments-service unavailable" {
+		t.Fatalf("failed step %+v, want the payments error without the address", failed)
+	}
+	if len(payments.keys) != createAttempts {
+		t.Fatalf("create attempts %v, want %d", payments.keys, createAttempts)
+	}
+
+	// Платёж не создан, поэтому заказ можно оформить ещё раз - уже с новым ключом
+	payments.outcome = client.PaymentStatusCaptured
+	order, err = o.Run(context.Background(), order, "Main st. 1")
+	if err != nil || order.Status != models.OrderStatusProcessing {
+		t.Fatalf("second Run = %s, %v", order.Status, err)
+	}
+	if last := payments.keys[len(payments.keys)-1]; last == payments.keys[0] {
+		t.Fatalf("second checkout reused key %s", last)
+	}
+}
+
+func TestCheckoutRetriesCreateWithSameKey(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	unavailable := &client.ServiceError{Service: "payments-service", Status: 503, Err: errors.New("status 503")}
+	payments := &fakePayments{createErrs: []error{unavailable}, lostResponse: true, outcome: client.PaymentStatusCaptured}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order := newOrder(t, repo)
+	done, err := o.Run(context.Background(), order, "Main st. 1")
+	if err != nil || done.Status != models.OrderStatusProcessing {
+		t.Fatalf("Run = %s, %v", done.Status, err)
+	}
+	assertSteps(t, done, "start", "create_payment", "await_payment", "start_processing", "create_delivery", "complete")
+
+	want := fmt.Sprintf("checkout-%d-v%d", order.ID, order.Version+1)
+	if len(payments.keys) != 2 || payments.keys[0] != want || payments.keys[1] != want {
+		t.Fatalf("create keys %v, want %s twice", payments.keys, want)
+	}
+}
+
+func TestCheckoutReleasesPaymentOfUnknownCreate(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	payments := &fakePayments{createErrs: []error{errPaymentsDown, errPaymentsDown, errPaymentsDown}, lostResponse: true}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if !errors.Is(err, ErrStepFailed) || order.Status != models.OrderStatusCreated {
+		t.Fatalf("Run = %s, %v; want created and ErrStepFailed", order.Status, err)
+	}
+	assertSteps(t, order, "start", "create_payment!", "find_payments", "fail_payment")
+	if len(payments.operations) != 1 || payments.operations[0] != "fail" {
+		t.Fatalf("payment operations %v, want fail", payments.operations)
+	}
+}
+
+func TestCheckoutDoesNotRetryRejectedCreate(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	rejected := &client.ServiceError{Service: "payments-service", Status: 422, Detail: "user not found", Err: errors.New("status 422")}
+	payments := &fakePayments{createErrs: []error{rejected}}
+	o := NewOrchestrator(repo, payments, &fakeDeliveries{}, fastConfig)
+
+	order, err := o.Run(context.Background(), newOrder(t, repo), "Main st. 1")
+	if !errors.Is(err, ErrStepFailed) {
+		t.Fatalf("Run error %v, want ErrStepFailed", err)
+	}
+	assertSteps(t, order, "start", "create_payment!")
+	if len(payments.keys) != 1 {
+		t.Fatalf("create attempts %v, want 1", payments.keys)
+	}
+}
+
+func TestCheckoutRejectsStaleOrConcurrentRuns(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryOrderRepository()
+	o := NewOrchestrator(repo, &fakePayments{outcome: client.PaymentStatusCaptured}, &fakeDeliveries{}, fastConfig)
+
+	order := newOrder(t, repo)
+	stale := order.Clone()
+	repo.AddCheckoutStep(ctx, order.ID, models.CheckoutStep{Step: models.CheckoutStepStart}, 0)
+	if _, err := o.Run(ctx, stale, "Main st. 1"); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("Run on stale order: error %v, want ErrVersionMismatch", err)
+	}
+
+	o.acquire(order.ID)
+	current, _ := repo.GetOrderByID(ctx, order.ID)
+	if _, err := o.Run(ctx, current, "Main st. 1"); !errors.Is(err, ErrInProgress) {
+		t.Fatalf("Run during checkout: error %v, want ErrInProgress", err)
+	}
+	o.release(order.ID)
+
+	done, err := o.Run(ctx, current, "Main st. 1")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := o.Run(ctx, done, "Main st. 1"); !errors.Is(err, ErrNotCheckoutable) {
+		t.Fatalf("Run on processing order: error %v, want ErrNotCheckoutable", err)
+	}
+}
+
+func TestConfigCheckTimeouts(t *testing.T) {
+	defaults := server.DefaultTimeouts
+	if err := DefaultConfig.CheckTimeouts(defaults.Write, defaults.Shutdown); err != nil {
+		t.Fatalf("default timeouts: %v", err)
+	}
+	if err := DefaultConfig.CheckTimeouts(0, defaults.Shutdown); err != nil {
+		t.Fatalf("no write timeout: %v", err)
+	}
+	if err := DefaultConfig.CheckTimeouts(defaults.Write, DefaultConfig.PaymentTimeout); err == nil {
+		t.Fatal("shutdown no longer than the payment wait accepted")
+	}
+	if err := DefaultConfig.CheckTimeouts(DefaultConfig.PaymentTimeout, defaults.Shutdown); err == nil {
+		t.Fatal("write timeout no longer than the payment wait accepted")
+	}
+}
@@ -1,54 +1,255 @@
 package client
 
 import (
-    "bytes"
-    "encoding/json"
-    "fmt"
-    "net/http"
-    "time"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"platform/health"
+	"platform/idempotency"
+	"platform/metrics"
+	"platform/money"
+	"platform/pagination"
+	"platform/problem"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+// Имена сервисов в ServiceError
+const (
+	paymentsService = "payments-service"
+	deliveryService = "delivery-service"
 )
 
+// checkoutActor - от чьего имени оформление меняет платежи (X-Actor в истории платежа)
+const checkoutActor = "orders-service"
+
+// Статусы платежа, которые различает оформление заказа
+const (
+	PaymentStatusPending           = "pending"
+	PaymentStatusAuthorized        = "authorized"
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusVoided            = "voided"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusFailed            = "failed"
+)
+
+// Payment - поля платежа, нужные оформлению
+type Payment struct {
+	ID      int64       `json:"id"`
+	OrderID int64       `json:"orderId"`
+	Amount  money.Money `json:"amount"`
+	Status  string      `json:"status"`
+}
+
+// Delivery - поля доставки, нужные оформлению
+type Delivery struct {
+	ID         int64  `json:"id"`
+	TrackingID string `json:"trackingId"`
+	Status     string `json:"status"`
+}
+
+// ServiceError - сбой вызова payments-service или delivery-service. Error -
+// полный текст для журнала, Public - текст, который можно показать клиенту
+type ServiceError struct {
+	Service string // payments-service или delivery-service
+	Status  int    // 0 - сервис не ответил
+	Detail  string // detail из ответа application/problem+json, если он есть
+	Err     error
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Service, e.Err)
+}
+
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
+// Public - текст ошибки без адреса сервиса и сетевых подробностей. Detail
+// безопасен: сервис формирует его для своих клиентов.
+func (e *ServiceError) Public() string {
+	switch {
+	case e.Status == 0:
+		return e.Service + " unavailable"
+	case e.Detail != "":
+		return fmt.Sprintf("%s: status %d: %s", e.Service, e.Status, e.Detail)
+	default:
+		return fmt.Sprintf("%s: status %d", e.Service, e.Status)
+	}
+}
+
+// ServiceClient вызывает payments-service и delivery-service при оформлении заказа
 type ServiceClient struct {
-    baseURL    string
-    httpClient *http.Client
-}
-
-func NewServiceClient(baseURL string) *ServiceClient {
-    return &ServiceClient{
-        baseURL: baseURL,
-        httpClient: &http.Client{
-            Timeout: 10 * time.Second,
-        },
-    }
-}
-
-func (c *ServiceClient) CreatePayment(orderID int64, amount float64) error {
-    payload := map[string]interface{}{
-        "order_id": orderID,
-        "amount":   amount,
-        "status":   "pending",
-    }
-    
-    body, _ := json.Marshal(payload)
-    
-    req, err := http.NewRequest("POST", 
-        fmt.Sprintf("%s/api/payments", c.baseURL), 
-        bytes.NewBuffer(body))
-    if err != nil {
-        return err
-    }
-    
-    req.Header.Set("Content-Type", "application/json")
-    
-    resp, err := c.httpClient.Do(req)
-    if err != nil {
-        return err
-    }
-    defer resp.Body.Close()
-    
-    if resp.StatusCode != http.StatusCreated {
-        return fmt.Errorf("failed to create payment: status %d", resp.StatusCode)
-    }
-    
-    return nil
+	paymentsURL string
+	deliveryURL string
+	httpClient  *http.Client
+}
+
+// NewServiceClient создаёт клиента с адресами payments-service и delivery-service
+func NewServiceClient(paymentsURL, deliveryURL string) *ServiceClient {
+	return &ServiceClient{
+		paymentsURL: paymentsURL,
+		deliveryURL: deliveryURL,
+		httpClient: &http.Client{
+			Transport: &metrics.Transport{Client: "ServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *ServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// PaymentsHealthCheck - проверка готовности: отвечает ли payments-service на /health/live
+func (c *ServiceClient) PaymentsHealthCheck() health.Check {
+	return health.HTTP(c.paymentsURL + "/health/live")
+}
+
+// DeliveryHealthCheck - то же для delivery-service
+func (c *ServiceClient) DeliveryHealthCheck() health.Check {
+	return health.HTTP(c.deliveryURL + "/health/live")
+}
+
+// CreatePayment создаёт платёж на сумму заказа. key уходит в Idempotency-Key:
+// повтор с тем же ключом вернёт уже созданный платёж, а не создаст второй.
+func (c *ServiceClient) CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money, key string) (*Payment, error) {
+	payload := map[string]interface{}{
+		"userId":  userID,
+		"orderId": orderID,
+		"amount":  amount,
+	}
+
+	var payment Payment
+	header := http.Header{idempotency.Header: {key}}
+	if err := c.do(ctx, paymentsService, http.MethodPost, c.paymentsURL+"/api/payments", header, payload, http.StatusCreated, &payment); err != nil {
+		return nil, fmt.Errorf("create payment: %w", err)
+	}
+	return &payment, nil
+}
+
+// OrderPayments возвращает платежи заказа (до pagination.MaxListLimit)
+func (c *ServiceClient) OrderPayments(ctx context.Context, orderID int64) ([]*Payment, error) {
+	var page struct {
+		Items []*Payment `json:"items"`
+	}
+	url := fmt.Sprintf("%s/api/payments?orderId=%d&limit=%d", c.paymentsURL, orderID, pagination.MaxListLimit)
+	if err := c.do(ctx, paymentsService, http.MethodGet, url, nil, nil, http.StatusOK, &page); err != nil {
+		return nil, fmt.Errorf("list payments of order %d: %w", orderID, err)
+	}
+	return page.Items, nil
+}
+
+// GetPayment читает текущее состояние платежа
+func (c *ServiceClient) GetPayment(ctx context.Context, paymentID int64) (*Payment, error) {
+	var payment Payment
+	url := fmt.Sprintf("%s/api/payments/%d", c.paymentsURL, paymentID)
+	if err := c.do(ctx, paymentsService, http.MethodGet, url, nil, nil, http.StatusOK, &payment); err != nil {
+		return nil, fmt.Errorf("get payment %d: %w", paymentID, err)
+	}
+	return &payment, nil
+}
+
+// VoidPayment отменяет авторизацию платежа
+func (c *ServiceClient) VoidPayment(ctx context.Context, paymentID int64) (*Payment, error) {
+	return c.paymentOperation(ctx, paymentID, "void")
+}
+
+// RefundPayment возвращает всю списанную сумму
+func (c *ServiceClient) RefundPayment(ctx context.Context, paymentID int64) (*Payment, error) {
+	return c.paymentOperation(ctx, paymentID, "refund")
+}
+
+// FailPayment помечает неавторизованный платёж неуспешным
+func (c *ServiceClient) FailPayment(ctx context.Context, paymentID int64) (*Payment, error) {
+	var payment Payment
+	url := fmt.Sprintf("%s/api/payments/%d", c.paymentsURL, paymentID)
+	payload := map[string]string{"status": PaymentStatusFailed}
+	if err := c.do(ctx, paymentsService, http.MethodPut, url, nil, payload, http.StatusOK, &payment); err != nil {
+		return nil, fmt.Errorf("fail payment %d: %w", paymentID, err)
+	}
+	return &payment, nil
+}
+
+func (c *ServiceClient) paymentOperation(ctx context.Context, paymentID int64, op string) (*Payment, error) {
+	var payment Payment
+	url := fmt.Sprintf("%s/api/payments/%d/%s", c.paymentsURL, paymentID, op)
+	if err := c.do(ctx, paymentsService, http.MethodPost, url, nil, nil, http.StatusOK, &payment); err != nil {
+		return nil, fmt.Errorf("%s payment %d: %w", op, paymentID, err)
+	}
+	return &payment, nil
+}
+
+// CreateDelivery создаёт доставку оплаченного заказа
+func (c *ServiceClient) CreateDelivery(ctx context.Context, userID, orderID int64, address string) (*Delivery, error) {
+	payload := map[string]interface{}{
+		"userId":  userID,
+		"orderId": orderID,
+		"address": address,
+	}
+
+	var delivery Delivery
+	if err := c.do(ctx, deliveryService, http.MethodPost, c.deliveryURL+"/api/deliveries", nil, payload, http.StatusCreated, &delivery); err != nil {
+		return nil, fmt.Errorf("create delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// do отправляет payload в JSON с заголовками header и разбирает ответ в out, если
+// статус равен want. Сбой вызова или другой статус - *ServiceError сервиса service.
+func (c *ServiceClient) do(ctx context.Context, service, method, url string, header http.Header, payload interface{}, want int, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("X-Actor", checkoutActor)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &ServiceError{Service: service, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		var p struct {
+			Detail string `json:"detail"`
+		}
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), problem.ContentType) {
+			json.Unmarshal(message, &p)
+		}
+		return &ServiceError{
+			Service: service,
+			Status:  resp.StatusCode,
+			Detail:  p.Detail,
+			Err:     fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message))),
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &ServiceError{Service: service, Status: resp.StatusCode, Err: fmt.Errorf("decode %s: %w", url, err)}
+	}
+	return nil
 }
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/money"
+	"platform/problem"
+)
+
+func TestServiceClientSendsCamelCase(t *testing.T) {
+	ctx := context.Background()
+
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Actor") != checkoutActor {
+			t.Errorf("X-Actor %q", r.Header.Get("X-Actor"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+
+		if r.Method == http.MethodGet {
+			if r.URL.Query().Get("orderId") != "3" {
+				t.Errorf("payments query %q", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"items":[{"id":5,"orderId":3,"status":"pending"}],"total":1}`))
+			return
+		}
+		if r.URL.Path == "/api/payments" && r.Header.Get("Idempotency-Key") != "checkout-3-v2" {
+			t.Errorf("Idempotency-Key %q", r.Header.Get("Idempotency-Key"))
+		}
+		w.WriteHeader(http.StatusCreated)
+		switch r.URL.Path {
+		case "/api/payments":
+			w.Write([]byte(`{"id":5,"orderId":3,"amount":{"minor":4250,"currency":"RUB"},"status":"pending"}`))
+		case "/api/deliveries":
+			w.Write([]byte(`{"id":8,"trackingId":"TRK1","status":"pending"}`))
+		}
+	}))
+	defer server.Close()
+	services := NewServiceClient(server.URL, server.URL)
+
+	payment, err := services.CreatePayment(ctx, 7, 3, money.New(4250, "RUB"), "checkout-3-v2")
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	if payment.ID != 5 || payment.Status != PaymentStatusPending || !payment.Amount.Equal(money.New(4250, "RUB")) {
+		t.Fatalf("payment %+v", payment)
+	}
+	amount, _ := got["amount"].(map[string]interface{})
+	if got["userId"] != float64(7) || got["orderId"] != float64(3) || got["order_id"] != nil ||
+		amount["minor"] != float64(4250) || amount["currency"] != "RUB" {
+		t.Fatalf("payment request body %v", got)
+	}
+
+	delivery, err := services.CreateDelivery(ctx, 7, 3, "Main st. 1")
+	if err != nil {
+		t.Fatalf("CreateDelivery: %v", err)
+	}
+	if delivery.ID != 8 || got["userId"] != float64(7) || got["orderId"] != float64(3) || got["address"] != "Main st. 1" {
+		t.Fatalf("delivery %+v, request body %v", delivery, got)
+	}
+
+	payments, err := services.OrderPayments(ctx, 3)
+	if err != nil || len(payments) != 1 || payments[0].ID != 5 {
+		t.Fatalf("OrderPayments = %+v, %v", payments, err)
+	}
+}
+
+func TestServiceClientReportsServiceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "payment amount 50.00 exceeds outstanding 40.00 of order 1", http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	_, err := NewServiceClient(server.URL, server.URL).CreatePayment(context.Background(), 1, 1, money.New(5000, "RUB"), "checkout-1-v2")
+	if err == nil || !strings.Contains(err.Error(), "status 422") || !strings.Contains(err.Error(), "exceeds outstanding") {
+		t.Fatalf("error %v, want status and message of payments-service", err)
+	}
+}
+
+func TestServiceErrorPublicHidesAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", problem.ContentType)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"status":422,"code":"user_not_found","detail":"user not found: 1"}`))
+	}))
+	url := server.URL
+	services := NewServiceClient(url, url)
+
+	var se *ServiceError
+	_, err := services.CreatePayment(context.Background(), 1, 1, money.New(5000, "RUB"), "checkout-1-v2")
+	if !errors.As(err, &se) || se.Public() != "payments-service: status 422: user not found: 1" {
+		t.Fatalf("error %v, want ServiceError with the problem detail", err)
+	}
+
+	server.Close()
+	_, err = services.CreateDelivery(context.Background(), 1, 1, "Main st. 1")
+	if !errors.As(err, &se) || se.Public() != "delivery-service unavailable" {
+		t.Fatalf("error %v, want unavailable delivery-service", err)
+	}
+	if !strings.Contains(err.Error(), strings.TrimPrefix(url, "http://")) || strings.Contains(se.Public(), "127.0.0.1") {
+		t.Fatalf("Error() %q should keep the address for logs, Public() %q should not", err, se.Public())
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"platform/money"
+
+	"orders-service/internal/checkout"
+	"orders-service/internal/client"
+	"orders-service/internal/repository"
+)
+
+// fakeUsers - любой пользователь существует
+type fakeUsers struct{}
+
+func (fakeUsers) VerifyUser(context.Context, int64) error { return nil }
+
+// fakePayments - платёж, который при опросе оказывается в статусе status
+type fakePayments struct{ status string }
+
+func (f fakePayments) CreatePayment(_ context.Context, _, orderID int64, amount money.Money, _ string) (*client.Payment, error) {
+	return &client.Payment{ID: 1, OrderID: orderID, Amount: amount, Status: client.PaymentStatusPending}, nil
+}
+
+func (f fakePayments) OrderPayments(context.Context, int64) ([]*client.Payment, error) {
+	return nil, nil
+}
+
+func (f fakePayments) GetPayment(_ context.Context, paymentID int64) (*client.Payment, error) {
+	return &client.Payment{ID: paymentID, Status: f.status}, nil
+}
+
+func (f fakePayments) VoidPayment(_ context.Context, paymentID int64) (*client.Payment, error) {
+	return &client.Payment{ID: paymentID, Status: client.PaymentStatusVoided}, nil
+}
+
+func (f fakePayments) RefundPayment(_ context.Context, paymentID int64) (*client.Payment, error) {
+	return &client.Payment{ID: paymentID, Status: client.PaymentStatusRefunded}, nil
+}
+
+func (f fakePayments) FailPayment(_ context.Context, paymentID int64) (*client.Payment, error) {
+	return &client.Payment{ID: paymentID, Status: client.PaymentStatusFailed}, nil
+}
+
+// fakeDeliveries создаёт доставку или возвращает err
+type fakeDeliveries struct{ err error }
+
+func (f fakeDeliveries) CreateDelivery(context.Context, int64, int64, string) (*client.Delivery, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &client.Delivery{ID: 1, TrackingID: "TRK00000000000", Status: "pending"}, nil
+}
+
+// newTestRouter - маршруты как в cmd/main.go поверх репозитория в памяти.
+// newCheckouts строит оркестратор оформления; nil - без оформления.
+func newTestRouter(newCheckouts func(repository.OrderRepository) *checkout.Orchestrator) *mux.Router {
+	repo := repository.NewMemoryOrderRepository()
+	var checkouts *checkout.Orchestrator
+	if newCheckouts != nil {
+		checkouts = newCheckouts(repo)
+	}
+	handler := NewOrderHandler(repo, fakeUsers{}, checkouts)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/orders", handler.CreateOrder).Methods("POST")
+	r.HandleFunc("/orders/{id}", handler.GetOrderByID).Methods("GET")
+	r.HandleFunc("/orders/{id}/history", handler.GetOrderHistory).Methods("GET")
+	r.HandleFunc("/orders/{id}/checkout", handler.Checkout).Methods("POST")
+	r.HandleFunc("/orders/{id}", handler.UpdateOrder).Methods("PUT")
+	return r
+}
+
+// checkoutRouter - newTestRouter с оформлением через payments и deliveries
+func checkoutRouter(payments checkout.Payments, deliveries checkout.Deliveries) *mux.Router {
+	config := checkout.Config{PaymentTimeout: time.Second, PollInterval: time.Millisecond}
+	return newTestRouter(func(repo repository.OrderRepository) *checkout.Orchestrator {
+		return checkout.NewOrchestrator(repo, payments, deliveries, config)
+	})
+}
+
+// serve выполняет запрос к router; header - дополнительные заголовки
+func serve(router http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// decode разбирает JSON-ответ в v; тело не JSON - ошибка теста
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decode response %d: %v", w.Code, err)
+	}
+}
+
+const testOrder = `{"userId": 1, "items": [{"sku": "A-1", "name": "Book", "quantity": 2, "unitPrice": {"minor": 5000, "currency": "RUB"}}]}`
+
+// createOrder создаёт заказ testOrder
+func createOrder(t *testing.T, router http.Handler) {
+	t.Helper()
+	if w := serve(router, http.MethodPost, "/orders", testOrder, nil); w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", w.Code, w.Body)
+	}
+}
@@ -2,76 +2,179 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 
+	"platform/httputil"
+	"platform/money"
+	"platform/pagination"
+	"platform/problem"
+	"platform/validation"
+
+	"orders-service/internal/checkout"
+	"orders-service/internal/metrics"
+	"orders-service/internal/models"
 	"orders-service/internal/repository"
 )
 
+// actorHeader - кто меняет заказ; попадает в историю статусов
+const actorHeader = "X-Actor"
+
 // OrderHandler обработчик заказов
 type OrderHandler struct {
-	repo *repository.JSONOrderRepository
+	repo      repository.OrderRepository
+	users     UserVerifier
+	checkouts *checkout.Orchestrator
 }
 
-// CreateOrderRequest структура для создания заказа
+// CreateOrderRequest структура для создания заказа. Суммы считает сервер;
+// переданный клиентом totalAmount только сверяется с расчётом.
+// Суммы - {"minor": 10050, "currency": "RUB"} или число в рублях.
 type CreateOrderRequest struct {
-	UserID      int64    `json:"userId" binding:"required"`
-	Items       []string `json:"items" binding:"required"`
-	TotalAmount float64  `json:"totalAmount" binding:"required,min=0"`
+	UserID      int64              `json:"userId" binding:"required,min=1"`
+	Items       []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	TotalAmount *money.Money       `json:"totalAmount,omitempty"`
+}
+
+// OrderItemRequest позиция заказа в запросе
+type OrderItemRequest struct {
+	SKU       string       `json:"sku" binding:"required"`
+	Name      string       `json:"name" binding:"required"`
+	Quantity  int          `json:"quantity" binding:"required,min=1,max=1000000"`
+	UnitPrice money.Money  `json:"unitPrice" binding:"min=0"`
+	Discount  money.Money  `json:"discount" binding:"min=0"` // на всю позицию, в валюте цены
+	Total     *money.Money `json:"total,omitempty"`          // если передан, сверяется с расчётом
+}
+
+// CheckoutRequest структура для оформления заказа
+type CheckoutRequest struct {
+	Address string `json:"address" binding:"required"`
 }
 
 // UpdateOrderRequest структура для обновления заказа
 type UpdateOrderRequest struct {
-	Status string `json:"status" binding:"required"`
+	Status string `json:"status" binding:"required"` // created -> processing -> completed, либо cancelled / failed
 }
 
-// NewOrderHandler создаёт новый обработчик
-func NewOrderHandler(repo *repository.JSONOrderRepository) *OrderHandler {
+// NewOrderHandler создаёт новый обработчик. users проверяет, что владелец заказа
+// существует, checkouts оформляет заказы через payments-service и delivery-service.
+func NewOrderHandler(repo repository.OrderRepository, users UserVerifier, checkouts *checkout.Orchestrator) *OrderHandler {
 	return &OrderHandler{
-		repo: repo,
+		repo:      repo,
+		users:     users,
+		checkouts: checkouts,
 	}
 }
 
 // CreateOrder создаёт заказ
 // @Summary Создать заказ
-// @Description Создать новый заказ
+// @Description Создать новый заказ из позиций. Итог позиций, подытог, скидку и итог заказа считает сервер;
+// @Description переданные клиентом total и totalAmount должны совпасть с расчётом. Суммы - {"minor", "currency"}
+// @Description или число в рублях; позиции в разных валютах отклоняются.
+// @Description Пользователь проверяется в users-service.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param order body CreateOrderRequest true "Данные заказа"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {string} string "Invalid request"
+// @Failure 400 {object} problem.Problem "Invalid request or line item"
+// @Failure 422 {object} problem.Problem "User not found or client total differs from the computed one"
+// @Failure 503 {object} problem.Problem "Users service unavailable"
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	var req CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	items, err := priceOrderRequest(&req)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	if err := h.users.VerifyUser(r.Context(), req.UserID); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	order, err := h.repo.CreateOrder(req.UserID, req.Items, req.TotalAmount)
+	order, err := h.repo.CreateOrder(r.Context(), req.UserID, items)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
+	metrics.OrderStatus(order.Status)
 
+	httputil.SetETag(w, order.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(order)
 }
 
-// GetAllOrders получает все заказы
-// @Summary Получить все заказы
-// @Description Получить список всех заказов
+// errTotalMismatch - переданный клиентом итог не совпал с расчётом
+var errTotalMismatch = problem.New(problem.Unprocessable, "total_mismatch", "total differs from computed")
+
+// priceOrderRequest переводит позиции запроса в модель и сверяет переданные
+// клиентом итоги с расчётом
+func priceOrderRequest(req *CreateOrderRequest) ([]models.LineItem, error) {
+	items := make([]models.LineItem, len(req.Items))
+	for n, item := range req.Items {
+		items[n] = models.LineItem{
+			SKU:       item.SKU,
+			Name:      item.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			Discount:  item.Discount,
+		}
+	}
+
+	priced, totals, err := models.PriceItems(items)
+	if err != nil {
+		return nil, err
+	}
+	for n, item := range req.Items {
+		if item.Total != nil && !item.Total.Equal(priced[n].Total) {
+			return nil, fmt.Errorf("item %d: %w: %s, computed %s", n+1, errTotalMismatch, *item.Total, priced[n].Total)
+		}
+	}
+	if req.TotalAmount != nil && !req.TotalAmount.Equal(totals.Total) {
+		return nil, fmt.Errorf("totalAmount: %w: %s, computed %s", errTotalMismatch, *req.TotalAmount, totals.Total)
+	}
+
+	return items, nil
+}
+
+// GetAllOrders получает страницу заказов
+// @Summary Получить заказы
+// @Description Получить страницу заказов с сортировкой, фильтрами и курсором
 // @Tags orders
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param status query string false "Статус"
+// @Param userId query int64 false "ID пользователя"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /orders [get]
 func (h *OrderHandler) GetAllOrders(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode([]interface{}{})
+	q, err := pagination.ParseListQuery(r.URL.Query(), pagination.FilterStatus, pagination.FilterUserID)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	h.writeList(w, r, q)
 }
 
 // GetOrderByID получает заказ по ID
@@ -80,128 +183,294 @@ func (h *OrderHandler) GetAllOrders(w http.ResponseWriter, r *http.Request) {
 // @Tags orders
 // @Produce json
 // @Param id path int64 true "ID заказа"
+// @Param If-None-Match header string false "ETag известной клиенту версии"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "Order not found"
+// @Success 304 "Not modified"
+// @Failure 404 {object} problem.Problem "Order not found"
 // @Router /orders/{id} [get]
 func (h *OrderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
-	order, err := h.repo.GetOrderByID(id)
+	order, err := h.repo.GetOrderByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, err)
+		return
+	}
+
+	if httputil.NotModified(w, r, order.Version) {
 		return
 	}
 
+	httputil.SetETag(w, order.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(order)
 }
 
 // GetOrdersByUserID получает заказы пользователя
 // @Summary Получить заказы пользователя
-// @Description Получить все заказы конкретного пользователя
+// @Description Получить страницу заказов конкретного пользователя; параметры те же, что у списка
 // @Tags orders
 // @Produce json
 // @Param userId path int64 true "ID пользователя"
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param status query string false "Статус"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /orders/user/{userId} [get]
 func (h *OrderHandler) GetOrdersByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidUserID)
 		return
 	}
 
-	orders, err := h.repo.GetOrdersByUserID(userID)
+	q, err := pagination.ParseListQuery(r.URL.Query(), pagination.FilterStatus)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, err)
+		return
+	}
+	q.UserID = userID
+
+	h.writeList(w, r, q)
+}
+
+// writeList отдаёт страницу заказов по разобранному запросу списка
+func (h *OrderHandler) writeList(w http.ResponseWriter, r *http.Request, q pagination.ListQuery) {
+	page, err := h.repo.ListOrders(r.Context(), q)
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	json.NewEncoder(w).Encode(page)
 }
 
 // UpdateOrder обновляет заказ
 // @Summary Обновить заказ
-// @Description Обновить статус заказа
+// @Description Перевести заказ в новый статус по автомату created -> processing -> completed (или cancelled / failed)
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param id path int64 true "ID заказа"
 // @Param order body UpdateOrderRequest true "Новый статус"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто меняет статус (для истории)"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "Order not found"
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Failure 412 {object} problem.Problem "Precondition failed"
 // @Router /orders/{id} [put]
 func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
 	var req UpdateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	version, err := httputil.ExpectedVersion(r, h.orderVersion(r, id))
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	order, err := h.repo.UpdateOrderStatus(id, req.Status)
+	order, err := h.repo.UpdateOrderStatus(r.Context(), id, req.Status, actor(r), version)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
+	metrics.OrderStatus(order.Status)
 
+	httputil.SetETag(w, order.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(order)
 }
 
+// GetOrderHistory получает историю статусов заказа
+// @Summary История статусов заказа
+// @Description Все переходы статуса заказа: откуда, куда, кто и когда
+// @Tags orders
+// @Produce json
+// @Param id path int64 true "ID заказа"
+// @Success 200 {array} models.StatusChange
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Router /orders/{id}/history [get]
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	order, err := h.repo.GetOrderByID(r.Context(), id)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	history := order.History
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// Checkout оформляет заказ
+// @Summary Оформить заказ
+// @Description Создаёт платёж на сумму заказа, ждёт его списания, переводит заказ в processing и создаёт доставку.
+// @Description Если шаг не удался, платёж отменяется или возвращается, а заказ отменяется.
+// @Description Все шаги, включая компенсации, пишутся в поле checkout заказа; при 402 и 502 в ответе тоже заказ.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path int64 true "ID заказа"
+// @Param If-Match header string false "ETag версии, которую клиент оформляет"
+// @Param checkout body CheckoutRequest true "Адрес доставки"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 402 {object} map[string]interface{} "Payment declined, order cancelled"
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Failure 409 {object} problem.Problem "Order is not in status created or is already being checked out"
+// @Failure 412 {object} problem.Problem "Precondition failed"
+// @Failure 502 {object} map[string]interface{} "Payments or delivery service failed"
+// @Router /orders/{id}/checkout [post]
+func (h *OrderHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	var req CheckoutRequest
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	order, err := h.repo.GetOrderByID(r.Context(), id)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+	if _, err := httputil.ExpectedVersion(r, func() (int64, error) { return order.Version, nil }); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	result, err := h.checkouts.Run(r.Context(), order, req.Address)
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, checkout.ErrPaymentDeclined):
+		status = http.StatusPaymentRequired
+	case errors.Is(err, checkout.ErrStepFailed):
+		status = http.StatusBadGateway
+	case err != nil:
+		problem.Write(w, r, err)
+		return
+	}
+
+	httputil.SetETag(w, result.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
 // DeleteOrder удаляет заказ
 // @Summary Удалить заказ
 // @Description Удалить заказ по ID
 // @Tags orders
 // @Param id path int64 true "ID заказа"
+// @Param If-Match header string false "ETag версии, которую клиент удаляет"
 // @Success 204
-// @Failure 404 {string} string "Order not found"
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Failure 412 {object} problem.Problem "Precondition failed"
 // @Router /orders/{id} [delete]
 func (h *OrderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
-	err = h.repo.DeleteOrder(id)
+	version, err := httputil.ExpectedVersion(r, h.orderVersion(r, id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, err)
+		return
+	}
+
+	err = h.repo.DeleteOrder(r.Context(), id, version)
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// actor возвращает автора изменения из X-Actor
+func actor(r *http.Request) string {
+	if actor := r.Header.Get(actorHeader); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// orderVersion читает текущую версию заказа для проверки If-Match
+func (h *OrderHandler) orderVersion(r *http.Request, id int64) func() (int64, error) {
+	return func() (int64, error) {
+		order, err := h.repo.GetOrderByID(r.Context(), id)
+		if err != nil {
+			return 0, err
+		}
+		return order.Version, nil
+	}
+}
+
 // DeleteOrdersByUserID удаляет заказы пользователя
 // @Summary Удалить заказы пользователя
 // @Description Удалить все заказы пользователя
 // @Tags orders
 // @Param userId path int64 true "ID пользователя"
 // @Success 204
+// @Failure 500 {object} problem.Problem "Storage error"
 // @Router /orders/user/{userId} [delete]
 func (h *OrderHandler) DeleteOrdersByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidUserID)
+		return
+	}
+
+	// Ошибку отдаём наружу: на этот ответ опирается сага удаления пользователя
+	if err := h.repo.DeleteOrdersByUserID(r.Context(), userID); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	h.repo.DeleteOrdersByUserID(userID)
 	w.WriteHeader(http.StatusNoContent)
 }
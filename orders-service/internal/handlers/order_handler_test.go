@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"platform/problem"
+
+	"orders-service/internal/client"
+	"orders-service/internal/models"
+)
+
+func TestUpdateOrderIllegalTransition(t *testing.T) {
+	router := newTestRouter(nil)
+	createOrder(t, router)
+
+	w := serve(router, http.MethodPut, "/orders/1", `{"status": "completed"}`, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status %d, want 409", w.Code)
+	}
+	var p problem.Problem
+	decode(t, w, &p)
+	if p.Code != "illegal_transition" || !strings.Contains(p.Detail, `from "created" to "completed"`) || !strings.Contains(p.Detail, "processing") {
+		t.Fatalf("problem %+v", p)
+	}
+
+	w = serve(router, http.MethodPut, "/orders/1", `{"status": "compleded"}`, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("unknown status: status %d, want 409", w.Code)
+	}
+}
+
+func TestGetOrderHistory(t *testing.T) {
+	router := newTestRouter(nil)
+	createOrder(t, router)
+
+	for _, status := range []string{"processing", "completed"} {
+		w := serve(router, http.MethodPut, "/orders/1", `{"status": "`+status+`"}`, map[string]string{"X-Actor": "alice"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, body %s", status, w.Code, w.Body)
+		}
+	}
+
+	w := serve(router, http.MethodGet, "/orders/1/history", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	var history []models.StatusChange
+	decode(t, w, &history)
+
+	var got []string
+	for _, change := range history {
+		if change.Actor == "alice" {
+			got = append(got, change.From+"->"+change.To)
+		}
+	}
+	if strings.Join(got, " ") != "created->processing processing->completed" {
+		t.Fatalf("history %+v", history)
+	}
+
+	if w := serve(router, http.MethodGet, "/orders/2/history", "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("missing order: status %d, want 404", w.Code)
+	}
+}
+
+// checkoutOrder создаёт заказ и оформляет его, ожидая ответ wantStatus
+func checkoutOrder(t *testing.T, router http.Handler, wantStatus int) models.Order {
+	t.Helper()
+	createOrder(t, router)
+
+	w := serve(router, http.MethodPost, "/orders/1/checkout", `{"address": "Lenina 1, Moscow"}`, nil)
+	if w.Code != wantStatus {
+		t.Fatalf("checkout: status %d, want %d, body %s", w.Code, wantStatus, w.Body)
+	}
+	var order models.Order
+	decode(t, w, &order)
+	return order
+}
+
+func TestCheckoutSucceeds(t *testing.T) {
+	order := checkoutOrder(t, checkoutRouter(fakePayments{status: client.PaymentStatusCaptured}, fakeDeliveries{}), http.StatusOK)
+	if order.Status != models.OrderStatusProcessing {
+		t.Fatalf("order status %q, want processing", order.Status)
+	}
+}
+
+func TestCheckoutPaymentDeclined(t *testing.T) {
+	order := checkoutOrder(t, checkoutRouter(fakePayments{status: client.PaymentStatusFailed}, fakeDeliveries{}), http.StatusPaymentRequired)
+	if order.Status != models.OrderStatusCancelled || len(order.Checkout) == 0 {
+		t.Fatalf("order %+v, want cancelled with checkout steps", order)
+	}
+}
+
+func TestCheckoutDeliveryFailed(t *testing.T) {
+	failure := &client.ServiceError{Service: "delivery-service", Err: errors.New("dial tcp 10.0.0.5:8084: connection refused")}
+	order := checkoutOrder(t, checkoutRouter(fakePayments{status: client.PaymentStatusCaptured}, fakeDeliveries{err: failure}), http.StatusBadGateway)
+	if order.Status != models.OrderStatusCancelled {
+		t.Fatalf("order status %q, want cancelled", order.Status)
+	}
+
+	var step *models.CheckoutStep
+	for i := range order.Checkout {
+		if order.Checkout[i].Step == models.CheckoutStepCreateDelivery {
+			step = &order.Checkout[i]
+		}
+	}
+	if step == nil || step.Status != models.CheckoutStepFailed || step.Error != "delivery-service unavailable" {
+		t.Fatalf("checkout steps %+v, want create_delivery failed without address", order.Checkout)
+	}
+}
+
+func TestCreateOrderReportsFields(t *testing.T) {
+	body := `{"userId": 0, "items": [{"sku": "A-1", "name": "Book", "quantity": 0, "unitPrice": {"minor": -1, "currency": "RUB"}}]}`
+	w := serve(newTestRouter(nil), http.MethodPost, "/orders", body, nil)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var p problem.Problem
+	decode(t, w, &p)
+	got := map[string]string{}
+	for _, f := range p.Errors {
+		got[f.Field] = f.Rule
+	}
+	want := map[string]string{"userId": "required", "items[0].quantity": "required", "items[0].unitPrice": "min"}
+	if len(got) != len(want) {
+		t.Fatalf("errors %+v, want %v", p.Errors, want)
+	}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Fatalf("errors %+v, want %v", p.Errors, want)
+		}
+	}
+
+	w = serve(newTestRouter(nil), http.MethodPost, "/orders", `{"userId": 1, "items": [], "coupon": "X"}`, nil)
+	p = problem.Problem{}
+	decode(t, w, &p)
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "coupon" || p.Errors[0].Rule != "unknown" {
+		t.Fatalf("unknown field: status %d, errors %+v", w.Code, p.Errors)
+	}
+}
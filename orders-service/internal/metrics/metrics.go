@@ -0,0 +1,36 @@
+// Package metrics - доменные метрики orders-service. Метрики HTTP, клиентов
+// и хранилища собирает platform/metrics, отдаются все вместе на /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Исходы оформления заказа
+const (
+	CheckoutCompleted       = "completed"
+	CheckoutPaymentDeclined = "payment_declined"
+	CheckoutFailed          = "failed"
+)
+
+var (
+	orders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_total",
+		Help: "Orders created and moved to a status, by resulting status.",
+	}, []string{"status"})
+	checkouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "checkouts_total",
+		Help: "Finished checkouts by outcome (completed, payment_declined, failed).",
+	}, []string{"outcome"})
+)
+
+// OrderStatus учитывает заказ, созданный или переведённый в статус status
+func OrderStatus(status string) {
+	orders.WithLabelValues(status).Inc()
+}
+
+// Checkout учитывает завершённое оформление с исходом outcome
+func Checkout(outcome string) {
+	checkouts.WithLabelValues(outcome).Inc()
+}
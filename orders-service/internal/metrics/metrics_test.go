@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCounters(t *testing.T) {
+	OrderStatus("created")
+	OrderStatus("created")
+	OrderStatus("cancelled")
+	Checkout(CheckoutPaymentDeclined)
+
+	if got := testutil.ToFloat64(orders.WithLabelValues("created")); got != 2 {
+		t.Errorf("created orders %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(orders.WithLabelValues("cancelled")); got != 1 {
+		t.Errorf("cancelled orders %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(checkouts.WithLabelValues(CheckoutPaymentDeclined)); got != 1 {
+		t.Errorf("declined checkouts %v, want 1", got)
+	}
+}
@@ -1,13 +1,262 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"platform/money"
+	"platform/problem"
+)
+
+// Статусы заказа
+const (
+	OrderStatusCreated    = "created"
+	OrderStatusProcessing = "processing"
+	OrderStatusCompleted  = "completed"
+	OrderStatusCancelled  = "cancelled"
+	OrderStatusFailed     = "failed"
+)
+
+// orderTransitions - автомат статусов: из какого статуса в какие можно перейти.
+// completed, cancelled и failed - конечные.
+var orderTransitions = map[string][]string{
+	OrderStatusCreated:    {OrderStatusProcessing, OrderStatusCancelled, OrderStatusFailed},
+	OrderStatusProcessing: {OrderStatusCompleted, OrderStatusCancelled, OrderStatusFailed},
+	OrderStatusCompleted:  {},
+	OrderStatusCancelled:  {},
+	OrderStatusFailed:     {},
+}
+
+// ErrIllegalTransition - переход не предусмотрен автоматом статусов
+var ErrIllegalTransition = problem.New(problem.Conflict, "illegal_transition", "illegal order status transition")
+
+// TransitionError описывает отклонённый переход и допустимые варианты
+type TransitionError struct {
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *TransitionError) Error() string {
+	if _, known := orderTransitions[e.To]; !known {
+		return fmt.Sprintf("unknown order status %q", e.To)
+	}
+	if len(e.Allowed) == 0 {
+		return fmt.Sprintf("order status %q is final, cannot change it to %q", e.From, e.To)
+	}
+	return fmt.Sprintf("cannot change order status from %q to %q, allowed: %s",
+		e.From, e.To, strings.Join(e.Allowed, ", "))
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// StatusChange - запись истории статусов заказа
+type StatusChange struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Actor string    `json:"actor"`
+	At    time.Time `json:"at"`
+}
+
+// Шаги оформления заказа (POST /orders/{id}/checkout) в порядке выполнения;
+// find_payments, refund/void/fail_payment и cancel_order - компенсации после сбоя
+const (
+	CheckoutStepStart           = "start"
+	CheckoutStepCreatePayment   = "create_payment"
+	CheckoutStepAwaitPayment    = "await_payment"
+	CheckoutStepStartProcessing = "start_processing"
+	CheckoutStepCreateDelivery  = "create_delivery"
+	CheckoutStepComplete        = "complete"
+
+	CheckoutStepFindPayments  = "find_payments"
+	CheckoutStepRefundPayment = "refund_payment"
+	CheckoutStepVoidPayment   = "void_payment"
+	CheckoutStepFailPayment   = "fail_payment"
+	CheckoutStepCancelOrder   = "cancel_order"
+)
+
+// Результат шага оформления
+const (
+	CheckoutStepSucceeded = "succeeded"
+	CheckoutStepFailed    = "failed"
+)
+
+// CheckoutStep - запись журнала оформления заказа
+type CheckoutStep struct {
+	Step       string    `json:"step"`
+	Status     string    `json:"status"` // succeeded, failed
+	PaymentID  int64     `json:"paymentId,omitempty"`
+	DeliveryID int64     `json:"deliveryId,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// ErrInvalidLineItem - позиция без SKU или названия, с количеством меньше
+// единицы, отрицательной ценой или скидкой больше стоимости позиции
+var ErrInvalidLineItem = problem.New(problem.Invalid, "invalid_line_item", "invalid line item")
+
+// LineItem - позиция заказа. Total считает сервер: Quantity*UnitPrice - Discount.
+// Все суммы заказа - в одной валюте.
+type LineItem struct {
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unitPrice"`
+	Discount  money.Money `json:"discount"` // скидка на всю позицию, а не на единицу
+	Total     money.Money `json:"total"`
+}
+
+// UnmarshalJSON читает и позиции-строки из заказов, созданных до появления
+// позиций: строка становится названием, количество - 1, цена неизвестна (0)
+func (i *LineItem) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*i = LineItem{Name: name, Quantity: 1}
+		return nil
+	}
+
+	type plain LineItem
+	return json.Unmarshal(data, (*plain)(i))
+}
+
+// Totals - суммы заказа, рассчитанные по позициям
+type Totals struct {
+	Subtotal money.Money // сумма Quantity*UnitPrice по позициям
+	Discount money.Money // сумма скидок по позициям
+	Total    money.Money // Subtotal - Discount
+}
+
+// PriceItems проверяет позиции и считает итог каждой и суммы заказа.
+// Позиции в разных валютах - ErrInvalidLineItem и money.ErrCurrencyMismatch,
+// суммы, не помещающиеся в int64, - ErrInvalidLineItem и money.ErrOverflow.
+func PriceItems(items []LineItem) ([]LineItem, Totals, error) {
+	if len(items) == 0 {
+		return nil, Totals{}, fmt.Errorf("%w: order has no items", ErrInvalidLineItem)
+	}
+
+	priced := make([]LineItem, len(items))
+	var subtotal, discount money.Money
+	for n, item := range items {
+		if item.SKU == "" || item.Name == "" {
+			return nil, Totals{}, fmt.Errorf("%w: item %d: sku and name are required", ErrInvalidLineItem, n+1)
+		}
+		if item.Quantity < 1 || item.UnitPrice.IsNegative() || item.Discount.IsNegative() {
+			return nil, Totals{}, fmt.Errorf("%w: item %d: quantity must be positive, price and discount not negative",
+				ErrInvalidLineItem, n+1)
+		}
+
+		gross, err := item.UnitPrice.Mul(int64(item.Quantity))
+		var total money.Money
+		if err == nil {
+			total, err = gross.Sub(item.Discount)
+		}
+		if err == nil {
+			subtotal, err = subtotal.Add(gross)
+		}
+		if err == nil {
+			discount, err = discount.Add(item.Discount)
+		}
+		if err != nil {
+			return nil, Totals{}, fmt.Errorf("%w: item %d: %w", ErrInvalidLineItem, n+1, err)
+		}
+		if total.IsNegative() {
+			return nil, Totals{}, fmt.Errorf("%w: item %d: discount %s exceeds line amount %s",
+				ErrInvalidLineItem, n+1, item.Discount, gross)
+		}
+
+		item.Total = total
+		priced[n] = item
+	}
+
+	// Бесплатные позиции без цены получают валюту заказа
+	currency := subtotal.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	for n := range priced {
+		priced[n].UnitPrice = inCurrency(priced[n].UnitPrice, currency)
+		priced[n].Discount = inCurrency(priced[n].Discount, currency)
+		priced[n].Total = inCurrency(priced[n].Total, currency)
+	}
+
+	total, _ := subtotal.Sub(discount)
+	return priced, Totals{
+		Subtotal: inCurrency(subtotal, currency),
+		Discount: inCurrency(discount, currency),
+		Total:    inCurrency(total, currency),
+	}, nil
+}
+
+// inCurrency проставляет валюту сумме без валюты; такая сумма всегда нулевая
+func inCurrency(m money.Money, currency string) money.Money {
+	if m.Currency == "" {
+		m.Currency = currency
+	}
+	return m
+}
 
 type Order struct {
-	ID          int64     `json:"id"`
-	UserID      int64     `json:"userId"`
-	Items       []string  `json:"items"`
-	TotalAmount float64   `json:"totalAmount"`
-	Status      string    `json:"status"` // created, processing, completed
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          int64          `json:"id"`
+	UserID      int64          `json:"userId"`
+	Items       []LineItem     `json:"items"`
+	Subtotal    money.Money    `json:"subtotal"`
+	Discount    money.Money    `json:"discount"`
+	TotalAmount money.Money    `json:"totalAmount"` // Subtotal - Discount, считает сервер
+	Status      string         `json:"status"`      // created, processing, completed, cancelled, failed
+	Version     int64          `json:"version"`     // растёт при каждом изменении, отдаётся как ETag
+	History     []StatusChange `json:"history,omitempty"`
+	Checkout    []CheckoutStep `json:"checkout,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+}
+
+// Transition переводит заказ в статус to и дописывает переход в историю.
+// Недопустимый переход возвращает *TransitionError.
+func (o *Order) Transition(to, actor string, at time.Time) error {
+	allowed := orderTransitions[o.Status]
+	for _, status := range allowed {
+		if status == to {
+			o.History = append(o.History, StatusChange{From: o.Status, To: to, Actor: actor, At: at})
+			o.Status = to
+			o.UpdatedAt = at
+			return nil
+		}
+	}
+
+	return &TransitionError{From: o.Status, To: to, Allowed: allowed}
+}
+
+// UpgradeLegacy дополняет заказ, сохранённый до появления позиций: итог
+// тогда задавал клиент, скидок не было, поэтому подытог равен итогу
+func (o *Order) UpgradeLegacy() {
+	if o.Subtotal.IsZero() && o.Discount.IsZero() {
+		o.Subtotal = o.TotalAmount
+	}
+
+	// Суммы, которых не было в старой записи, получают валюту заказа
+	currency := o.TotalAmount.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	o.TotalAmount = inCurrency(o.TotalAmount, currency)
+	o.Subtotal = inCurrency(o.Subtotal, currency)
+	o.Discount = inCurrency(o.Discount, currency)
+	for n := range o.Items {
+		o.Items[n].UnitPrice = inCurrency(o.Items[n].UnitPrice, currency)
+		o.Items[n].Discount = inCurrency(o.Items[n].Discount, currency)
+		o.Items[n].Total = inCurrency(o.Items[n].Total, currency)
+	}
+}
+
+// Clone возвращает независимую копию заказа
+func (o *Order) Clone() *Order {
+	clone := *o
+	clone.Items = append([]LineItem(nil), o.Items...)
+	clone.History = append([]StatusChange(nil), o.History...)
+	clone.Checkout = append([]CheckoutStep(nil), o.Checkout...)
+	return &clone
 }
@@ -0,0 +1,514 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"platform/money"
+	"platform/pagination"
+
+	"orders-service/internal/models"
+)
+
+// Общий набор проверок, который обязан проходить каждый бэкенд OrderRepository
+
+func TestJSONOrderRepositoryConformance(t *testing.T) {
+	runOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		repo, err := NewJSONOrderRepository(filepath.Join(t.TempDir(), "orders.json"))
+		if err != nil {
+			t.Fatalf("NewJSONOrderRepository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestMemoryOrderRepositoryConformance(t *testing.T) {
+	runOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		return NewMemoryOrderRepository()
+	})
+}
+
+func TestTracedOrderRepositoryConformance(t *testing.T) {
+	runOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		return NewTracedOrderRepository(NewMemoryOrderRepository())
+	})
+}
+
+func TestSQLiteOrderRepositoryConformance(t *testing.T) {
+	runOrderRepositoryConformance(t, func(t *testing.T) OrderRepository {
+		repo, err := NewSQLiteOrderRepository(filepath.Join(t.TempDir(), "orders.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteOrderRepository: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+// lineItems - по одной единице каждого sku с ценой price
+func lineItems(price float64, skus ...string) []models.LineItem {
+	items := make([]models.LineItem, len(skus))
+	for n, sku := range skus {
+		items[n] = models.LineItem{SKU: sku, Name: sku, Quantity: 1, UnitPrice: rub(price)}
+	}
+	return items
+}
+
+// rub - сумма в рублях
+func rub(amount float64) money.Money {
+	return money.FromMajor(amount, "RUB")
+}
+
+// legacyOrders - orders.json времён, когда позиции были строками, а итог задавал клиент
+const legacyOrders = `[{"id":1,"userId":7,"items":["book","pen"],"totalAmount":120.5,"status":"created",` +
+	`"createdAt":"2024-01-02T03:04:05Z","updatedAt":"2024-01-02T03:04:05Z"}]`
+
+func assertLegacyOrder(t *testing.T, order *models.Order) {
+	t.Helper()
+
+	if len(order.Items) != 2 || order.Items[0].Name != "book" || order.Items[0].Quantity != 1 ||
+		order.Items[1].Name != "pen" {
+		t.Fatalf("legacy items read as %+v", order.Items)
+	}
+	if order.TotalAmount != rub(120.5) || order.Subtotal != rub(120.5) || order.Discount != rub(0) {
+		t.Fatalf("legacy totals: subtotal %v, discount %v, total %v", order.Subtotal, order.Discount, order.TotalAmount)
+	}
+}
+
+func TestJSONOrderRepositoryReadsLegacyItems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.json")
+	if err := os.WriteFile(path, []byte(legacyOrders), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewJSONOrderRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONOrderRepository: %v", err)
+	}
+	order, err := repo.GetOrderByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	assertLegacyOrder(t, order)
+}
+
+func TestJSONOrderRepositoryKeepsStateOnStorageError(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewJSONOrderRepository(filepath.Join(t.TempDir(), "orders.json"))
+	if err != nil {
+		t.Fatalf("NewJSONOrderRepository: %v", err)
+	}
+	order, _ := repo.CreateOrder(ctx, 1, lineItems(10, "a"))
+	// Закрытое хранилище отклоняет запись в журнал
+	repo.storage.Close()
+
+	if _, err := repo.CreateOrder(ctx, 1, lineItems(20, "b")); err == nil {
+		t.Fatal("CreateOrder succeeded with failing storage")
+	}
+	if orders, _ := repo.GetOrdersByUserID(ctx, 1); len(orders) != 1 {
+		t.Fatalf("%d orders in memory, want only the persisted one", len(orders))
+	}
+	if _, err := repo.UpdateOrderStatus(ctx, order.ID, "processing", "tester", 0); err == nil {
+		t.Fatal("UpdateOrderStatus succeeded with failing storage")
+	}
+	if err := repo.DeleteOrder(ctx, order.ID, 0); err == nil {
+		t.Fatal("DeleteOrder succeeded with failing storage")
+	}
+	if err := repo.DeleteOrdersByUserID(ctx, 1); err == nil {
+		t.Fatal("DeleteOrdersByUserID succeeded with failing storage")
+	}
+
+	got, err := repo.GetOrderByID(ctx, order.ID)
+	if err != nil || got.Status != models.OrderStatusCreated || got.Version != 1 {
+		t.Fatalf("got %+v, %v; want the order as persisted", got, err)
+	}
+}
+
+func TestJSONOrderRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.json")
+
+	repo, err := NewJSONOrderRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONOrderRepository: %v", err)
+	}
+	first, _ := repo.CreateOrder(ctx, 1, lineItems(10, "a"))
+	second, _ := repo.CreateOrder(ctx, 1, lineItems(20, "b"))
+	repo.UpdateOrderStatus(ctx, first.ID, "processing", "tester", 0)
+	repo.DeleteOrder(ctx, second.ID, 0)
+
+	reopened, err := NewJSONOrderRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := reopened.GetOrderByID(ctx, first.ID)
+	if err != nil || got.Status != "processing" || got.Version != 2 {
+		t.Fatalf("got %+v, %v after reopen", got, err)
+	}
+	if _, err := reopened.GetOrderByID(ctx, second.ID); err == nil {
+		t.Fatalf("deleted order is back after reopen")
+	}
+
+	third, _ := reopened.CreateOrder(ctx, 1, lineItems(30, "c"))
+	if third.ID == first.ID {
+		t.Fatalf("id %d reused after reopen", third.ID)
+	}
+}
+
+func runOrderRepositoryConformance(t *testing.T, newRepo func(t *testing.T) OrderRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, err := repo.CreateOrder(ctx, 7, lineItems(75, "item1", "item2"))
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if order.ID == 0 || order.UserID != 7 || order.Status != "created" || order.TotalAmount != rub(150) ||
+			order.Subtotal != rub(150) || order.Items[1].Total != rub(75) {
+			t.Fatalf("unexpected order %+v", order)
+		}
+
+		got, err := repo.GetOrderByID(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrderByID: %v", err)
+		}
+		if got.ID != order.ID || len(got.Items) != 2 || got.CreatedAt.IsZero() {
+			t.Fatalf("unexpected order %+v", got)
+		}
+	})
+
+	t.Run("LineItemTotals", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, err := repo.CreateOrder(ctx, 1, []models.LineItem{
+			{SKU: "A-1", Name: "Notebook", Quantity: 3, UnitPrice: rub(0.1), Discount: rub(0.05)},
+			{SKU: "B-2", Name: "Pen", Quantity: 2, UnitPrice: rub(19.99)},
+		})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if order.Items[0].Total != rub(0.25) || order.Items[1].Total != rub(39.98) {
+			t.Fatalf("line totals %v, %v; want 0.25, 39.98", order.Items[0].Total, order.Items[1].Total)
+		}
+		if order.Subtotal != rub(40.28) || order.Discount != rub(0.05) || order.TotalAmount != rub(40.23) {
+			t.Fatalf("totals: subtotal %v, discount %v, total %v", order.Subtotal, order.Discount, order.TotalAmount)
+		}
+
+		got, _ := repo.GetOrderByID(ctx, order.ID)
+		if got.Items[0].SKU != "A-1" || got.Items[0].Quantity != 3 || got.Items[0].Discount != rub(0.05) ||
+			got.Subtotal != order.Subtotal || got.TotalAmount != order.TotalAmount {
+			t.Fatalf("stored order %+v differs from created %+v", got, order)
+		}
+
+		for _, items := range [][]models.LineItem{
+			nil,
+			{{SKU: "A-1", Name: "Notebook", Quantity: 0, UnitPrice: rub(1)}},
+			{{SKU: "A-1", Name: "Notebook", Quantity: 1, UnitPrice: rub(-1)}},
+			{{Name: "Notebook", Quantity: 1, UnitPrice: rub(1)}},
+			{{SKU: "A-1", Name: "Notebook", Quantity: 2, UnitPrice: rub(1), Discount: rub(2.01)}},
+			{{SKU: "A-1", Name: "Notebook", Quantity: 1 << 30, UnitPrice: money.New(1<<40, "RUB")}},
+			{
+				{SKU: "A-1", Name: "Notebook", Quantity: 1, UnitPrice: money.New(math.MaxInt64, "RUB")},
+				{SKU: "B-2", Name: "Pen", Quantity: 1, UnitPrice: rub(1)},
+			},
+			{
+				{SKU: "A-1", Name: "Notebook", Quantity: 1, UnitPrice: rub(1)},
+				{SKU: "B-2", Name: "Pen", Quantity: 1, UnitPrice: money.New(100, "USD")},
+			},
+		} {
+			if _, err := repo.CreateOrder(ctx, 1, items); !errors.Is(err, models.ErrInvalidLineItem) {
+				t.Fatalf("CreateOrder(%+v): error %v, want ErrInvalidLineItem", items, err)
+			}
+		}
+	})
+
+	t.Run("IDsAreUnique", func(t *testing.T) {
+		repo := newRepo(t)
+
+		a, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		b, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		if a.ID == b.ID {
+			t.Fatalf("duplicate id %d", a.ID)
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetOrderByID(ctx, 42); err == nil {
+			t.Fatal("expected error for missing order")
+		}
+	})
+
+	t.Run("GetByUserID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		repo.CreateOrder(ctx, 2, lineItems(1, "a"))
+		repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+
+		orders, err := repo.GetOrdersByUserID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetOrdersByUserID: %v", err)
+		}
+		if len(orders) != 2 {
+			t.Fatalf("got %d orders, want 2", len(orders))
+		}
+		for _, order := range orders {
+			if order.UserID != 1 {
+				t.Fatalf("order %d belongs to user %d", order.ID, order.UserID)
+			}
+		}
+
+		none, err := repo.GetOrdersByUserID(ctx, 3)
+		if err != nil || len(none) != 0 {
+			t.Fatalf("got %v, %v for user without orders", none, err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, userID := range []int64{1, 2, 1, 1, 2} {
+			if _, err := repo.CreateOrder(ctx, userID, lineItems(1, "a")); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+		if _, err := repo.UpdateOrderStatus(ctx, 3, "processing", "tester", 0); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		q := pagination.ListQuery{Limit: 2, Sort: pagination.SortByID, UserID: 1}
+		var got []int64
+		for pages := 0; ; pages++ {
+			if pages > 2 {
+				t.Fatalf("too many pages: %v", got)
+			}
+			page, err := repo.ListOrders(ctx, q)
+			if err != nil {
+				t.Fatalf("ListOrders: %v", err)
+			}
+			if page.Total != 3 {
+				t.Fatalf("total %d, want 3", page.Total)
+			}
+			for _, order := range page.Items {
+				got = append(got, order.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			last := page.Items[len(page.Items)-1]
+			q.After = &pagination.Cursor{Sort: q.Sort, ID: last.ID}
+		}
+		if len(got) != 3 || got[0] != 1 || got[1] != 3 || got[2] != 4 {
+			t.Fatalf("pages %v, want [1 3 4]", got)
+		}
+
+		page, err := repo.ListOrders(ctx, pagination.ListQuery{Limit: 10, Sort: pagination.SortByUpdatedAt, Desc: true, Status: "processing"})
+		if err != nil {
+			t.Fatalf("ListOrders: %v", err)
+		}
+		if page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID != 3 || page.NextCursor != "" {
+			t.Fatalf("page by status %+v", page)
+		}
+	})
+
+	t.Run("UpdateStatus", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		updated, err := repo.UpdateOrderStatus(ctx, order.ID, "processing", "tester", 0)
+		if err != nil {
+			t.Fatalf("UpdateOrderStatus: %v", err)
+		}
+		if updated.Status != "processing" {
+			t.Fatalf("status %q, want processing", updated.Status)
+		}
+
+		got, _ := repo.GetOrderByID(ctx, order.ID)
+		if got.Status != "processing" {
+			t.Fatalf("status %q not stored", got.Status)
+		}
+
+		if _, err := repo.UpdateOrderStatus(ctx, 42, "processing", "tester", 0); err == nil {
+			t.Fatal("expected error for missing order")
+		}
+	})
+
+	t.Run("StatusTransitions", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		repo.UpdateOrderStatus(ctx, order.ID, "processing", "alice", 0)
+		done, err := repo.UpdateOrderStatus(ctx, order.ID, "completed", "bob", 0)
+		if err != nil {
+			t.Fatalf("processing -> completed: %v", err)
+		}
+
+		for _, status := range []string{"created", "processing", "compleded"} {
+			_, err := repo.UpdateOrderStatus(ctx, order.ID, status, "mallory", 0)
+			var transitionErr *models.TransitionError
+			if !errors.Is(err, models.ErrIllegalTransition) || !errors.As(err, &transitionErr) {
+				t.Fatalf("completed -> %s: error %v, want illegal transition", status, err)
+			}
+		}
+
+		got, _ := repo.GetOrderByID(ctx, order.ID)
+		if got.Status != "completed" || got.Version != done.Version {
+			t.Fatalf("rejected transition changed order: %+v", got)
+		}
+		if len(got.History) != 2 {
+			t.Fatalf("history %+v, want 2 entries", got.History)
+		}
+		first, second := got.History[0], got.History[1]
+		if first.From != "created" || first.To != "processing" || first.Actor != "alice" || first.At.IsZero() {
+			t.Fatalf("unexpected first history entry %+v", first)
+		}
+		if second.From != "processing" || second.To != "completed" || second.Actor != "bob" || second.At.Before(first.At) {
+			t.Fatalf("unexpected second history entry %+v", second)
+		}
+
+		cancelled, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		if _, err := repo.UpdateOrderStatus(ctx, cancelled.ID, "cancelled", "alice", 0); err != nil {
+			t.Fatalf("created -> cancelled: %v", err)
+		}
+		if _, err := repo.UpdateOrderStatus(ctx, cancelled.ID, "processing", "alice", 0); !errors.Is(err, models.ErrIllegalTransition) {
+			t.Fatalf("cancelled -> processing: error %v, want illegal transition", err)
+		}
+	})
+
+	t.Run("CheckoutSteps", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		started, err := repo.AddCheckoutStep(ctx, order.ID,
+			models.CheckoutStep{Step: models.CheckoutStepStart, Status: models.CheckoutStepSucceeded}, order.Version)
+		if err != nil || started.Version != 2 || len(started.Checkout) != 1 || started.Checkout[0].At.IsZero() {
+			t.Fatalf("AddCheckoutStep = %+v, %v", started, err)
+		}
+
+		// Второе оформление с той же прочитанной версией не проходит
+		if _, err := repo.AddCheckoutStep(ctx, order.ID,
+			models.CheckoutStep{Step: models.CheckoutStepStart, Status: models.CheckoutStepSucceeded}, order.Version); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale AddCheckoutStep error %v, want ErrVersionMismatch", err)
+		}
+
+		repo.AddCheckoutStep(ctx, order.ID, models.CheckoutStep{
+			Step: models.CheckoutStepCreatePayment, Status: models.CheckoutStepFailed, PaymentID: 7, Error: "declined",
+		}, 0)
+		got, _ := repo.GetOrderByID(ctx, order.ID)
+		if len(got.Checkout) != 2 || got.Status != "created" {
+			t.Fatalf("order %+v, want 2 checkout steps", got)
+		}
+		if step := got.Checkout[1]; step.Step != "create_payment" || step.PaymentID != 7 || step.Error != "declined" {
+			t.Fatalf("unexpected checkout step %+v", step)
+		}
+
+		if _, err := repo.AddCheckoutStep(ctx, 999, models.CheckoutStep{Step: models.CheckoutStepStart}, 0); err == nil {
+			t.Fatal("expected error for missing order")
+		}
+	})
+
+	t.Run("DeleteOrder", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		if err := repo.DeleteOrder(ctx, order.ID, 0); err != nil {
+			t.Fatalf("DeleteOrder: %v", err)
+		}
+		if _, err := repo.GetOrderByID(ctx, order.ID); err == nil {
+			t.Fatal("order still exists after delete")
+		}
+		if err := repo.DeleteOrder(ctx, order.ID, 0); err == nil {
+			t.Fatal("expected error for second delete")
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		if order.Version != 1 {
+			t.Fatalf("new order version %d, want 1", order.Version)
+		}
+
+		updated, err := repo.UpdateOrderStatus(ctx, order.ID, "processing", "tester", 1)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("conditional update = %+v, %v; want version 2", updated, err)
+		}
+
+		// Устаревшая версия не перезаписывает чужое изменение
+		if _, err := repo.UpdateOrderStatus(ctx, order.ID, "completed", "tester", 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale update error %v, want ErrVersionMismatch", err)
+		}
+		if err := repo.DeleteOrder(ctx, order.ID, 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale delete error %v, want ErrVersionMismatch", err)
+		}
+		if got, _ := repo.GetOrderByID(ctx, order.ID); got.Status != "processing" || got.Version != 2 {
+			t.Fatalf("order changed by stale write: %+v", got)
+		}
+
+		if err := repo.DeleteOrder(ctx, order.ID, 2); err != nil {
+			t.Fatalf("conditional delete: %v", err)
+		}
+	})
+
+	t.Run("ConcurrentConditionalUpdates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		order, _ := repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.UpdateOrderStatus(ctx, order.ID, "processing", "tester", order.Version)
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				} else if !errors.Is(err, ErrVersionMismatch) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 1 {
+			t.Fatalf("%d updates of the same version succeeded, want 1", succeeded)
+		}
+	})
+
+	t.Run("DeleteByUserID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		repo.CreateOrder(ctx, 1, lineItems(1, "a"))
+		kept, _ := repo.CreateOrder(ctx, 2, lineItems(1, "a"))
+
+		if err := repo.DeleteOrdersByUserID(ctx, 1); err != nil {
+			t.Fatalf("DeleteOrdersByUserID: %v", err)
+		}
+		if orders, _ := repo.GetOrdersByUserID(ctx, 1); len(orders) != 0 {
+			t.Fatalf("%d orders left for deleted user", len(orders))
+		}
+		if _, err := repo.GetOrderByID(ctx, kept.ID); err != nil {
+			t.Fatalf("other user's order removed: %v", err)
+		}
+		if err := repo.DeleteOrdersByUserID(ctx, 1); err != nil {
+			t.Fatalf("DeleteOrdersByUserID without orders: %v", err)
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"platform/pagination"
+
+	"orders-service/internal/models"
+)
+
+func orderListKey(order *models.Order) pagination.ListKey {
+	return pagination.ListKey{
+		ID:        order.ID,
+		UserID:    order.UserID,
+		Status:    order.Status,
+		CreatedAt: order.CreatedAt,
+		UpdatedAt: order.UpdatedAt,
+	}
+}
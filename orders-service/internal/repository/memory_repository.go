@@ -0,0 +1,18 @@
+package repository
+
+import "orders-service/internal/models"
+
+// MemoryOrderRepository - репозиторий без записи на диск, для тестов и локального запуска
+type MemoryOrderRepository struct {
+	JSONOrderRepository
+}
+
+// NewMemoryOrderRepository создаёт пустой репозиторий в памяти
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{
+		JSONOrderRepository: JSONOrderRepository{
+			orders: make(map[int64]*models.Order),
+			nextID: 1,
+		},
+	}
+}
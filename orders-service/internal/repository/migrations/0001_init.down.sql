@@ -0,0 +1,3 @@
+DROP TABLE json_imports;
+DROP INDEX idx_orders_user_id;
+DROP TABLE orders;
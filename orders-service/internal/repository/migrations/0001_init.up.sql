@@ -0,0 +1,18 @@
+CREATE TABLE orders (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER NOT NULL,
+    items        TEXT    NOT NULL DEFAULT '[]',
+    total_amount REAL    NOT NULL DEFAULT 0,
+    status       TEXT    NOT NULL,
+    created_at   TEXT    NOT NULL,
+    updated_at   TEXT    NOT NULL
+);
+
+CREATE INDEX idx_orders_user_id ON orders (user_id);
+
+-- Какие JSON-файлы уже перенесены импортёром
+CREATE TABLE json_imports (
+    source      TEXT PRIMARY KEY,
+    records     INTEGER NOT NULL,
+    imported_at TEXT    NOT NULL
+);
@@ -0,0 +1,2 @@
+-- История переходов статуса: JSON-массив {from, to, actor, at}
+ALTER TABLE orders ADD COLUMN history TEXT NOT NULL DEFAULT '[]';
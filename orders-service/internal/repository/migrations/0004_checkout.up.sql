@@ -0,0 +1,2 @@
+-- Журнал оформления заказа: JSON-массив {step, status, paymentId, deliveryId, error, at}
+ALTER TABLE orders ADD COLUMN checkout TEXT NOT NULL DEFAULT '[]';
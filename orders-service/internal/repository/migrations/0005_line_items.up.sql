@@ -0,0 +1,6 @@
+-- Позиции заказа хранятся в items JSON-массивом {sku, name, quantity, unitPrice, discount, total};
+-- старые строки читаются как позиции с одним названием.
+-- Итог старых заказов задавал клиент без скидок, поэтому подытог равен итогу.
+ALTER TABLE orders ADD COLUMN subtotal REAL NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount REAL NOT NULL DEFAULT 0;
+UPDATE orders SET subtotal = total_amount;
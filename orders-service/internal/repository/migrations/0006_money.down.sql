@@ -0,0 +1,13 @@
+ALTER TABLE orders ADD COLUMN subtotal REAL NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount REAL NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total_amount REAL NOT NULL DEFAULT 0;
+
+UPDATE orders SET
+    subtotal = subtotal_minor / 100.0,
+    discount = discount_minor / 100.0,
+    total_amount = total_minor / 100.0;
+
+ALTER TABLE orders DROP COLUMN total_minor;
+ALTER TABLE orders DROP COLUMN discount_minor;
+ALTER TABLE orders DROP COLUMN subtotal_minor;
+ALTER TABLE orders DROP COLUMN currency;
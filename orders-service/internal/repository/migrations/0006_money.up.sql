@@ -0,0 +1,16 @@
+-- Суммы хранятся целым числом минимальных единиц валюты (копеек) и кодом валюты ISO 4217.
+-- Все суммы до появления валют - в рублях. Цены позиций в items остаются числами
+-- и читаются как рубли, новые позиции пишутся как {"minor", "currency"}.
+ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE orders ADD COLUMN subtotal_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total_minor INTEGER NOT NULL DEFAULT 0;
+
+UPDATE orders SET
+    subtotal_minor = CAST(ROUND(subtotal * 100) AS INTEGER),
+    discount_minor = CAST(ROUND(discount * 100) AS INTEGER),
+    total_minor = CAST(ROUND(total_amount * 100) AS INTEGER);
+
+ALTER TABLE orders DROP COLUMN subtotal;
+ALTER TABLE orders DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN total_amount;
@@ -1,27 +1,36 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
+	"platform/pagination"
+	"platform/storage"
+
 	"orders-service/internal/models"
-	"orders-service/internal/utils"
 )
 
-// JSONOrderRepository - репозиторий с JSON-хранилищем
+// JSONOrderRepository - репозиторий с JSON-хранилищем.
+//
+// Блокировки: mu защищает карту и nextID (чтение под RLock, изменения под
+// Lock), persistMu упорядочивает запись в журнал. Изменение захватывает
+// persistMu до того, как отпустить mu, поэтому записи попадают в журнал в
+// том же порядке, что и в карту, а читатели не ждут fsync. Наружу отдаются
+// только копии заказов.
 type JSONOrderRepository struct {
-	mu       sync.RWMutex
-	storage  *utils.FileStorage
-	orders   map[int64]*models.Order
-	nextID   int64
-	filePath string
+	mu        sync.RWMutex
+	persistMu sync.Mutex
+	storage   *storage.FileStorage
+	orders    map[int64]*models.Order
+	nextID    int64
+	filePath  string
 }
 
 // NewJSONOrderRepository создаёт новый репозиторий
 func NewJSONOrderRepository(filePath string) (*JSONOrderRepository, error) {
 	repo := &JSONOrderRepository{
-		storage:  utils.NewFileStorage(filePath),
+		storage:  storage.NewFileStorage(filePath),
 		orders:   make(map[int64]*models.Order),
 		nextID:   1,
 		filePath: filePath,
@@ -40,18 +49,27 @@ func NewJSONOrderRepository(filePath string) (*JSONOrderRepository, error) {
 
 // LoadFromFile загружает заказы из JSON
 func (r *JSONOrderRepository) LoadFromFile() error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+	if r.storage == nil {
+		return nil
+	}
 
 	var orders []*models.Order
 	if err := r.storage.LoadJSON(&orders); err != nil {
 		return err
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.orders = make(map[int64]*models.Order)
 	r.nextID = 1
 
 	for _, order := range orders {
+		// Записи, сохранённые до появления версий, начинают с первой
+		if order.Version == 0 {
+			order.Version = 1
+		}
+		order.UpgradeLegacy()
 		r.orders[order.ID] = order
 		if order.ID >= r.nextID {
 			r.nextID = order.ID + 1
@@ -61,66 +79,171 @@ func (r *JSONOrderRepository) LoadFromFile() error {
 	return nil
 }
 
-// SaveToFile сохраняет заказы в JSON
+// SaveToFile сохраняет полный снимок заказов в JSON
 func (r *JSONOrderRepository) SaveToFile() error {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+	if r.storage == nil {
+		return nil
+	}
 
+	r.mu.RLock()
 	orders := make([]*models.Order, 0, len(r.orders))
 	for _, order := range r.orders {
-		orders = append(orders, order)
+		orders = append(orders, order.Clone())
 	}
 
+	// Держим очередь журнала до конца записи снимка: иначе более позднее
+	// изменение попадёт в журнал и будет стёрто при его очистке
+	r.persistMu.Lock()
+	r.mu.RUnlock()
+	defer r.persistMu.Unlock()
+
 	return r.storage.SaveJSON(orders)
 }
 
+// Close дожидается записи в журнал и сворачивает его в снимок; изменения
+// после этого не сохраняются (storage.ErrClosed)
+func (r *JSONOrderRepository) Close() error {
+	if r.storage == nil {
+		return nil
+	}
+
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	return r.storage.Close()
+}
+
+// unlockAndPersist отпускает mu и дописывает updated в журнал. Если запись
+// не удалась, в карту возвращается previous (nil - заказа не было), чтобы
+// память не расходилась с журналом.
+func (r *JSONOrderRepository) unlockAndPersist(previous, updated *models.Order) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
+
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	err := r.storage.Put(updated.ID, updated)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	// mu берётся после persistMu: изменения захватывают их в обратном порядке.
+	// Запись, которую успели изменить снова, не откатывается.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.orders[updated.ID]; ok && current.Version == updated.Version {
+		if previous == nil {
+			delete(r.orders, updated.ID)
+		} else {
+			r.orders[updated.ID] = previous
+		}
+	}
+	return err
+}
+
+// unlockAndPersistDelete отпускает mu и дописывает удаление в журнал; если
+// запись не удалась, удалённые заказы возвращаются в карту
+func (r *JSONOrderRepository) unlockAndPersistDelete(deleted ...*models.Order) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
+
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	ids := make([]int64, len(deleted))
+	for i, order := range deleted {
+		ids[i] = order.ID
+	}
+	err := r.storage.Delete(ids...)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, order := range deleted {
+		r.orders[order.ID] = order
+	}
+	return err
+}
+
 // CreateOrder создаёт новый заказ (с проверкой пользователя через HTTP)
-func (r *JSONOrderRepository) CreateOrder(userID int64, items []string, totalAmount float64) (*models.Order, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONOrderRepository) CreateOrder(ctx context.Context, userID int64, items []models.LineItem) (*models.Order, error) {
+	priced, totals, err := models.PriceItems(items)
+	if err != nil {
+		return nil, err
+	}
 
+	now := time.Now()
 	order := &models.Order{
-		ID:          r.nextID,
 		UserID:      userID,
-		Items:       items,
-		TotalAmount: totalAmount,
-		Status:      "created",
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Items:       priced,
+		Subtotal:    totals.Subtotal,
+		Discount:    totals.Discount,
+		TotalAmount: totals.Total,
+		Status:      models.OrderStatusCreated,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
+	r.mu.Lock()
+	order.ID = r.nextID
 	r.orders[order.ID] = order
 	r.nextID++
+	created := order.Clone()
 
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(nil, created); err != nil {
 		return nil, err
 	}
 
-	return order, nil
+	return created, nil
 }
 
 // GetOrderByID получает заказ по ID
-func (r *JSONOrderRepository) GetOrderByID(id int64) (*models.Order, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONOrderRepository) GetOrderByID(ctx context.Context, id int64) (*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	order, exists := r.orders[id]
 	if !exists {
-		return nil, fmt.Errorf("order not found")
+		return nil, ErrOrderNotFound
 	}
 
-	return order, nil
+	return order.Clone(), nil
+}
+
+// ListOrders возвращает страницу заказов
+func (r *JSONOrderRepository) ListOrders(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Order], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := make([]*models.Order, 0, len(r.orders))
+	for _, order := range r.orders {
+		orders = append(orders, order)
+	}
+
+	page := pagination.Paginate(orders, q, orderListKey)
+	for i, order := range page.Items {
+		page.Items[i] = order.Clone()
+	}
+	return page, nil
 }
 
 // GetOrdersByUserID получает все заказы пользователя
-func (r *JSONOrderRepository) GetOrdersByUserID(userID int64) ([]*models.Order, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONOrderRepository) GetOrdersByUserID(ctx context.Context, userID int64) ([]*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	var userOrders []*models.Order
 	for _, order := range r.orders {
 		if order.UserID == userID {
-			userOrders = append(userOrders, order)
+			userOrders = append(userOrders, order.Clone())
 		}
 	}
 
@@ -128,58 +251,102 @@ func (r *JSONOrderRepository) GetOrdersByUserID(userID int64) ([]*models.Order,
 }
 
 // DeleteOrdersByUserID удаляет все заказы пользователя
-func (r *JSONOrderRepository) DeleteOrdersByUserID(userID int64) error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONOrderRepository) DeleteOrdersByUserID(ctx context.Context, userID int64) error {
+	r.mu.Lock()
 
-	ordersToDelete := []int64{}
-	for id, order := range r.orders {
+	ordersToDelete := []*models.Order{}
+	for _, order := range r.orders {
 		if order.UserID == userID {
-			ordersToDelete = append(ordersToDelete, id)
+			ordersToDelete = append(ordersToDelete, order)
 		}
 	}
 
-	for _, id := range ordersToDelete {
-		delete(r.orders, id)
+	for _, order := range ordersToDelete {
+		delete(r.orders, order.ID)
 	}
 
-	if len(ordersToDelete) > 0 {
-		return r.SaveToFile()
+	if len(ordersToDelete) == 0 {
+		r.mu.Unlock()
+		return nil
 	}
 
-	return nil
+	return r.unlockAndPersistDelete(ordersToDelete...)
 }
 
 // DeleteOrder удаляет один заказ
-func (r *JSONOrderRepository) DeleteOrder(id int64) error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONOrderRepository) DeleteOrder(ctx context.Context, id int64, expectedVersion int64) error {
+	r.mu.Lock()
 
-	if _, exists := r.orders[id]; !exists {
-		return fmt.Errorf("order not found")
+	order, exists := r.orders[id]
+	if !exists {
+		r.mu.Unlock()
+		return ErrOrderNotFound
+	}
+	if expectedVersion != 0 && order.Version != expectedVersion {
+		r.mu.Unlock()
+		return ErrVersionMismatch
 	}
 
 	delete(r.orders, id)
 
-	return r.SaveToFile()
+	return r.unlockAndPersistDelete(order)
+}
+
+// UpdateOrderStatus переводит заказ в новый статус
+func (r *JSONOrderRepository) UpdateOrderStatus(ctx context.Context, id int64, status, actor string, expectedVersion int64) (*models.Order, error) {
+	r.mu.Lock()
+
+	order, exists := r.orders[id]
+	if !exists {
+		r.mu.Unlock()
+		return nil, ErrOrderNotFound
+	}
+	if expectedVersion != 0 && order.Version != expectedVersion {
+		r.mu.Unlock()
+		return nil, ErrVersionMismatch
+	}
+	previous := order.Clone()
+
+	if err := order.Transition(status, actor, time.Now()); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	order.Version++
+	updated := order.Clone()
+
+	if err := r.unlockAndPersist(previous, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
 }
 
-// UpdateOrderStatus обновляет статус заказа
-func (r *JSONOrderRepository) UpdateOrderStatus(id int64, status string) (*models.Order, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+// AddCheckoutStep дописывает шаг в журнал оформления заказа
+func (r *JSONOrderRepository) AddCheckoutStep(ctx context.Context, id int64, step models.CheckoutStep, expectedVersion int64) (*models.Order, error) {
+	r.mu.Lock()
 
 	order, exists := r.orders[id]
 	if !exists {
-		return nil, fmt.Errorf("order not found")
+		r.mu.Unlock()
+		return nil, ErrOrderNotFound
+	}
+	if expectedVersion != 0 && order.Version != expectedVersion {
+		r.mu.Unlock()
+		return nil, ErrVersionMismatch
 	}
+	previous := order.Clone()
 
-	order.Status = status
-	order.UpdatedAt = time.Now()
+	if step.At.IsZero() {
+		step.At = time.Now()
+	}
+	order.Checkout = append(order.Checkout, step)
+	order.UpdatedAt = step.At
+	order.Version++
+	updated := order.Clone()
 
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(previous, updated); err != nil {
 		return nil, err
 	}
 
-	return order, nil
+	return updated, nil
 }
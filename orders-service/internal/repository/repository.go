@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"platform/pagination"
+	"platform/problem"
+	"platform/storage"
+
+	"orders-service/internal/models"
+)
+
+var (
+	// ErrVersionMismatch - запись изменилась с тех пор, как клиент её прочитал
+	ErrVersionMismatch = storage.ErrVersionMismatch
+	// ErrOrderNotFound - заказа нет
+	ErrOrderNotFound = problem.New(problem.NotFound, "order_not_found", "order not found")
+)
+
+// OrderRepository - хранилище заказов, от которого зависят обработчики.
+// Реализации: JSONOrderRepository (файл), MemoryOrderRepository (память),
+// SQLiteOrderRepository (встроенная база).
+type OrderRepository interface {
+	// CreateOrder создаёт заказ из позиций и сам считает суммы;
+	// некорректная позиция - models.ErrInvalidLineItem
+	CreateOrder(ctx context.Context, userID int64, items []models.LineItem) (*models.Order, error)
+	GetOrderByID(ctx context.Context, id int64) (*models.Order, error)
+	GetOrdersByUserID(ctx context.Context, userID int64) ([]*models.Order, error)
+	// ListOrders возвращает страницу заказов по статусу, пользователю и датам
+	ListOrders(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Order], error)
+	// UpdateOrderStatus переводит заказ по автомату статусов и пишет переход
+	// в историю от имени actor; недопустимый переход - models.ErrIllegalTransition.
+	// expectedVersion != 0 - изменить, только если версия заказа совпадает,
+	// иначе ErrVersionMismatch
+	UpdateOrderStatus(ctx context.Context, id int64, status, actor string, expectedVersion int64) (*models.Order, error)
+	// AddCheckoutStep дописывает шаг в журнал оформления заказа.
+	// expectedVersion != 0 - только если версия заказа совпадает, иначе ErrVersionMismatch
+	AddCheckoutStep(ctx context.Context, id int64, step models.CheckoutStep, expectedVersion int64) (*models.Order, error)
+	DeleteOrder(ctx context.Context, id int64, expectedVersion int64) error
+	DeleteOrdersByUserID(ctx context.Context, userID int64) error
+	// Close дописывает незавершённые изменения и освобождает хранилище
+	Close() error
+}
+
+var (
+	_ OrderRepository = (*JSONOrderRepository)(nil)
+	_ OrderRepository = (*MemoryOrderRepository)(nil)
+	_ OrderRepository = (*SQLiteOrderRepository)(nil)
+)
@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"platform/money"
+	"platform/pagination"
+	"platform/storage"
+
+	"orders-service/internal/models"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations - версионированные миграции схемы SQLite
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrationFiles, "migrations")
+	return sub
+}
+
+// SQLiteOrderRepository - репозиторий заказов во встроенной базе SQLite
+type SQLiteOrderRepository struct {
+	db *sql.DB
+}
+
+const orderColumns = `id, user_id, items, subtotal_minor, discount_minor, total_minor, currency, status, version, history, checkout, created_at, updated_at`
+
+// NewSQLiteOrderRepository открывает базу и применяет недостающие миграции
+func NewSQLiteOrderRepository(dsn string) (*SQLiteOrderRepository, error) {
+	db, err := storage.OpenSQLite(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.Migrate(context.Background(), db, Migrations()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteOrderRepository{db: db}, nil
+}
+
+// Close закрывает соединение с базой
+func (r *SQLiteOrderRepository) Close() error {
+	return r.db.Close()
+}
+
+// CreateOrder создаёт новый заказ
+func (r *SQLiteOrderRepository) CreateOrder(ctx context.Context, userID int64, items []models.LineItem) (*models.Order, error) {
+	priced, totals, err := models.PriceItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &models.Order{
+		UserID:      userID,
+		Items:       priced,
+		Subtotal:    totals.Subtotal,
+		Discount:    totals.Discount,
+		TotalAmount: totals.Total,
+		Status:      models.OrderStatusCreated,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	itemsJSON, err := json.Marshal(order.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO orders (user_id, items, subtotal_minor, discount_minor, total_minor, currency, status, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.UserID, string(itemsJSON), order.Subtotal.Minor, order.Discount.Minor, order.TotalAmount.Minor,
+		order.TotalAmount.Currency, order.Status, order.Version,
+		storage.FormatTime(order.CreatedAt), storage.FormatTime(order.UpdatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	order.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetOrderByID получает заказ по ID
+func (r *SQLiteOrderRepository) GetOrderByID(ctx context.Context, id int64) (*models.Order, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, id)
+
+	order, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// ListOrders возвращает страницу заказов
+func (r *SQLiteOrderRepository) ListOrders(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Order], error) {
+	filter, filterArgs, page, pageArgs := storage.ListSQL(q)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders`+filter, filterArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+orderColumns+` FROM orders`+page, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(orders, q, total, orderListKey), nil
+}
+
+// GetOrdersByUserID получает все заказы пользователя
+func (r *SQLiteOrderRepository) GetOrdersByUserID(ctx context.Context, userID int64) ([]*models.Order, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// UpdateOrderStatus переводит заказ в новый статус
+func (r *SQLiteOrderRepository) UpdateOrderStatus(ctx context.Context, id int64, status, actor string, expectedVersion int64) (*models.Order, error) {
+	err := storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		order, err := scanOrder(tx.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && order.Version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		if err := order.Transition(status, actor, time.Now()); err != nil {
+			return err
+		}
+		historyJSON, err := json.Marshal(order.History)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE orders SET status = ?, history = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+			order.Status, string(historyJSON), storage.FormatTime(order.UpdatedAt), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetOrderByID(ctx, id)
+}
+
+// AddCheckoutStep дописывает шаг в журнал оформления заказа
+func (r *SQLiteOrderRepository) AddCheckoutStep(ctx context.Context, id int64, step models.CheckoutStep, expectedVersion int64) (*models.Order, error) {
+	err := storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		order, err := scanOrder(tx.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = ?`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && order.Version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		if step.At.IsZero() {
+			step.At = time.Now()
+		}
+		checkoutJSON, err := json.Marshal(append(order.Checkout, step))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE orders SET checkout = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+			string(checkoutJSON), storage.FormatTime(step.At), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetOrderByID(ctx, id)
+}
+
+// DeleteOrder удаляет один заказ
+func (r *SQLiteOrderRepository) DeleteOrder(ctx context.Context, id int64, expectedVersion int64) error {
+	return storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		if err := checkOrderVersion(ctx, tx, id, expectedVersion); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE id = ?`, id)
+		return err
+	})
+}
+
+// DeleteOrdersByUserID удаляет все заказы пользователя
+func (r *SQLiteOrderRepository) DeleteOrdersByUserID(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM orders WHERE user_id = ?`, userID)
+	return err
+}
+
+// ImportJSON один раз переносит заказы из JSON-хранилища в базу.
+// Повторный вызов для того же файла ничего не делает и возвращает 0.
+func (r *SQLiteOrderRepository) ImportJSON(ctx context.Context, filePath string) (int, error) {
+	var imported bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM json_imports WHERE source = ?)`, filePath).Scan(&imported)
+	if err != nil || imported {
+		return 0, err
+	}
+
+	var orders []*models.Order
+	if err := storage.NewFileStorage(filePath).LoadJSON(&orders); err != nil {
+		return 0, err
+	}
+
+	err = storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, order := range orders {
+			order.UpgradeLegacy()
+			itemsJSON, err := json.Marshal(order.Items)
+			if err != nil {
+				return err
+			}
+			historyJSON, err := json.Marshal(order.History)
+			if err != nil {
+				return err
+			}
+			checkoutJSON, err := json.Marshal(order.Checkout)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO orders (`+orderColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				order.ID, order.UserID, string(itemsJSON), order.Subtotal.Minor, order.Discount.Minor, order.TotalAmount.Minor,
+				order.TotalAmount.Currency, order.Status, max(order.Version, 1),
+				string(historyJSON), string(checkoutJSON), storage.FormatTime(order.CreatedAt), storage.FormatTime(order.UpdatedAt))
+			if err != nil {
+				return fmt.Errorf("import order %d: %w", order.ID, err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO json_imports (source, records, imported_at) VALUES (?, ?, ?)`,
+			filePath, len(orders), storage.FormatTime(time.Now()))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(orders), nil
+}
+
+// checkOrderVersion проверяет, что заказ есть и, если версия задана, не изменился
+func checkOrderVersion(ctx context.Context, tx *sql.Tx, id, expectedVersion int64) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, `SELECT version FROM orders WHERE id = ?`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if expectedVersion != 0 && version != expectedVersion {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrder(row rowScanner) (*models.Order, error) {
+	var order models.Order
+	var items, currency, history, checkout, createdAt, updatedAt string
+	var subtotal, discount, total int64
+
+	err := row.Scan(&order.ID, &order.UserID, &items, &subtotal, &discount, &total, &currency,
+		&order.Status, &order.Version, &history, &checkout, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	order.Subtotal = money.New(subtotal, currency)
+	order.Discount = money.New(discount, currency)
+	order.TotalAmount = money.New(total, currency)
+
+	if err := json.Unmarshal([]byte(items), &order.Items); err != nil {
+		return nil, fmt.Errorf("order %d: decode items: %w", order.ID, err)
+	}
+	order.UpgradeLegacy()
+	if err := json.Unmarshal([]byte(history), &order.History); err != nil {
+		return nil, fmt.Errorf("order %d: decode history: %w", order.ID, err)
+	}
+	if err := json.Unmarshal([]byte(checkout), &order.Checkout); err != nil {
+		return nil, fmt.Errorf("order %d: decode checkout: %w", order.ID, err)
+	}
+	if order.CreatedAt, err = storage.ParseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if order.UpdatedAt, err = storage.ParseTime(updatedAt); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
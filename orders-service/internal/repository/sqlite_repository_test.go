@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"platform/storage"
+)
+
+func TestSQLiteMigrationsUpDown(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := storage.LoadMigrations(Migrations())
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != latest {
+		t.Fatalf("schema version %d, want %d", v, latest)
+	}
+
+	// Повторный запуск ничего не делает
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+
+	if err := storage.MigrateDown(ctx, db, Migrations(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != 0 {
+		t.Fatalf("schema version %d after rollback, want 0", v)
+	}
+	var tables int
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'orders'`).Scan(&tables)
+	if tables != 0 {
+		t.Fatal("orders table survived rollback")
+	}
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate after rollback: %v", err)
+	}
+}
+
+func TestSQLiteImportJSON(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "orders.json")
+
+	source, err := NewJSONOrderRepository(jsonPath)
+	if err != nil {
+		t.Fatalf("NewJSONOrderRepository: %v", err)
+	}
+	first, _ := source.CreateOrder(ctx, 1, lineItems(50, "a", "b"))
+	source.CreateOrder(ctx, 2, lineItems(50, "c"))
+	source.UpdateOrderStatus(ctx, first.ID, "processing", "tester", 0)
+	source.UpdateOrderStatus(ctx, first.ID, "completed", "tester", 0)
+
+	repo, err := NewSQLiteOrderRepository(filepath.Join(dir, "orders.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteOrderRepository: %v", err)
+	}
+	defer repo.Close()
+
+	n, err := repo.ImportJSON(ctx, jsonPath)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportJSON = %d, %v; want 2", n, err)
+	}
+
+	got, err := repo.GetOrderByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	if got.Status != "completed" || got.Version != 3 || len(got.History) != 2 || got.TotalAmount != rub(100) ||
+		got.Subtotal != rub(100) || len(got.Items) != 2 || got.Items[1].SKU != "b" || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("imported order %+v differs from %+v", got, first)
+	}
+
+	// Импорт однократный
+	if n, err := repo.ImportJSON(ctx, jsonPath); err != nil || n != 0 {
+		t.Fatalf("second ImportJSON = %d, %v; want 0", n, err)
+	}
+
+	// Новые записи не конфликтуют с перенесёнными ID
+	created, err := repo.CreateOrder(ctx, 3, lineItems(1, "a"))
+	if err != nil || created.ID <= 2 {
+		t.Fatalf("CreateOrder after import = %+v, %v", created, err)
+	}
+}
+
+func TestSQLiteImportLegacyItems(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "orders.json")
+	if err := os.WriteFile(jsonPath, []byte(legacyOrders), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewSQLiteOrderRepository(filepath.Join(dir, "orders.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteOrderRepository: %v", err)
+	}
+	defer repo.Close()
+
+	if _, err := repo.ImportJSON(ctx, jsonPath); err != nil {
+		t.Fatalf("ImportJSON: %v", err)
+	}
+	order, err := repo.GetOrderByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	assertLegacyOrder(t, order)
+}
+
+func TestSQLiteMoneyMigrationKeepsAmounts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+
+	db, err := storage.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if err := storage.MigrateDown(ctx, db, Migrations(), 5); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO orders (user_id, items, subtotal, discount, total_amount, status, created_at, updated_at)
+		VALUES (1, '[{"sku":"A-1","name":"Pen","quantity":3,"unitPrice":19.99,"discount":0.1,"total":59.87}]',
+		59.97, 0.1, 59.87, 'created', '2024-01-02T03:04:05Z', '2024-01-02T03:04:05Z')`)
+	if err != nil {
+		t.Fatalf("insert order at version 5: %v", err)
+	}
+	db.Close()
+
+	repo, err := NewSQLiteOrderRepository(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteOrderRepository: %v", err)
+	}
+	defer repo.Close()
+
+	order, err := repo.GetOrderByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	if order.Subtotal != rub(59.97) || order.Discount != rub(0.1) || order.TotalAmount != rub(59.87) ||
+		order.Items[0].UnitPrice != rub(19.99) || order.Items[0].Total != rub(59.87) {
+		t.Fatalf("amounts after migration: %+v", order)
+	}
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"platform/storage/storagetest"
+)
+
+// Стресс-тесты рассчитаны на запуск с детектором гонок: go test -race ./...
+
+const stressOrders = 50
+
+func stressOrderBackends() map[string]func(t *testing.T) OrderRepository {
+	return map[string]func(t *testing.T) OrderRepository{
+		"json": func(t *testing.T) OrderRepository {
+			repo, err := NewJSONOrderRepository(filepath.Join(t.TempDir(), "orders.json"))
+			if err != nil {
+				t.Fatalf("NewJSONOrderRepository: %v", err)
+			}
+			return repo
+		},
+		"memory": func(t *testing.T) OrderRepository {
+			return NewMemoryOrderRepository()
+		},
+		"sqlite": func(t *testing.T) OrderRepository {
+			repo, err := NewSQLiteOrderRepository(filepath.Join(t.TempDir(), "orders.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteOrderRepository: %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+}
+
+func TestOrderRepositoryConcurrentAccess(t *testing.T) {
+	for name, newRepo := range stressOrderBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			var ids storagetest.IDs
+
+			storagetest.Run(t, stressOrders,
+				// Писатель: создаёт, обновляет и удаляет заказы своего пользователя
+				func(w, i int) error {
+					order, err := repo.CreateOrder(ctx, int64(w+1), lineItems(float64(i), fmt.Sprintf("item-%d", i)))
+					if err != nil {
+						return err
+					}
+					if err := ids.Add(order.ID); err != nil {
+						return err
+					}
+					if _, err := repo.UpdateOrderStatus(ctx, order.ID, "processing", "tester", 0); err != nil {
+						return err
+					}
+					if i%5 == 0 {
+						return repo.DeleteOrder(ctx, order.ID, 0)
+					}
+					return nil
+				},
+				// Читатель: параллельно листает заказы и читает отдельные записи
+				func(w, i int) error {
+					userID := int64(w + 1)
+					orders, err := repo.GetOrdersByUserID(ctx, userID)
+					if err != nil {
+						return err
+					}
+					for _, order := range orders {
+						if order.UserID != userID {
+							return fmt.Errorf("order %d of user %d listed for user %d", order.ID, order.UserID, userID)
+						}
+						repo.GetOrderByID(ctx, order.ID)
+					}
+					return nil
+				},
+			)
+
+			want := stressOrders - stressOrders/5
+			for w := 0; w < storagetest.Workers; w++ {
+				orders, _ := repo.GetOrdersByUserID(ctx, int64(w+1))
+				if len(orders) != want {
+					t.Fatalf("user %d: %d orders, want %d", w+1, len(orders), want)
+				}
+				for _, order := range orders {
+					if order.Status != "processing" {
+						t.Fatalf("order %d: status %q, want processing", order.ID, order.Status)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestJSONOrderRepositoryConcurrentWritesSurviveReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.json")
+
+	repo, err := NewJSONOrderRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONOrderRepository: %v", err)
+	}
+	repo.storage.SetCompactEvery(7) // компактификация посреди параллельной записи
+
+	storagetest.Run(t, stressOrders,
+		func(w, i int) error {
+			order, err := repo.CreateOrder(ctx, int64(w+1), lineItems(1, "a"))
+			if err != nil {
+				return err
+			}
+			repo.UpdateOrderStatus(ctx, order.ID, "processing", "tester", 0)
+			repo.UpdateOrderStatus(ctx, order.ID, "completed", "tester", 0)
+			return nil
+		},
+		// Полный снимок параллельно с журналом
+		func(w, i int) error {
+			if w > 0 || i >= 10 {
+				return nil
+			}
+			return repo.SaveToFile()
+		},
+	)
+
+	reopened, err := NewJSONOrderRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	for w := 0; w < storagetest.Workers; w++ {
+		orders, _ := reopened.GetOrdersByUserID(ctx, int64(w+1))
+		if len(orders) != stressOrders {
+			t.Fatalf("user %d: %d orders after reload, want %d", w+1, len(orders), stressOrders)
+		}
+		for _, order := range orders {
+			if order.Status != "completed" {
+				t.Fatalf("order %d: status %q after reload", order.ID, order.Status)
+			}
+		}
+	}
+}
+
+func TestOrderRepositoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryOrderRepository()
+
+	items := lineItems(10, "a")
+	created, _ := repo.CreateOrder(ctx, 1, items)
+	items[0].Name = "changed by caller"
+	created.Status = "hacked"
+	created.Items[0].Name = "hacked"
+
+	got, _ := repo.GetOrderByID(ctx, created.ID)
+	if got.Status != "created" || got.Items[0].Name != "a" {
+		t.Fatalf("stored order changed through returned pointer: %+v", got)
+	}
+
+	listed, _ := repo.GetOrdersByUserID(ctx, 1)
+	listed[0].Items[0].Name = "hacked"
+	if got, _ := repo.GetOrderByID(ctx, created.ID); got.Items[0].Name != "a" {
+		t.Fatalf("stored order changed through listed pointer: %+v", got)
+	}
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"platform/pagination"
+	"platform/storage"
+	"platform/tracing"
+
+	"orders-service/internal/models"
+)
+
+// TracedOrderRepository пишет span на каждый вызов хранилища next (storage.Traced)
+type TracedOrderRepository struct {
+	storage.Traced[OrderRepository]
+}
+
+var _ OrderRepository = (*TracedOrderRepository)(nil)
+
+// NewTracedOrderRepository оборачивает хранилище next трассировкой
+func NewTracedOrderRepository(next OrderRepository) *TracedOrderRepository {
+	return &TracedOrderRepository{storage.NewTraced("OrderRepository", next)}
+}
+
+func (r *TracedOrderRepository) CreateOrder(ctx context.Context, userID int64, items []models.LineItem) (order *models.Order, err error) {
+	ctx, span := r.Start(ctx, "CreateOrder", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.CreateOrder(ctx, userID, items)
+}
+
+func (r *TracedOrderRepository) GetOrderByID(ctx context.Context, id int64) (order *models.Order, err error) {
+	ctx, span := r.Start(ctx, "GetOrderByID", attribute.Int64("order.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.GetOrderByID(ctx, id)
+}
+
+func (r *TracedOrderRepository) GetOrdersByUserID(ctx context.Context, userID int64) (orders []*models.Order, err error) {
+	ctx, span := r.Start(ctx, "GetOrdersByUserID", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.GetOrdersByUserID(ctx, userID)
+}
+
+func (r *TracedOrderRepository) ListOrders(ctx context.Context, q pagination.ListQuery) (page *pagination.Page[*models.Order], err error) {
+	ctx, span := r.Start(ctx, "ListOrders")
+	defer tracing.End(span, &err)
+	return r.Next.ListOrders(ctx, q)
+}
+
+func (r *TracedOrderRepository) UpdateOrderStatus(ctx context.Context, id int64, status, actor string, expectedVersion int64) (order *models.Order, err error) {
+	ctx, span := r.Start(ctx, "UpdateOrderStatus", attribute.Int64("order.id", id), attribute.String("order.status", status))
+	defer tracing.End(span, &err)
+	return r.Next.UpdateOrderStatus(ctx, id, status, actor, expectedVersion)
+}
+
+func (r *TracedOrderRepository) AddCheckoutStep(ctx context.Context, id int64, step models.CheckoutStep, expectedVersion int64) (order *models.Order, err error) {
+	ctx, span := r.Start(ctx, "AddCheckoutStep", attribute.Int64("order.id", id), attribute.String("checkout.step", step.Step))
+	defer tracing.End(span, &err)
+	return r.Next.AddCheckoutStep(ctx, id, step, expectedVersion)
+}
+
+func (r *TracedOrderRepository) DeleteOrder(ctx context.Context, id int64, expectedVersion int64) (err error) {
+	ctx, span := r.Start(ctx, "DeleteOrder", attribute.Int64("order.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.DeleteOrder(ctx, id, expectedVersion)
+}
+
+func (r *TracedOrderRepository) DeleteOrdersByUserID(ctx context.Context, userID int64) (err error) {
+	ctx, span := r.Start(ctx, "DeleteOrdersByUserID", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.DeleteOrdersByUserID(ctx, userID)
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
-	"os"
+	"path/filepath"
 
-	"github.com/gorilla/mux"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"platform/config"
+	"platform/health"
+	"platform/httputil"
+	"platform/idempotency"
+	"platform/logging"
+	"platform/server"
+	"platform/storage"
+	"platform/tracing"
 
+	_ "payments-service/docs"
+	"payments-service/internal/client"
 	"payments-service/internal/handlers"
 	"payments-service/internal/repository"
-	_ "payments-service/docs"
 )
 
 // @title Payments Service API
@@ -19,45 +28,178 @@ import (
 // @host localhost:8083
 // @basePath /api
 
+const (
+	jsonPath        = "./data/payments.json"
+	idempotencyPath = "./data/idempotency_keys.json"
+)
+
 func main() {
-	router := mux.NewRouter()
+	if err := logging.Setup("payments-service"); err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+
+	migrateDown := flag.Int("migrate-down", -1, "откатить схему SQLite до указанной версии и выйти")
+	flag.Parse()
+
+	if *migrateDown >= 0 {
+		if err := rollbackSQLite(*migrateDown); err != nil {
+			log.Fatalf("Failed to roll back migrations: %v", err)
+		}
+		log.Printf("SQLite schema rolled back to version %d", *migrateDown)
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "payments-service")
+	if err != nil {
+		log.Fatalf("Invalid tracing config: %v", err)
+	}
 
-	// Инициализируем JSON репозиторий
-	repo, err := repository.NewJSONPaymentRepository("./data/payments.json")
+	srv := server.New("payments-service", "8083")
+	// Функции остановки вызываются в обратном порядке: span выгружаются последними
+	srv.OnShutdown(shutdownTracing)
+	// Пробы отвечают уже во время загрузки хранилища; /health/startup - 503 до srv.Run
+	if err := srv.Listen(); err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	backend := config.String("STORAGE_BACKEND", "json")
+
+	// Инициализируем репозиторий выбранного бэкенда
+	repo, err := newRepository(backend)
 	if err != nil {
 		log.Fatalf("Failed to initialize repository: %v", err)
 	}
+	// Каждый вызов хранилища - span в трассе запроса
+	repo = repository.NewTracedPaymentRepository(repo)
+	srv.OnShutdown(func(context.Context) error { return repo.Close() })
+	if dir := storageDir(backend); dir != "" {
+		srv.Health.Add("storage", health.Dir(dir))
+	}
 
-	handler := handlers.NewPaymentHandler(repo)
+	users, err := httputil.UsersClientFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid users-service client config: %v", err)
+	}
+
+	orders := newOrdersClient()
+	srv.OnShutdown(func(context.Context) error {
+		users.Close()
+		orders.Close()
+		return nil
+	})
+	// Соседи проверяются по /health/live: их собственные зависимости не должны
+	// снимать готовность этого сервиса
+	if users.Required() {
+		srv.Health.Add("users-service", users.HealthCheck())
+	} else {
+		srv.Health.AddOptional("users-service", users.HealthCheck())
+	}
+	srv.Health.Add("orders-service", orders.HealthCheck())
+
+	handler := handlers.NewPaymentHandler(repo, users, orders)
+
+	keys, err := newIdempotencyStore(backend)
+	if err != nil {
+		log.Fatalf("Failed to initialize idempotency keys: %v", err)
+	}
+	srv.OnShutdown(func(context.Context) error { return keys.Close() })
 
 	// API endpoints
-	api := router.PathPrefix("/api").Subrouter()
+	api := srv.API
+	api.Use(idempotency.Middleware(keys))
 
 	api.HandleFunc("/payments", handler.CreatePayment).Methods("POST")
 	api.HandleFunc("/payments", handler.GetAllPayments).Methods("GET")
 	api.HandleFunc("/payments/{id}", handler.GetPaymentByID).Methods("GET")
 	api.HandleFunc("/payments/user/{userId}", handler.GetPaymentsByUserID).Methods("GET")
 	api.HandleFunc("/payments/{id}", handler.UpdatePayment).Methods("PUT")
+	api.HandleFunc("/payments/{id}/authorize", handler.AuthorizePayment).Methods("POST")
+	api.HandleFunc("/payments/{id}/capture", handler.CapturePayment).Methods("POST")
+	api.HandleFunc("/payments/{id}/void", handler.VoidPayment).Methods("POST")
+	api.HandleFunc("/payments/{id}/refund", handler.RefundPayment).Methods("POST")
+	api.HandleFunc("/payments/{id}/partial-refund", handler.PartialRefundPayment).Methods("POST")
+	api.HandleFunc("/payments/{id}/history", handler.GetPaymentHistory).Methods("GET")
 	api.HandleFunc("/payments/user/{userId}", handler.DeletePaymentsByUserID).Methods("DELETE")
 
-	// Swagger
-	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8083/swagger/doc.json"),
-	))
+	if err := srv.Run(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
 
-	// Health check
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+// newRepository выбирает бэкенд хранилища: json (по умолчанию), memory или sqlite
+func newRepository(backend string) (repository.PaymentRepository, error) {
+	switch backend {
+	case "json":
+		repo, err := repository.NewJSONPaymentRepository(jsonPath)
+		if err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "memory":
+		return repository.NewMemoryPaymentRepository(), nil
+	case "sqlite":
+		repo, err := repository.NewSQLitePaymentRepository(sqlitePath())
+		if err != nil {
+			return nil, err
+		}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8083"
+		// Однократно переносим данные, накопленные в JSON-хранилище
+		imported, err := repo.ImportJSON(context.Background(), jsonPath)
+		if err != nil {
+			repo.Close()
+			return nil, fmt.Errorf("import %s: %w", jsonPath, err)
+		}
+		if imported > 0 {
+			log.Printf("Imported %d payments from %s", imported, jsonPath)
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
 	}
+}
 
-	log.Printf("Starting payments-service on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// newOrdersClient читает заказы из ORDERS_SERVICE_URL
+func newOrdersClient() *client.OrdersServiceClient {
+	return client.NewOrdersServiceClient(config.String("ORDERS_SERVICE_URL", "http://orders-service:8082"))
+}
+
+// newIdempotencyStore хранит ответы на запросы с Idempotency-Key: в памяти для
+// memory-бэкенда, иначе в idempotencyPath. IDEMPOTENCY_KEY_TTL - сколько хранится ключ, например 48h
+func newIdempotencyStore(backend string) (*idempotency.Store, error) {
+	ttl, err := config.Duration("IDEMPOTENCY_KEY_TTL", idempotency.DefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend == "memory" {
+		return idempotency.NewMemoryStore(ttl), nil
 	}
+	return idempotency.NewStore(idempotencyPath, ttl)
+}
+
+// storageDir - каталог файлов бэкенда, его проверяет /health/ready; у memory файлов нет
+func storageDir(backend string) string {
+	switch backend {
+	case "memory":
+		return ""
+	case "sqlite":
+		return filepath.Dir(sqlitePath())
+	default:
+		return filepath.Dir(jsonPath)
+	}
+}
+
+func sqlitePath() string {
+	return config.String("SQLITE_PATH", "./data/payments.db")
+}
+
+// rollbackSQLite откатывает схему базы до версии target
+func rollbackSQLite(target int) error {
+	db, err := storage.OpenSQLite(sqlitePath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return storage.MigrateDown(context.Background(), db, repository.Migrations(), target)
 }
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"platform/health"
+	"platform/metrics"
+	"platform/money"
+	"platform/problem"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+var (
+	// ErrOrderNotFound - orders-service ответил, что такого заказа нет
+	ErrOrderNotFound = problem.New(problem.Unprocessable, "order_not_found", "order not found")
+	// ErrOrdersUnavailable - orders-service не ответил или ответил ошибкой
+	ErrOrdersUnavailable = problem.New(problem.Unavailable, "orders_unavailable", "orders-service unavailable")
+)
+
+// Статусы заказа, от которых зависят проверки
+const (
+	OrderStatusCreated    = "created"
+	OrderStatusProcessing = "processing"
+	OrderStatusCompleted  = "completed"
+	OrderStatusCancelled  = "cancelled"
+	OrderStatusFailed     = "failed"
+)
+
+// Order - поля заказа, нужные для проверок
+type Order struct {
+	ID          int64       `json:"id"`
+	UserID      int64       `json:"userId"`
+	TotalAmount money.Money `json:"totalAmount"`
+	Status      string      `json:"status"`
+}
+
+// OrdersServiceClient читает заказы через GET /api/orders/{id}
+type OrdersServiceClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOrdersServiceClient создаёт клиента orders-service с адресом baseURL
+func NewOrdersServiceClient(baseURL string) *OrdersServiceClient {
+	return &OrdersServiceClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Transport: &metrics.Transport{Client: "OrdersServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   3 * time.Second,
+		},
+	}
+}
+
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *OrdersServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// HealthCheck - проверка готовности: отвечает ли orders-service на /health/live
+func (c *OrdersServiceClient) HealthCheck() health.Check {
+	return health.HTTP(c.baseURL + "/health/live")
+}
+
+// GetOrder возвращает заказ, ErrOrderNotFound или ErrOrdersUnavailable
+func (c *OrdersServiceClient) GetOrder(ctx context.Context, orderID int64) (*Order, error) {
+	url := fmt.Sprintf("%s/api/orders/%d", c.baseURL, orderID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrdersUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %d", ErrOrderNotFound, orderID)
+	default:
+		return nil, fmt.Errorf("%w: status %d", ErrOrdersUnavailable, resp.StatusCode)
+	}
+
+	var order Order
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil || order.ID != orderID {
+		return nil, fmt.Errorf("%w: unexpected response from %s", ErrOrdersUnavailable, url)
+	}
+	return &order, nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"platform/money"
+)
+
+func TestGetOrder(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orders/1":
+			w.Write([]byte(`{"id":1,"userId":7,"items":["book"],"totalAmount":{"minor":9950,"currency":"RUB"},"status":"processing"}`))
+		case "/api/orders/2":
+			http.Error(w, "order not found", http.StatusNotFound)
+		case "/api/orders/3":
+			w.Write([]byte(`{"id":4}`))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	orders := NewOrdersServiceClient(server.URL)
+
+	order, err := orders.GetOrder(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order.UserID != 7 || order.TotalAmount != money.New(9950, "RUB") || order.Status != OrderStatusProcessing {
+		t.Fatalf("order %+v", order)
+	}
+
+	if _, err := orders.GetOrder(ctx, 2); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("missing order: error %v, want ErrOrderNotFound", err)
+	}
+	for _, id := range []int64{3, 5} {
+		if _, err := orders.GetOrder(ctx, id); !errors.Is(err, ErrOrdersUnavailable) {
+			t.Fatalf("order %d: error %v, want ErrOrdersUnavailable", id, err)
+		}
+	}
+
+	server.Close()
+	if _, err := orders.GetOrder(ctx, 1); !errors.Is(err, ErrOrdersUnavailable) {
+		t.Fatalf("closed server: error %v, want ErrOrdersUnavailable", err)
+	}
+}
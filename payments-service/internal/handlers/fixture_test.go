@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"platform/money"
+
+	"payments-service/internal/client"
+	"payments-service/internal/repository"
+)
+
+// fakeUsers - любой пользователь существует
+type fakeUsers struct{}
+
+func (fakeUsers) VerifyUser(context.Context, int64) error { return nil }
+
+// fakeOrders - заказы пользователя 1 на 100 RUB
+type fakeOrders struct{}
+
+func (fakeOrders) GetOrder(_ context.Context, orderID int64) (*client.Order, error) {
+	return &client.Order{ID: orderID, UserID: 1, TotalAmount: money.New(10000, "RUB"), Status: client.OrderStatusCreated}, nil
+}
+
+// newTestRouter - маршруты как в cmd/main.go поверх репозитория в памяти
+func newTestRouter() *mux.Router {
+	handler := NewPaymentHandler(repository.NewMemoryPaymentRepository(), fakeUsers{}, fakeOrders{})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/payments", handler.CreatePayment).Methods("POST")
+	r.HandleFunc("/payments/{id}", handler.GetPaymentByID).Methods("GET")
+	r.HandleFunc("/payments/{id}/authorize", handler.AuthorizePayment).Methods("POST")
+	return r
+}
+
+// serve выполняет запрос к router; header - дополнительные заголовки
+func serve(router http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// decode разбирает JSON-ответ в v; тело не JSON - ошибка теста
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decode response %d: %v", w.Code, err)
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"platform/problem"
+
+	"payments-service/internal/client"
+)
+
+// OrderLookup читает заказ платежа из orders-service (client.OrdersServiceClient)
+type OrderLookup interface {
+	GetOrder(ctx context.Context, orderID int64) (*client.Order, error)
+}
+
+// lookupOrder находит заказ и проверяет, что он принадлежит userID. При ошибке
+// отвечает сам: 422 - заказа нет или он чужой, 503 - orders-service недоступен.
+func lookupOrder(w http.ResponseWriter, r *http.Request, orders OrderLookup, userID, orderID int64) (*client.Order, bool) {
+	order, err := orders.GetOrder(r.Context(), orderID)
+	if err != nil {
+		problem.Write(w, r, err)
+		return nil, false
+	}
+
+	if order.UserID != userID {
+		problem.Write(w, r, problem.Errorf(problem.Unprocessable, "order_of_another_user", "order %d belongs to another user", orderID))
+		return nil, false
+	}
+	return order, true
+}
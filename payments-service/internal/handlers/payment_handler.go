@@ -2,76 +2,197 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
 
 	"github.com/gorilla/mux"
 
+	"platform/httputil"
+	"platform/money"
+	"platform/pagination"
+	"platform/problem"
+	"platform/validation"
+
+	"payments-service/internal/client"
+	"payments-service/internal/metrics"
+	"payments-service/internal/models"
 	"payments-service/internal/repository"
 )
 
+// actorHeader - кто выполняет операцию; попадает в историю платежа
+const actorHeader = "X-Actor"
+
+// Как операция берёт сумму из тела запроса
+const (
+	amountNone     = iota // тело не читается, операция на всю сумму
+	amountOptional        // сумма необязательна, без неё - вся
+	amountRequired        // нужна положительная сумма
+)
+
 // PaymentHandler обработчик платежей
 type PaymentHandler struct {
-	repo *repository.JSONPaymentRepository
+	repo   repository.PaymentRepository
+	users  UserVerifier
+	orders OrderLookup
+
+	// createMu делает проверку остатка по заказу и создание платежа атомарными,
+	// иначе два одновременных платежа вместе переплатили бы заказ
+	createMu sync.Mutex
 }
 
-// CreatePaymentRequest структура для создания платежа
+// CreatePaymentRequest структура для создания платежа.
+// Сумма - {"minor": 10050, "currency": "RUB"} или число в рублях.
 type CreatePaymentRequest struct {
-	UserID  int64   `json:"userId" binding:"required"`
-	OrderID int64   `json:"orderId" binding:"required"`
-	Amount  float64 `json:"amount" binding:"required,min=0"`
+	UserID  int64       `json:"userId" binding:"required,min=1"`
+	OrderID int64       `json:"orderId" binding:"required,min=1"`
+	Amount  money.Money `json:"amount" binding:"required,min=0"`
 }
 
 // UpdatePaymentRequest структура для обновления платежа
 type UpdatePaymentRequest struct {
-	Status string `json:"status" binding:"required"`
+	Status string `json:"status" binding:"required"` // authorized, captured, voided, refunded, failed
 }
 
-// NewPaymentHandler создаёт новый обработчик
-func NewPaymentHandler(repo *repository.JSONPaymentRepository) *PaymentHandler {
+// PaymentAmountRequest сумма для capture и partial-refund, в валюте платежа
+type PaymentAmountRequest struct {
+	Amount money.Money `json:"amount" binding:"min=0"`
+}
+
+// NewPaymentHandler создаёт новый обработчик. users проверяет, что плательщик
+// существует, orders - что заказ его и ещё не оплачен.
+func NewPaymentHandler(repo repository.PaymentRepository, users UserVerifier, orders OrderLookup) *PaymentHandler {
 	return &PaymentHandler{
-		repo: repo,
+		repo:   repo,
+		users:  users,
+		orders: orders,
 	}
 }
 
 // CreatePayment создаёт платёж
 // @Summary Создать платёж
-// @Description Создать новый платёж
+// @Description Создать новый платёж. Пользователь проверяется в users-service, заказ - в orders-service:
+// @Description он должен принадлежать пользователю, а платёж - быть ровно на сумму заказа в его валюте
+// @Description и не превышать неоплаченный остаток (второй платёж, пока первый не отменён, отклоняется).
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Param payment body CreatePaymentRequest true "Данные платежа"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {string} string "Invalid request"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 409 {object} problem.Problem "Order is cancelled or failed"
+// @Failure 422 {object} problem.Problem "User or order not found, order of another user, amount other than the order total, overpayment or currency mismatch"
+// @Failure 503 {object} problem.Problem "Users or orders service unavailable"
 // @Router /payments [post]
 func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
 	var req CreatePaymentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+	if !money.ValidCurrency(req.Amount.Currency) {
+		problem.Write(w, r, fmt.Errorf("%w: unknown currency %q", models.ErrInvalidAmount, req.Amount.Currency))
+		return
+	}
+
+	if err := h.users.VerifyUser(r.Context(), req.UserID); err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	order, ok := lookupOrder(w, r, h.orders, req.UserID, req.OrderID)
+	if !ok {
+		return
+	}
+	if order.Status == client.OrderStatusCancelled || order.Status == client.OrderStatusFailed {
+		problem.Write(w, r, problem.Errorf(problem.Conflict, "order_not_payable", "order %d is %s", order.ID, order.Status))
 		return
 	}
 
-	payment, err := h.repo.CreatePayment(req.UserID, req.OrderID, req.Amount)
+	if req.Amount.Currency != order.TotalAmount.Currency {
+		problem.Write(w, r, problem.Errorf(problem.Unprocessable, "currency_mismatch", "payment currency %s differs from currency %s of order %d",
+			req.Amount.Currency, order.TotalAmount.Currency, order.ID))
+		return
+	}
+	// Частичная оплата не предусмотрена: заказ оплачивается одним платежом
+	if !req.Amount.Equal(order.TotalAmount) {
+		problem.Write(w, r, problem.Errorf(problem.Unprocessable, "amount_mismatch", "payment amount %s differs from total %s of order %d",
+			req.Amount, order.TotalAmount, order.ID))
+		return
+	}
+
+	h.createMu.Lock()
+	defer h.createMu.Unlock()
+
+	outstanding, err := h.outstanding(r, order)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+	if cmp, _ := req.Amount.Cmp(outstanding); cmp > 0 {
+		problem.Write(w, r, problem.Errorf(problem.Unprocessable, "amount_exceeds_outstanding", "payment amount %s exceeds outstanding %s of order %d", req.Amount, outstanding, order.ID))
+		return
+	}
+
+	payment, err := h.repo.CreatePayment(r.Context(), req.UserID, req.OrderID, req.Amount)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
+	metrics.PaymentStatus(payment.Status)
 
+	httputil.SetETag(w, payment.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(payment)
 }
 
-// GetAllPayments получает все платежи
-// @Summary Получить все платежи
-// @Description Получить список всех платежей
+// outstanding - сколько по заказу ещё не оплачено с учётом незавершённых платежей и возвратов
+func (h *PaymentHandler) outstanding(r *http.Request, order *client.Order) (money.Money, error) {
+	payments, err := h.repo.GetPaymentsByOrderID(r.Context(), order.ID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	outstanding := order.TotalAmount
+	for _, payment := range payments {
+		// Платежи по заказу приняты только в его валюте
+		if outstanding, err = outstanding.Sub(payment.Committed()); err != nil {
+			return money.Money{}, fmt.Errorf("payment %d: %w", payment.ID, err)
+		}
+	}
+	return outstanding, nil
+}
+
+// GetAllPayments получает страницу платежей
+// @Summary Получить платежи
+// @Description Получить страницу платежей с сортировкой, фильтрами и курсором
 // @Tags payments
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param status query string false "Статус"
+// @Param userId query int64 false "ID пользователя"
+// @Param orderId query int64 false "ID заказа"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /payments [get]
 func (h *PaymentHandler) GetAllPayments(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode([]interface{}{})
+	q, err := pagination.ParseListQuery(r.URL.Query(), pagination.FilterStatus, pagination.FilterUserID, pagination.FilterOrderID)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	h.writeList(w, r, q)
 }
 
 // GetPaymentByID получает платёж по ID
@@ -80,103 +201,334 @@ func (h *PaymentHandler) GetAllPayments(w http.ResponseWriter, r *http.Request)
 // @Tags payments
 // @Produce json
 // @Param id path int64 true "ID платежа"
+// @Param If-None-Match header string false "ETag известной клиенту версии"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "Payment not found"
+// @Success 304 "Not modified"
+// @Failure 404 {object} problem.Problem "Payment not found"
 // @Router /payments/{id} [get]
 func (h *PaymentHandler) GetPaymentByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
-	payment, err := h.repo.GetPaymentByID(id)
+	payment, err := h.repo.GetPaymentByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, err)
 		return
 	}
 
+	if httputil.NotModified(w, r, payment.Version) {
+		return
+	}
+
+	httputil.SetETag(w, payment.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(payment)
 }
 
 // GetPaymentsByUserID получает платежи пользователя
 // @Summary Получить платежи пользователя
-// @Description Получить все платежи конкретного пользователя
+// @Description Получить страницу платежей конкретного пользователя; параметры те же, что у списка
 // @Tags payments
 // @Produce json
 // @Param userId path int64 true "ID пользователя"
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param status query string false "Статус"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /payments/user/{userId} [get]
 func (h *PaymentHandler) GetPaymentsByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidUserID)
+		return
+	}
+
+	q, err := pagination.ParseListQuery(r.URL.Query(), pagination.FilterStatus)
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
+	q.UserID = userID
 
-	payments, err := h.repo.GetPaymentsByUserID(userID)
+	h.writeList(w, r, q)
+}
+
+// writeList отдаёт страницу платежей по разобранному запросу списка
+func (h *PaymentHandler) writeList(w http.ResponseWriter, r *http.Request, q pagination.ListQuery) {
+	page, err := h.repo.ListPayments(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(payments)
+	json.NewEncoder(w).Encode(page)
 }
 
 // UpdatePayment обновляет платёж
 // @Summary Обновить платёж
-// @Description Обновить статус платежа
+// @Description Перевести платёж в статус операцией жизненного цикла на всю сумму
+// @Description (частичные суммы - через /capture и /partial-refund)
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Param id path int64 true "ID платежа"
 // @Param payment body UpdatePaymentRequest true "Новый статус"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто меняет платёж (для истории)"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "Payment not found"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Failure 412 {object} problem.Problem "Precondition failed"
 // @Router /payments/{id} [put]
 func (h *PaymentHandler) UpdatePayment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
 	var req UpdatePaymentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
+		return
+	}
+
+	op, ok := models.OperationForStatus(req.Status)
+	if !ok {
+		problem.Write(w, r, fmt.Errorf("%w: cannot change payment status to %q", models.ErrIllegalTransition, req.Status))
 		return
 	}
 
-	payment, err := h.repo.UpdatePaymentStatus(id, req.Status)
+	h.applyOperation(w, r, id, op, money.Money{})
+}
+
+// AuthorizePayment авторизует платёж
+// @Summary Авторизовать платёж
+// @Description Зарезервировать сумму платежа: pending -> authorized
+// @Tags payments
+// @Produce json
+// @Param id path int64 true "ID платежа"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто выполняет операцию (для истории)"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Router /payments/{id}/authorize [post]
+func (h *PaymentHandler) AuthorizePayment(w http.ResponseWriter, r *http.Request) {
+	h.handleOperation(w, r, models.PaymentOpAuthorize, amountNone)
+}
+
+// CapturePayment списывает авторизованный платёж
+// @Summary Списать платёж
+// @Description Списать авторизованную сумму целиком или частично: authorized -> captured
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int64 true "ID платежа"
+// @Param payment body PaymentAmountRequest false "Сумма списания, по умолчанию вся"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто выполняет операцию (для истории)"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid amount"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Router /payments/{id}/capture [post]
+func (h *PaymentHandler) CapturePayment(w http.ResponseWriter, r *http.Request) {
+	h.handleOperation(w, r, models.PaymentOpCapture, amountOptional)
+}
+
+// VoidPayment отменяет авторизацию
+// @Summary Отменить авторизацию
+// @Description Снять резерв без списания: authorized -> voided
+// @Tags payments
+// @Produce json
+// @Param id path int64 true "ID платежа"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто выполняет операцию (для истории)"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Router /payments/{id}/void [post]
+func (h *PaymentHandler) VoidPayment(w http.ResponseWriter, r *http.Request) {
+	h.handleOperation(w, r, models.PaymentOpVoid, amountNone)
+}
+
+// RefundPayment возвращает всю оставшуюся списанную сумму
+// @Summary Вернуть платёж
+// @Description Вернуть всё, что списано и ещё не возвращено: -> refunded
+// @Tags payments
+// @Produce json
+// @Param id path int64 true "ID платежа"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто выполняет операцию (для истории)"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Router /payments/{id}/refund [post]
+func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	h.handleOperation(w, r, models.PaymentOpRefund, amountNone)
+}
+
+// PartialRefundPayment возвращает часть списанной суммы
+// @Summary Частично вернуть платёж
+// @Description Вернуть часть списанной суммы: -> partially_refunded, либо refunded, если вернули всё
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int64 true "ID платежа"
+// @Param payment body PaymentAmountRequest true "Сумма возврата"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
+// @Param X-Actor header string false "Кто выполняет операцию (для истории)"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid amount"
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Failure 409 {object} problem.Problem "Illegal status transition"
+// @Failure 422 {object} problem.Problem "Refund exceeds captured amount"
+// @Router /payments/{id}/partial-refund [post]
+func (h *PaymentHandler) PartialRefundPayment(w http.ResponseWriter, r *http.Request) {
+	h.handleOperation(w, r, models.PaymentOpRefund, amountRequired)
+}
+
+// GetPaymentHistory получает историю платежа
+// @Summary История платежа
+// @Description Все операции над платежом: статусы, суммы, кто и когда
+// @Tags payments
+// @Produce json
+// @Param id path int64 true "ID платежа"
+// @Success 200 {array} models.PaymentTransition
+// @Failure 404 {object} problem.Problem "Payment not found"
+// @Router /payments/{id}/history [get]
+func (h *PaymentHandler) GetPaymentHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	payment, err := h.repo.GetPaymentByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
 
+	history := payment.History
+	if history == nil {
+		history = []models.PaymentTransition{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// handleOperation разбирает запрос операции жизненного цикла;
+// amountMode - нужна ли операции сумма из тела (amountNone/Optional/Required)
+func (h *PaymentHandler) handleOperation(w http.ResponseWriter, r *http.Request, op string, amountMode int) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	var req PaymentAmountRequest
+	if amountMode != amountNone {
+		err := validation.DecodeJSON(w, r, &req)
+		if errors.Is(err, validation.ErrEmptyBody) && amountMode == amountOptional {
+			err = nil
+		}
+		if err != nil {
+			problem.Write(w, r, err)
+			return
+		}
+		if amountMode == amountRequired && req.Amount.IsZero() {
+			problem.Write(w, r, fmt.Errorf("%w: must be positive", models.ErrInvalidAmount))
+			return
+		}
+	}
+
+	h.applyOperation(w, r, id, op, req.Amount)
+}
+
+// applyOperation выполняет операцию; ошибки жизненного цикла несут свой статус
+func (h *PaymentHandler) applyOperation(w http.ResponseWriter, r *http.Request, id int64, op string, amount money.Money) {
+	version, err := httputil.ExpectedVersion(r, h.paymentVersion(r, id))
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	payment, err := h.repo.ApplyPaymentOperation(r.Context(), id, op, amount, actor(r), version)
+	metrics.Operation(op, err)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+	metrics.PaymentStatus(payment.Status)
+
+	httputil.SetETag(w, payment.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(payment)
 }
 
+// actor возвращает автора изменения из X-Actor
+func actor(r *http.Request) string {
+	if actor := r.Header.Get(actorHeader); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// paymentVersion читает текущую версию платежа для проверки If-Match
+func (h *PaymentHandler) paymentVersion(r *http.Request, id int64) func() (int64, error) {
+	return func() (int64, error) {
+		payment, err := h.repo.GetPaymentByID(r.Context(), id)
+		if err != nil {
+			return 0, err
+		}
+		return payment.Version, nil
+	}
+}
+
 // DeletePaymentsByUserID удаляет платежи пользователя
 // @Summary Удалить платежи пользователя
 // @Description Удалить все платежи пользователя
 // @Tags payments
 // @Param userId path int64 true "ID пользователя"
 // @Success 204
+// @Failure 500 {object} problem.Problem "Storage error"
 // @Router /payments/user/{userId} [delete]
 func (h *PaymentHandler) DeletePaymentsByUserID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID, err := strconv.ParseInt(vars["userId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidUserID)
+		return
+	}
+
+	// Ошибку отдаём наружу: на этот ответ опирается сага удаления пользователя
+	if err := h.repo.DeletePaymentsByUserID(r.Context(), userID); err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	h.repo.DeletePaymentsByUserID(userID)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"platform/problem"
+)
+
+const testPayment = `{"userId": 1, "orderId": 1, "amount": {"minor": 10000, "currency": "RUB"}}`
+
+func TestGetPaymentNotModified(t *testing.T) {
+	router := newTestRouter()
+	created := serve(router, http.MethodPost, "/payments", testPayment, nil)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", created.Code, created.Body)
+	}
+
+	w := serve(router, http.MethodGet, "/payments/1", "", map[string]string{"If-None-Match": created.Header().Get("ETag")})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("status %d, body %q, want 304 without body", w.Code, w.Body)
+	}
+}
+
+func TestAuthorizePaymentPreconditionFailed(t *testing.T) {
+	router := newTestRouter()
+	created := serve(router, http.MethodPost, "/payments", testPayment, nil)
+	etag := created.Header().Get("ETag")
+
+	w := serve(router, http.MethodPost, "/payments/1/authorize", "", map[string]string{"If-Match": etag})
+	if w.Code != http.StatusOK {
+		t.Fatalf("authorize: status %d, body %s", w.Code, w.Body)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Fatalf("ETag %q did not change after authorize", etag)
+	}
+
+	// Второй клиент читал исходную версию
+	w = serve(router, http.MethodPost, "/payments/1/authorize", "", map[string]string{"If-Match": etag})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412", w.Code)
+	}
+	var p problem.Problem
+	decode(t, w, &p)
+	if p.Status != http.StatusPreconditionFailed {
+		t.Fatalf("problem %+v", p)
+	}
+}
+
+func TestCreatePaymentReportsFields(t *testing.T) {
+	body := `{"userId": 1, "orderId": 0, "amount": {"minor": -500, "currency": "RUB"}}`
+	w := serve(newTestRouter(), http.MethodPost, "/payments", body, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+
+	var p problem.Problem
+	decode(t, w, &p)
+	got := map[string]string{}
+	for _, f := range p.Errors {
+		got[f.Field] = f.Rule
+	}
+	if len(got) != 2 || got["orderId"] != "required" || got["amount"] != "min" {
+		t.Fatalf("errors %+v, want orderId required and amount min", p.Errors)
+	}
+	if p.Code != "validation_failed" || !strings.Contains(p.Detail, "amount must be at least 0") {
+		t.Fatalf("problem %+v", p)
+	}
+}
+
+func TestCreatePaymentRequiresOrderTotal(t *testing.T) {
+	router := newTestRouter()
+	for _, minor := range []int{5000, 15000} {
+		body := fmt.Sprintf(`{"userId": 1, "orderId": 1, "amount": {"minor": %d, "currency": "RUB"}}`, minor)
+		w := serve(router, http.MethodPost, "/payments", body, nil)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("amount %d: status %d, want 422", minor, w.Code)
+		}
+		var p problem.Problem
+		decode(t, w, &p)
+		if p.Code != "amount_mismatch" {
+			t.Fatalf("amount %d: problem %+v", minor, p)
+		}
+	}
+
+	if w := serve(router, http.MethodPost, "/payments", testPayment, nil); w.Code != http.StatusCreated {
+		t.Fatalf("order total: status %d, body %s", w.Code, w.Body)
+	}
+}
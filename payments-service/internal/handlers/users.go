@@ -0,0 +1,9 @@
+package handlers
+
+import "context"
+
+// UserVerifier проверяет владельца платежа до сохранения (httputil.UsersServiceClient).
+// Ошибки - httputil.ErrUserNotFound (422) и httputil.ErrUsersUnavailable (503).
+type UserVerifier interface {
+	VerifyUser(ctx context.Context, userID int64) error
+}
@@ -0,0 +1,33 @@
+// Package metrics - доменные метрики payments-service. Метрики HTTP, клиентов
+// и хранилища собирает platform/metrics, отдаются все вместе на /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	payments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payments_total",
+		Help: "Payments created and moved to a status, by resulting status.",
+	}, []string{"status"})
+	operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "payment_operations_total",
+		Help: "Payment operations by operation and outcome (succeeded, failed).",
+	}, []string{"operation", "outcome"})
+)
+
+// PaymentStatus учитывает платёж, созданный или переведённый в статус status
+func PaymentStatus(status string) {
+	payments.WithLabelValues(status).Inc()
+}
+
+// Operation учитывает операцию op над платежом; err != nil - операция не выполнена
+func Operation(op string, err error) {
+	outcome := "succeeded"
+	if err != nil {
+		outcome = "failed"
+	}
+	operations.WithLabelValues(op, outcome).Inc()
+}
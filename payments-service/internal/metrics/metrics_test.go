@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCounters(t *testing.T) {
+	PaymentStatus("pending")
+	PaymentStatus("captured")
+	Operation("capture", nil)
+	Operation("capture", errors.New("illegal transition"))
+	Operation("capture", errors.New("illegal transition"))
+
+	if got := testutil.ToFloat64(payments.WithLabelValues("captured")); got != 1 {
+		t.Errorf("captured payments %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(operations.WithLabelValues("capture", "succeeded")); got != 1 {
+		t.Errorf("succeeded captures %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(operations.WithLabelValues("capture", "failed")); got != 2 {
+		t.Errorf("failed captures %v, want 2", got)
+	}
+}
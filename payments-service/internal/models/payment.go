@@ -1,13 +1,229 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"platform/money"
+	"platform/problem"
+)
+
+// Статусы платежа
+const (
+	PaymentStatusPending           = "pending"
+	PaymentStatusAuthorized        = "authorized"
+	PaymentStatusCaptured          = "captured"
+	PaymentStatusVoided            = "voided"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusFailed            = "failed"
+
+	// PaymentStatusLegacyCompleted - так до жизненного цикла назывался списанный платёж
+	PaymentStatusLegacyCompleted = "completed"
+)
+
+// Операции жизненного цикла платежа
+const (
+	PaymentOpAuthorize = "authorize"
+	PaymentOpCapture   = "capture"
+	PaymentOpVoid      = "void"
+	PaymentOpRefund    = "refund"
+	PaymentOpFail      = "fail"
+)
+
+// paymentOperations - из каких статусов допустима каждая операция
+var paymentOperations = map[string][]string{
+	PaymentOpAuthorize: {PaymentStatusPending},
+	PaymentOpCapture:   {PaymentStatusAuthorized},
+	PaymentOpVoid:      {PaymentStatusAuthorized},
+	PaymentOpRefund:    {PaymentStatusCaptured, PaymentStatusPartiallyRefunded},
+	PaymentOpFail:      {PaymentStatusPending, PaymentStatusAuthorized},
+}
+
+var (
+	// ErrIllegalTransition - операция недопустима в текущем статусе платежа
+	ErrIllegalTransition = problem.New(problem.Conflict, "illegal_transition", "illegal payment status transition")
+	// ErrInvalidAmount - сумма операции не положительна, больше допустимой
+	// или в другой валюте (тогда также money.ErrCurrencyMismatch)
+	ErrInvalidAmount = problem.New(problem.Invalid, "invalid_amount", "invalid payment amount")
+	// ErrRefundExceedsCaptured - возврат больше, чем осталось списанных средств
+	ErrRefundExceedsCaptured = problem.New(problem.Unprocessable, "refund_exceeds_captured", "refund exceeds captured amount")
+)
+
+// TransitionError описывает отклонённую операцию
+type TransitionError struct {
+	Operation string
+	Status    string
+}
+
+func (e *TransitionError) Error() string {
+	allowed, known := paymentOperations[e.Operation]
+	if !known {
+		return fmt.Sprintf("unknown payment operation %q", e.Operation)
+	}
+	return fmt.Sprintf("cannot %s payment in status %q, allowed in: %v", e.Operation, e.Status, allowed)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// PaymentTransition - запись истории платежа
+type PaymentTransition struct {
+	Operation string       `json:"operation"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Amount    *money.Money `json:"amount,omitempty"` // только у capture и refund
+	Actor     string       `json:"actor"`
+	At        time.Time    `json:"at"`
+}
 
 type Payment struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"userId"`
-	OrderID   int64     `json:"orderId"`
-	Amount    float64   `json:"amount"`
-	Status    string    `json:"status"` // pending, completed, failed
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID             int64               `json:"id"`
+	UserID         int64               `json:"userId"`
+	OrderID        int64               `json:"orderId"`
+	Amount         money.Money         `json:"amount"`
+	CapturedAmount money.Money         `json:"capturedAmount"`
+	RefundedAmount money.Money         `json:"refundedAmount"`
+	Status         string              `json:"status"`  // pending, authorized, captured, voided, partially_refunded, refunded, failed
+	Version        int64               `json:"version"` // растёт при каждом изменении, отдаётся как ETag
+	History        []PaymentTransition `json:"history,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+}
+
+// Apply выполняет операцию жизненного цикла и дописывает переход в историю.
+// amount нужен для capture и refund; нулевая сумма - вся доступная.
+func (p *Payment) Apply(op string, amount money.Money, actor string, at time.Time) error {
+	if !p.allows(op) {
+		return &TransitionError{Operation: op, Status: p.Status}
+	}
+	if amount.IsNegative() {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, amount)
+	}
+
+	to := p.Status
+	var recorded *money.Money
+	switch op {
+	case PaymentOpAuthorize:
+		to = PaymentStatusAuthorized
+	case PaymentOpCapture:
+		if amount.IsZero() {
+			amount = p.Amount
+		}
+		cmp, err := amount.Cmp(p.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidAmount, err)
+		}
+		if cmp > 0 {
+			return fmt.Errorf("%w: capture %s exceeds authorized %s", ErrInvalidAmount, amount, p.Amount)
+		}
+		p.CapturedAmount = amount
+		recorded = &amount
+		to = PaymentStatusCaptured
+	case PaymentOpVoid:
+		to = PaymentStatusVoided
+	case PaymentOpRefund:
+		remaining, err := p.CapturedAmount.Sub(p.RefundedAmount)
+		if err != nil {
+			return err
+		}
+		if amount.IsZero() {
+			amount = remaining
+		}
+		cmp, err := amount.Cmp(remaining)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidAmount, err)
+		}
+		if cmp > 0 {
+			return fmt.Errorf("%w: refund %s, refundable %s", ErrRefundExceedsCaptured, amount, remaining)
+		}
+		p.RefundedAmount, _ = p.RefundedAmount.Add(amount)
+		recorded = &amount
+		to = PaymentStatusPartiallyRefunded
+		if p.RefundedAmount.Equal(p.CapturedAmount) {
+			to = PaymentStatusRefunded
+		}
+	case PaymentOpFail:
+		to = PaymentStatusFailed
+	}
+
+	p.History = append(p.History, PaymentTransition{
+		Operation: op,
+		From:      p.Status,
+		To:        to,
+		Amount:    recorded,
+		Actor:     actor,
+		At:        at,
+	})
+	p.Status = to
+	p.UpdatedAt = at
+	return nil
+}
+
+func (p *Payment) allows(op string) bool {
+	for _, status := range paymentOperations[op] {
+		if status == p.Status {
+			return true
+		}
+	}
+	return false
+}
+
+// OperationForStatus - какая операция переводит платёж в status.
+// Нужна для PUT /payments/{id}, который принимает целевой статус.
+func OperationForStatus(status string) (string, bool) {
+	switch status {
+	case PaymentStatusAuthorized:
+		return PaymentOpAuthorize, true
+	case PaymentStatusCaptured, PaymentStatusLegacyCompleted:
+		return PaymentOpCapture, true
+	case PaymentStatusVoided:
+		return PaymentOpVoid, true
+	case PaymentStatusRefunded:
+		return PaymentOpRefund, true
+	case PaymentStatusFailed:
+		return PaymentOpFail, true
+	}
+	return "", false
+}
+
+// UpgradeLegacy приводит платёж, сохранённый до жизненного цикла, к новым
+// статусам, а суммы, которых не было в старой записи, - к валюте платежа
+func (p *Payment) UpgradeLegacy() {
+	if p.Status == PaymentStatusLegacyCompleted {
+		p.Status = PaymentStatusCaptured
+		p.CapturedAmount = p.Amount
+	}
+
+	if p.Amount.Currency == "" {
+		p.Amount.Currency = money.DefaultCurrency
+	}
+	if p.CapturedAmount.Currency == "" {
+		p.CapturedAmount.Currency = p.Amount.Currency
+	}
+	if p.RefundedAmount.Currency == "" {
+		p.RefundedAmount.Currency = p.Amount.Currency
+	}
+}
+
+// Committed - какую часть суммы заказа покрывает платёж: зарезервированную
+// сумму, пока он не списан, и списанную за вычетом возвратов после
+func (p *Payment) Committed() money.Money {
+	switch p.Status {
+	case PaymentStatusPending, PaymentStatusAuthorized:
+		return p.Amount
+	case PaymentStatusCaptured, PaymentStatusPartiallyRefunded:
+		// Списанное и возвращённое всегда в валюте платежа
+		committed, _ := p.CapturedAmount.Sub(p.RefundedAmount)
+		return committed
+	}
+	return money.Zero(p.Amount.Currency)
+}
+
+// Clone возвращает независимую копию платежа
+func (p *Payment) Clone() *Payment {
+	clone := *p
+	clone.History = append([]PaymentTransition(nil), p.History...)
+	return &clone
 }
@@ -0,0 +1,445 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"platform/money"
+	"platform/pagination"
+
+	"payments-service/internal/models"
+)
+
+// rub - сумма в рублях
+func rub(amount float64) money.Money {
+	return money.FromMajor(amount, "RUB")
+}
+
+// Общий набор проверок, который обязан проходить каждый бэкенд PaymentRepository
+
+func TestJSONPaymentRepositoryConformance(t *testing.T) {
+	runPaymentRepositoryConformance(t, func(t *testing.T) PaymentRepository {
+		repo, err := NewJSONPaymentRepository(filepath.Join(t.TempDir(), "payments.json"))
+		if err != nil {
+			t.Fatalf("NewJSONPaymentRepository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestMemoryPaymentRepositoryConformance(t *testing.T) {
+	runPaymentRepositoryConformance(t, func(t *testing.T) PaymentRepository {
+		return NewMemoryPaymentRepository()
+	})
+}
+
+func TestTracedPaymentRepositoryConformance(t *testing.T) {
+	runPaymentRepositoryConformance(t, func(t *testing.T) PaymentRepository {
+		return NewTracedPaymentRepository(NewMemoryPaymentRepository())
+	})
+}
+
+func TestSQLitePaymentRepositoryConformance(t *testing.T) {
+	runPaymentRepositoryConformance(t, func(t *testing.T) PaymentRepository {
+		repo, err := NewSQLitePaymentRepository(filepath.Join(t.TempDir(), "payments.db"))
+		if err != nil {
+			t.Fatalf("NewSQLitePaymentRepository: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestJSONPaymentRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "payments.json")
+
+	repo, err := NewJSONPaymentRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONPaymentRepository: %v", err)
+	}
+	first, _ := repo.CreatePayment(ctx, 1, 10, rub(100))
+	repo.CreatePayment(ctx, 2, 11, rub(200))
+	repo.ApplyPaymentOperation(ctx, first.ID, models.PaymentOpAuthorize, rub(0), "tester", 0)
+	repo.DeletePaymentsByUserID(ctx, 2)
+
+	reopened, err := NewJSONPaymentRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := reopened.GetPaymentByID(ctx, first.ID)
+	if err != nil || got.Status != "authorized" || got.Version != 2 || len(got.History) != 1 {
+		t.Fatalf("got %+v, %v after reopen", got, err)
+	}
+	if payments, _ := reopened.GetPaymentsByUserID(ctx, 2); len(payments) != 0 {
+		t.Fatalf("deleted payments are back after reopen")
+	}
+}
+
+func TestJSONPaymentRepositoryKeepsStateOnStorageError(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewJSONPaymentRepository(filepath.Join(t.TempDir(), "payments.json"))
+	if err != nil {
+		t.Fatalf("NewJSONPaymentRepository: %v", err)
+	}
+	payment, _ := repo.CreatePayment(ctx, 1, 10, rub(100))
+	// Закрытое хранилище отклоняет запись в журнал
+	repo.storage.Close()
+
+	if _, err := repo.CreatePayment(ctx, 1, 11, rub(200)); err == nil {
+		t.Fatal("CreatePayment succeeded with failing storage")
+	}
+	if payments, _ := repo.GetPaymentsByUserID(ctx, 1); len(payments) != 1 {
+		t.Fatalf("%d payments in memory, want only the persisted one", len(payments))
+	}
+	if _, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpAuthorize, rub(0), "tester", 0); err == nil {
+		t.Fatal("ApplyPaymentOperation succeeded with failing storage")
+	}
+	if err := repo.DeletePaymentsByUserID(ctx, 1); err == nil {
+		t.Fatal("DeletePaymentsByUserID succeeded with failing storage")
+	}
+
+	got, err := repo.GetPaymentByID(ctx, payment.ID)
+	if err != nil || got.Status != models.PaymentStatusPending || got.Version != 1 || len(got.History) != 0 {
+		t.Fatalf("got %+v, %v; want the payment as persisted", got, err)
+	}
+}
+
+func TestJSONPaymentRepositoryUpgradesLegacyStatus(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "payments.json")
+
+	legacy := `[{"id": 1, "userId": 1, "orderId": 1, "amount": 40, "status": "completed",
+		"createdAt": "2025-12-05T06:31:00Z", "updatedAt": "2025-12-05T06:31:00Z"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewJSONPaymentRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONPaymentRepository: %v", err)
+	}
+	got, _ := repo.GetPaymentByID(ctx, 1)
+	if got.Status != "captured" || got.CapturedAmount != rub(40) || got.Version != 1 {
+		t.Fatalf("legacy payment loaded as %+v", got)
+	}
+
+	refunded, err := repo.ApplyPaymentOperation(ctx, 1, models.PaymentOpRefund, rub(15), "tester", 0)
+	if err != nil {
+		t.Fatalf("refund of legacy payment: %v", err)
+	}
+	if refunded.RefundedAmount != rub(15) || refunded.Committed() != rub(25) {
+		t.Fatalf("legacy payment after refund: %+v", refunded)
+	}
+}
+
+func runPaymentRepositoryConformance(t *testing.T, newRepo func(t *testing.T) PaymentRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+
+		payment, err := repo.CreatePayment(ctx, 7, 3, rub(99.5))
+		if err != nil {
+			t.Fatalf("CreatePayment: %v", err)
+		}
+		if payment.ID == 0 || payment.UserID != 7 || payment.OrderID != 3 || payment.Amount != rub(99.5) || payment.Status != "pending" {
+			t.Fatalf("unexpected payment %+v", payment)
+		}
+
+		got, err := repo.GetPaymentByID(ctx, payment.ID)
+		if err != nil {
+			t.Fatalf("GetPaymentByID: %v", err)
+		}
+		if got.ID != payment.ID || got.CreatedAt.IsZero() {
+			t.Fatalf("unexpected payment %+v", got)
+		}
+	})
+
+	t.Run("IDsAreUnique", func(t *testing.T) {
+		repo := newRepo(t)
+
+		a, _ := repo.CreatePayment(ctx, 1, 1, rub(1))
+		b, _ := repo.CreatePayment(ctx, 1, 1, rub(1))
+		if a.ID == b.ID {
+			t.Fatalf("duplicate id %d", a.ID)
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetPaymentByID(ctx, 42); err == nil {
+			t.Fatal("expected error for missing payment")
+		}
+	})
+
+	t.Run("GetByUserID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreatePayment(ctx, 1, 1, rub(1))
+		repo.CreatePayment(ctx, 2, 2, rub(1))
+		repo.CreatePayment(ctx, 1, 3, rub(1))
+
+		payments, err := repo.GetPaymentsByUserID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetPaymentsByUserID: %v", err)
+		}
+		if len(payments) != 2 {
+			t.Fatalf("got %d payments, want 2", len(payments))
+		}
+		for _, payment := range payments {
+			if payment.UserID != 1 {
+				t.Fatalf("payment %d belongs to user %d", payment.ID, payment.UserID)
+			}
+		}
+
+		none, err := repo.GetPaymentsByUserID(ctx, 3)
+		if err != nil || len(none) != 0 {
+			t.Fatalf("got %v, %v for user without payments", none, err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for i, userID := range []int64{1, 2, 1, 1, 2} {
+			if _, err := repo.CreatePayment(ctx, userID, int64(i+1), rub(1)); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+		if _, err := repo.ApplyPaymentOperation(ctx, 3, models.PaymentOpAuthorize, money.Money{}, "tester", 0); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		q := pagination.ListQuery{Limit: 2, Sort: pagination.SortByID, UserID: 1}
+		var got []int64
+		for pages := 0; ; pages++ {
+			if pages > 2 {
+				t.Fatalf("too many pages: %v", got)
+			}
+			page, err := repo.ListPayments(ctx, q)
+			if err != nil {
+				t.Fatalf("ListPayments: %v", err)
+			}
+			if page.Total != 3 {
+				t.Fatalf("total %d, want 3", page.Total)
+			}
+			for _, payment := range page.Items {
+				got = append(got, payment.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			last := page.Items[len(page.Items)-1]
+			q.After = &pagination.Cursor{Sort: q.Sort, ID: last.ID}
+		}
+		if len(got) != 3 || got[0] != 1 || got[1] != 3 || got[2] != 4 {
+			t.Fatalf("pages %v, want [1 3 4]", got)
+		}
+
+		page, err := repo.ListPayments(ctx, pagination.ListQuery{Limit: 10, Sort: pagination.SortByUpdatedAt, Desc: true, Status: "authorized"})
+		if err != nil {
+			t.Fatalf("ListPayments: %v", err)
+		}
+		if page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID != 3 || page.NextCursor != "" {
+			t.Fatalf("page by status %+v", page)
+		}
+
+		page, err = repo.ListPayments(ctx, pagination.ListQuery{Limit: 10, Sort: pagination.SortByID, OrderID: 4})
+		if err != nil {
+			t.Fatalf("ListPayments: %v", err)
+		}
+		if page.Total != 1 || len(page.Items) != 1 || page.Items[0].ID != 4 {
+			t.Fatalf("page by order %+v", page)
+		}
+	})
+
+	t.Run("GetByOrderID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreatePayment(ctx, 1, 10, rub(30))
+		repo.CreatePayment(ctx, 2, 11, rub(40))
+		repo.CreatePayment(ctx, 1, 10, rub(70))
+
+		payments, err := repo.GetPaymentsByOrderID(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetPaymentsByOrderID: %v", err)
+		}
+		if len(payments) != 2 {
+			t.Fatalf("got %d payments, want 2", len(payments))
+		}
+		for _, p := range payments {
+			if p.OrderID != 10 {
+				t.Fatalf("payment of order %d returned", p.OrderID)
+			}
+		}
+	})
+
+	t.Run("ApplyOperation", func(t *testing.T) {
+		repo := newRepo(t)
+
+		payment, _ := repo.CreatePayment(ctx, 1, 1, rub(1))
+		updated, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpAuthorize, rub(0), "tester", 0)
+		if err != nil {
+			t.Fatalf("ApplyPaymentOperation: %v", err)
+		}
+		if updated.Status != "authorized" {
+			t.Fatalf("status %q, want authorized", updated.Status)
+		}
+
+		got, _ := repo.GetPaymentByID(ctx, payment.ID)
+		if got.Status != "authorized" {
+			t.Fatalf("status %q not stored", got.Status)
+		}
+
+		if _, err := repo.ApplyPaymentOperation(ctx, 42, models.PaymentOpAuthorize, rub(0), "tester", 0); err == nil {
+			t.Fatal("expected error for missing payment")
+		}
+	})
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		repo := newRepo(t)
+		apply := func(id int64, op string, amount float64) (*models.Payment, error) {
+			return repo.ApplyPaymentOperation(ctx, id, op, rub(amount), "tester", 0)
+		}
+
+		payment, _ := repo.CreatePayment(ctx, 1, 1, rub(100))
+		apply(payment.ID, models.PaymentOpAuthorize, 0)
+
+		if _, err := apply(payment.ID, models.PaymentOpCapture, 150); !errors.Is(err, models.ErrInvalidAmount) {
+			t.Fatalf("capture over authorized: error %v, want ErrInvalidAmount", err)
+		}
+		_, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpCapture, money.New(8000, "USD"), "tester", 0)
+		if !errors.Is(err, models.ErrInvalidAmount) || !errors.Is(err, money.ErrCurrencyMismatch) {
+			t.Fatalf("capture in another currency: error %v, want ErrInvalidAmount and ErrCurrencyMismatch", err)
+		}
+		captured, err := apply(payment.ID, models.PaymentOpCapture, 80)
+		if err != nil || captured.Status != "captured" || captured.CapturedAmount != rub(80) {
+			t.Fatalf("capture = %+v, %v", captured, err)
+		}
+
+		if _, err := apply(payment.ID, models.PaymentOpRefund, 80.01); !errors.Is(err, models.ErrRefundExceedsCaptured) {
+			t.Fatalf("refund over captured: error %v, want ErrRefundExceedsCaptured", err)
+		}
+		partial, err := apply(payment.ID, models.PaymentOpRefund, 30)
+		if err != nil || partial.Status != "partially_refunded" || partial.RefundedAmount != rub(30) {
+			t.Fatalf("partial refund = %+v, %v", partial, err)
+		}
+		if _, err := apply(payment.ID, models.PaymentOpRefund, 50.5); !errors.Is(err, models.ErrRefundExceedsCaptured) {
+			t.Fatalf("second refund over remaining: error %v, want ErrRefundExceedsCaptured", err)
+		}
+		refunded, err := apply(payment.ID, models.PaymentOpRefund, 0)
+		if err != nil || refunded.Status != "refunded" || refunded.RefundedAmount != rub(80) {
+			t.Fatalf("full refund = %+v, %v", refunded, err)
+		}
+		if _, err := apply(payment.ID, models.PaymentOpRefund, 1); !errors.Is(err, models.ErrIllegalTransition) {
+			t.Fatalf("refund of refunded payment: error %v, want ErrIllegalTransition", err)
+		}
+
+		got, _ := repo.GetPaymentByID(ctx, payment.ID)
+		ops := []string{"authorize", "capture", "refund", "refund"}
+		if len(got.History) != len(ops) {
+			t.Fatalf("history %+v, want %d entries", got.History, len(ops))
+		}
+		for i, entry := range got.History {
+			if entry.Operation != ops[i] || entry.Actor != "tester" || entry.At.IsZero() {
+				t.Fatalf("history[%d] = %+v, want %s", i, entry, ops[i])
+			}
+		}
+		if last := got.History[3]; last.From != "partially_refunded" || last.To != "refunded" ||
+			last.Amount == nil || *last.Amount != rub(50) {
+			t.Fatalf("last history entry %+v", last)
+		}
+
+		voided, _ := repo.CreatePayment(ctx, 1, 2, rub(10))
+		if _, err := apply(voided.ID, models.PaymentOpCapture, 0); !errors.Is(err, models.ErrIllegalTransition) {
+			t.Fatalf("capture of pending payment: error %v, want ErrIllegalTransition", err)
+		}
+		apply(voided.ID, models.PaymentOpAuthorize, 0)
+		if got, err := apply(voided.ID, models.PaymentOpVoid, 0); err != nil || got.Status != "voided" {
+			t.Fatalf("void = %+v, %v", got, err)
+		}
+		if _, err := apply(voided.ID, models.PaymentOpRefund, 0); !errors.Is(err, models.ErrIllegalTransition) {
+			t.Fatalf("refund of voided payment: error %v, want ErrIllegalTransition", err)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repo := newRepo(t)
+
+		payment, _ := repo.CreatePayment(ctx, 1, 1, rub(1))
+		if payment.Version != 1 {
+			t.Fatalf("new payment version %d, want 1", payment.Version)
+		}
+
+		updated, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpAuthorize, rub(0), "tester", 1)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("conditional update = %+v, %v; want version 2", updated, err)
+		}
+
+		// Устаревшая версия не перезаписывает чужое изменение
+		if _, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpFail, rub(0), "tester", 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale update error %v, want ErrVersionMismatch", err)
+		}
+		if got, _ := repo.GetPaymentByID(ctx, payment.ID); got.Status != "authorized" || got.Version != 2 {
+			t.Fatalf("payment changed by stale write: %+v", got)
+		}
+	})
+
+	t.Run("ConcurrentConditionalUpdates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		payment, _ := repo.CreatePayment(ctx, 1, 1, rub(1))
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpAuthorize, rub(0), "tester", payment.Version)
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				} else if !errors.Is(err, ErrVersionMismatch) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 1 {
+			t.Fatalf("%d updates of the same version succeeded, want 1", succeeded)
+		}
+	})
+
+	t.Run("DeleteByUserID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreatePayment(ctx, 1, 1, rub(1))
+		repo.CreatePayment(ctx, 1, 2, rub(1))
+		kept, _ := repo.CreatePayment(ctx, 2, 3, rub(1))
+
+		if err := repo.DeletePaymentsByUserID(ctx, 1); err != nil {
+			t.Fatalf("DeletePaymentsByUserID: %v", err)
+		}
+		if payments, _ := repo.GetPaymentsByUserID(ctx, 1); len(payments) != 0 {
+			t.Fatalf("%d payments left for deleted user", len(payments))
+		}
+		if _, err := repo.GetPaymentByID(ctx, kept.ID); err != nil {
+			t.Fatalf("other user's payment removed: %v", err)
+		}
+		if err := repo.DeletePaymentsByUserID(ctx, 1); err != nil {
+			t.Fatalf("DeletePaymentsByUserID without payments: %v", err)
+		}
+	})
+}
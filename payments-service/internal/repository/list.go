@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"platform/pagination"
+
+	"payments-service/internal/models"
+)
+
+func paymentListKey(payment *models.Payment) pagination.ListKey {
+	return pagination.ListKey{
+		ID:        payment.ID,
+		UserID:    payment.UserID,
+		OrderID:   payment.OrderID,
+		Status:    payment.Status,
+		CreatedAt: payment.CreatedAt,
+		UpdatedAt: payment.UpdatedAt,
+	}
+}
@@ -0,0 +1,18 @@
+package repository
+
+import "payments-service/internal/models"
+
+// MemoryPaymentRepository - репозиторий без записи на диск, для тестов и локального запуска
+type MemoryPaymentRepository struct {
+	JSONPaymentRepository
+}
+
+// NewMemoryPaymentRepository создаёт пустой репозиторий в памяти
+func NewMemoryPaymentRepository() *MemoryPaymentRepository {
+	return &MemoryPaymentRepository{
+		JSONPaymentRepository: JSONPaymentRepository{
+			payments: make(map[int64]*models.Payment),
+			nextID:   1,
+		},
+	}
+}
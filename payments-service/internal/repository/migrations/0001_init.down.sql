@@ -0,0 +1,4 @@
+DROP TABLE json_imports;
+DROP INDEX idx_payments_order_id;
+DROP INDEX idx_payments_user_id;
+DROP TABLE payments;
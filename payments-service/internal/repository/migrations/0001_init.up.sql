@@ -0,0 +1,19 @@
+CREATE TABLE payments (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL,
+    order_id   INTEGER NOT NULL,
+    amount     REAL    NOT NULL DEFAULT 0,
+    status     TEXT    NOT NULL,
+    created_at TEXT    NOT NULL,
+    updated_at TEXT    NOT NULL
+);
+
+CREATE INDEX idx_payments_user_id ON payments (user_id);
+CREATE INDEX idx_payments_order_id ON payments (order_id);
+
+-- Какие JSON-файлы уже перенесены импортёром
+CREATE TABLE json_imports (
+    source      TEXT PRIMARY KEY,
+    records     INTEGER NOT NULL,
+    imported_at TEXT    NOT NULL
+);
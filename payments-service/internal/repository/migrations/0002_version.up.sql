@@ -0,0 +1,2 @@
+-- Версия для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE payments ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
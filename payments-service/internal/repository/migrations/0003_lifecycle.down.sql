@@ -0,0 +1,5 @@
+UPDATE payments SET status = 'completed' WHERE status = 'captured';
+
+ALTER TABLE payments DROP COLUMN history;
+ALTER TABLE payments DROP COLUMN refunded_amount;
+ALTER TABLE payments DROP COLUMN captured_amount;
@@ -0,0 +1,7 @@
+-- Жизненный цикл платежа: списанная и возвращённая суммы, история операций
+ALTER TABLE payments ADD COLUMN captured_amount REAL NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN refunded_amount REAL NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN history TEXT NOT NULL DEFAULT '[]';
+
+-- Старый статус completed означал полностью списанный платёж
+UPDATE payments SET status = 'captured', captured_amount = amount WHERE status = 'completed';
@@ -0,0 +1,13 @@
+ALTER TABLE payments ADD COLUMN amount REAL NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN captured_amount REAL NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN refunded_amount REAL NOT NULL DEFAULT 0;
+
+UPDATE payments SET
+    amount = amount_minor / 100.0,
+    captured_amount = captured_minor / 100.0,
+    refunded_amount = refunded_minor / 100.0;
+
+ALTER TABLE payments DROP COLUMN refunded_minor;
+ALTER TABLE payments DROP COLUMN captured_minor;
+ALTER TABLE payments DROP COLUMN amount_minor;
+ALTER TABLE payments DROP COLUMN currency;
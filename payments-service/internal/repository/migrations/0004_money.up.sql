@@ -0,0 +1,16 @@
+-- Суммы хранятся целым числом минимальных единиц валюты (копеек) и кодом валюты ISO 4217.
+-- Все суммы до появления валют - в рублях. Суммы в history остаются числами
+-- и читаются как рубли, новые записи пишутся как {"minor", "currency"}.
+ALTER TABLE payments ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB';
+ALTER TABLE payments ADD COLUMN amount_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN captured_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN refunded_minor INTEGER NOT NULL DEFAULT 0;
+
+UPDATE payments SET
+    amount_minor = CAST(ROUND(amount * 100) AS INTEGER),
+    captured_minor = CAST(ROUND(captured_amount * 100) AS INTEGER),
+    refunded_minor = CAST(ROUND(refunded_amount * 100) AS INTEGER);
+
+ALTER TABLE payments DROP COLUMN amount;
+ALTER TABLE payments DROP COLUMN captured_amount;
+ALTER TABLE payments DROP COLUMN refunded_amount;
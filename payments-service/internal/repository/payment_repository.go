@@ -1,27 +1,37 @@
 package repository
 
 import (
-	"fmt"
+	"context"
 	"sync"
 	"time"
 
+	"platform/money"
+	"platform/pagination"
+	"platform/storage"
+
 	"payments-service/internal/models"
-	"payments-service/internal/utils"
 )
 
-// JSONPaymentRepository - репозиторий с JSON-хранилищем
+// JSONPaymentRepository - репозиторий с JSON-хранилищем.
+//
+// Блокировки: mu защищает карту и nextID (чтение под RLock, изменения под
+// Lock), persistMu упорядочивает запись в журнал. Изменение захватывает
+// persistMu до того, как отпустить mu, поэтому записи попадают в журнал в
+// том же порядке, что и в карту, а читатели не ждут fsync. Наружу отдаются
+// только копии платежей.
 type JSONPaymentRepository struct {
-	mu       sync.RWMutex
-	storage  *utils.FileStorage
-	payments map[int64]*models.Payment
-	nextID   int64
-	filePath string
+	mu        sync.RWMutex
+	persistMu sync.Mutex
+	storage   *storage.FileStorage
+	payments  map[int64]*models.Payment
+	nextID    int64
+	filePath  string
 }
 
 // NewJSONPaymentRepository создаёт новый репозиторий
 func NewJSONPaymentRepository(filePath string) (*JSONPaymentRepository, error) {
 	repo := &JSONPaymentRepository{
-		storage:  utils.NewFileStorage(filePath),
+		storage:  storage.NewFileStorage(filePath),
 		payments: make(map[int64]*models.Payment),
 		nextID:   1,
 		filePath: filePath,
@@ -40,18 +50,27 @@ func NewJSONPaymentRepository(filePath string) (*JSONPaymentRepository, error) {
 
 // LoadFromFile загружает платежи из JSON
 func (r *JSONPaymentRepository) LoadFromFile() error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+	if r.storage == nil {
+		return nil
+	}
 
 	var payments []*models.Payment
 	if err := r.storage.LoadJSON(&payments); err != nil {
 		return err
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.payments = make(map[int64]*models.Payment)
 	r.nextID = 1
 
 	for _, payment := range payments {
+		// Записи, сохранённые до появления версий, начинают с первой
+		if payment.Version == 0 {
+			payment.Version = 1
+		}
+		payment.UpgradeLegacy()
 		r.payments[payment.ID] = payment
 		if payment.ID >= r.nextID {
 			r.nextID = payment.ID + 1
@@ -61,111 +80,235 @@ func (r *JSONPaymentRepository) LoadFromFile() error {
 	return nil
 }
 
-// SaveToFile сохраняет платежи в JSON
+// SaveToFile сохраняет полный снимок платежей в JSON
 func (r *JSONPaymentRepository) SaveToFile() error {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+	if r.storage == nil {
+		return nil
+	}
 
+	r.mu.RLock()
 	payments := make([]*models.Payment, 0, len(r.payments))
 	for _, payment := range r.payments {
-		payments = append(payments, payment)
+		payments = append(payments, payment.Clone())
 	}
 
+	// Держим очередь журнала до конца записи снимка: иначе более позднее
+	// изменение попадёт в журнал и будет стёрто при его очистке
+	r.persistMu.Lock()
+	r.mu.RUnlock()
+	defer r.persistMu.Unlock()
+
 	return r.storage.SaveJSON(payments)
 }
 
-// CreatePayment создаёт новый платёж (с проверкой пользователя)
-func (r *JSONPaymentRepository) CreatePayment(userID, orderID int64, amount float64) (*models.Payment, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+// Close дожидается записи в журнал и сворачивает его в снимок; изменения
+// после этого не сохраняются (storage.ErrClosed)
+func (r *JSONPaymentRepository) Close() error {
+	if r.storage == nil {
+		return nil
+	}
+
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	return r.storage.Close()
+}
+
+// unlockAndPersist отпускает mu и дописывает updated в журнал. Если запись
+// не удалась, в карту возвращается previous (nil - платежа не было), чтобы
+// память не расходилась с журналом.
+func (r *JSONPaymentRepository) unlockAndPersist(previous, updated *models.Payment) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
 
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	err := r.storage.Put(updated.ID, updated)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	// mu берётся после persistMu: изменения захватывают их в обратном порядке.
+	// Запись, которую успели изменить снова, не откатывается.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.payments[updated.ID]; ok && current.Version == updated.Version {
+		if previous == nil {
+			delete(r.payments, updated.ID)
+		} else {
+			r.payments[updated.ID] = previous
+		}
+	}
+	return err
+}
+
+// unlockAndPersistDelete отпускает mu и дописывает удаление в журнал; если
+// запись не удалась, удалённые платежи возвращаются в карту
+func (r *JSONPaymentRepository) unlockAndPersistDelete(deleted ...*models.Payment) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
+
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	ids := make([]int64, len(deleted))
+	for i, payment := range deleted {
+		ids[i] = payment.ID
+	}
+	err := r.storage.Delete(ids...)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, payment := range deleted {
+		r.payments[payment.ID] = payment
+	}
+	return err
+}
+
+// CreatePayment создаёт новый платёж (с проверкой пользователя)
+func (r *JSONPaymentRepository) CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money) (*models.Payment, error) {
+	now := time.Now()
 	payment := &models.Payment{
-		ID:        r.nextID,
-		UserID:    userID,
-		OrderID:   orderID,
-		Amount:    amount,
-		Status:    "pending",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		UserID:         userID,
+		OrderID:        orderID,
+		Amount:         amount,
+		CapturedAmount: money.Zero(amount.Currency),
+		RefundedAmount: money.Zero(amount.Currency),
+		Status:         models.PaymentStatusPending,
+		Version:        1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
 	}
 
+	r.mu.Lock()
+	payment.ID = r.nextID
 	r.payments[payment.ID] = payment
 	r.nextID++
+	created := payment.Clone()
 
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(nil, created); err != nil {
 		return nil, err
 	}
 
-	return payment, nil
+	return created, nil
 }
 
 // GetPaymentByID получает платёж по ID
-func (r *JSONPaymentRepository) GetPaymentByID(id int64) (*models.Payment, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONPaymentRepository) GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	payment, exists := r.payments[id]
 	if !exists {
-		return nil, fmt.Errorf("payment not found")
+		return nil, ErrPaymentNotFound
 	}
 
-	return payment, nil
+	return payment.Clone(), nil
+}
+
+// ListPayments возвращает страницу платежей
+func (r *JSONPaymentRepository) ListPayments(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Payment], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payments := make([]*models.Payment, 0, len(r.payments))
+	for _, payment := range r.payments {
+		payments = append(payments, payment)
+	}
+
+	page := pagination.Paginate(payments, q, paymentListKey)
+	for i, payment := range page.Items {
+		page.Items[i] = payment.Clone()
+	}
+	return page, nil
 }
 
 // GetPaymentsByUserID получает все платежи пользователя
-func (r *JSONPaymentRepository) GetPaymentsByUserID(userID int64) ([]*models.Payment, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONPaymentRepository) GetPaymentsByUserID(ctx context.Context, userID int64) ([]*models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	var userPayments []*models.Payment
 	for _, payment := range r.payments {
 		if payment.UserID == userID {
-			userPayments = append(userPayments, payment)
+			userPayments = append(userPayments, payment.Clone())
 		}
 	}
 
 	return userPayments, nil
 }
 
-// UpdatePaymentStatus обновляет статус платежа
-func (r *JSONPaymentRepository) UpdatePaymentStatus(id int64, status string) (*models.Payment, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+// GetPaymentsByOrderID получает все платежи заказа
+func (r *JSONPaymentRepository) GetPaymentsByOrderID(ctx context.Context, orderID int64) ([]*models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var orderPayments []*models.Payment
+	for _, payment := range r.payments {
+		if payment.OrderID == orderID {
+			orderPayments = append(orderPayments, payment.Clone())
+		}
+	}
+
+	return orderPayments, nil
+}
+
+// ApplyPaymentOperation выполняет операцию жизненного цикла платежа
+func (r *JSONPaymentRepository) ApplyPaymentOperation(ctx context.Context, id int64, op string, amount money.Money, actor string, expectedVersion int64) (*models.Payment, error) {
+	r.mu.Lock()
 
 	payment, exists := r.payments[id]
 	if !exists {
-		return nil, fmt.Errorf("payment not found")
+		r.mu.Unlock()
+		return nil, ErrPaymentNotFound
+	}
+	if expectedVersion != 0 && payment.Version != expectedVersion {
+		r.mu.Unlock()
+		return nil, ErrVersionMismatch
 	}
+	previous := payment.Clone()
 
-	payment.Status = status
-	payment.UpdatedAt = time.Now()
+	if err := payment.Apply(op, amount, actor, time.Now()); err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	payment.Version++
+	updated := payment.Clone()
 
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(previous, updated); err != nil {
 		return nil, err
 	}
 
-	return payment, nil
+	return updated, nil
 }
 
 // DeletePaymentsByUserID удаляет все платежи пользователя
-func (r *JSONPaymentRepository) DeletePaymentsByUserID(userID int64) error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONPaymentRepository) DeletePaymentsByUserID(ctx context.Context, userID int64) error {
+	r.mu.Lock()
 
-	paymentsToDelete := []int64{}
-	for id, payment := range r.payments {
+	paymentsToDelete := []*models.Payment{}
+	for _, payment := range r.payments {
 		if payment.UserID == userID {
-			paymentsToDelete = append(paymentsToDelete, id)
+			paymentsToDelete = append(paymentsToDelete, payment)
 		}
 	}
 
-	for _, id := range paymentsToDelete {
-		delete(r.payments, id)
+	for _, payment := range paymentsToDelete {
+		delete(r.payments, payment.ID)
 	}
 
-	if len(paymentsToDelete) > 0 {
-		return r.SaveToFile()
+	if len(paymentsToDelete) == 0 {
+		r.mu.Unlock()
+		return nil
 	}
 
-	return nil
+	return r.unlockAndPersistDelete(paymentsToDelete...)
 }
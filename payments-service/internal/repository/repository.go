@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+
+	"platform/money"
+	"platform/pagination"
+	"platform/problem"
+	"platform/storage"
+
+	"payments-service/internal/models"
+)
+
+var (
+	// ErrVersionMismatch - запись изменилась с тех пор, как клиент её прочитал
+	ErrVersionMismatch = storage.ErrVersionMismatch
+	// ErrPaymentNotFound - платежа с таким ID нет
+	ErrPaymentNotFound = problem.New(problem.NotFound, "payment_not_found", "payment not found")
+)
+
+// PaymentRepository - хранилище платежей, от которого зависят обработчики.
+// Реализации: JSONPaymentRepository (файл), MemoryPaymentRepository (память),
+// SQLitePaymentRepository (встроенная база).
+type PaymentRepository interface {
+	CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money) (*models.Payment, error)
+	GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error)
+	GetPaymentsByUserID(ctx context.Context, userID int64) ([]*models.Payment, error)
+	// ListPayments возвращает страницу платежей по статусу, пользователю и датам
+	ListPayments(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Payment], error)
+	GetPaymentsByOrderID(ctx context.Context, orderID int64) ([]*models.Payment, error)
+	// ApplyPaymentOperation выполняет операцию жизненного цикла (models.PaymentOp*)
+	// и пишет переход в историю от имени actor. amount - сумма capture/refund,
+	// нулевая - вся доступная. expectedVersion != 0 - изменить, только если версия
+	// платежа совпадает, иначе ErrVersionMismatch
+	ApplyPaymentOperation(ctx context.Context, id int64, op string, amount money.Money, actor string, expectedVersion int64) (*models.Payment, error)
+	DeletePaymentsByUserID(ctx context.Context, userID int64) error
+	// Close дописывает незавершённые изменения и освобождает хранилище
+	Close() error
+}
+
+var (
+	_ PaymentRepository = (*JSONPaymentRepository)(nil)
+	_ PaymentRepository = (*MemoryPaymentRepository)(nil)
+	_ PaymentRepository = (*SQLitePaymentRepository)(nil)
+)
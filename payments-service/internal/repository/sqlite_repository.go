@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"platform/money"
+	"platform/pagination"
+	"platform/storage"
+
+	"payments-service/internal/models"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations - версионированные миграции схемы SQLite
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrationFiles, "migrations")
+	return sub
+}
+
+// SQLitePaymentRepository - репозиторий платежей во встроенной базе SQLite
+type SQLitePaymentRepository struct {
+	db *sql.DB
+}
+
+const paymentColumns = `id, user_id, order_id, amount_minor, captured_minor, refunded_minor, currency, status, version, history, created_at, updated_at`
+
+// NewSQLitePaymentRepository открывает базу и применяет недостающие миграции
+func NewSQLitePaymentRepository(dsn string) (*SQLitePaymentRepository, error) {
+	db, err := storage.OpenSQLite(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.Migrate(context.Background(), db, Migrations()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLitePaymentRepository{db: db}, nil
+}
+
+// Close закрывает соединение с базой
+func (r *SQLitePaymentRepository) Close() error {
+	return r.db.Close()
+}
+
+// CreatePayment создаёт новый платёж
+func (r *SQLitePaymentRepository) CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money) (*models.Payment, error) {
+	now := time.Now()
+	payment := &models.Payment{
+		UserID:         userID,
+		OrderID:        orderID,
+		Amount:         amount,
+		CapturedAmount: money.Zero(amount.Currency),
+		RefundedAmount: money.Zero(amount.Currency),
+		Status:         models.PaymentStatusPending,
+		Version:        1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO payments (user_id, order_id, amount_minor, currency, status, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		payment.UserID, payment.OrderID, payment.Amount.Minor, payment.Amount.Currency, payment.Status, payment.Version,
+		storage.FormatTime(payment.CreatedAt), storage.FormatTime(payment.UpdatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	payment.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetPaymentByID получает платёж по ID
+func (r *SQLitePaymentRepository) GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = ?`, id)
+
+	payment, err := scanPayment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// ListPayments возвращает страницу платежей
+func (r *SQLitePaymentRepository) ListPayments(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.Payment], error) {
+	filter, filterArgs, page, pageArgs := storage.ListSQL(q)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM payments`+filter, filterArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+paymentColumns+` FROM payments`+page, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(payments, q, total, paymentListKey), nil
+}
+
+// GetPaymentsByUserID получает все платежи пользователя
+func (r *SQLitePaymentRepository) GetPaymentsByUserID(ctx context.Context, userID int64) ([]*models.Payment, error) {
+	return r.queryPayments(ctx, `SELECT `+paymentColumns+` FROM payments WHERE user_id = ? ORDER BY id`, userID)
+}
+
+func (r *SQLitePaymentRepository) queryPayments(ctx context.Context, query string, args ...interface{}) ([]*models.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*models.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// GetPaymentsByOrderID получает все платежи заказа
+func (r *SQLitePaymentRepository) GetPaymentsByOrderID(ctx context.Context, orderID int64) ([]*models.Payment, error) {
+	return r.queryPayments(ctx, `SELECT `+paymentColumns+` FROM payments WHERE order_id = ? ORDER BY id`, orderID)
+}
+
+// ApplyPaymentOperation выполняет операцию жизненного цикла платежа
+func (r *SQLitePaymentRepository) ApplyPaymentOperation(ctx context.Context, id int64, op string, amount money.Money, actor string, expectedVersion int64) (*models.Payment, error) {
+	err := storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		payment, err := scanPayment(tx.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = ?`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && payment.Version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		if err := payment.Apply(op, amount, actor, time.Now()); err != nil {
+			return err
+		}
+		historyJSON, err := json.Marshal(payment.History)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE payments SET status = ?, captured_minor = ?, refunded_minor = ?, history = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+			payment.Status, payment.CapturedAmount.Minor, payment.RefundedAmount.Minor, string(historyJSON),
+			storage.FormatTime(payment.UpdatedAt), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetPaymentByID(ctx, id)
+}
+
+// DeletePaymentsByUserID удаляет все платежи пользователя
+func (r *SQLitePaymentRepository) DeletePaymentsByUserID(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM payments WHERE user_id = ?`, userID)
+	return err
+}
+
+// ImportJSON один раз переносит платежи из JSON-хранилища в базу.
+// Повторный вызов для того же файла ничего не делает и возвращает 0.
+func (r *SQLitePaymentRepository) ImportJSON(ctx context.Context, filePath string) (int, error) {
+	var imported bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM json_imports WHERE source = ?)`, filePath).Scan(&imported)
+	if err != nil || imported {
+		return 0, err
+	}
+
+	var payments []*models.Payment
+	if err := storage.NewFileStorage(filePath).LoadJSON(&payments); err != nil {
+		return 0, err
+	}
+
+	err = storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, payment := range payments {
+			payment.UpgradeLegacy()
+			historyJSON, err := json.Marshal(payment.History)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO payments (`+paymentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				payment.ID, payment.UserID, payment.OrderID, payment.Amount.Minor, payment.CapturedAmount.Minor,
+				payment.RefundedAmount.Minor, payment.Amount.Currency, payment.Status, max(payment.Version, 1), string(historyJSON),
+				storage.FormatTime(payment.CreatedAt), storage.FormatTime(payment.UpdatedAt))
+			if err != nil {
+				return fmt.Errorf("import payment %d: %w", payment.ID, err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO json_imports (source, records, imported_at) VALUES (?, ?, ?)`,
+			filePath, len(payments), storage.FormatTime(time.Now()))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(payments), nil
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var payment models.Payment
+	var currency, history, createdAt, updatedAt string
+	var amount, captured, refunded int64
+
+	err := row.Scan(&payment.ID, &payment.UserID, &payment.OrderID, &amount, &captured, &refunded, &currency,
+		&payment.Status, &payment.Version, &history, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	payment.Amount = money.New(amount, currency)
+	payment.CapturedAmount = money.New(captured, currency)
+	payment.RefundedAmount = money.New(refunded, currency)
+
+	if err := json.Unmarshal([]byte(history), &payment.History); err != nil {
+		return nil, fmt.Errorf("payment %d: decode history: %w", payment.ID, err)
+	}
+	if payment.CreatedAt, err = storage.ParseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if payment.UpdatedAt, err = storage.ParseTime(updatedAt); err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
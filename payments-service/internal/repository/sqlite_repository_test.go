@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"platform/storage"
+
+	"payments-service/internal/models"
+)
+
+func TestSQLiteMigrationsUpDown(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "payments.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := storage.LoadMigrations(Migrations())
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != latest {
+		t.Fatalf("schema version %d, want %d", v, latest)
+	}
+
+	// Повторный запуск ничего не делает
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+
+	if err := storage.MigrateDown(ctx, db, Migrations(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != 0 {
+		t.Fatalf("schema version %d after rollback, want 0", v)
+	}
+	var tables int
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'payments'`).Scan(&tables)
+	if tables != 0 {
+		t.Fatal("payments table survived rollback")
+	}
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate after rollback: %v", err)
+	}
+}
+
+func TestSQLiteImportJSON(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "payments.json")
+
+	source, err := NewJSONPaymentRepository(jsonPath)
+	if err != nil {
+		t.Fatalf("NewJSONPaymentRepository: %v", err)
+	}
+	first, _ := source.CreatePayment(ctx, 1, 10, rub(100))
+	source.CreatePayment(ctx, 2, 11, rub(50))
+	source.ApplyPaymentOperation(ctx, first.ID, models.PaymentOpAuthorize, rub(0), "tester", 0)
+	source.ApplyPaymentOperation(ctx, first.ID, models.PaymentOpCapture, rub(60), "tester", 0)
+
+	repo, err := NewSQLitePaymentRepository(filepath.Join(dir, "payments.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePaymentRepository: %v", err)
+	}
+	defer repo.Close()
+
+	n, err := repo.ImportJSON(ctx, jsonPath)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportJSON = %d, %v; want 2", n, err)
+	}
+
+	got, err := repo.GetPaymentByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetPaymentByID: %v", err)
+	}
+	if got.Status != "captured" || got.Version != 3 || got.Amount != rub(100) || got.CapturedAmount != rub(60) || len(got.History) != 2 ||
+		got.OrderID != 10 || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("imported payment %+v differs from %+v", got, first)
+	}
+
+	// Импорт однократный
+	if n, err := repo.ImportJSON(ctx, jsonPath); err != nil || n != 0 {
+		t.Fatalf("second ImportJSON = %d, %v; want 0", n, err)
+	}
+
+	// Новые записи не конфликтуют с перенесёнными ID
+	created, err := repo.CreatePayment(ctx, 3, 12, rub(1))
+	if err != nil || created.ID <= 2 {
+		t.Fatalf("CreatePayment after import = %+v, %v", created, err)
+	}
+}
+
+func TestSQLiteMoneyMigrationKeepsAmounts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "payments.db")
+
+	db, err := storage.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if err := storage.MigrateDown(ctx, db, Migrations(), 3); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO payments (user_id, order_id, amount, captured_amount, refunded_amount, status, history, created_at, updated_at)
+		VALUES (1, 1, 59.97, 59.97, 0.1, 'partially_refunded', '[{"operation":"refund","from":"captured","to":"partially_refunded","amount":0.1,"actor":"tester","at":"2024-01-02T03:04:05Z"}]',
+		'2024-01-02T03:04:05Z', '2024-01-02T03:04:05Z')`)
+	if err != nil {
+		t.Fatalf("insert payment at version 3: %v", err)
+	}
+	db.Close()
+
+	repo, err := NewSQLitePaymentRepository(path)
+	if err != nil {
+		t.Fatalf("NewSQLitePaymentRepository: %v", err)
+	}
+	defer repo.Close()
+
+	payment, err := repo.GetPaymentByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetPaymentByID: %v", err)
+	}
+	if payment.Amount != rub(59.97) || payment.CapturedAmount != rub(59.97) || payment.RefundedAmount != rub(0.1) ||
+		*payment.History[0].Amount != rub(0.1) || payment.Committed() != rub(59.87) {
+		t.Fatalf("amounts after migration: %+v", payment)
+	}
+}
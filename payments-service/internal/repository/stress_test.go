@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"platform/storage/storagetest"
+
+	"payments-service/internal/models"
+)
+
+// Стресс-тесты рассчитаны на запуск с детектором гонок: go test -race ./...
+
+const stressPayments = 50
+
+func stressPaymentBackends() map[string]func(t *testing.T) PaymentRepository {
+	return map[string]func(t *testing.T) PaymentRepository{
+		"json": func(t *testing.T) PaymentRepository {
+			repo, err := NewJSONPaymentRepository(filepath.Join(t.TempDir(), "payments.json"))
+			if err != nil {
+				t.Fatalf("NewJSONPaymentRepository: %v", err)
+			}
+			return repo
+		},
+		"memory": func(t *testing.T) PaymentRepository {
+			return NewMemoryPaymentRepository()
+		},
+		"sqlite": func(t *testing.T) PaymentRepository {
+			repo, err := NewSQLitePaymentRepository(filepath.Join(t.TempDir(), "payments.db"))
+			if err != nil {
+				t.Fatalf("NewSQLitePaymentRepository: %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+}
+
+func TestPaymentRepositoryConcurrentAccess(t *testing.T) {
+	for name, newRepo := range stressPaymentBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			var ids storagetest.IDs
+
+			storagetest.Run(t, stressPayments,
+				// Писатель: создаёт и обновляет платежи своего пользователя,
+				// заодно создаёт и удаляет платежи временного пользователя
+				func(w, i int) error {
+					userID := int64(w + 1)
+					payment, err := repo.CreatePayment(ctx, userID, int64(i), rub(float64(i)))
+					if err != nil {
+						return err
+					}
+					if err := ids.Add(payment.ID); err != nil {
+						return err
+					}
+					if _, err := repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpAuthorize, rub(0), "tester", 0); err != nil {
+						return err
+					}
+
+					if _, err := repo.CreatePayment(ctx, userID+1000, int64(i), rub(1)); err != nil {
+						return err
+					}
+					if i%5 == 0 {
+						return repo.DeletePaymentsByUserID(ctx, userID+1000)
+					}
+					return nil
+				},
+				// Читатель: параллельно листает платежи и читает отдельные записи
+				func(w, i int) error {
+					userID := int64(w + 1)
+					payments, err := repo.GetPaymentsByUserID(ctx, userID)
+					if err != nil {
+						return err
+					}
+					for _, payment := range payments {
+						if payment.UserID != userID {
+							return fmt.Errorf("payment %d of user %d listed for user %d", payment.ID, payment.UserID, userID)
+						}
+						repo.GetPaymentByID(ctx, payment.ID)
+					}
+					return nil
+				},
+			)
+
+			for w := 0; w < storagetest.Workers; w++ {
+				payments, _ := repo.GetPaymentsByUserID(ctx, int64(w+1))
+				if len(payments) != stressPayments {
+					t.Fatalf("user %d: %d payments, want %d", w+1, len(payments), stressPayments)
+				}
+				for _, payment := range payments {
+					if payment.Status != "authorized" {
+						t.Fatalf("payment %d: status %q, want authorized", payment.ID, payment.Status)
+					}
+				}
+
+				// После последнего удаления (i=45) остались платежи с i=46..49
+				scratch, _ := repo.GetPaymentsByUserID(ctx, int64(w+1001))
+				if len(scratch) != 4 {
+					t.Fatalf("scratch user %d: %d payments, want 4", w+1001, len(scratch))
+				}
+			}
+		})
+	}
+}
+
+func TestJSONPaymentRepositoryConcurrentWritesSurviveReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "payments.json")
+
+	repo, err := NewJSONPaymentRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONPaymentRepository: %v", err)
+	}
+	repo.storage.SetCompactEvery(7) // компактификация посреди параллельной записи
+
+	storagetest.Run(t, stressPayments,
+		func(w, i int) error {
+			payment, err := repo.CreatePayment(ctx, int64(w+1), int64(i), rub(1))
+			if err != nil {
+				return err
+			}
+			repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpAuthorize, rub(0), "tester", 0)
+			repo.ApplyPaymentOperation(ctx, payment.ID, models.PaymentOpCapture, rub(0), "tester", 0)
+			return nil
+		},
+		// Полный снимок параллельно с журналом
+		func(w, i int) error {
+			if w > 0 || i >= 10 {
+				return nil
+			}
+			return repo.SaveToFile()
+		},
+	)
+
+	reopened, err := NewJSONPaymentRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	for w := 0; w < storagetest.Workers; w++ {
+		payments, _ := reopened.GetPaymentsByUserID(ctx, int64(w+1))
+		if len(payments) != stressPayments {
+			t.Fatalf("user %d: %d payments after reload, want %d", w+1, len(payments), stressPayments)
+		}
+		for _, payment := range payments {
+			if payment.Status != "captured" || payment.CapturedAmount != rub(1) {
+				t.Fatalf("payment %d: status %q after reload", payment.ID, payment.Status)
+			}
+		}
+	}
+}
+
+func TestPaymentRepositoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryPaymentRepository()
+
+	created, _ := repo.CreatePayment(ctx, 1, 1, rub(10))
+	created.Status = "hacked"
+	created.Amount = rub(0)
+
+	got, _ := repo.GetPaymentByID(ctx, created.ID)
+	if got.Status != "pending" || got.Amount != rub(10) {
+		t.Fatalf("stored payment changed through returned pointer: %+v", got)
+	}
+
+	listed, _ := repo.GetPaymentsByUserID(ctx, 1)
+	listed[0].Status = "hacked"
+	if got, _ := repo.GetPaymentByID(ctx, created.ID); got.Status != "pending" {
+		t.Fatalf("stored payment changed through listed pointer: %+v", got)
+	}
+}
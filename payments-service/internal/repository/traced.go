@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"platform/money"
+	"platform/pagination"
+	"platform/storage"
+	"platform/tracing"
+
+	"payments-service/internal/models"
+)
+
+// TracedPaymentRepository пишет span на каждый вызов хранилища next (storage.Traced)
+type TracedPaymentRepository struct {
+	storage.Traced[PaymentRepository]
+}
+
+var _ PaymentRepository = (*TracedPaymentRepository)(nil)
+
+// NewTracedPaymentRepository оборачивает хранилище next трассировкой
+func NewTracedPaymentRepository(next PaymentRepository) *TracedPaymentRepository {
+	return &TracedPaymentRepository{storage.NewTraced("PaymentRepository", next)}
+}
+
+func (r *TracedPaymentRepository) CreatePayment(ctx context.Context, userID, orderID int64, amount money.Money) (payment *models.Payment, err error) {
+	ctx, span := r.Start(ctx, "CreatePayment", attribute.Int64("user.id", userID), attribute.Int64("order.id", orderID))
+	defer tracing.End(span, &err)
+	return r.Next.CreatePayment(ctx, userID, orderID, amount)
+}
+
+func (r *TracedPaymentRepository) GetPaymentByID(ctx context.Context, id int64) (payment *models.Payment, err error) {
+	ctx, span := r.Start(ctx, "GetPaymentByID", attribute.Int64("payment.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.GetPaymentByID(ctx, id)
+}
+
+func (r *TracedPaymentRepository) GetPaymentsByUserID(ctx context.Context, userID int64) (payments []*models.Payment, err error) {
+	ctx, span := r.Start(ctx, "GetPaymentsByUserID", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.GetPaymentsByUserID(ctx, userID)
+}
+
+func (r *TracedPaymentRepository) ListPayments(ctx context.Context, q pagination.ListQuery) (page *pagination.Page[*models.Payment], err error) {
+	ctx, span := r.Start(ctx, "ListPayments")
+	defer tracing.End(span, &err)
+	return r.Next.ListPayments(ctx, q)
+}
+
+func (r *TracedPaymentRepository) GetPaymentsByOrderID(ctx context.Context, orderID int64) (payments []*models.Payment, err error) {
+	ctx, span := r.Start(ctx, "GetPaymentsByOrderID", attribute.Int64("order.id", orderID))
+	defer tracing.End(span, &err)
+	return r.Next.GetPaymentsByOrderID(ctx, orderID)
+}
+
+func (r *TracedPaymentRepository) ApplyPaymentOperation(ctx context.Context, id int64, op string, amount money.Money, actor string, expectedVersion int64) (payment *models.Payment, err error) {
+	ctx, span := r.Start(ctx, "ApplyPaymentOperation", attribute.Int64("payment.id", id), attribute.String("payment.operation", op))
+	defer tracing.End(span, &err)
+	return r.Next.ApplyPaymentOperation(ctx, id, op, amount, actor, expectedVersion)
+}
+
+func (r *TracedPaymentRepository) DeletePaymentsByUserID(ctx context.Context, userID int64) (err error) {
+	ctx, span := r.Start(ctx, "DeletePaymentsByUserID", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.DeletePaymentsByUserID(ctx, userID)
+}
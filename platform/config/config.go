@@ -0,0 +1,44 @@
+// Package config читает настройки сервиса из переменных окружения.
+// Пустая переменная равна незаданной: берётся значение по умолчанию.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// String - значение переменной name или fallback
+func String(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Int - целое из переменной name или fallback
+func Int(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
+
+// Duration - положительная длительность из переменной name (например 45s) или fallback
+func Duration(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s %q: want a positive duration", name, value)
+	}
+	return d, nil
+}
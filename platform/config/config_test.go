@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	t.Setenv("CONFIG_TEST_URL", "")
+	if got := String("CONFIG_TEST_URL", "http://users-service:8081"); got != "http://users-service:8081" {
+		t.Fatalf("unset: %q", got)
+	}
+	t.Setenv("CONFIG_TEST_URL", "http://127.0.0.1:9000")
+	if got := String("CONFIG_TEST_URL", "http://users-service:8081"); got != "http://127.0.0.1:9000" {
+		t.Fatalf("set: %q", got)
+	}
+}
+
+func TestInt(t *testing.T) {
+	t.Setenv("CONFIG_TEST_DIGITS", "12")
+	if n, err := Int("CONFIG_TEST_DIGITS", 10); err != nil || n != 12 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	t.Setenv("CONFIG_TEST_DIGITS", "twelve")
+	if _, err := Int("CONFIG_TEST_DIGITS", 10); err == nil {
+		t.Fatal("want an error for a non-number")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	t.Setenv("CONFIG_TEST_TTL", "")
+	if d, err := Duration("CONFIG_TEST_TTL", time.Hour); err != nil || d != time.Hour {
+		t.Fatalf("unset: %v, %v", d, err)
+	}
+	t.Setenv("CONFIG_TEST_TTL", "45s")
+	if d, err := Duration("CONFIG_TEST_TTL", time.Hour); err != nil || d != 45*time.Second {
+		t.Fatalf("45s: %v, %v", d, err)
+	}
+	for _, value := range []string{"soon", "0s", "-1m"} {
+		t.Setenv("CONFIG_TEST_TTL", value)
+		if _, err := Duration("CONFIG_TEST_TTL", time.Hour); err == nil {
+			t.Fatalf("%s: want an error", value)
+		}
+	}
+}
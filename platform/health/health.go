@@ -0,0 +1,255 @@
+// Package health - пробы состояния сервиса: live (процесс отвечает), startup
+// (хранилище загружено) и ready (хранилище и соседние сервисы доступны).
+// Ответ - отчёт JSON с результатом и длительностью каждой проверки.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Статусы проверки и отчёта
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout - сколько ждать одну проверку готовности
+const DefaultTimeout = 2 * time.Second
+
+// errUnavailable - текст провала проверки в отчёте. Пробы открыты без
+// авторизации, а ошибка проверки раскрывает адреса соседей и пути хранилища,
+// поэтому она пишется только в журнал.
+const errUnavailable = "unavailable"
+
+// Check - проверка зависимости; nil - зависимость доступна
+type Check func(ctx context.Context) error
+
+// Result - результат одной проверки
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"` // false - провал не снимает готовность
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report - ответ пробы: down, если не прошла хотя бы одна критичная проверка
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+type check struct {
+	name     string
+	fn       Check
+	critical bool
+}
+
+// Checker - проверки готовности и признак завершённого старта. Проверки
+// добавляются при старте, когда сервер уже отвечает на пробы.
+type Checker struct {
+	Timeout time.Duration
+
+	mu      sync.RWMutex
+	checks  []check
+	started atomic.Bool
+}
+
+// NewChecker создаёт проверки с таймаутом DefaultTimeout
+func NewChecker() *Checker {
+	return &Checker{Timeout: DefaultTimeout}
+}
+
+// Add добавляет критичную проверку готовности name
+func (c *Checker) Add(name string, fn Check) {
+	c.add(check{name: name, fn: fn, critical: true})
+}
+
+// AddOptional добавляет проверку, которая попадает в отчёт, но не снимает
+// готовность: без зависимости сервис работает, хоть и хуже
+func (c *Checker) AddOptional(name string, fn Check) {
+	c.add(check{name: name, fn: fn})
+}
+
+func (c *Checker) add(ch check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, ch)
+}
+
+// MarkStarted отмечает, что хранилище загружено и маршруты зарегистрированы
+func (c *Checker) MarkStarted() {
+	c.started.Store(true)
+}
+
+// Started - завершён ли старт
+func (c *Checker) Started() bool {
+	return c.started.Load()
+}
+
+// Run выполняет проверки параллельно, каждую не дольше Timeout.
+// До завершения старта проверки не выполняются, отчёт - down.
+func (c *Checker) Run(ctx context.Context) Report {
+	if !c.Started() {
+		return starting()
+	}
+
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Critical && result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run выполняет одну проверку. Зависшая проверка (например, запись на
+// отвалившийся диск) не держит пробу дольше Timeout: её результат отбрасывается.
+func (c *Checker) run(ctx context.Context, ch check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- ch.fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Name:      ch.name,
+		Status:    StatusUp,
+		Critical:  ch.critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = errUnavailable
+		slog.WarnContext(ctx, "health check failed", "check", ch.name, "critical", ch.critical, "err", err)
+	}
+	return result
+}
+
+// starting - отчёт, пока сервис не загрузил хранилище
+func starting() Report {
+	return Report{Status: StatusDown, Checks: []Result{{Name: "startup", Status: StatusDown, Critical: true, Error: "service is starting"}}}
+}
+
+// Live отвечает 200, пока процесс обслуживает запросы; зависимости не проверяются,
+// чтобы их сбой не приводил к перезапуску сервиса
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	write(w, Report{Status: StatusUp})
+}
+
+// Startup отвечает 503, пока не вызван MarkStarted
+func (c *Checker) Startup(w http.ResponseWriter, r *http.Request) {
+	if !c.Started() {
+		write(w, starting())
+		return
+	}
+	write(w, Report{Status: StatusUp})
+}
+
+// Ready отвечает отчётом Run: 200, если сервис готов принимать запросы, иначе 503
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	write(w, c.Run(r.Context()))
+}
+
+func write(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// Dir проверяет, что каталог хранилища dir читается и в него можно писать:
+// создаёт, перечитывает и удаляет временный файл
+func Dir(dir string) Check {
+	return func(ctx context.Context) error {
+		if _, err := os.ReadDir(dir); err != nil {
+			return err
+		}
+
+		f, err := os.CreateTemp(dir, ".health-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+
+		const probe = "ok"
+		_, err = f.WriteString(probe)
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			return err
+		}
+		if string(data) != probe {
+			return fmt.Errorf("read back %q from %s, want %q", data, f.Name(), probe)
+		}
+		return nil
+	}
+}
+
+// probeClient - клиент проверок соседних сервисов. Он не проходит через
+// клиентов сервиса, чтобы пробы каждые несколько секунд не засоряли их
+// метрики и трассы; время ограничивает контекст проверки.
+var probeClient = &http.Client{}
+
+// HTTP проверяет, что url отвечает 2xx, например http://users-service:8081/health/live
+func HTTP(url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := probeClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"platform/logging"
+	"platform/requestid"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	return w.Code, report
+}
+
+func TestStartupFailsUntilStarted(t *testing.T) {
+	c := NewChecker()
+	c.Add("storage", func(context.Context) error { return nil })
+
+	if status, report := probe(t, c.Startup); status != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatalf("startup before MarkStarted: %d %+v", status, report)
+	}
+	if status, _ := probe(t, c.Ready); status != http.StatusServiceUnavailable {
+		t.Fatalf("ready before MarkStarted: %d, want 503", status)
+	}
+	if status, _ := probe(t, c.Live); status != http.StatusOK {
+		t.Fatalf("live before MarkStarted: %d, want 200", status)
+	}
+
+	c.MarkStarted()
+	if status, report := probe(t, c.Startup); status != http.StatusOK || report.Status != StatusUp {
+		t.Fatalf("startup after MarkStarted: %d %+v", status, report)
+	}
+	if status, report := probe(t, c.Ready); status != http.StatusOK || len(report.Checks) != 1 || report.Checks[0].Name != "storage" {
+		t.Fatalf("ready after MarkStarted: %d %+v", status, report)
+	}
+}
+
+func TestReadyReportsEachCheck(t *testing.T) {
+	c := NewChecker()
+	c.Timeout = 50 * time.Millisecond
+	c.Add("storage", func(context.Context) error { return nil })
+	c.AddOptional("orders-service", func(context.Context) error { return errors.New("connection refused") })
+	c.MarkStarted()
+
+	// Провал необязательной проверки виден в отчёте, но сервис готов
+	status, report := probe(t, c.Ready)
+	if status != http.StatusOK || report.Status != StatusUp {
+		t.Fatalf("optional check down: %d %+v", status, report)
+	}
+	if got := report.Checks[1]; got.Status != StatusDown || got.Critical || got.Error != errUnavailable {
+		t.Fatalf("optional result %+v", got)
+	}
+
+	// Зависшая критичная проверка обрывается по таймауту
+	stuck := make(chan struct{})
+	defer close(stuck)
+	c.Add("users-service", func(context.Context) error { <-stuck; return nil })
+	status, report = probe(t, c.Ready)
+	if status != http.StatusServiceUnavailable || report.Status != StatusDown {
+		t.Fatalf("critical check stuck: %d %+v", status, report)
+	}
+	got := report.Checks[2]
+	if got.Status != StatusDown || got.Error != errUnavailable || got.LatencyMS < 50 {
+		t.Fatalf("stuck result %+v", got)
+	}
+}
+
+func TestReadyHidesCheckErrors(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logging.New(&logs, "test", slog.LevelInfo))
+
+	c := NewChecker()
+	c.Add("users-service", func(context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:8081: connection refused")
+	})
+	c.MarkStarted()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+	c.Ready(w, r.WithContext(requestid.WithID(r.Context(), "probe-1")))
+
+	if strings.Contains(w.Body.String(), "10.0.0.5") || !strings.Contains(w.Body.String(), `"error":"unavailable"`) {
+		t.Fatalf("report %s exposes the check error", w.Body)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log %q: %v", logs.String(), err)
+	}
+	if entry["level"] != "WARN" || entry["request_id"] != "probe-1" || !strings.Contains(entry["err"].(string), "10.0.0.5") {
+		t.Fatalf("log entry %v", entry)
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := Dir(dir)(context.Background()); err != nil {
+		t.Fatalf("writable dir: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("probe file left behind: %v", entries)
+	}
+
+	if err := Dir(filepath.Join(dir, "missing"))(context.Background()); err == nil {
+		t.Error("missing dir: no error")
+	}
+	if os.Geteuid() != 0 { // root пишет и в каталог только для чтения
+		readOnly := filepath.Join(dir, "ro")
+		os.Mkdir(readOnly, 0555)
+		if err := Dir(readOnly)(context.Background()); err == nil {
+			t.Error("read-only dir: no error")
+		}
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	if err := HTTP(up.URL)(context.Background()); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if err := HTTP(down.URL)(context.Background()); err == nil {
+		t.Error("503: no error")
+	}
+	closed := httptest.NewServer(nil)
+	closed.Close()
+	if err := HTTP(closed.URL)(context.Background()); err == nil {
+		t.Error("closed server: no error")
+	}
+}
@@ -0,0 +1,77 @@
+// Package httputil - общие части обработчиков и клиентов сервисов:
+// условные запросы по версии записи и проверка пользователей в users-service.
+package httputil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"platform/storage"
+)
+
+// ETag записи - её версия в кавычках, например "3".
+// If-Match сравнивается строго (слабые W/"3" не подходят), If-None-Match - слабо.
+
+// ETag - тег версии записи
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// SetETag отдаёт версию записи в заголовке ETag
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// NotModified отвечает 304, если у клиента уже текущая версия (If-None-Match)
+func NotModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagListMatches(header, version, true) {
+		return false
+	}
+
+	SetETag(w, version)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// ExpectedVersion разбирает If-Match для PUT/DELETE. Без заголовка возвращает 0 -
+// изменение безусловное. Иначе сверяет теги с текущей версией записи и
+// возвращает её, чтобы репозиторий атомарно проверил её ещё раз при записи;
+// не совпала - storage.ErrVersionMismatch.
+func ExpectedVersion(r *http.Request, current func() (int64, error)) (int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	version, err := current()
+	if err != nil {
+		return 0, err
+	}
+	if !etagListMatches(header, version, false) {
+		return 0, storage.ErrVersionMismatch
+	}
+
+	return version, nil
+}
+
+func etagListMatches(header string, version int64, weak bool) bool {
+	want := ETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+// Package roundtrip - общее для обёрток http.RoundTripper (метрик, трассировки,
+// X-Request-ID). Как и statuswriter, не зависит от остальной платформы.
+package roundtrip
+
+import "net/http"
+
+// CloseIdle закрывает простаивающие соединения base (nil - http.DefaultTransport),
+// чтобы http.Client.CloseIdleConnections работал и через обёртку
+func CloseIdle(base http.RoundTripper) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if closer, ok := base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
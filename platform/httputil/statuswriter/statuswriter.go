@@ -0,0 +1,50 @@
+// Package statuswriter - обёртка http.ResponseWriter, запоминающая статус и
+// размер ответа, для middleware журнала, метрик и трассировки. Пакет не
+// зависит от остальной платформы, поэтому его импортируют и пакеты, от которых
+// зависит httputil.
+package statuswriter
+
+import "net/http"
+
+// Writer пропускает ответ дальше и запоминает его статус и размер тела.
+// Flush и Unwrap дают обработчикам потоковую отдачу и http.ResponseController
+// сквозь обёртку.
+type Writer struct {
+	http.ResponseWriter
+	Status      int   // по умолчанию 200: обработчик мог не вызвать WriteHeader
+	Bytes       int64 // записано байт тела
+	wroteHeader bool
+}
+
+// New оборачивает w
+func New(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (w *Writer) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.Status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.Bytes += int64(n)
+	return n, err
+}
+
+// Flush отправляет буферизованный ответ, если это умеет обёрнутый writer
+func (w *Writer) Flush() {
+	w.wroteHeader = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap - обёрнутый writer для http.ResponseController
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
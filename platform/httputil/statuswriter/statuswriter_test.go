@@ -0,0 +1,26 @@
+package statuswriter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriterRecordsStatusAndFlushes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := New(rec)
+
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("hello"))
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Fatalf("Flush through wrapper: %v", err)
+	}
+
+	if w.Status != http.StatusAccepted || w.Bytes != 5 {
+		t.Fatalf("status %d, bytes %d, want 202 and 5", w.Status, w.Bytes)
+	}
+	if !rec.Flushed {
+		t.Fatal("underlying writer not flushed")
+	}
+}
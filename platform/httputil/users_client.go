@@ -0,0 +1,135 @@
+package httputil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"platform/config"
+	"platform/health"
+	"platform/metrics"
+	"platform/problem"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+var (
+	// ErrUserNotFound - users-service ответил, что такого пользователя нет
+	ErrUserNotFound = problem.New(problem.Unprocessable, "user_not_found", "user not found")
+	// ErrUsersUnavailable - users-service не ответил или ответил ошибкой
+	ErrUsersUnavailable = problem.New(problem.Unavailable, "users_unavailable", "users-service unavailable")
+)
+
+// UserCheckPolicy - что делать, если users-service недоступен
+type UserCheckPolicy string
+
+const (
+	// FailClosed отклоняет запрос: лучше отказать, чем сохранить данные несуществующего пользователя
+	FailClosed UserCheckPolicy = "fail-closed"
+	// FailOpen пропускает запрос без проверки, чтобы сбой users-service не останавливал приём запросов
+	FailOpen UserCheckPolicy = "fail-open"
+)
+
+// ParseUserCheckPolicy разбирает политику из конфигурации; пустая строка - FailClosed
+func ParseUserCheckPolicy(s string) (UserCheckPolicy, error) {
+	switch policy := UserCheckPolicy(s); policy {
+	case "":
+		return FailClosed, nil
+	case FailClosed, FailOpen:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown user check policy %q, want %s or %s", s, FailClosed, FailOpen)
+	}
+}
+
+// UsersClientFromEnv настраивает проверку пользователей: USERS_SERVICE_URL и
+// USER_CHECK_POLICY (fail-closed по умолчанию или fail-open)
+func UsersClientFromEnv() (*UsersServiceClient, error) {
+	policy, err := ParseUserCheckPolicy(config.String("USER_CHECK_POLICY", string(FailClosed)))
+	if err != nil {
+		return nil, err
+	}
+	return NewUsersServiceClient(config.String("USERS_SERVICE_URL", "http://users-service:8081"), policy), nil
+}
+
+// UsersServiceClient проверяет пользователей через GET /api/users/{id}/exists
+type UsersServiceClient struct {
+	baseURL    string
+	policy     UserCheckPolicy
+	httpClient *http.Client
+}
+
+// NewUsersServiceClient создаёт клиента users-service с адресом baseURL
+func NewUsersServiceClient(baseURL string, policy UserCheckPolicy) *UsersServiceClient {
+	return &UsersServiceClient{
+		baseURL: baseURL,
+		policy:  policy,
+		httpClient: &http.Client{
+			// Проверка стоит на пути запроса клиента, долго ждать нельзя
+			Transport: &metrics.Transport{Client: "UsersServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   3 * time.Second,
+		},
+	}
+}
+
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *UsersServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// HealthCheck - проверка готовности: отвечает ли users-service на /health/live
+func (c *UsersServiceClient) HealthCheck() health.Check {
+	return health.HTTP(c.baseURL + "/health/live")
+}
+
+// Required - нужен ли users-service для приёма запросов; при FailOpen без него можно обойтись
+func (c *UsersServiceClient) Required() bool {
+	return c.policy != FailOpen
+}
+
+// VerifyUser возвращает nil, если пользователь существует, ErrUserNotFound -
+// если нет, и ErrUsersUnavailable, если users-service не ответил, а политика FailClosed
+func (c *UsersServiceClient) VerifyUser(ctx context.Context, userID int64) error {
+	exists, err := c.userExists(ctx, userID)
+	if err != nil {
+		if c.policy == FailOpen {
+			slog.WarnContext(ctx, "users-service check skipped (fail-open)", "user_id", userID, "err", err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrUsersUnavailable, err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	return nil
+}
+
+func (c *UsersServiceClient) userExists(ctx context.Context, userID int64) (bool, error) {
+	url := fmt.Sprintf("%s/api/users/%d/exists", c.baseURL, userID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Exists *bool `json:"exists"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Exists == nil {
+		return false, fmt.Errorf("unexpected response from %s", url)
+	}
+	return *body.Exists, nil
+}
@@ -0,0 +1,107 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/problem"
+)
+
+func usersServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users/1/exists":
+			w.Write([]byte(`{"exists":true}`))
+		case "/api/users/2/exists":
+			w.Write([]byte(`{"exists":false}`))
+		case "/health/live":
+			w.Write([]byte(`{"status":"up"}`))
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerifyUser(t *testing.T) {
+	ctx := context.Background()
+	users := NewUsersServiceClient(usersServer(t).URL, FailClosed)
+
+	if err := users.VerifyUser(ctx, 1); err != nil {
+		t.Fatalf("existing user: %v", err)
+	}
+	if err := users.VerifyUser(ctx, 2); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("missing user: error %v, want ErrUserNotFound", err)
+	}
+	if err := users.VerifyUser(ctx, 3); !errors.Is(err, ErrUsersUnavailable) {
+		t.Fatalf("server error: error %v, want ErrUsersUnavailable", err)
+	}
+}
+
+func TestVerifyUserPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// Адрес, на котором никто не слушает
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	if err := NewUsersServiceClient(url, FailClosed).VerifyUser(ctx, 1); !errors.Is(err, ErrUsersUnavailable) {
+		t.Fatalf("fail-closed: error %v, want ErrUsersUnavailable", err)
+	}
+	if err := NewUsersServiceClient(url, FailOpen).VerifyUser(ctx, 1); err != nil {
+		t.Fatalf("fail-open: error %v, want nil", err)
+	}
+
+	// Отсутствие пользователя не зависит от политики
+	if err := NewUsersServiceClient(usersServer(t).URL, FailOpen).VerifyUser(ctx, 2); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("fail-open, missing user: error %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestVerifyUserUnavailableHidesAddress(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/orders", nil)
+	err := NewUsersServiceClient(url, FailClosed).VerifyUser(r.Context(), 1)
+	w := httptest.NewRecorder()
+	problem.Write(w, r, err)
+
+	host := strings.TrimPrefix(url, "http://")
+	if w.Code != http.StatusServiceUnavailable || strings.Contains(w.Body.String(), host) {
+		t.Fatalf("status %d, body %s leaks %s", w.Code, w.Body, host)
+	}
+}
+
+func TestUsersHealthCheck(t *testing.T) {
+	users := NewUsersServiceClient(usersServer(t).URL, FailClosed)
+	if err := users.HealthCheck()(context.Background()); err != nil {
+		t.Fatalf("users-service up: %v", err)
+	}
+	if !users.Required() {
+		t.Error("fail-closed: users-service not required for readiness")
+	}
+	if NewUsersServiceClient(usersServer(t).URL, FailOpen).Required() {
+		t.Error("fail-open: users-service required for readiness")
+	}
+}
+
+func TestParseUserCheckPolicy(t *testing.T) {
+	for input, want := range map[string]UserCheckPolicy{"": FailClosed, "fail-closed": FailClosed, "fail-open": FailOpen} {
+		if got, err := ParseUserCheckPolicy(input); err != nil || got != want {
+			t.Errorf("ParseUserCheckPolicy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseUserCheckPolicy("open"); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
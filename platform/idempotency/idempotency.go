@@ -0,0 +1,320 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"platform/problem"
+	"platform/requestid"
+	"platform/storage"
+	"platform/validation"
+)
+
+// Header - заголовок, которым клиент помечает повторы одного запроса
+const Header = "Idempotency-Key"
+
+// DefaultTTL - сколько хранится ответ на запрос с ключом
+const DefaultTTL = 24 * time.Hour
+
+// maxKeyLen - ограничение длины ключа, чтобы ключи не раздували хранилище
+const maxKeyLen = 255
+
+// requestHeaders - заголовки ответа, которые middleware выставляют каждому
+// запросу заново; повтор отдаёт их от текущего запроса, а не от первого
+var requestHeaders = []string{requestid.Header}
+
+var (
+	// ErrKeyReused - ключ уже использован с другим запросом
+	ErrKeyReused = problem.New(problem.Unprocessable, "idempotency_key_reused",
+		"idempotency key reused with a different request")
+	// ErrKeyInFlight - запрос с этим ключом ещё выполняется
+	ErrKeyInFlight = problem.New(problem.Conflict, "idempotency_key_in_flight",
+		"request with this idempotency key is in progress")
+	// ErrKeyTooLong - ключ длиннее maxKeyLen
+	ErrKeyTooLong = problem.New(problem.Invalid, "idempotency_key_too_long", "Idempotency-Key is too long")
+)
+
+// Record - сохранённый ответ на запрос с ключом.
+// ID нужен журналу FileStorage, ключ записи - Key.
+type Record struct {
+	ID          int64       `json:"id"`
+	Key         string      `json:"key"`
+	Fingerprint string      `json:"fingerprint"` // sha256 метода, пути и тела запроса
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	ExpiresAt   time.Time   `json:"expiresAt"`
+}
+
+// Store хранит ответы на запросы с Idempotency-Key до истечения ttl.
+// Без storage (NewMemoryStore) ответы живут только в памяти процесса.
+type Store struct {
+	mu        sync.Mutex
+	storage   *storage.FileStorage
+	ttl       time.Duration
+	now       func() time.Time
+	records   map[string]*Record
+	inFlight  map[string]string // ключ -> отпечаток выполняющегося запроса
+	nextID    int64
+	lastPurge time.Time
+}
+
+// NewStore создаёт хранилище ключей в JSON-файле filePath
+func NewStore(filePath string, ttl time.Duration) (*Store, error) {
+	store := NewMemoryStore(ttl)
+	store.storage = storage.NewFileStorage(filePath)
+
+	if err := store.storage.EnsureFile(); err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	if err := store.storage.LoadJSON(&records); err != nil {
+		return nil, err
+	}
+	now := store.now()
+	var expired []int64
+	for _, record := range records {
+		if record.ID >= store.nextID {
+			store.nextID = record.ID + 1
+		}
+		if now.Before(record.ExpiresAt) {
+			store.records[record.Key] = record
+		} else {
+			expired = append(expired, record.ID)
+		}
+	}
+	if err := store.storage.Delete(expired...); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// NewMemoryStore создаёт хранилище ключей в памяти
+func NewMemoryStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:      ttl,
+		now:      time.Now,
+		records:  make(map[string]*Record),
+		inFlight: make(map[string]string),
+		nextID:   1,
+	}
+}
+
+// Begin начинает запрос с ключом. Возвращает сохранённый ответ для повтора,
+// nil - если запрос нужно выполнить (тогда обязателен Complete или Abandon),
+// ErrKeyReused или ErrKeyInFlight.
+func (s *Store) Begin(key, fingerprint string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && s.now().Before(record.ExpiresAt) {
+		if record.Fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		return record, nil
+	}
+	if running, ok := s.inFlight[key]; ok {
+		if running != fingerprint {
+			return nil, ErrKeyReused
+		}
+		return nil, ErrKeyInFlight
+	}
+
+	s.inFlight[key] = fingerprint
+	return nil, nil
+}
+
+// Complete сохраняет ответ на запрос, начатый Begin
+func (s *Store) Complete(key string, status int, header http.Header, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fingerprint, ok := s.inFlight[key]
+	if !ok {
+		return nil
+	}
+	delete(s.inFlight, key)
+
+	now := s.now()
+	record := &Record{
+		ID:          s.nextID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		Status:      status,
+		Header:      header,
+		Body:        body,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	s.nextID++
+	replaced := s.records[key]
+	s.records[key] = record
+
+	if s.storage == nil {
+		s.purgeLocked(now)
+		return nil
+	}
+	if err := s.storage.Put(record.ID, record); err != nil {
+		return err
+	}
+	if replaced != nil {
+		if err := s.storage.Delete(replaced.ID); err != nil {
+			return err
+		}
+	}
+	return s.purgeLocked(now)
+}
+
+// Abandon снимает ключ без сохранения ответа: повтор выполнит запрос заново
+func (s *Store) Abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inFlight, key)
+}
+
+// Close сворачивает журнал ключей в снимок и закрывает файл; сохранить ответ
+// после этого нельзя. Хранилище в памяти закрывать не нужно.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.storage == nil {
+		return nil
+	}
+	return s.storage.Close()
+}
+
+// purgeLocked не чаще раза в минуту удаляет истёкшие ключи
+func (s *Store) purgeLocked(now time.Time) error {
+	if now.Sub(s.lastPurge) < time.Minute {
+		return nil
+	}
+	s.lastPurge = now
+
+	var expired []int64
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			expired = append(expired, record.ID)
+			delete(s.records, key)
+		}
+	}
+	if s.storage == nil {
+		return nil
+	}
+	return s.storage.Delete(expired...)
+}
+
+// Middleware повторяет сохранённый ответ на POST с тем же Idempotency-Key и
+// телом вместо повторного выполнения. Тот же ключ с другим запросом - 422,
+// пока первый запрос выполняется - 409. Сохраняются только ответы 2xx:
+// после ошибки (конфликт версий, сбой зависимости) клиент повторяет запрос с
+// тем же ключом и получает результат нового выполнения.
+func Middleware(store *Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLen {
+				problem.Write(w, r, ErrKeyTooLong)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, validation.MaxRequestBody))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					problem.Write(w, r, validation.ErrRequestTooLarge)
+					return
+				}
+				problem.Write(w, r, problem.Errorf(problem.Invalid, "invalid_request", "%w: %w", validation.ErrInvalidRequest, err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record, err := store.Begin(key, fingerprint(r, body))
+			switch {
+			case err != nil:
+				problem.Write(w, r, err)
+				return
+			case record != nil:
+				replayResponse(w, record)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			done := false
+			defer func() {
+				// Паника в обработчике или не 2xx: ключ освобождается без ответа
+				if !done || recorder.status < http.StatusOK || recorder.status >= http.StatusMultipleChoices {
+					store.Abandon(key)
+					return
+				}
+				// Ответ уже отправлен; если запись не удалась, повтор просто выполнится заново
+				store.Complete(key, recorder.status, responseHeader(w.Header()), recorder.body.Bytes())
+			}()
+			next.ServeHTTP(recorder, r)
+			done = true
+		})
+	}
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseHeader - копия заголовков ответа без requestHeaders
+func responseHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, name := range requestHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
+
+func replayResponse(w http.ResponseWriter, record *Record) {
+	// Ключи, сохранённые до отбрасывания requestHeaders, тоже не подменяют текущие
+	for name, values := range responseHeader(record.Header) {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// responseRecorder пропускает ответ клиенту и запоминает статус и тело
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
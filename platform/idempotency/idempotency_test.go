@@ -0,0 +1,203 @@
+package idempotency
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"platform/requestid"
+)
+
+// countingHandler создаёт запись с новым ID на каждый выполненный запрос
+func countingHandler(calls *int64, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		id := atomic.AddInt64(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"id":%d,"request":%s}`, id, body)
+	})
+}
+
+func post(t *testing.T, h http.Handler, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls int64
+	h := Middleware(NewMemoryStore(time.Hour))(countingHandler(&calls, http.StatusCreated))
+
+	first := post(t, h, "key-1", `{"userId":1}`)
+	second := post(t, h, "key-1", `{"userId":1}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("ETag") != `"1"` || second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay headers %v", second.Header())
+	}
+
+	// Без ключа запрос выполняется каждый раз
+	post(t, h, "", `{"userId":1}`)
+	post(t, h, "", `{"userId":1}`)
+	if calls != 3 {
+		t.Fatalf("handler ran %d times, want 3", calls)
+	}
+}
+
+func TestIdempotencyReplayKeepsRequestID(t *testing.T) {
+	var calls int64
+	h := requestid.Middleware(Middleware(NewMemoryStore(time.Hour))(countingHandler(&calls, http.StatusCreated)))
+
+	for _, id := range []string{"first-request", "second-request"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"userId":1}`))
+		req.Header.Set(Header, "key-1")
+		req.Header.Set(requestid.Header, id)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(requestid.Header); got != id {
+			t.Fatalf("%s: response X-Request-ID %q", id, got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyRejectsReusedKey(t *testing.T) {
+	var calls int64
+	h := Middleware(NewMemoryStore(time.Hour))(countingHandler(&calls, http.StatusCreated))
+
+	post(t, h, "key-1", `{"userId":1}`)
+	if rec := post(t, h, "key-1", `{"userId":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key: status %d, want 422", rec.Code)
+	}
+
+	// Тот же ключ на другом пути - тоже другой запрос
+	req := httptest.NewRequest(http.MethodPost, "/api/payments", strings.NewReader(`{"userId":1}`))
+	req.Header.Set(Header, "key-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity || calls != 1 {
+		t.Fatalf("key on another path: status %d after %d calls", rec.Code, calls)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := Middleware(NewMemoryStore(time.Hour))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		post(t, h, "key-1", `{}`)
+		close(done)
+	}()
+	<-started
+
+	if rec := post(t, h, "key-1", `{}`); rec.Code != http.StatusConflict {
+		t.Fatalf("concurrent repeat: status %d, want 409", rec.Code)
+	}
+	close(release)
+	<-done
+
+	if rec := post(t, h, "key-1", `{}`); rec.Code != http.StatusCreated {
+		t.Fatalf("repeat after completion: status %d, want 201", rec.Code)
+	}
+}
+
+func TestIdempotencyStoresOnlySuccess(t *testing.T) {
+	for _, status := range []int{http.StatusConflict, http.StatusPreconditionFailed, http.StatusServiceUnavailable} {
+		var calls int64
+		h := Middleware(NewMemoryStore(time.Hour))(countingHandler(&calls, status))
+
+		post(t, h, "key-1", `{}`)
+		rec := post(t, h, "key-1", `{}`)
+		if calls != 2 || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatalf("status %d: handler ran %d times, want 2: only 2xx may be replayed", status, calls)
+		}
+	}
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	var calls int64
+	store := NewMemoryStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	h := Middleware(store)(countingHandler(&calls, http.StatusCreated))
+
+	post(t, h, "key-1", `{"userId":1}`)
+	now = now.Add(2 * time.Minute)
+
+	// После истечения ключ можно использовать даже с другим телом
+	if rec := post(t, h, "key-1", `{"userId":2}`); rec.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expired key: status %d after %d calls", rec.Code, calls)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency_keys.json")
+	var calls int64
+
+	store, err := NewStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	first := post(t, Middleware(store)(countingHandler(&calls, http.StatusCreated)), "key-1", `{"userId":1}`)
+
+	reopened, err := NewStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	second := post(t, Middleware(reopened)(countingHandler(&calls, http.StatusCreated)), "key-1", `{"userId":1}`)
+
+	if calls != 1 || second.Body.String() != first.Body.String() {
+		t.Fatalf("after restart: %d calls, replay %q, want %q", calls, second.Body, first.Body)
+	}
+}
+
+func TestStoreCloseKeepsKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency_keys.json")
+	var calls int64
+
+	store, err := NewStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	post(t, Middleware(store)(countingHandler(&calls, http.StatusCreated)), "key-1", `{"userId":1}`)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	post(t, Middleware(reopened)(countingHandler(&calls, http.StatusCreated)), "key-1", `{"userId":1}`)
+	if calls != 1 {
+		t.Fatalf("%d calls after close and reopen, want 1", calls)
+	}
+}
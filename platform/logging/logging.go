@@ -0,0 +1,90 @@
+// Package logging - JSON-журнал сервиса (log/slog) и журнал HTTP-запросов.
+// Записи, сделанные с контекстом запроса (slog.InfoContext и т.п.), получают
+// поле request_id (и trace_id/span_id, если запрос трассируется), поэтому один
+// запрос прослеживается по журналам всех сервисов.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"platform/config"
+	"platform/httputil/statuswriter"
+	"platform/requestid"
+)
+
+// Setup делает JSON-журнал в stdout журналом по умолчанию, в том числе для
+// пакета log. Уровень - LOG_LEVEL (debug, info, warn, error), по умолчанию info.
+func Setup(service string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.String("LOG_LEVEL", "info"))); err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	slog.SetDefault(New(os.Stdout, service, level))
+	return nil
+}
+
+// New - JSON-журнал сервиса service в w
+func New(w io.Writer, service string, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(contextHandler{handler}).With("service", service)
+}
+
+// contextHandler дописывает в записи request_id и span трассировки из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestid.From(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Middleware пишет по записи на запрос: метод, шаблон маршрута из route
+// (пустой для неизвестного пути), статус, длительность и размер ответа.
+// Ответы 5xx пишутся с уровнем error, 4xx - warn.
+func Middleware(route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := statuswriter.New(w)
+			next.ServeHTTP(sw, r)
+
+			level := slog.LevelInfo
+			switch {
+			case sw.Status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case sw.Status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			slog.Default().LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", route(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.Status),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int64("bytes", sw.Bytes),
+			)
+		})
+	}
+}
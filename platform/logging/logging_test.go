@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"platform/requestid"
+)
+
+// capture подменяет журнал по умолчанию на время теста
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(New(&buf, "things-service", slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line %q: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func TestMiddlewareLogsRequest(t *testing.T) {
+	buf := capture(t)
+	h := Middleware(func(*http.Request) string { return "/api/things/{id}" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/things/7", nil)
+	r = r.WithContext(requestid.WithID(r.Context(), "req-1"))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	entry := decodeLine(t, buf)
+	want := map[string]interface{}{
+		"level":      "WARN",
+		"msg":        "request",
+		"service":    "things-service",
+		"request_id": "req-1",
+		"method":     "GET",
+		"route":      "/api/things/{id}",
+		"path":       "/api/things/7",
+		"status":     float64(http.StatusNotFound),
+		"bytes":      float64(len("not found")),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v (line %v)", key, entry[key], value, entry)
+		}
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("no duration_ms in %v", entry)
+	}
+}
+
+func TestContextRecordsCarryRequestID(t *testing.T) {
+	buf := capture(t)
+	slog.InfoContext(context.Background(), "no request")
+	if entry := decodeLine(t, buf); entry["request_id"] != nil {
+		t.Fatalf("request_id without a request: %v", entry)
+	}
+
+	buf.Reset()
+	slog.With("step", "orders").InfoContext(requestid.WithID(context.Background(), "req-2"), "in request")
+	if entry := decodeLine(t, buf); entry["request_id"] != "req-2" || entry["step"] != "orders" || entry["trace_id"] != nil {
+		t.Fatalf("entry %v", entry)
+	}
+
+	buf.Reset()
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	slog.InfoContext(ctx, "in span")
+	if entry := decodeLine(t, buf); entry["trace_id"] != traceID.String() || entry["span_id"] != spanID.String() {
+		t.Fatalf("entry %v", entry)
+	}
+}
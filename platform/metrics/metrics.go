@@ -0,0 +1,96 @@
+// Package metrics - метрики Prometheus: обработчик /metrics, входящие
+// HTTP-запросы сервиса и исходящие вызовы соседних сервисов. Метрики
+// регистрируются в prometheus.DefaultRegisterer вместе с метриками Go и процесса.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"platform/httputil/roundtrip"
+	"platform/httputil/statuswriter"
+)
+
+// unmatchedRoute - метка запросов, не попавших ни в один маршрут
+const unmatchedRoute = "unmatched"
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	clientRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_requests_total",
+		Help: "Outbound HTTP calls by client, peer host, method and status (error - no response).",
+	}, []string{"client", "peer", "method", "status"})
+	clientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_request_duration_seconds",
+		Help:    "Outbound HTTP call latency by client, peer host and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"client", "peer", "method"})
+)
+
+// Handler отдаёт метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware считает запросы и их длительность по шаблону маршрута из route
+// (например /api/orders/{id}), а не по пути, чтобы ID не плодили серии
+func Middleware(route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := statuswriter.New(w)
+			next.ServeHTTP(sw, r)
+
+			template := route(r)
+			if template == "" {
+				template = unmatchedRoute
+			}
+			requests.WithLabelValues(r.Method, template, strconv.Itoa(sw.Status)).Inc()
+			requestDuration.WithLabelValues(r.Method, template).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// Transport считает исходящие вызовы клиента Client (например
+// OrdersServiceClient); peer - хост из адреса запроса.
+// Base - транспорт запросов, по умолчанию http.DefaultTransport.
+type Transport struct {
+	Client string
+	Base   http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	clientRequests.WithLabelValues(t.Client, req.URL.Host, req.Method, status).Inc()
+	clientDuration.WithLabelValues(t.Client, req.URL.Host, req.Method).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// CloseIdleConnections закрывает простаивающие соединения Base
+func (t *Transport) CloseIdleConnections() {
+	roundtrip.CloseIdle(t.Base)
+}
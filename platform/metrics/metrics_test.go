@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareCountsByRoute(t *testing.T) {
+	route := func(r *http.Request) string {
+		if strings.HasPrefix(r.URL.Path, "/api/things/") {
+			return "/api/things/{id}"
+		}
+		return ""
+	}
+	h := Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/things/404" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	for _, path := range []string{"/api/things/1", "/api/things/2", "/api/things/404", "/nothing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	for _, tc := range []struct {
+		route, status string
+		want          float64
+	}{
+		{"/api/things/{id}", "200", 2},
+		{"/api/things/{id}", "404", 1},
+		{unmatchedRoute, "200", 1},
+	} {
+		if got := testutil.ToFloat64(requests.WithLabelValues("GET", tc.route, tc.status)); got != tc.want {
+			t.Errorf("%s %s: %v requests, want %v", tc.route, tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestTransportCountsCalls(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	upstream.Close() // первый вызов - без ответа
+	client := &http.Client{Transport: &Transport{Client: "ThingsClient"}}
+	if _, err := client.Get(upstream.URL); err == nil {
+		t.Fatal("call to a closed server succeeded")
+	}
+
+	upstream = httptest.NewServer(upstream.Config.Handler)
+	defer upstream.Close()
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	resp.Body.Close()
+
+	host, _ := url.Parse(upstream.URL)
+	if got := testutil.ToFloat64(clientRequests.WithLabelValues("ThingsClient", host.Host, "GET", "202")); got != 1 {
+		t.Fatalf("%v answered calls, want 1", got)
+	}
+	if testutil.CollectAndCount(clientRequests, "http_client_requests_total") < 2 {
+		t.Fatal("failed call is not counted")
+	}
+}
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	requests.WithLabelValues("GET", "/exposed", "200").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `http_requests_total{method="GET",route="/exposed",status="200"} 1`) {
+		t.Fatalf("metrics output lacks the request counter:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,221 @@
+// Package money - точные денежные суммы: целое число минимальных единиц
+// валюты (копеек, центов) и код валюты ISO 4217.
+//
+// Операции над суммами в разных валютах возвращают ErrCurrencyMismatch,
+// результат за пределами int64 - ErrOverflow.
+// Нулевое значение Money{} без валюты совместимо с любой валютой, чтобы
+// отсутствующее в JSON поле не ломало арифметику.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"platform/validation"
+)
+
+// DefaultCurrency - валюта сумм, сохранённых числом до появления валют
+const DefaultCurrency = "RUB"
+
+var (
+	// ErrCurrencyMismatch - операция над суммами в разных валютах
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrInvalidCurrency - код валюты не из трёх заглавных латинских букв
+	ErrInvalidCurrency = errors.New("invalid currency")
+	// ErrOverflow - сумма не помещается в int64 минимальных единиц
+	ErrOverflow = errors.New("amount overflows")
+)
+
+func init() {
+	// Теги binding проверяют сумму по числу минимальных единиц:
+	// required - ненулевая, min=0 - неотрицательная
+	validation.RegisterType(func(v reflect.Value) interface{} {
+		return v.Interface().(Money).Minor
+	}, Money{})
+}
+
+// exponents - число знаков после запятой у валют, где их не два
+var exponents = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// Money - сумма в минимальных единицах валюты
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// New создаёт сумму из минимальных единиц
+func New(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// Zero - нулевая сумма в валюте currency
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// FromMajor переводит сумму в основных единицах (рублях, долларах) в минимальные
+// с округлением до ближайшей. Нужна для сумм, пришедших числом с плавающей точкой.
+func FromMajor(amount float64, currency string) Money {
+	return Money{Minor: int64(math.Round(amount * math.Pow10(Exponent(currency)))), Currency: currency}
+}
+
+// Exponent - число знаков после запятой в валюте
+func Exponent(currency string) int {
+	if exp, ok := exponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// ValidCurrency - код похож на ISO 4217: три заглавные латинские буквы
+func ValidCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Major - сумма в основных единицах; только для вывода, не для расчётов
+func (m Money) Major() float64 {
+	return float64(m.Minor) / math.Pow10(Exponent(m.Currency))
+}
+
+// IsZero - сумма равна нулю в любой валюте
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// IsNegative - сумма меньше нуля
+func (m Money) IsNegative() bool {
+	return m.Minor < 0
+}
+
+// Add складывает суммы одной валюты
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := common(m, other)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Minor + other.Minor
+	if (other.Minor > 0 && sum < m.Minor) || (other.Minor < 0 && sum > m.Minor) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrOverflow, m, other)
+	}
+	return Money{Minor: sum, Currency: currency}, nil
+}
+
+// Sub вычитает other из m
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := common(m, other)
+	if err != nil {
+		return Money{}, err
+	}
+	diff := m.Minor - other.Minor
+	if (other.Minor > 0 && diff > m.Minor) || (other.Minor < 0 && diff < m.Minor) {
+		return Money{}, fmt.Errorf("%w: %s - %s", ErrOverflow, m, other)
+	}
+	return Money{Minor: diff, Currency: currency}, nil
+}
+
+// Mul умножает сумму на целое число, например цену на количество
+func (m Money) Mul(n int64) (Money, error) {
+	product := m.Minor * n
+	if m.Minor != 0 && (product/m.Minor != n || (m.Minor == -1 && n == math.MinInt64)) {
+		return Money{}, fmt.Errorf("%w: %s * %d", ErrOverflow, m, n)
+	}
+	return Money{Minor: product, Currency: m.Currency}, nil
+}
+
+// Cmp сравнивает суммы одной валюты: -1, если m меньше other, 0 - равны, 1 - больше
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := common(m, other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Minor < other.Minor:
+		return -1, nil
+	case m.Minor > other.Minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Equal - суммы равны и в одной валюте
+func (m Money) Equal(other Money) bool {
+	cmp, err := m.Cmp(other)
+	return err == nil && cmp == 0
+}
+
+// String - сумма в основных единицах с кодом валюты, например "100.50 RUB"
+func (m Money) String() string {
+	exp := Exponent(m.Currency)
+	return strconv.FormatFloat(m.Major(), 'f', exp, 64) + " " + m.Currency
+}
+
+// common - валюта результата операции над a и b
+func common(a, b Money) (string, error) {
+	switch {
+	case a.Currency == b.Currency:
+		return a.Currency, nil
+	case a.Currency == "" && a.Minor == 0:
+		return b.Currency, nil
+	case b.Currency == "" && b.Minor == 0:
+		return a.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+}
+
+// jsonMoney - формат суммы в JSON
+type jsonMoney struct {
+	Minor    int64  `json:"minor"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON пишет сумму как {"minor": 10050, "currency": "RUB"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Minor: m.Minor, Currency: m.Currency})
+}
+
+// UnmarshalJSON читает {"minor": ..., "currency": ...} и, для совместимости,
+// число в основных единицах в валюте DefaultCurrency: так суммы хранились и
+// передавались до появления валют
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] != '{' {
+		var amount float64
+		if err := json.Unmarshal(data, &amount); err != nil {
+			return fmt.Errorf("money: want a number or {\"minor\", \"currency\"}: %w", err)
+		}
+		if math.Abs(amount*math.Pow10(Exponent(DefaultCurrency))) >= math.MaxInt64 {
+			return fmt.Errorf("%w: %v %s", ErrOverflow, amount, DefaultCurrency)
+		}
+		*m = FromMajor(amount, DefaultCurrency)
+		return nil
+	}
+
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if !ValidCurrency(v.Currency) {
+		return fmt.Errorf("%w: %q", ErrInvalidCurrency, v.Currency)
+	}
+	*m = Money{Minor: v.Minor, Currency: v.Currency}
+	return nil
+}
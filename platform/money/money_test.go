@@ -0,0 +1,130 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"platform/validation"
+)
+
+func TestArithmeticIsExact(t *testing.T) {
+	price := FromMajor(0.1, "RUB")
+	total := Zero("RUB")
+	for i := 0; i < 3; i++ {
+		var err error
+		if total, err = total.Add(price); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if !total.Equal(New(30, "RUB")) || total.String() != "0.30 RUB" {
+		t.Fatalf("0.1 * 3 = %v, want 0.30 RUB", total)
+	}
+
+	rest, err := New(1000, "USD").Sub(New(1, "USD"))
+	if err != nil || rest.Minor != 999 {
+		t.Fatalf("Sub = %v, %v", rest, err)
+	}
+	if got, err := New(1999, "USD").Mul(3); err != nil || got.Minor != 5997 || got.Currency != "USD" {
+		t.Fatalf("Mul = %v, %v", got, err)
+	}
+}
+
+func TestOverflowIsAnError(t *testing.T) {
+	big := New(math.MaxInt64, "RUB")
+
+	if _, err := big.Add(New(1, "RUB")); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("MaxInt64 + 1: error %v, want ErrOverflow", err)
+	}
+	if _, err := New(math.MinInt64, "RUB").Sub(New(1, "RUB")); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("MinInt64 - 1: error %v, want ErrOverflow", err)
+	}
+	if _, err := New(1<<40, "RUB").Mul(1 << 30); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("2^40 * 2^30: error %v, want ErrOverflow", err)
+	}
+	if _, err := New(-1, "RUB").Mul(math.MinInt64); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("-1 * MinInt64: error %v, want ErrOverflow", err)
+	}
+	if got, err := New(-(1 << 31), "RUB").Mul(1 << 32); err != nil || got.Minor != math.MinInt64 {
+		t.Fatalf("-2^31 * 2^32 = %v, %v, want MinInt64", got, err)
+	}
+	if sum, err := big.Add(New(-1, "RUB")); err != nil || sum.Minor != math.MaxInt64-1 {
+		t.Fatalf("MaxInt64 + -1 = %v, %v", sum, err)
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`1e20`), &m); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Unmarshal(1e20): error %v, want ErrOverflow", err)
+	}
+}
+
+func TestMixedCurrenciesAreRejected(t *testing.T) {
+	rub, usd := New(100, "RUB"), New(100, "USD")
+
+	if _, err := rub.Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Add: error %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := rub.Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Sub: error %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := rub.Cmp(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Cmp: error %v, want ErrCurrencyMismatch", err)
+	}
+	if rub.Equal(usd) {
+		t.Fatalf("100 RUB equals 100 USD")
+	}
+
+	// Нулевое значение без валюты совместимо с любой
+	if sum, err := (Money{}).Add(usd); err != nil || !sum.Equal(usd) {
+		t.Fatalf("Money{} + 100 USD = %v, %v", sum, err)
+	}
+}
+
+func TestJSONAcceptsLegacyNumbers(t *testing.T) {
+	var v struct {
+		Legacy  Money `json:"legacy"`
+		Current Money `json:"current"`
+		Missing Money `json:"missing"`
+	}
+	err := json.Unmarshal([]byte(`{"legacy": 19.99, "current": {"minor": 1500, "currency": "JPY"}}`), &v)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !v.Legacy.Equal(New(1999, DefaultCurrency)) {
+		t.Fatalf("legacy number read as %v", v.Legacy)
+	}
+	if !v.Current.Equal(New(1500, "JPY")) || v.Current.String() != "1500 JPY" {
+		t.Fatalf("current read as %v", v.Current)
+	}
+	if v.Missing != (Money{}) {
+		t.Fatalf("missing read as %v", v.Missing)
+	}
+
+	out, _ := json.Marshal(v.Legacy)
+	if string(out) != `{"minor":1999,"currency":"RUB"}` {
+		t.Fatalf("Marshal = %s", out)
+	}
+
+	for _, bad := range []string{`{"minor": 1}`, `{"minor": 1, "currency": "rub"}`, `"10"`} {
+		var m Money
+		if err := json.Unmarshal([]byte(bad), &m); err == nil {
+			t.Fatalf("Unmarshal(%s) = %v, want error", bad, m)
+		}
+	}
+}
+
+func TestBindingChecksMinorUnits(t *testing.T) {
+	type request struct {
+		Price Money `json:"price" binding:"required,min=0"`
+	}
+
+	if err := validation.Validate(request{Price: New(1, "RUB")}); err != nil {
+		t.Fatalf("valid price: %v", err)
+	}
+	for _, price := range []Money{{}, New(-100, "RUB")} {
+		if err := validation.Validate(request{Price: price}); err == nil {
+			t.Fatalf("price %v passed validation", price)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package pagination
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"platform/problem"
+)
+
+// Размер страницы списка
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// Поля сортировки списков
+const (
+	SortByID        = "id"
+	SortByCreatedAt = "createdAt"
+	SortByUpdatedAt = "updatedAt"
+)
+
+// Фильтры, которые есть не у каждого списка
+const (
+	FilterStatus  = "status"
+	FilterUserID  = "userId"
+	FilterOrderID = "orderId"
+)
+
+// ErrInvalidListQuery - неверный параметр списка: limit, cursor, sort, order, фильтр или дата
+var ErrInvalidListQuery = problem.New(problem.Invalid, "invalid_list_query", "invalid list query")
+
+// ListQuery - страница списка: фильтры, сортировка и курсор.
+// Даты фильтров - полуинтервалы [From, To), нулевая дата - без границы.
+type ListQuery struct {
+	Limit int
+	Sort  string // SortByID, SortByCreatedAt или SortByUpdatedAt
+	Desc  bool
+	After *Cursor // nil - первая страница
+
+	Status      string
+	UserID      int64
+	OrderID     int64
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+}
+
+// Cursor - последняя запись предыдущей страницы. Записи упорядочены по полю
+// сортировки, при равенстве - по ID, поэтому страницы не пропускают и не
+// повторяют записи, даже если между запросами добавились новые.
+type Cursor struct {
+	Sort string    `json:"s"`
+	Desc bool      `json:"d,omitempty"`
+	At   time.Time `json:"t"` // значение поля сортировки, кроме SortByID
+	ID   int64     `json:"i"`
+}
+
+// Encode - курсор для ответа клиенту; клиенту он непрозрачен
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ParseListQuery разбирает limit, cursor, sort (id, createdAt, updatedAt), order (asc, desc),
+// createdFrom/createdTo, updatedFrom/updatedTo (RFC 3339) и фильтры из filters
+// (FilterStatus, FilterUserID, FilterOrderID). Неподдерживаемый фильтр - тоже ErrInvalidListQuery.
+func ParseListQuery(values url.Values, filters ...string) (ListQuery, error) {
+	q := ListQuery{Limit: DefaultListLimit, Sort: SortByID}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxListLimit {
+			return q, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListQuery, MaxListLimit)
+		}
+		q.Limit = n
+	}
+
+	switch sort := values.Get("sort"); sort {
+	case "":
+	case SortByID, SortByCreatedAt, SortByUpdatedAt:
+		q.Sort = sort
+	default:
+		return q, fmt.Errorf("%w: sort must be id, createdAt or updatedAt", ErrInvalidListQuery)
+	}
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("%w: order must be asc or desc", ErrInvalidListQuery)
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return q, fmt.Errorf("%w: malformed cursor", ErrInvalidListQuery)
+		}
+		// Курсор другой сортировки указал бы на чужое место в списке
+		if after.Sort != q.Sort || after.Desc != q.Desc {
+			return q, fmt.Errorf("%w: cursor was issued for another sort", ErrInvalidListQuery)
+		}
+		q.After = after
+	}
+
+	for name, value := range values {
+		if value[0] == "" {
+			continue
+		}
+		switch name {
+		case FilterStatus, FilterUserID, FilterOrderID:
+			if !slices.Contains(filters, name) {
+				return q, fmt.Errorf("%w: filter %s is not supported here", ErrInvalidListQuery, name)
+			}
+		}
+	}
+	q.Status = values.Get(FilterStatus)
+	if userID := values.Get(FilterUserID); userID != "" {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("%w: userId must be an integer", ErrInvalidListQuery)
+		}
+		q.UserID = id
+	}
+	if orderID := values.Get(FilterOrderID); orderID != "" {
+		id, err := strconv.ParseInt(orderID, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("%w: orderId must be an integer", ErrInvalidListQuery)
+		}
+		q.OrderID = id
+	}
+
+	dates := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"createdFrom", &q.CreatedFrom},
+		{"createdTo", &q.CreatedTo},
+		{"updatedFrom", &q.UpdatedFrom},
+		{"updatedTo", &q.UpdatedTo},
+	}
+	for _, date := range dates {
+		value := values.Get(date.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return q, fmt.Errorf("%w: %s must be an RFC 3339 time", ErrInvalidListQuery, date.name)
+		}
+		*date.dst = t
+	}
+
+	return q, nil
+}
+
+// ListKey - поля записи, по которым списки фильтруются и сортируются
+type ListKey struct {
+	ID        int64
+	UserID    int64
+	OrderID   int64
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Match - запись проходит фильтры запроса; курсор не учитывается
+func (q ListQuery) Match(k ListKey) bool {
+	switch {
+	case q.Status != "" && k.Status != q.Status:
+		return false
+	case q.UserID != 0 && k.UserID != q.UserID:
+		return false
+	case q.OrderID != 0 && k.OrderID != q.OrderID:
+		return false
+	}
+	return inRange(k.CreatedAt, q.CreatedFrom, q.CreatedTo) && inRange(k.UpdatedAt, q.UpdatedFrom, q.UpdatedTo)
+}
+
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// SortTime - значение поля сортировки; для SortByID - нулевое
+func (q ListQuery) SortTime(k ListKey) time.Time {
+	switch q.Sort {
+	case SortByCreatedAt:
+		return k.CreatedAt
+	case SortByUpdatedAt:
+		return k.UpdatedAt
+	}
+	return time.Time{}
+}
+
+// compare - порядок записей в списке: по полю сортировки, затем по ID
+func (q ListQuery) compare(at time.Time, id int64, other time.Time, otherID int64) int {
+	c := at.Compare(other)
+	if c == 0 {
+		c = cmp.Compare(id, otherID)
+	}
+	if q.Desc {
+		return -c
+	}
+	return c
+}
+
+// Page - страница списка. NextCursor пуст на последней странице,
+// Total - сколько всего записей проходит фильтры.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// NewPage собирает страницу из записей, уже отобранных по фильтрам и курсору
+// и упорядоченных. Записей может быть на одну больше Limit - так видно, что
+// есть следующая страница.
+func NewPage[T any](items []T, q ListQuery, total int, key func(T) ListKey) *Page[T] {
+	page := &Page[T]{Items: items, Total: total}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(items) > q.Limit {
+		page.Items = items[:q.Limit]
+		last := key(page.Items[q.Limit-1])
+		page.NextCursor = Cursor{Sort: q.Sort, Desc: q.Desc, At: q.SortTime(last), ID: last.ID}.Encode()
+	}
+	return page
+}
+
+// Paginate отбирает страницу из записей в памяти: фильтрует, сортирует и
+// пропускает записи до курсора
+func Paginate[T any](records []T, q ListQuery, key func(T) ListKey) *Page[T] {
+	type keyed struct {
+		record T
+		key    ListKey
+	}
+	matched := make([]keyed, 0, len(records))
+	for _, record := range records {
+		if k := key(record); q.Match(k) {
+			matched = append(matched, keyed{record, k})
+		}
+	}
+	slices.SortFunc(matched, func(a, b keyed) int {
+		return q.compare(q.SortTime(a.key), a.key.ID, q.SortTime(b.key), b.key.ID)
+	})
+
+	start := 0
+	if q.After != nil {
+		start, _ = slices.BinarySearchFunc(matched, q.After, func(k keyed, c *Cursor) int {
+			if q.compare(q.SortTime(k.key), k.key.ID, c.At, c.ID) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	end := min(start+q.Limit+1, len(matched))
+	items := make([]T, 0, end-start)
+	for _, k := range matched[start:end] {
+		items = append(items, k.record)
+	}
+	return NewPage(items, q, len(matched), key)
+}
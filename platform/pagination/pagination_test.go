@@ -0,0 +1,130 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type record struct {
+	id      int64
+	status  string
+	created time.Time
+}
+
+func recordKey(r record) ListKey {
+	return ListKey{ID: r.id, Status: r.status, CreatedAt: r.created, UpdatedAt: r.created}
+}
+
+func ids(items []record) []int64 {
+	out := make([]int64, len(items))
+	for i, r := range items {
+		out[i] = r.id
+	}
+	return out
+}
+
+func mustQuery(t *testing.T, raw string, filters ...string) ListQuery {
+	t.Helper()
+
+	values, _ := url.ParseQuery(raw)
+	q, err := ParseListQuery(values, filters...)
+	if err != nil {
+		t.Fatalf("ParseListQuery(%q): %v", raw, err)
+	}
+	return q
+}
+
+func TestPaginateWalksAllPages(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Одинаковое время у 2 и 3 - порядок между ними задаёт ID
+	records := []record{
+		{5, "created", base.Add(1 * time.Hour)},
+		{3, "paid", base.Add(2 * time.Hour)},
+		{1, "created", base.Add(3 * time.Hour)},
+		{2, "created", base.Add(2 * time.Hour)},
+		{4, "created", base},
+	}
+
+	var got []int64
+	raw := "sort=createdAt&order=desc&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("too many pages: %v", got)
+		}
+		page := Paginate(records, mustQuery(t, raw), recordKey)
+		if page.Total != 5 {
+			t.Fatalf("total %d, want 5", page.Total)
+		}
+		got = append(got, ids(page.Items)...)
+		if page.NextCursor == "" {
+			break
+		}
+		raw = "sort=createdAt&order=desc&limit=2&cursor=" + page.NextCursor
+	}
+
+	want := []int64{1, 3, 2, 5, 4}
+	if len(got) != len(want) {
+		t.Fatalf("pages %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("pages %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPaginateFilters(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []record{
+		{1, "created", base},
+		{2, "paid", base.Add(time.Hour)},
+		{3, "created", base.Add(2 * time.Hour)},
+		{4, "created", base.Add(3 * time.Hour)},
+	}
+
+	q := mustQuery(t, "status=created&createdFrom=2025-01-01T01:00:00Z&createdTo=2025-01-01T03:00:00Z", FilterStatus)
+	page := Paginate(records, q, recordKey)
+	if page.Total != 1 || len(page.Items) != 1 || page.Items[0].id != 3 || page.NextCursor != "" {
+		t.Fatalf("filtered page %+v", page)
+	}
+
+	if page := Paginate(nil, mustQuery(t, ""), recordKey); page.Items == nil || page.Total != 0 {
+		t.Fatalf("empty page %+v: items must encode as []", page)
+	}
+}
+
+func TestParseListQueryRejectsBadParameters(t *testing.T) {
+	idCursor := Cursor{Sort: SortByID, ID: 3}.Encode()
+
+	for _, raw := range []string{
+		"limit=0",
+		"limit=101",
+		"limit=ten",
+		"sort=name",
+		"order=up",
+		"cursor=!!!",
+		"cursor=" + idCursor + "&sort=createdAt",
+		"cursor=" + idCursor + "&order=desc",
+		"userId=abc",
+		"orderId=5",
+		"status=created",
+		"createdFrom=yesterday",
+	} {
+		values, _ := url.ParseQuery(raw)
+		if _, err := ParseListQuery(values, FilterUserID); !errors.Is(err, ErrInvalidListQuery) {
+			t.Errorf("ParseListQuery(%q): error %v, want ErrInvalidListQuery", raw, err)
+		}
+	}
+
+	q := mustQuery(t, "cursor="+idCursor+"&userId=7", FilterUserID)
+	if q.Limit != DefaultListLimit || q.Sort != SortByID || q.After.ID != 3 || q.UserID != 7 {
+		t.Fatalf("query %+v", q)
+	}
+
+	q = mustQuery(t, "orderId=5", FilterOrderID)
+	if q.OrderID != 5 || !q.Match(ListKey{OrderID: 5}) || q.Match(ListKey{OrderID: 6}) {
+		t.Fatalf("query %+v", q)
+	}
+}
@@ -0,0 +1,170 @@
+// Package problem - типизированные ошибки сервиса и их ответ клиенту
+// в формате application/problem+json (RFC 7807).
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"platform/requestid"
+)
+
+// ContentType - тип ответа с ошибкой
+const ContentType = "application/problem+json"
+
+// Kind - категория ошибки; по ней выбирается статус ответа
+type Kind int
+
+const (
+	Internal           Kind = iota // 500: ошибка сервиса, подробности клиенту не показываются
+	Invalid                        // 400: запрос не разобран или не прошёл проверку
+	Forbidden                      // 403
+	NotFound                       // 404
+	MethodNotAllowed               // 405
+	Conflict                       // 409: состояние записи не допускает операцию
+	PreconditionFailed             // 412: запись изменилась с тех пор, как клиент её прочитал
+	TooLarge                       // 413
+	Unprocessable                  // 422: запрос понятен, но нарушает правило (нет пользователя, чужой заказ)
+	Unavailable                    // 503: зависимый сервис недоступен
+)
+
+var statuses = map[Kind]int{
+	Internal:           http.StatusInternalServerError,
+	Invalid:            http.StatusBadRequest,
+	Forbidden:          http.StatusForbidden,
+	NotFound:           http.StatusNotFound,
+	MethodNotAllowed:   http.StatusMethodNotAllowed,
+	Conflict:           http.StatusConflict,
+	PreconditionFailed: http.StatusPreconditionFailed,
+	TooLarge:           http.StatusRequestEntityTooLarge,
+	Unprocessable:      http.StatusUnprocessableEntity,
+	Unavailable:        http.StatusServiceUnavailable,
+}
+
+// Status - HTTP-статус категории
+func (k Kind) Status() int {
+	if status, ok := statuses[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError - нарушенное правило одного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`           // путь в JSON, например items[0].quantity
+	Rule    string `json:"rule"`            // тег binding, unknown или type
+	Param   string `json:"param,omitempty"` // параметр правила: 1 для min=1
+	Message string `json:"message"`
+}
+
+// Error - ошибка домена: категория, машиночитаемый код (snake_case) и текст
+// для клиента. Сравнивается по указателю, поэтому ошибки-значения пакетов
+// (repository.ErrVersionMismatch) объявляются через New и находятся errors.Is
+// сквозь обёртки fmt.Errorf("...: %w", err).
+type Error struct {
+	Kind   Kind
+	Code   string
+	Detail string
+	Fields []FieldError // для Invalid - нарушения по полям
+	Err    error        // причина
+}
+
+var (
+	// ErrInvalidID - идентификатор записи в пути не число
+	ErrInvalidID = New(Invalid, "invalid_id", "invalid ID")
+	// ErrInvalidUserID - идентификатор пользователя в пути не число
+	ErrInvalidUserID = New(Invalid, "invalid_user_id", "invalid user ID")
+)
+
+// New - ошибка без причины
+func New(kind Kind, code, detail string) *Error {
+	return &Error{Kind: kind, Code: code, Detail: detail}
+}
+
+// Errorf - ошибка с текстом по формату; ошибки под %w находятся errors.Is
+func Errorf(kind Kind, code, format string, args ...interface{}) *Error {
+	err := fmt.Errorf(format, args...)
+	return &Error{Kind: kind, Code: code, Detail: err.Error(), Err: err}
+}
+
+// Wrap - ошибка категории kind с текстом и причиной err. Так чужие ошибки
+// (другого пакета или сервиса) получают категорию там, где она известна.
+func Wrap(kind Kind, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Detail: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem - тело ответа с ошибкой (RFC 7807) и расширения code, requestId, errors
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// internalError отвечает на ошибки без категории: их текст может раскрыть
+// устройство сервиса, поэтому он пишется в журнал с request_id, а клиенту уходит общий
+var internalError = New(Internal, "internal_error", "internal server error")
+
+// Public - категория err и текст, который можно показать клиенту: текст всей
+// цепочки, для ошибки без категории - общий текст internalError, для Unavailable -
+// только Detail без причины (в ней адрес соседнего сервиса и сетевая ошибка)
+func Public(err error) (*Error, string) {
+	var e *Error
+	if !errors.As(err, &e) || e.Kind == Internal {
+		return internalError, internalError.Detail
+	}
+	if e.Kind == Unavailable {
+		return e, e.Detail
+	}
+	return e, err.Error()
+}
+
+// Write отвечает на err документом application/problem+json. Категория, код и
+// поля берутся из первой *Error в цепочке, detail - из Public. Скрытый от
+// клиента текст пишется в журнал с request_id.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e, detail := Public(err)
+	switch {
+	case e == internalError:
+		slog.ErrorContext(r.Context(), "internal error", "method", r.Method, "path", r.URL.Path, "err", err)
+	case detail != err.Error():
+		slog.WarnContext(r.Context(), "dependency unavailable", "method", r.Method, "path", r.URL.Path, "err", err)
+	}
+
+	status := e.Kind.Status()
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: requestid.From(r.Context()),
+		Errors:    e.Fields,
+	})
+}
+
+// Handler отвечает ошибкой err на любой запрос: для NotFoundHandler и
+// MethodNotAllowedHandler роутера
+func Handler(err error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, err)
+	})
+}
@@ -0,0 +1,96 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"platform/requestid"
+)
+
+var errThingNotFound = New(NotFound, "thing_not_found", "thing not found")
+
+func write(t *testing.T, err error) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/things/7", nil)
+	r = r.WithContext(requestid.WithID(r.Context(), "req-1"))
+	w := httptest.NewRecorder()
+	Write(w, r, err)
+
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	return w, p
+}
+
+func TestWriteTypedError(t *testing.T) {
+	err := fmt.Errorf("thing 7: %w", errThingNotFound)
+	if !errors.Is(err, errThingNotFound) {
+		t.Fatal("wrapped sentinel is not found by errors.Is")
+	}
+
+	w, p := write(t, err)
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	want := Problem{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "thing 7: thing not found",
+		Instance:  "/api/things/7",
+		Code:      "thing_not_found",
+		RequestID: "req-1",
+	}
+	if p.Type != want.Type || p.Title != want.Title || p.Status != want.Status || p.Detail != want.Detail ||
+		p.Instance != want.Instance || p.Code != want.Code || p.RequestID != want.RequestID {
+		t.Fatalf("problem %+v, want %+v", p, want)
+	}
+}
+
+func TestWriteFieldErrors(t *testing.T) {
+	err := &Error{Kind: Invalid, Code: "validation_failed", Detail: "age must be at most 150",
+		Fields: []FieldError{{Field: "age", Rule: "max", Param: "150", Message: "must be at most 150"}}}
+
+	w, p := write(t, err)
+	if w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "age" {
+		t.Fatalf("status %d, problem %+v", w.Code, p)
+	}
+}
+
+func TestWriteHidesUntypedErrors(t *testing.T) {
+	w, p := write(t, errors.New("database is locked at /data/users.db"))
+	if w.Code != http.StatusInternalServerError || p.Code != "internal_error" || p.Detail != "internal server error" {
+		t.Fatalf("status %d, problem %+v", w.Code, p)
+	}
+}
+
+func TestWriteHidesUnavailableCause(t *testing.T) {
+	unavailable := New(Unavailable, "things_unavailable", "things-service unavailable")
+	err := fmt.Errorf("%w: Get \"http://things:8085/api/things/7\": dial tcp 10.0.0.5:8085: connection refused", unavailable)
+
+	w, p := write(t, err)
+	if w.Code != http.StatusServiceUnavailable || p.Code != "things_unavailable" || p.Detail != "things-service unavailable" {
+		t.Fatalf("status %d, problem %+v", w.Code, p)
+	}
+	if !errors.Is(err, unavailable) {
+		t.Fatal("cause lost the sentinel")
+	}
+}
+
+func TestErrorfKeepsCause(t *testing.T) {
+	err := Errorf(Unprocessable, "thing_gone", "cannot use thing: %w", errThingNotFound)
+	if !errors.Is(err, errThingNotFound) || err.Detail != "cannot use thing: thing not found" {
+		t.Fatalf("error %+v", err)
+	}
+
+	var e *Error
+	if !errors.As(fmt.Errorf("outer: %w", err), &e) || e.Kind.Status() != http.StatusUnprocessableEntity {
+		t.Fatalf("errors.As found %+v", e)
+	}
+}
@@ -0,0 +1,88 @@
+// Package requestid - идентификатор запроса для ответов с ошибками и логов
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"platform/httputil/roundtrip"
+)
+
+// Header - заголовок с идентификатором запроса
+const Header = "X-Request-ID"
+
+// maxLen - длиннее идентификатор клиента не принимается, выдаётся свой
+const maxLen = 128
+
+type contextKey struct{}
+
+// Middleware берёт идентификатор из X-Request-ID или выдаёт новый, кладёт его
+// в контекст запроса и возвращает клиенту в том же заголовке
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// New - случайный идентификатор: 16 байт в hex
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithID - контекст с идентификатором запроса
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// From - идентификатор запроса из контекста; пустой вне Middleware
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Transport пересылает идентификатор запроса из контекста исходящего запроса
+// в X-Request-ID, чтобы вызов соседнего сервиса попал в его журнал с тем же ID.
+// Base - транспорт запросов, по умолчанию http.DefaultTransport.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if id := From(req.Context()); id != "" && req.Header.Get(Header) == "" {
+		// RoundTrip не должен менять запрос вызывающего
+		req = req.Clone(req.Context())
+		req.Header.Set(Header, id)
+	}
+	return base.RoundTrip(req)
+}
+
+// CloseIdleConnections закрывает простаивающие соединения Base
+func (t *Transport) CloseIdleConnections() {
+	roundtrip.CloseIdle(t.Base)
+}
+
+// valid - идентификатор клиента непустой, не длиннее maxLen и из видимых ASCII-символов
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
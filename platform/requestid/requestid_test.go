@@ -0,0 +1,86 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, header string) (response, seen string) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		r.Header.Set(Header, header)
+	}
+	w := httptest.NewRecorder()
+	Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = From(r.Context())
+	})).ServeHTTP(w, r)
+	return w.Header().Get(Header), seen
+}
+
+func TestMiddlewareKeepsClientID(t *testing.T) {
+	response, seen := serve(t, "req-42")
+	if response != "req-42" || seen != "req-42" {
+		t.Fatalf("response %q, context %q, want req-42", response, seen)
+	}
+}
+
+func TestMiddlewareReplacesMissingOrBadID(t *testing.T) {
+	for _, header := range []string{"", "two words", strings.Repeat("x", maxLen+1)} {
+		response, seen := serve(t, header)
+		if len(response) != 32 || response != seen {
+			t.Errorf("header %q: response %q, context %q", header, response, seen)
+		}
+	}
+
+	first, _ := serve(t, "")
+	second, _ := serve(t, "")
+	if first == second {
+		t.Fatalf("generated IDs repeat: %s", first)
+	}
+}
+
+func TestTransportForwardsID(t *testing.T) {
+	var got []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(Header))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &Transport{}}
+	for _, ctx := range []context.Context{WithID(context.Background(), "req-7"), context.Background()} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		if req.Header.Get(Header) != "" {
+			t.Fatal("transport changed the caller's request")
+		}
+	}
+
+	if len(got) != 2 || got[0] != "req-7" || got[1] != "" {
+		t.Fatalf("upstream saw %q, want [req-7 \"\"]", got)
+	}
+}
+
+// idleCloser считает вызовы CloseIdleConnections
+type idleCloser struct {
+	http.RoundTripper
+	closed int
+}
+
+func (c *idleCloser) CloseIdleConnections() { c.closed++ }
+
+func TestTransportClosesIdleConnections(t *testing.T) {
+	base := &idleCloser{}
+	(&http.Client{Transport: &Transport{Base: base}}).CloseIdleConnections()
+	if base.closed != 1 {
+		t.Fatalf("CloseIdleConnections reached base %d times, want 1", base.closed)
+	}
+}
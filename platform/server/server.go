@@ -0,0 +1,253 @@
+// Package server собирает HTTP-сервер сервиса: роутер с ответами
+// problem+json на неизвестные пути, пробы /health/*, /metrics, Swagger,
+// X-Request-ID, трассировка, журнал и метрики запросов, таймауты и плавная остановка.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	httpSwagger "github.com/swaggo/http-swagger"
+
+	"platform/config"
+	"platform/health"
+	"platform/logging"
+	"platform/metrics"
+	"platform/problem"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+// Timeouts - таймауты HTTP-сервера и остановки
+type Timeouts struct {
+	ReadHeader time.Duration // HTTP_READ_HEADER_TIMEOUT - на заголовки запроса
+	Read       time.Duration // HTTP_READ_TIMEOUT - на весь запрос с телом
+	Write      time.Duration // HTTP_WRITE_TIMEOUT - на обработку и ответ; больше CHECKOUT_PAYMENT_TIMEOUT
+	Idle       time.Duration // HTTP_IDLE_TIMEOUT - простой keep-alive соединения
+	Shutdown   time.Duration // SHUTDOWN_TIMEOUT - на дожидание запросов и OnShutdown
+}
+
+// DefaultTimeouts - таймауты по умолчанию. Shutdown больше ожидания списания
+// при оформлении заказа (30 секунд) с запасом на остальные шаги и меньше
+// 60 секунд stop_grace_period в docker-compose, после которых приходит SIGKILL.
+var DefaultTimeouts = Timeouts{
+	ReadHeader: 5 * time.Second,
+	Read:       15 * time.Second,
+	Write:      60 * time.Second,
+	Idle:       2 * time.Minute,
+	Shutdown:   45 * time.Second,
+}
+
+// errStarting - ответ на запросы, пришедшие до конца старта
+var errStarting = problem.New(problem.Unavailable, "service_starting", "service is starting")
+
+// Server - роутер и порт сервиса. Маршруты API регистрируются в API,
+// остальные - в Router; проверки готовности - в Health.
+type Server struct {
+	Name     string
+	Port     string
+	Router   *mux.Router
+	API      *mux.Router
+	Health   *health.Checker
+	Timeouts Timeouts
+
+	probes     *mux.Router
+	onShutdown []func(ctx context.Context) error
+	httpServer *http.Server
+	served     chan error
+}
+
+// New создаёт сервер name на порту из PORT или defaultPort. Swagger отдаёт
+// документацию, зарегистрированную пакетом docs сервиса.
+func New(name, defaultPort string) *Server {
+	router := mux.NewRouter()
+	router.NotFoundHandler = problem.Handler(problem.New(problem.NotFound, "route_not_found", "no such endpoint"))
+	router.MethodNotAllowedHandler = problem.Handler(problem.New(problem.MethodNotAllowed, "method_not_allowed", "method not allowed"))
+
+	port := config.String("PORT", defaultPort)
+
+	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
+		httpSwagger.URL("http://localhost:" + port + "/swagger/doc.json"),
+	))
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	checker := health.NewChecker()
+	probes := mux.NewRouter()
+	probes.NotFoundHandler = router.NotFoundHandler
+	probes.MethodNotAllowedHandler = router.MethodNotAllowedHandler
+	probes.HandleFunc("/health/live", checker.Live).Methods("GET")
+	probes.HandleFunc("/health/startup", checker.Startup).Methods("GET")
+	probes.HandleFunc("/health/ready", checker.Ready).Methods("GET")
+	// Прежний /health отвечал OK, пока процесс жив; его смысл не меняется,
+	// чтобы внешние проверки на нём не начали перезапускать сервис при сбое соседа
+	probes.HandleFunc("/health", checker.Live).Methods("GET")
+
+	return &Server{
+		Name:     name,
+		Port:     port,
+		Router:   router,
+		API:      router.PathPrefix("/api").Subrouter(),
+		Health:   checker,
+		Timeouts: DefaultTimeouts,
+		probes:   probes,
+	}
+}
+
+// OnShutdown добавляет fn к остановке: после того как запросы дождались,
+// функции вызываются в обратном порядке, как defer. Поэтому ресурс, от
+// которого зависят другие (хранилище), регистрируется раньше них (саги, клиенты).
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Handler - обработчик всех запросов сервера. Пробы /health/* обходят
+// журнал, метрики и трассы: их присылают каждые несколько секунд. До конца
+// старта main ещё регистрирует маршруты, поэтому Router не трогается вовсе,
+// а остальные запросы получают 503.
+func (s *Server) Handler() http.Handler {
+	// Трассировка - снаружи журнала, чтобы запись о запросе получила trace_id
+	handler := metrics.Middleware(s.route)(s.Router)
+	handler = logging.Middleware(s.route)(handler)
+	handler = tracing.Middleware(s.route)(handler)
+	return requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/"):
+			s.probes.ServeHTTP(w, r)
+		case !s.Health.Started():
+			problem.Write(w, r, errStarting)
+		default:
+			handler.ServeHTTP(w, r)
+		}
+	}))
+}
+
+// Listen начинает принимать соединения на Port до Run, чтобы пробы отвечали,
+// пока main загружает хранилище: /health/startup и /health/ready - 503 до
+// вызова Run. Таймауты из DefaultTimeouts переопределяются переменными окружения.
+func (s *Server) Listen() error {
+	if s.httpServer != nil {
+		return nil
+	}
+	if err := s.loadTimeouts(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", ":"+s.Port)
+	if err != nil {
+		return err
+	}
+	slog.Info("listening", "port", s.Port)
+	s.listen(ln)
+	return nil
+}
+
+// listen запускает HTTP-сервер на ln
+func (s *Server) listen(ln net.Listener) {
+	s.httpServer = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: s.Timeouts.ReadHeader,
+		ReadTimeout:       s.Timeouts.Read,
+		WriteTimeout:      s.Timeouts.Write,
+		IdleTimeout:       s.Timeouts.Idle,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	s.served = make(chan error, 1)
+	go func() { s.served <- s.httpServer.Serve(ln) }()
+}
+
+// Run завершает старт (если Listen не вызван, вызывает его) и принимает
+// запросы до SIGINT или SIGTERM, затем плавно останавливается (см. Serve)
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	return s.serve(ctx)
+}
+
+// Serve принимает запросы на ln до отмены ctx. Затем перестаёт принимать
+// соединения, ждёт выполняющиеся запросы и вызывает функции OnShutdown -
+// всё вместе не дольше Timeouts.Shutdown. Функции OnShutdown вызываются, даже
+// если запросы не успели завершиться.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.listen(ln)
+	return s.serve(ctx)
+}
+
+func (s *Server) serve(ctx context.Context) error {
+	s.Health.MarkStarted()
+	slog.Info("server started", "port", s.Port)
+
+	select {
+	case err := <-s.served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "timeout", s.Timeouts.Shutdown.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Timeouts.Shutdown)
+	defer cancel()
+
+	var errs []error
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drain requests: %w", err))
+	}
+	for i := len(s.onShutdown) - 1; i >= 0; i-- {
+		if err := s.onShutdown[i](shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
+}
+
+// loadTimeouts читает таймауты из окружения; незаданные остаются прежними
+func (s *Server) loadTimeouts() error {
+	for _, timeout := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &s.Timeouts.ReadHeader},
+		{"HTTP_READ_TIMEOUT", &s.Timeouts.Read},
+		{"HTTP_WRITE_TIMEOUT", &s.Timeouts.Write},
+		{"HTTP_IDLE_TIMEOUT", &s.Timeouts.Idle},
+		{"SHUTDOWN_TIMEOUT", &s.Timeouts.Shutdown},
+	} {
+		value, err := config.Duration(timeout.name, *timeout.value)
+		if err != nil {
+			return err
+		}
+		*timeout.value = value
+	}
+	return nil
+}
+
+// route - шаблон маршрута запроса, например /api/orders/{id}; пустой, если
+// маршрута нет. Журнал пишется по шаблону, а не по пути, чтобы записи одного
+// маршрута группировались.
+func (s *Server) route(r *http.Request) string {
+	var match mux.RouteMatch
+	if !s.Router.Match(r, &match) || match.Route == nil {
+		return ""
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"platform/health"
+	"platform/problem"
+	"platform/requestid"
+	"platform/storage"
+)
+
+func serve(s *Server, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestServerRoutes(t *testing.T) {
+	t.Setenv("PORT", "")
+	s := New("things-service", "8090")
+	if s.Port != "8090" {
+		t.Fatalf("port %q, want default 8090", s.Port)
+	}
+	s.API.HandleFunc("/things", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST")
+	s.Health.Add("users-service", func(context.Context) error { return errors.New("connection refused") })
+	s.Health.MarkStarted()
+
+	// Прежний /health - живость: недоступный сосед снимает только готовность
+	if w := serve(s, http.MethodGet, "/health"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"up"`) {
+		t.Fatalf("health: %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(s, http.MethodGet, "/health/ready"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("ready with a failing check: %d, want 503", w.Code)
+	}
+	if w := serve(s, http.MethodPost, "/api/things"); w.Code != http.StatusCreated || w.Header().Get(requestid.Header) == "" {
+		t.Fatalf("api: %d, request ID %q", w.Code, w.Header().Get(requestid.Header))
+	}
+	if w := serve(s, http.MethodGet, "/metrics"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `route="/api/things"`) {
+		t.Fatalf("metrics: %d, api request not counted", w.Code)
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		status         int
+		code           string
+	}{
+		{http.MethodGet, "/api/nothing", http.StatusNotFound, "route_not_found"},
+		{http.MethodDelete, "/api/things", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, "/health/nothing", http.StatusNotFound, "route_not_found"},
+	} {
+		w := serve(s, tc.method, tc.target)
+		var p problem.Problem
+		json.NewDecoder(w.Body).Decode(&p)
+		if w.Code != tc.status || p.Code != tc.code || p.RequestID == "" {
+			t.Errorf("%s %s: status %d, problem %+v", tc.method, tc.target, w.Code, p)
+		}
+	}
+}
+
+func TestServerPortFromEnv(t *testing.T) {
+	t.Setenv("PORT", "9999")
+	if s := New("things-service", "8090"); s.Port != "9999" {
+		t.Fatalf("port %q, want 9999 from PORT", s.Port)
+	}
+}
+
+func TestServerRouteTemplate(t *testing.T) {
+	s := New("things-service", "8090")
+	s.API.HandleFunc("/things/{id}", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+
+	for target, want := range map[string]string{"/api/things/7": "/api/things/{id}", "/metrics": "/metrics", "/api/nothing": ""} {
+		if got := s.route(httptest.NewRequest(http.MethodGet, target, nil)); got != want {
+			t.Errorf("%s: route %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestStartupProbes(t *testing.T) {
+	s := New("things-service", "8090")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s.listen(ln)
+	t.Cleanup(func() { s.httpServer.Close() })
+	url := "http://" + ln.Addr().String()
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(url + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body struct{ Status, Code string }
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Status + body.Code
+	}
+
+	// Хранилище ещё загружается, маршруты регистрируются
+	for path, want := range map[string]int{
+		"/health/live":    http.StatusOK,
+		"/health/startup": http.StatusServiceUnavailable,
+		"/health/ready":   http.StatusServiceUnavailable,
+		"/api/things":     http.StatusServiceUnavailable,
+	} {
+		if status, _ := get(path); status != want {
+			t.Errorf("starting: %s %d, want %d", path, status, want)
+		}
+	}
+	if _, code := get("/api/things"); code != "service_starting" {
+		t.Errorf("starting: api problem code %q", code)
+	}
+	s.API.HandleFunc("/things", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+
+	s.Health.Add("storage", health.Dir(t.TempDir()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.serve(ctx)
+	for deadline := time.Now().Add(time.Second); !s.Health.Started(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("not started")
+		}
+	}
+
+	for _, path := range []string{"/health/startup", "/health/ready", "/api/things"} {
+		if status, _ := get(path); status != http.StatusOK {
+			t.Errorf("started: %s %d, want 200", path, status)
+		}
+	}
+}
+
+// slowJSON кодируется, только когда release закрыт; started закрывается,
+// когда SaveJSON начал запись
+type slowJSON struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s slowJSON) MarshalJSON() ([]byte, error) {
+	close(s.started)
+	<-s.release
+	return []byte(`[{"id":1,"name":"saved"}]`), nil
+}
+
+// start запускает Serve на свободном порту; отмена - cancel, результат Serve - в done
+func start(t *testing.T, s *Server) (url string, cancel context.CancelFunc, done chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done = make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	t.Cleanup(cancel)
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestShutdownWaitsForInFlightSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "things.json")
+	fs := storage.NewFileStorage(path)
+	if err := fs.EnsureFile(); err != nil {
+		t.Fatalf("EnsureFile: %v", err)
+	}
+
+	s := New("things-service", "8090")
+	var order []string
+	s.OnShutdown(func(context.Context) error {
+		order = append(order, "storage")
+		return fs.Close()
+	})
+	s.OnShutdown(func(context.Context) error {
+		order = append(order, "clients")
+		return nil
+	})
+	slow := slowJSON{started: make(chan struct{}), release: make(chan struct{})}
+	s.API.HandleFunc("/things", func(w http.ResponseWriter, r *http.Request) {
+		if err := fs.SaveJSON(slow); err != nil {
+			problem.Write(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST")
+
+	url, cancel, done := start(t, s)
+	responded := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(url+"/api/things", "application/json", nil)
+		if err != nil {
+			responded <- 0
+			return
+		}
+		resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+
+	<-slow.started
+	cancel() // SIGTERM посреди SaveJSON
+	select {
+	case err := <-done:
+		t.Fatalf("Serve returned during SaveJSON: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := http.Get(url + "/health"); err == nil {
+		t.Error("new connections accepted during shutdown")
+	}
+
+	close(slow.release)
+	if status := <-responded; status != http.StatusCreated {
+		t.Fatalf("in-flight request: status %d, want 201", status)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	if fmt.Sprint(order) != "[clients storage]" {
+		t.Errorf("shutdown order %v, want clients before storage", order)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"saved"`) {
+		t.Errorf("snapshot after shutdown: %s", data)
+	}
+	if err := fs.SaveJSON([]int{}); !errors.Is(err, storage.ErrClosed) {
+		t.Errorf("SaveJSON after shutdown: %v, want storage.ErrClosed", err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	s := New("things-service", "8090")
+	s.Timeouts.Shutdown = 50 * time.Millisecond
+	closed := false
+	s.OnShutdown(func(context.Context) error {
+		closed = true
+		return nil
+	})
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s.API.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}).Methods("GET")
+
+	url, cancel, done := start(t, s)
+	go http.Get(url + "/api/stuck")
+	<-started
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Serve: %v, want deadline exceeded", err)
+	}
+	if !closed {
+		t.Fatal("OnShutdown not called after the deadline")
+	}
+}
+
+func TestTimeoutsFromEnv(t *testing.T) {
+	t.Setenv("HTTP_WRITE_TIMEOUT", "90s")
+	s := New("things-service", "8090")
+	if err := s.loadTimeouts(); err != nil {
+		t.Fatalf("loadTimeouts: %v", err)
+	}
+	if s.Timeouts.Write != 90*time.Second || s.Timeouts.Read != DefaultTimeouts.Read {
+		t.Fatalf("timeouts %+v", s.Timeouts)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	if err := New("things-service", "8090").loadTimeouts(); err == nil {
+		t.Fatal("invalid SHUTDOWN_TIMEOUT accepted")
+	}
+}
@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCompactEvery - через сколько записей в журнале он сворачивается в снимок
+const DefaultCompactEvery = 100
+
+// ErrClosed - хранилище закрыто, запись отклонена
+var ErrClosed = errors.New("storage is closed")
+
+// FileStorage - утилита для работы с JSON-хранилищем на диске.
+//
+// Снимок данных лежит в filePath и всегда перезаписывается атомарно:
+// временный файл + fsync + rename. Каждая мутация дописывается в журнал
+// упреждающей записи (filePath + ".wal"), который проигрывается поверх
+// снимка в LoadJSON и периодически сворачивается в новый снимок.
+type FileStorage struct {
+	mu           sync.RWMutex
+	name         string // метка файла в метриках
+	filePath     string
+	walPath      string
+	walEntries   int
+	compactEvery int
+	closed       bool
+}
+
+// walEntry - одна запись журнала
+type walEntry struct {
+	Op   string          `json:"op"` // put, delete
+	ID   int64           `json:"id"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+const (
+	walOpPut    = "put"
+	walOpDelete = "delete"
+)
+
+// NewFileStorage создаёт новое хранилище
+func NewFileStorage(filePath string) *FileStorage {
+	return &FileStorage{
+		name:         filepath.Base(filePath),
+		filePath:     filePath,
+		walPath:      filePath + ".wal",
+		compactEvery: DefaultCompactEvery,
+	}
+}
+
+// SetCompactEvery задаёт порог компактификации журнала (0 - отключить)
+func (fs *FileStorage) SetCompactEvery(n int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.compactEvery = n
+}
+
+// EnsureFile гарантирует, что файл существует
+func (fs *FileStorage) EnsureFile() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Убираем временные файлы, оставшиеся после сбоя посреди записи снимка
+	leftovers, _ := filepath.Glob(fs.filePath + ".tmp-*")
+	for _, name := range leftovers {
+		os.Remove(name)
+	}
+
+	if _, err := os.Stat(fs.filePath); os.IsNotExist(err) {
+		// Создаём файл с пустым массивом
+		return writeFileAtomic(fs.filePath, []byte("[]"), 0644)
+	}
+	return nil
+}
+
+// LoadJSON загружает снимок, проигрывает поверх него журнал и декодирует результат в v
+func (fs *FileStorage) LoadJSON(v interface{}) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	defer fs.observeLocked("load", time.Now())
+
+	data, err := os.ReadFile(fs.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	entries, err := fs.readWALLocked()
+	if err != nil {
+		return err
+	}
+	fs.walEntries = len(entries)
+
+	if len(entries) == 0 {
+		if len(data) == 0 {
+			return nil // файла нет или он пуст - это норма
+		}
+		return json.Unmarshal(data, v)
+	}
+
+	records, err := replay(data, entries)
+	if err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(merged, v)
+}
+
+// SaveJSON атомарно сохраняет полный снимок данных и очищает журнал
+func (fs *FileStorage) SaveJSON(v interface{}) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.closed {
+		return ErrClosed
+	}
+	defer fs.observeLocked("save", time.Now())
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(fs.filePath, data, 0644); err != nil {
+		return err
+	}
+
+	return fs.truncateWALLocked()
+}
+
+// Put дописывает в журнал новую версию записи с указанным ID
+func (fs *FileStorage) Put(id int64, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.appendLocked(walEntry{Op: walOpPut, ID: id, Data: data})
+}
+
+// Delete дописывает в журнал удаление записей с указанными ID
+func (fs *FileStorage) Delete(ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	entries := make([]walEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, walEntry{Op: walOpDelete, ID: id})
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.appendLocked(entries...)
+}
+
+// Compact сворачивает журнал в новый снимок
+func (fs *FileStorage) Compact() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.closed {
+		return ErrClosed
+	}
+	defer fs.observeLocked("compact", time.Now())
+
+	return fs.compactLocked()
+}
+
+// Close дожидается текущей операции, сворачивает журнал в снимок и закрывает
+// хранилище: дальнейшие записи возвращают ErrClosed. Повторный Close ничего не делает.
+func (fs *FileStorage) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.closed {
+		return nil
+	}
+	fs.closed = true
+	defer fs.observeLocked("compact", time.Now())
+
+	return fs.compactLocked()
+}
+
+// appendLocked пишет записи в журнал одним вызовом write и делает fsync
+func (fs *FileStorage) appendLocked(entries ...walEntry) error {
+	if fs.closed {
+		return ErrClosed
+	}
+	defer fs.observeLocked("append", time.Now())
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(fs.walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fs.walEntries += len(entries)
+	if fs.compactEvery > 0 && fs.walEntries >= fs.compactEvery {
+		return fs.compactLocked()
+	}
+
+	return nil
+}
+
+// compactLocked записывает снимок с проигранным журналом и удаляет журнал.
+// Если процесс упадёт между rename снимка и удалением журнала, журнал
+// будет проигран повторно - put и delete идемпотентны, так что это безопасно.
+func (fs *FileStorage) compactLocked() error {
+	data, err := os.ReadFile(fs.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	entries, err := fs.readWALLocked()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	records, err := replay(data, entries)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(fs.filePath, snapshot, 0644); err != nil {
+		return err
+	}
+
+	return fs.truncateWALLocked()
+}
+
+// readWALLocked читает журнал. Недописанная последняя строка (сбой посреди
+// append) отбрасывается и обрезается, повреждение в середине - ошибка.
+func (fs *FileStorage) readWALLocked() ([]walEntry, error) {
+	data, err := os.ReadFile(fs.walPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []walEntry
+	var offset int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		next := offset + int64(len(raw)) + 1
+		complete := next <= int64(len(data)) // строка завершена переводом строки
+
+		var entry walEntry
+		if err := json.Unmarshal(raw, &entry); err != nil || !complete || (entry.Op != walOpPut && entry.Op != walOpDelete) {
+			if next >= int64(len(data)) {
+				// Хвост оборван - обрезаем журнал до последней целой записи
+				if err := os.Truncate(fs.walPath, offset); err != nil {
+					return nil, err
+				}
+				break
+			}
+			return nil, fmt.Errorf("corrupted wal record at %s:%d", fs.walPath, line)
+		}
+
+		entries = append(entries, entry)
+		offset = next
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// truncateWALLocked удаляет журнал после того, как снимок записан
+func (fs *FileStorage) truncateWALLocked() error {
+	if err := os.Remove(fs.walPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fs.walEntries = 0
+
+	return syncDir(filepath.Dir(fs.walPath))
+}
+
+// replay применяет записи журнала к снимку. Порядок записей снимка
+// сохраняется, новые записи добавляются в конец.
+func replay(snapshot []byte, entries []walEntry) ([]json.RawMessage, error) {
+	var records []json.RawMessage
+	if len(bytes.TrimSpace(snapshot)) > 0 {
+		if err := json.Unmarshal(snapshot, &records); err != nil {
+			return nil, err
+		}
+	}
+
+	index := make(map[int64]int, len(records))
+	for i, record := range records {
+		var key struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(record, &key); err != nil {
+			return nil, err
+		}
+		index[key.ID] = i
+	}
+
+	for _, entry := range entries {
+		i, exists := index[entry.ID]
+		switch entry.Op {
+		case walOpPut:
+			if exists {
+				records[i] = entry.Data
+			} else {
+				index[entry.ID] = len(records)
+				records = append(records, entry.Data)
+			}
+		case walOpDelete:
+			if exists {
+				records[i] = nil
+				delete(index, entry.ID)
+			}
+		}
+	}
+
+	result := make([]json.RawMessage, 0, len(records))
+	for _, record := range records {
+		if record != nil {
+			result = append(result, record)
+		}
+	}
+
+	return result, nil
+}
+
+// writeFileAtomic пишет данные во временный файл рядом с path, делает fsync
+// и переименовывает его поверх path, так что читатель видит либо старое,
+// либо новое содержимое целиком.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir делает fsync каталога, чтобы rename и удаление файлов пережили сбой питания
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTestStorage(t *testing.T) (*FileStorage, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "records.json")
+	fs := NewFileStorage(path)
+	if err := fs.EnsureFile(); err != nil {
+		t.Fatalf("EnsureFile: %v", err)
+	}
+	return fs, path
+}
+
+// reopen имитирует перезапуск процесса: новое хранилище поверх тех же файлов
+func reopen(t *testing.T, path string) []testRecord {
+	t.Helper()
+
+	fs := NewFileStorage(path)
+	if err := fs.EnsureFile(); err != nil {
+		t.Fatalf("EnsureFile: %v", err)
+	}
+
+	var records []testRecord
+	if err := fs.LoadJSON(&records); err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	return records
+}
+
+func assertNames(t *testing.T, records []testRecord, want ...string) {
+	t.Helper()
+
+	if len(records) != len(want) {
+		t.Fatalf("got %d records %+v, want %v", len(records), records, want)
+	}
+	for i, name := range want {
+		if records[i].Name != name {
+			t.Fatalf("record %d: got %q, want %q", i, records[i].Name, name)
+		}
+	}
+}
+
+func TestSaveJSONRoundTrip(t *testing.T) {
+	fs, path := newTestStorage(t)
+
+	if err := fs.SaveJSON([]testRecord{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+
+	assertNames(t, reopen(t, path), "a", "b")
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestWALReplayedAfterCrash(t *testing.T) {
+	fs, path := newTestStorage(t)
+
+	if err := fs.Put(1, testRecord{ID: 1, Name: "a"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := fs.Put(2, testRecord{ID: 2, Name: "b"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := fs.Put(1, testRecord{ID: 1, Name: "a2"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := fs.Delete(2); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// Снимок не переписывался - всё состояние только в журнале
+	assertNames(t, reopen(t, path), "a2")
+}
+
+func TestTornWALTailIsDiscarded(t *testing.T) {
+	fs, path := newTestStorage(t)
+
+	if err := fs.Put(1, testRecord{ID: 1, Name: "a"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Сбой посреди append: в журнале осталась половина записи
+	f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"put","id":2,"data":{"id":2,"na`)
+	f.Close()
+
+	assertNames(t, reopen(t, path), "a")
+
+	// После восстановления журнал снова пригоден для записи
+	fs = NewFileStorage(path)
+	var records []testRecord
+	if err := fs.LoadJSON(&records); err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	if err := fs.Put(3, testRecord{ID: 3, Name: "c"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	assertNames(t, reopen(t, path), "a", "c")
+}
+
+func TestCompleteRecordWithoutNewlineIsDiscarded(t *testing.T) {
+	_, path := newTestStorage(t)
+
+	// write оборвался прямо перед завершающим переводом строки
+	if err := os.WriteFile(path+".wal", []byte(`{"op":"put","id":1,"data":{"id":1,"name":"a"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNames(t, reopen(t, path))
+}
+
+func TestCorruptedWALInTheMiddleFails(t *testing.T) {
+	_, path := newTestStorage(t)
+
+	wal := "garbage\n" + `{"op":"put","id":1,"data":{"id":1,"name":"a"}}` + "\n"
+	if err := os.WriteFile(path+".wal", []byte(wal), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []testRecord
+	if err := NewFileStorage(path).LoadJSON(&records); err == nil {
+		t.Fatal("expected error for corrupted journal")
+	}
+}
+
+func TestCrashDuringSnapshotWriteKeepsPreviousSnapshot(t *testing.T) {
+	fs, path := newTestStorage(t)
+
+	if err := fs.SaveJSON([]testRecord{{ID: 1, Name: "a"}}); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+
+	// Сбой до rename: недописанный временный файл рядом со снимком
+	tmp := path + ".tmp-123"
+	if err := os.WriteFile(tmp, []byte(`[{"id":1,"name":"b"},{"id":2`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNames(t, reopen(t, path), "a")
+
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Fatalf("stale temp file was not removed")
+	}
+}
+
+func TestCrashBetweenCompactionAndWALRemoval(t *testing.T) {
+	fs, path := newTestStorage(t)
+
+	fs.Put(1, testRecord{ID: 1, Name: "a"})
+	fs.Put(2, testRecord{ID: 2, Name: "b"})
+	fs.Delete(1)
+
+	wal, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
+	// Снимок уже новый, но журнал удалить не успели - повторный replay идемпотентен
+	if err := os.WriteFile(path+".wal", wal, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNames(t, reopen(t, path), "b")
+}
+
+func TestPeriodicCompaction(t *testing.T) {
+	fs, path := newTestStorage(t)
+	fs.SetCompactEvery(3)
+
+	fs.Put(1, testRecord{ID: 1, Name: "a"})
+	fs.Put(2, testRecord{ID: 2, Name: "b"})
+	if _, err := os.Stat(path + ".wal"); err != nil {
+		t.Fatalf("journal should exist before compaction: %v", err)
+	}
+
+	fs.Put(3, testRecord{ID: 3, Name: "c"})
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Fatalf("journal should be folded into snapshot")
+	}
+
+	var snapshot []testRecord
+	if err := NewFileStorage(path).LoadJSON(&snapshot); err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	assertNames(t, snapshot, "a", "b", "c")
+}
+
+// slowRecords кодируется, только когда release закрыт; started закрывается,
+// когда кодирование (а значит, SaveJSON под блокировкой) началось
+type slowRecords struct {
+	records []testRecord
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s slowRecords) MarshalJSON() ([]byte, error) {
+	close(s.started)
+	<-s.release
+	return json.Marshal(s.records)
+}
+
+func TestCloseWaitsForInFlightSave(t *testing.T) {
+	fs, path := newTestStorage(t)
+	fs.Put(1, testRecord{ID: 1, Name: "journalled"})
+
+	slow := slowRecords{
+		records: []testRecord{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}},
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	saved := make(chan error, 1)
+	go func() { saved <- fs.SaveJSON(slow) }()
+	<-slow.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- fs.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while SaveJSON was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(slow.release)
+	if err := <-saved; err != nil {
+		t.Fatalf("in-flight SaveJSON: %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	assertNames(t, reopen(t, path), "a", "b")
+	if err := fs.SaveJSON([]testRecord{}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("SaveJSON after Close: %v, want ErrClosed", err)
+	}
+	if err := fs.Put(3, testRecord{ID: 3}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Put after Close: %v, want ErrClosed", err)
+	}
+	if err := fs.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestCloseFoldsJournal(t *testing.T) {
+	fs, path := newTestStorage(t)
+	fs.Put(1, testRecord{ID: 1, Name: "a"})
+
+	if err := fs.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Fatal("journal left after Close")
+	}
+	assertNames(t, reopen(t, path), "a")
+}
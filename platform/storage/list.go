@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"strings"
+
+	"platform/pagination"
+)
+
+// listColumns - колонки SQLite для полей сортировки pagination.ListQuery
+var listColumns = map[string]string{
+	pagination.SortByID:        "id",
+	pagination.SortByCreatedAt: "created_at",
+	pagination.SortByUpdatedAt: "updated_at",
+}
+
+// ListSQL переводит запрос списка в SQL для таблицы с колонками id, created_at,
+// updated_at и, если список их фильтрует, status, user_id и order_id: filter - условие по
+// фильтрам для подсчёта, page - условие, порядок и LIMIT для страницы (на одну
+// запись больше, чтобы NewPage увидел следующую страницу)
+func ListSQL(q pagination.ListQuery) (filter string, filterArgs []interface{}, page string, pageArgs []interface{}) {
+	var conds []string
+	add := func(cond string, args ...interface{}) {
+		conds = append(conds, cond)
+		filterArgs = append(filterArgs, args...)
+	}
+	if q.Status != "" {
+		add(`status = ?`, q.Status)
+	}
+	if q.UserID != 0 {
+		add(`user_id = ?`, q.UserID)
+	}
+	if q.OrderID != 0 {
+		add(`order_id = ?`, q.OrderID)
+	}
+	if !q.CreatedFrom.IsZero() {
+		add(`created_at >= ?`, FormatTime(q.CreatedFrom))
+	}
+	if !q.CreatedTo.IsZero() {
+		add(`created_at < ?`, FormatTime(q.CreatedTo))
+	}
+	if !q.UpdatedFrom.IsZero() {
+		add(`updated_at >= ?`, FormatTime(q.UpdatedFrom))
+	}
+	if !q.UpdatedTo.IsZero() {
+		add(`updated_at < ?`, FormatTime(q.UpdatedTo))
+	}
+	filter = where(conds)
+
+	column, cmp, dir := listColumns[q.Sort], ">", "ASC"
+	if q.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	pageArgs = append(pageArgs, filterArgs...)
+	if q.After != nil {
+		if q.Sort == pagination.SortByID {
+			conds = append(conds, `id `+cmp+` ?`)
+			pageArgs = append(pageArgs, q.After.ID)
+		} else {
+			at := FormatTime(q.After.At)
+			conds = append(conds, `(`+column+` `+cmp+` ? OR (`+column+` = ? AND id `+cmp+` ?))`)
+			pageArgs = append(pageArgs, at, at, q.After.ID)
+		}
+	}
+	page = where(conds) + ` ORDER BY ` + column + ` ` + dir
+	if column != "id" {
+		page += `, id ` + dir
+	}
+	page += ` LIMIT ?`
+	pageArgs = append(pageArgs, q.Limit+1)
+	return filter, filterArgs, page, pageArgs
+}
+
+func where(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(conds, ` AND `)
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_operation_duration_seconds",
+		Help:    "FileStorage operation latency by file and operation (load, save, append, compact).",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"file", "op"})
+	fileSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "storage_file_size_bytes",
+		Help: "FileStorage file size by file and kind (snapshot, wal).",
+	}, []string{"file", "kind"})
+)
+
+// observeLocked записывает длительность операции op, начатой в start,
+// и текущие размеры снимка и журнала
+func (fs *FileStorage) observeLocked(op string, start time.Time) {
+	operationDuration.WithLabelValues(fs.name, op).Observe(time.Since(start).Seconds())
+
+	for kind, path := range map[string]string{"snapshot": fs.filePath, "wal": fs.walPath} {
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		fileSize.WithLabelValues(fs.name, kind).Set(float64(size))
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsTrackFileSizes(t *testing.T) {
+	fs, path := newTestStorage(t)
+	if err := fs.SaveJSON([]testRecord{{ID: 1, Name: "first"}}); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+	if err := fs.Put(2, testRecord{ID: 2, Name: "second"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	for kind, file := range map[string]string{"snapshot": path, "wal": path + ".wal"} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("stat %s: %v", kind, err)
+		}
+		if got := testutil.ToFloat64(fileSize.WithLabelValues("records.json", kind)); got != float64(info.Size()) {
+			t.Errorf("%s size %v, want %d", kind, got, info.Size())
+		}
+	}
+
+	if err := fs.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if got := testutil.ToFloat64(fileSize.WithLabelValues("records.json", "wal")); got != 0 {
+		t.Errorf("wal size %v after compaction, want 0", got)
+	}
+	if n := testutil.CollectAndCount(operationDuration, "storage_operation_duration_seconds"); n < 3 {
+		t.Errorf("%d operation series, want save, append and compact", n)
+	}
+}
@@ -0,0 +1,64 @@
+// Package storagetest - каркас стресс-тестов репозиториев: параллельные
+// писатели и читатели поверх любого бэкенда. Тесты с ним рассчитаны на
+// запуск с детектором гонок: go test -race ./...
+package storagetest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Workers - сколько горутин запускает Run на каждую функцию
+const Workers = 8
+
+// Run запускает каждую из fns в Workers горутинах и ждёт их завершения.
+// Горутина w вызывает fn(w, i) для i от 0 до n-1; первая ошибка
+// останавливает горутину и проваливает тест.
+func Run(t *testing.T, n int, fns ...func(w, i int) error) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, Workers*len(fns))
+	for _, fn := range fns {
+		for w := 0; w < Workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					if err := fn(w, i); err != nil {
+						errs <- fmt.Errorf("worker %d, step %d: %w", w, i, err)
+						return
+					}
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// IDs проверяет, что бэкенд не выдаёт один идентификатор дважды
+type IDs struct {
+	mu   sync.Mutex
+	seen map[int64]bool
+}
+
+// Add запоминает id; повтор - ошибка
+func (ids *IDs) Add(id int64) error {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+
+	if ids.seen == nil {
+		ids.seen = make(map[int64]bool)
+	}
+	if ids.seen[id] {
+		return fmt.Errorf("duplicate id %d", id)
+	}
+	ids.seen[id] = true
+	return nil
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"platform/tracing"
+)
+
+// Traced - основа обёрток репозиториев, которые пишут span на каждый вызов
+// хранилища Next, так что трасса запроса показывает время в хранилище любого
+// бэкенда. Обёртка встраивает Traced и в каждом методе открывает span через Start.
+type Traced[R io.Closer] struct {
+	Next R
+	name string
+}
+
+// NewTraced оборачивает next; name - префикс имён span, например OrderRepository
+func NewTraced[R io.Closer](name string, next R) Traced[R] {
+	return Traced[R]{Next: next, name: name}
+}
+
+// Start начинает span name.method, например OrderRepository.CreateOrder;
+// завершается он через tracing.End
+func (t Traced[R]) Start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, t.name+"."+method, attrs...)
+}
+
+// Close закрывает Next; у закрытия нет контекста запроса, span не нужен
+func (t Traced[R]) Close() error {
+	return t.Next.Close()
+}
@@ -0,0 +1,7 @@
+package storage
+
+import "platform/problem"
+
+// ErrVersionMismatch - запись изменилась с тех пор, как клиент её прочитал:
+// версия из If-Match или expectedVersion не совпала с текущей
+var ErrVersionMismatch = problem.New(problem.PreconditionFailed, "version_mismatch", "version mismatch")
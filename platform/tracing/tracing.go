@@ -0,0 +1,194 @@
+// Package tracing - распределённая трассировка OpenTelemetry: span на каждый
+// входящий запрос, исходящий вызов и операцию хранилища, заголовок traceparent
+// (W3C Trace Context) между сервисами и экспорт span в OTLP, stdout или файл.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"platform/config"
+	"platform/httputil/roundtrip"
+	"platform/httputil/statuswriter"
+	"platform/requestid"
+)
+
+// instrumentation - имя трассировщика span этого пакета
+const instrumentation = "platform/tracing"
+
+// Экспортёры span (OTEL_TRACES_EXPORTER)
+const (
+	ExporterNone   = "none"   // span не экспортируются, traceparent всё равно пересылается
+	ExporterOTLP   = "otlp"   // OTLP/HTTP, адрес - OTEL_EXPORTER_OTLP_ENDPOINT
+	ExporterStdout = "stdout" // JSON в stdout
+	ExporterFile   = "file"   // JSON построчно в OTEL_TRACES_FILE
+)
+
+// Setup настраивает трассировку сервиса service по OTEL_TRACES_EXPORTER
+// (по умолчанию none) и пропагатор traceparent/baggage. Возвращённая функция
+// выгружает оставшиеся span и закрывает экспортёр.
+func Setup(ctx context.Context, service string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var processor sdktrace.SpanProcessor
+	closeOutput := func() error { return nil }
+	switch exporter := config.String("OTEL_TRACES_EXPORTER", ExporterNone); exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exp)
+	case ExporterStdout:
+		processor, err = writerProcessor(os.Stdout)
+		if err != nil {
+			return nil, err
+		}
+	case ExporterFile:
+		path := config.String("OTEL_TRACES_FILE", "./data/traces.jsonl")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if processor, err = writerProcessor(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		closeOutput = f.Close
+	default:
+		return nil, fmt.Errorf("invalid OTEL_TRACES_EXPORTER %q: want %s, %s, %s or %s",
+			exporter, ExporterNone, ExporterOTLP, ExporterStdout, ExporterFile)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(service)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeOutput(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// writerProcessor пишет каждый span строкой JSON в w сразу по завершении,
+// чтобы файл можно было читать в тестах без выгрузки
+func writerProcessor(w io.Writer) (sdktrace.SpanProcessor, error) {
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewSimpleSpanProcessor(exp), nil
+}
+
+// Start начинает внутренний span name, например OrderRepository.CreateOrder
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End завершает span; ошибка *err, если есть, записывается в span.
+// Вызывается через defer с именованным результатом: defer tracing.End(span, &err).
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// Middleware продолжает трассу из traceparent запроса (или начинает новую)
+// серверным span с именем "METHOD шаблон маршрута" из route
+func Middleware(route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			name := r.Method
+			attrs := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)}
+			if template := route(r); template != "" {
+				name += " " + template
+				attrs = append(attrs, semconv.HTTPRoute(template))
+			}
+			if id := requestid.From(ctx); id != "" {
+				attrs = append(attrs, attribute.String("request.id", id))
+			}
+
+			ctx, span := otel.Tracer(instrumentation).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			sw := statuswriter.New(w)
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(sw.Status))
+			if sw.Status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.Status))
+			}
+		})
+	}
+}
+
+// Transport оборачивает исходящий вызов клиентским span "METHOD хост" и
+// передаёт трассу соседнему сервису в traceparent.
+// Base - транспорт запросов, по умолчанию http.DefaultTransport.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := otel.Tracer(instrumentation).Start(req.Context(), req.Method+" "+req.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLFull(req.URL.String()),
+		))
+	defer span.End()
+
+	// RoundTrip не должен менять запрос вызывающего
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections закрывает простаивающие соединения Base
+func (t *Transport) CloseIdleConnections() {
+	roundtrip.CloseIdle(t.Base)
+}
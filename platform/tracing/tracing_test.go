@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record подменяет глобальные провайдер и пропагатор на время теста и
+// возвращает записанные span
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	recorder := record(t)
+	h := Middleware(func(*http.Request) string { return "/api/things/{id}" })(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !trace.SpanContextFromContext(r.Context()).IsValid() {
+				t.Error("handler context has no span")
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest(http.MethodGet, "/api/things/7", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /api/things/{id}" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span %q kind %v", span.Name(), span.SpanKind())
+	}
+	if span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span is not a child of traceparent: trace %s, parent %s", span.SpanContext().TraceID(), span.Parent().SpanID())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("status %v for 502", span.Status())
+	}
+}
+
+func TestTransportPropagatesTrace(t *testing.T) {
+	recorder := record(t)
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	ctx, parent := Start(context.Background(), "checkout")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL, nil)
+	resp, err := (&http.Client{Transport: &Transport{}}).Do(req)
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("caller's request was modified")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].SpanKind() != trace.SpanKindClient {
+		t.Fatalf("spans %v, want client span and its parent", spans)
+	}
+	client := spans[0].SpanContext()
+	if want := "00-" + client.TraceID().String() + "-" + client.SpanID().String() + "-01"; traceparent != want {
+		t.Fatalf("traceparent %q, want %q", traceparent, want)
+	}
+	if spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("client span is not a child of the caller's span")
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	t.Setenv("OTEL_TRACES_EXPORTER", ExporterFile)
+	t.Setenv("OTEL_TRACES_FILE", path)
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	shutdown, err := Setup(context.Background(), "things-service")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	func() (err error) {
+		_, span := Start(context.Background(), "ThingRepository.GetThing")
+		defer End(span, &err)
+		return errors.New("thing not found")
+	}()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open traces: %v", err)
+	}
+	defer f.Close()
+	var span struct {
+		Name   string
+		Status struct{ Code, Description string }
+	}
+	if err := json.NewDecoder(f).Decode(&span); err != nil && err != io.EOF {
+		t.Fatalf("decode span: %v", err)
+	}
+	if span.Name != "ThingRepository.GetThing" || span.Status.Code != "Error" || span.Status.Description != "thing not found" {
+		t.Fatalf("span %+v", span)
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+	if _, err := Setup(context.Background(), "things-service"); err == nil {
+		t.Fatal("unknown exporter accepted")
+	}
+}
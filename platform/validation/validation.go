@@ -0,0 +1,196 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"platform/problem"
+)
+
+// MaxRequestBody - предельный размер JSON-тела запроса
+const MaxRequestBody = 1 << 20
+
+var (
+	// ErrInvalidRequest - тело запроса не разбирается или не проходит проверку
+	ErrInvalidRequest = problem.New(problem.Invalid, "invalid_request", "invalid request")
+	// ErrEmptyBody - тела нет; для запросов с необязательным телом это не ошибка
+	ErrEmptyBody = problem.Errorf(problem.Invalid, "empty_body", "%w: request body is empty", ErrInvalidRequest)
+	// ErrRequestTooLarge - тело длиннее MaxRequestBody
+	ErrRequestTooLarge = problem.New(problem.TooLarge, "body_too_large", "request body too large")
+)
+
+// invalidFields - ошибка проверки с нарушениями по полям
+func invalidFields(fields []problem.FieldError) error {
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return &problem.Error{
+		Kind:   problem.Invalid,
+		Code:   "validation_failed",
+		Detail: ErrInvalidRequest.Error() + ": " + strings.Join(msgs, "; "),
+		Fields: fields,
+		Err:    ErrInvalidRequest,
+	}
+}
+
+// validate проверяет теги binding структур запросов; поля называются по JSON
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// RegisterType проверяет поля перечисленных типов как значение fn.
+// Вызывается до обработки запросов, например из init.
+func RegisterType(fn func(reflect.Value) interface{}, types ...interface{}) {
+	validate.RegisterCustomTypeFunc(fn, types...)
+}
+
+// Validate проверяет теги binding структуры; нарушения - *problem.Error с Fields
+func Validate(v interface{}) error {
+	err := validate.Struct(v)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fields := make([]problem.FieldError, len(errs))
+	for i, e := range errs {
+		field := e.Namespace()
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest // без имени структуры запроса
+		}
+		fields[i] = problem.FieldError{Field: field, Rule: e.Tag(), Param: e.Param(), Message: ruleMessage(e)}
+	}
+	return invalidFields(fields)
+}
+
+func ruleMessage(e validator.FieldError) string {
+	unit := ""
+	switch e.Kind() {
+	case reflect.Slice, reflect.Map:
+		unit = " elements"
+	case reflect.String:
+		unit = " characters"
+	}
+
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if unit != "" {
+			return "must have at least " + e.Param() + unit
+		}
+		return "must be at least " + e.Param()
+	case "max":
+		if unit != "" {
+			return "must have at most " + e.Param() + unit
+		}
+		return "must be at most " + e.Param()
+	case "oneof":
+		return "must be one of " + e.Param()
+	}
+	return "must satisfy " + e.Tag()
+}
+
+// DecodeJSON читает тело запроса в dst и проверяет его теги binding.
+// Тело - ровно один JSON-объект не длиннее MaxRequestBody без неизвестных полей.
+// Ошибки - *problem.Error: нарушения по полям, ErrEmptyBody, ErrRequestTooLarge;
+// все, кроме последней, оборачивают ErrInvalidRequest.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBody))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return ErrRequestTooLarge
+		}
+		return problem.Errorf(problem.Invalid, "malformed_json", "%w: body must contain a single JSON object", ErrInvalidRequest)
+	}
+
+	return Validate(dst)
+}
+
+// Decode читает тело запроса в dst как DecodeJSON; при ошибке сам отвечает
+// клиенту и возвращает false
+func Decode(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := DecodeJSON(w, r, dst); err != nil {
+		problem.Write(w, r, err)
+		return false
+	}
+	return true
+}
+
+func decodeError(err error) error {
+	var (
+		tooLarge *http.MaxBytesError
+		typeErr  *json.UnmarshalTypeError
+		syntax   *json.SyntaxError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return ErrEmptyBody
+	case errors.As(err, &tooLarge):
+		return ErrRequestTooLarge
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return invalidFields([]problem.FieldError{{
+			Field:   fieldPath(typeErr.Field),
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: "must be " + typeErr.Type.String(),
+		}})
+	case errors.As(err, &syntax), errors.Is(err, io.ErrUnexpectedEOF):
+		return problem.Errorf(problem.Invalid, "malformed_json", "%w: malformed JSON", ErrInvalidRequest)
+	}
+
+	// Неизвестное поле encoding/json сообщает только текстом
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return invalidFields([]problem.FieldError{{
+			Field:   strings.Trim(name, `"`),
+			Rule:    "unknown",
+			Message: "is not a known field",
+		}})
+	}
+	return problem.Errorf(problem.Invalid, "invalid_request", "%w: %w", ErrInvalidRequest, err)
+}
+
+// fieldPath переводит путь encoding/json (items.0.quantity) в вид
+// validator (items[0].quantity), чтобы поля назывались одинаково
+func fieldPath(field string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/problem"
+)
+
+type testItem struct {
+	SKU      string `json:"sku" binding:"required"`
+	Quantity int    `json:"quantity" binding:"required,min=1"`
+}
+
+type testRequest struct {
+	UserID int64      `json:"userId" binding:"required,min=1"`
+	Age    int        `json:"age" binding:"min=0,max=150"`
+	Items  []testItem `json:"items" binding:"required,min=1,dive"`
+}
+
+func decode(t *testing.T, body string) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	var req testRequest
+	err := DecodeJSON(w, r, &req)
+	if err != nil {
+		problem.Write(w, r, err)
+	}
+	return w, err
+}
+
+func TestDecodeJSONReportsFields(t *testing.T) {
+	w, err := decode(t, `{"userId": 0, "age": 9000, "items": [{"sku": "A", "quantity": 0}]}`)
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("error %v, want ErrInvalidRequest", err)
+	}
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var body problem.Problem
+	json.NewDecoder(w.Body).Decode(&body)
+	got := map[string]string{}
+	for _, f := range body.Errors {
+		got[f.Field] = f.Rule
+	}
+	want := map[string]string{"userId": "required", "age": "max", "items[0].quantity": "required"}
+	if len(got) != len(want) {
+		t.Fatalf("fields %+v, want %v", body.Errors, want)
+	}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Fatalf("fields %+v, want %v", body.Errors, want)
+		}
+	}
+}
+
+func TestDecodeJSONRejectsMalformedBodies(t *testing.T) {
+	for _, tc := range []struct {
+		body  string
+		field string
+	}{
+		{`{"userId": 1, "items": [{"sku": "A", "quantity": 1}], "admin": true}`, "admin"},
+		{`{"userId": "one", "items": [{"sku": "A", "quantity": 1}]}`, "userId"},
+		{`{"userId": 1, "items": [{"sku": "A", "quantity": 1}, {"sku": "B", "quantity": "two"}]}`, "items[1].quantity"},
+		{`{"userId": 1, "items": []}`, "items"},
+		{`{"userId": 1, "items": [{"sku": "A", "quantity": 1}]} {}`, ""},
+		{`{"userId": 1,`, ""},
+		{``, ""},
+	} {
+		w, err := decode(t, tc.body)
+		if !errors.Is(err, ErrInvalidRequest) || w.Code != http.StatusBadRequest {
+			t.Errorf("%s: error %v, status %d", tc.body, err, w.Code)
+			continue
+		}
+		var invalid *problem.Error
+		if tc.field != "" && (!errors.As(err, &invalid) || len(invalid.Fields) == 0 || invalid.Fields[0].Field != tc.field) {
+			t.Errorf("%s: error %v, want field %s", tc.body, err, tc.field)
+		}
+	}
+
+	if _, err := decode(t, ``); !errors.Is(err, ErrEmptyBody) {
+		t.Fatalf("empty body: %v, want ErrEmptyBody", err)
+	}
+	if _, err := decode(t, `{"userId": 1, "items": [{"sku": "A", "quantity": 1}]}`); err != nil {
+		t.Fatalf("valid body: %v", err)
+	}
+}
+
+func TestDecodeJSONLimitsBodySize(t *testing.T) {
+	w, err := decode(t, `{"items": [{"sku": "`+strings.Repeat("a", MaxRequestBody)+`"}]}`)
+	if !errors.Is(err, ErrRequestTooLarge) || w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("error %v, status %d", err, w.Code)
+	}
+}
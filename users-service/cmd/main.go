@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
-	"os"
+	"path/filepath"
 
-	"github.com/gorilla/mux"
-	httpSwagger "github.com/swaggo/http-swagger"
+	"platform/config"
+	"platform/health"
+	"platform/idempotency"
+	"platform/logging"
+	"platform/server"
+	"platform/storage"
+	"platform/tracing"
 
+	_ "users-service/docs"
+	"users-service/internal/client"
 	"users-service/internal/handlers"
 	"users-service/internal/repository"
-	_ "users-service/docs"
+	"users-service/internal/saga"
 )
 
 // @title Users Service API
@@ -19,19 +29,97 @@ import (
 // @host localhost:8081
 // @basePath /api
 
+const (
+	jsonPath          = "./data/users.json"
+	deletionsJSONPath = "./data/user_deletions.json"
+	idempotencyPath   = "./data/idempotency_keys.json"
+)
+
 func main() {
-	router := mux.NewRouter()
+	if err := logging.Setup("users-service"); err != nil {
+		log.Fatalf("Invalid logging config: %v", err)
+	}
+
+	migrateDown := flag.Int("migrate-down", -1, "откатить схему SQLite до указанной версии и выйти")
+	flag.Parse()
+
+	if *migrateDown >= 0 {
+		if err := rollbackSQLite(*migrateDown); err != nil {
+			log.Fatalf("Failed to roll back migrations: %v", err)
+		}
+		log.Printf("SQLite schema rolled back to version %d", *migrateDown)
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "users-service")
+	if err != nil {
+		log.Fatalf("Invalid tracing config: %v", err)
+	}
 
-	// Инициализируем JSON репозиторий
-	repo, err := repository.NewJSONUserRepository("./data/users.json")
+	srv := server.New("users-service", "8081")
+	// Функции остановки вызываются в обратном порядке: span выгружаются последними
+	srv.OnShutdown(shutdownTracing)
+	// Пробы отвечают уже во время загрузки хранилища; /health/startup - 503 до srv.Run
+	if err := srv.Listen(); err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	backend := config.String("STORAGE_BACKEND", "json")
+
+	// Инициализируем репозиторий выбранного бэкенда
+	repo, deletionRepo, err := newRepository(backend)
 	if err != nil {
 		log.Fatalf("Failed to initialize repository: %v", err)
 	}
+	// Каждый вызов хранилища - span в трассе запроса
+	repo, deletionRepo = repository.NewTracedUserRepository(repo), repository.NewTracedDeletionRepository(deletionRepo)
+	srv.OnShutdown(func(context.Context) error {
+		return errors.Join(deletionRepo.Close(), repo.Close())
+	})
+	if dir := storageDir(backend); dir != "" {
+		srv.Health.Add("storage", health.Dir(dir))
+	}
+
+	orders := client.NewOrdersServiceClient()
+	payments := client.NewPaymentsServiceClient()
+	deliveries := client.NewDeliveryServiceClient()
+	srv.OnShutdown(func(context.Context) error {
+		orders.Close()
+		payments.Close()
+		deliveries.Close()
+		return nil
+	})
+	// Саги повторяют шаги, пока сервис не вернётся, поэтому соседи не снимают готовность
+	srv.Health.AddOptional("orders-service", orders.HealthCheck())
+	srv.Health.AddOptional("payments-service", payments.HealthCheck())
+	srv.Health.AddOptional("delivery-service", deliveries.HealthCheck())
 
-	handler := handlers.NewUserHandler(repo)
+	// Сага удаления: сначала сам пользователь, затем его данные в других сервисах
+	deletions := saga.NewCoordinator(deletionRepo, deletionSteps(repo, orders, payments, deliveries), saga.DefaultConfig)
+	// Остановка прерывает шаги саг, ход сохранён - после перезапуска они продолжатся
+	srv.OnShutdown(func(context.Context) error {
+		deletions.Close()
+		return nil
+	})
+	resumed, err := deletions.Resume(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to resume user deletions: %v", err)
+	}
+	if resumed > 0 {
+		log.Printf("Resumed %d user deletions", resumed)
+	}
+
+	handler := handlers.NewUserHandler(repo, deletions)
+
+	keys, err := newIdempotencyStore(backend)
+	if err != nil {
+		log.Fatalf("Failed to initialize idempotency keys: %v", err)
+	}
+	srv.OnShutdown(func(context.Context) error { return keys.Close() })
 
 	// API endpoints
-	api := router.PathPrefix("/api").Subrouter()
+	api := srv.API
+	api.Use(idempotency.Middleware(keys))
 
 	api.HandleFunc("/users", handler.CreateUser).Methods("POST")
 	api.HandleFunc("/users", handler.GetAllUsers).Methods("GET")
@@ -39,25 +127,105 @@ func main() {
 	api.HandleFunc("/users/{id}", handler.UpdateUser).Methods("PUT")
 	api.HandleFunc("/users/{id}", handler.DeleteUser).Methods("DELETE")
 	api.HandleFunc("/users/{id}/exists", handler.UserExists).Methods("GET")
+	api.HandleFunc("/users/{id}/deletion", handler.GetUserDeletion).Methods("GET")
 
-	// Swagger
-	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8081/swagger/doc.json"),
-	))
+	if err := srv.Run(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
 
-	// Health check
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+// newRepository выбирает бэкенд хранилища: json (по умолчанию), memory или sqlite.
+// Саги удаления хранятся в том же бэкенде, что и пользователи.
+func newRepository(backend string) (repository.UserRepository, repository.DeletionRepository, error) {
+	switch backend {
+	case "json":
+		repo, err := repository.NewJSONUserRepository(jsonPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		deletions, err := repository.NewJSONDeletionRepository(deletionsJSONPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, deletions, nil
+	case "memory":
+		return repository.NewMemoryUserRepository(), repository.NewMemoryDeletionRepository(), nil
+	case "sqlite":
+		repo, err := repository.NewSQLiteUserRepository(sqlitePath())
+		if err != nil {
+			return nil, nil, err
+		}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8081"
+		// Однократно переносим данные, накопленные в JSON-хранилище
+		imported, err := repo.ImportJSON(context.Background(), jsonPath)
+		if err != nil {
+			repo.Close()
+			return nil, nil, fmt.Errorf("import %s: %w", jsonPath, err)
+		}
+		if imported > 0 {
+			log.Printf("Imported %d users from %s", imported, jsonPath)
+		}
+		return repo, repo.Deletions(), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", backend)
 	}
+}
 
-	log.Printf("Starting users-service on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatalf("Server failed: %v", err)
+// deletionSteps - шаги саги удаления пользователя в порядке выполнения.
+// Пользователь удаляется первым, чтобы на него нельзя было завести новые данные.
+func deletionSteps(repo repository.UserRepository, orders *client.OrdersServiceClient,
+	payments *client.PaymentsServiceClient, deliveries *client.DeliveryServiceClient) []saga.Step {
+	return []saga.Step{
+		{Name: "user", Run: func(ctx context.Context, userID int64) error {
+			exists, err := repo.UserExists(ctx, userID)
+			if err != nil || !exists {
+				return err
+			}
+			return repo.DeleteUser(ctx, userID, 0)
+		}},
+		{Name: "orders", Run: orders.DeleteUserOrders},
+		{Name: "payments", Run: payments.DeleteUserPayments},
+		{Name: "deliveries", Run: deliveries.DeleteUserDeliveries},
+	}
+}
+
+// newIdempotencyStore хранит ответы на запросы с Idempotency-Key: в памяти для
+// memory-бэкенда, иначе в idempotencyPath. IDEMPOTENCY_KEY_TTL - сколько хранится ключ, например 48h
+func newIdempotencyStore(backend string) (*idempotency.Store, error) {
+	ttl, err := config.Duration("IDEMPOTENCY_KEY_TTL", idempotency.DefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend == "memory" {
+		return idempotency.NewMemoryStore(ttl), nil
+	}
+	return idempotency.NewStore(idempotencyPath, ttl)
+}
+
+// storageDir - каталог файлов бэкенда, его проверяет /health/ready; у memory файлов нет
+func storageDir(backend string) string {
+	switch backend {
+	case "memory":
+		return ""
+	case "sqlite":
+		return filepath.Dir(sqlitePath())
+	default:
+		return filepath.Dir(jsonPath)
 	}
 }
+
+func sqlitePath() string {
+	return config.String("SQLITE_PATH", "./data/users.db")
+}
+
+// rollbackSQLite откатывает схему базы до версии target
+func rollbackSQLite(target int) error {
+	db, err := storage.OpenSQLite(sqlitePath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return storage.MigrateDown(context.Background(), db, repository.Migrations(), target)
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"platform/config"
+	"platform/health"
+	"platform/metrics"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+type DeliveryServiceClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewDeliveryServiceClient создаёт клиента, адрес берётся из DELIVERY_SERVICE_URL
+func NewDeliveryServiceClient() *DeliveryServiceClient {
+	return &DeliveryServiceClient{
+		baseURL: config.String("DELIVERY_SERVICE_URL", "http://delivery-service:8084"),
+		httpClient: &http.Client{
+			Transport: &metrics.Transport{Client: "DeliveryServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *DeliveryServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// HealthCheck - проверка готовности: отвечает ли delivery-service на /health/live
+func (c *DeliveryServiceClient) HealthCheck() health.Check {
+	return health.HTTP(c.baseURL + "/health/live")
+}
+
+// DeleteUserDeliveries удаляет все доставки пользователя
+func (c *DeliveryServiceClient) DeleteUserDeliveries(ctx context.Context, userID int64) error {
+	url := fmt.Sprintf("%s/api/deliveries/user/%d", c.baseURL, userID)
+	return deleteUserData(ctx, c.httpClient, url, "deliveries")
+}
@@ -1,10 +1,16 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
+
+	"platform/config"
+	"platform/health"
+	"platform/metrics"
+	"platform/requestid"
+	"platform/tracing"
 )
 
 type OrdersServiceClient struct {
@@ -14,37 +20,48 @@ type OrdersServiceClient struct {
 
 // создаём клиента берем урл из енв
 func NewOrdersServiceClient() *OrdersServiceClient {
-	ordersURL := os.Getenv("ORDERS_SERVICE_URL")
-	if ordersURL == "" {
-		ordersURL = "http://orders-service:8082"
-	}
-
 	return &OrdersServiceClient{
-		baseURL: ordersURL,
+		baseURL: config.String("ORDERS_SERVICE_URL", "http://orders-service:8082"),
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Transport: &metrics.Transport{Client: "OrdersServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   10 * time.Second,
 		},
 	}
 }
 
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *OrdersServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// HealthCheck - проверка готовности: отвечает ли orders-service на /health/live
+func (c *OrdersServiceClient) HealthCheck() health.Check {
+	return health.HTTP(c.baseURL + "/health/live")
+}
+
 // DeleteUserOrders удаляет все заказы пользователя по иду
-func (c *OrdersServiceClient) DeleteUserOrders(userID int64) error {
+func (c *OrdersServiceClient) DeleteUserOrders(ctx context.Context, userID int64) error {
 	url := fmt.Sprintf("%s/api/orders/user/%d", c.baseURL, userID)
+	return deleteUserData(ctx, c.httpClient, url, "orders")
+}
 
-	req, err := http.NewRequest("DELETE", url, nil)
+// deleteUserData вызывает DELETE .../user/{userId} сервиса-владельца данных.
+// Удаление идемпотентно: повтор после сбоя просто ничего не найдёт.
+func deleteUserData(ctx context.Context, httpClient *http.Client, url, what string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to delete user orders: %w", err)
+		return fmt.Errorf("failed to delete user %s: %w", what, err)
 	}
 	defer resp.Body.Close()
 
 	// 204 No Content или 200 OK — оба успешны
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to delete user orders: status %d", resp.StatusCode)
+		return fmt.Errorf("failed to delete user %s: status %d", what, resp.StatusCode)
 	}
 
 	return nil
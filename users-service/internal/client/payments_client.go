@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"platform/config"
+	"platform/health"
+	"platform/metrics"
+	"platform/requestid"
+	"platform/tracing"
+)
+
+type PaymentsServiceClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewPaymentsServiceClient создаёт клиента, адрес берётся из PAYMENTS_SERVICE_URL
+func NewPaymentsServiceClient() *PaymentsServiceClient {
+	return &PaymentsServiceClient{
+		baseURL: config.String("PAYMENTS_SERVICE_URL", "http://payments-service:8083"),
+		httpClient: &http.Client{
+			Transport: &metrics.Transport{Client: "PaymentsServiceClient", Base: &tracing.Transport{Base: &requestid.Transport{}}},
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// Close закрывает простаивающие соединения с сервисом при остановке
+func (c *PaymentsServiceClient) Close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// HealthCheck - проверка готовности: отвечает ли payments-service на /health/live
+func (c *PaymentsServiceClient) HealthCheck() health.Check {
+	return health.HTTP(c.baseURL + "/health/live")
+}
+
+// DeleteUserPayments удаляет все платежи пользователя
+func (c *PaymentsServiceClient) DeleteUserPayments(ctx context.Context, userID int64) error {
+	url := fmt.Sprintf("%s/api/payments/user/%d", c.baseURL, userID)
+	return deleteUserData(ctx, c.httpClient, url, "payments")
+}
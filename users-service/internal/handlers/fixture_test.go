@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"users-service/internal/repository"
+	"users-service/internal/saga"
+)
+
+// newTestRouter - маршруты как в cmd/main.go поверх репозиториев в памяти
+func newTestRouter() *mux.Router {
+	deletions := saga.NewCoordinator(repository.NewMemoryDeletionRepository(), nil, saga.DefaultConfig)
+	handler := NewUserHandler(repository.NewMemoryUserRepository(), deletions)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/users", handler.CreateUser).Methods("POST")
+	r.HandleFunc("/users/{id}", handler.GetUserByID).Methods("GET")
+	r.HandleFunc("/users/{id}", handler.UpdateUser).Methods("PUT")
+	return r
+}
+
+// serve выполняет запрос к router; header - дополнительные заголовки
+func serve(router http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// decode разбирает JSON-ответ в v; тело не JSON - ошибка теста
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatalf("decode response %d: %v", w.Code, err)
+	}
+}
@@ -2,37 +2,49 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 
+	"platform/httputil"
+	"platform/pagination"
+	"platform/problem"
+	"platform/validation"
+
+	"users-service/internal/models"
 	"users-service/internal/repository"
+	"users-service/internal/saga"
 )
 
 // UserHandler обработчик пользователей
 type UserHandler struct {
-	repo *repository.JSONUserRepository
+	repo      repository.UserRepository
+	deletions *saga.Coordinator
 }
 
 // CreateUserRequest структура для создания пользователя
 type CreateUserRequest struct {
 	Email string `json:"email" binding:"required"`
 	Name  string `json:"name" binding:"required"`
-	Age   int    `json:"age" binding:"required,min=0,max=150"`
+	Age   *int   `json:"age" binding:"required,min=0,max=150"` // указатель: с int required отверг бы возраст 0
 }
 
 // UpdateUserRequest структура для обновления пользователя
 type UpdateUserRequest struct {
 	Email string `json:"email" binding:"required"`
 	Name  string `json:"name" binding:"required"`
-	Age   int    `json:"age" binding:"required,min=0,max=150"`
+	Age   *int   `json:"age" binding:"required,min=0,max=150"`
 }
 
-// NewUserHandler создаёт новый обработчик
-func NewUserHandler(repo *repository.JSONUserRepository) *UserHandler {
+// NewUserHandler создаёт новый обработчик. deletions удаляет данные
+// пользователя в остальных сервисах.
+func NewUserHandler(repo repository.UserRepository, deletions *saga.Coordinator) *UserHandler {
 	return &UserHandler{
-		repo: repo,
+		repo:      repo,
+		deletions: deletions,
 	}
 }
 
@@ -43,43 +55,59 @@ func NewUserHandler(repo *repository.JSONUserRepository) *UserHandler {
 // @Accept json
 // @Produce json
 // @Param user body CreateUserRequest true "Данные пользователя"
+// @Param Idempotency-Key header string false "Ключ повтора: тот же ключ и тело вернут сохранённый ответ"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {string} string "Invalid email"
+// @Failure 400 {object} problem.Problem "Invalid email"
 // @Router /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	user, err := h.repo.CreateUser(req.Email, req.Name, req.Age)
+	user, err := h.repo.CreateUser(r.Context(), req.Email, req.Name, *req.Age)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
 
+	httputil.SetETag(w, user.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
 }
 
-// GetAllUsers получает всех пользователей
-// @Summary Получить всех пользователей
-// @Description Получить список всех пользователей
+// GetAllUsers получает страницу пользователей
+// @Summary Получить пользователей
+// @Description Получить страницу пользователей с сортировкой, фильтрами по датам и курсором
 // @Tags users
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param limit query int false "Размер страницы (1-100, по умолчанию 20)"
+// @Param cursor query string false "nextCursor предыдущей страницы"
+// @Param sort query string false "Поле сортировки: id, createdAt, updatedAt"
+// @Param order query string false "Порядок: asc, desc"
+// @Param createdFrom query string false "Создан не раньше (RFC 3339)"
+// @Param createdTo query string false "Создан раньше (RFC 3339)"
+// @Param updatedFrom query string false "Изменён не раньше (RFC 3339)"
+// @Param updatedTo query string false "Изменён раньше (RFC 3339)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} problem.Problem "Invalid list query"
 // @Router /users [get]
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.repo.GetAllUsers()
+	q, err := pagination.ParseListQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, err)
+		return
+	}
+
+	page, err := h.repo.ListUsers(r.Context(), q)
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(page)
 }
 
 // GetUserByID получает пользователя по ID
@@ -88,23 +116,30 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 // @Tags users
 // @Produce json
 // @Param id path int64 true "ID пользователя"
+// @Param If-None-Match header string false "ETag известной клиенту версии"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "User not found"
+// @Success 304 "Not modified"
+// @Failure 404 {object} problem.Problem "User not found"
 // @Router /users/{id} [get]
 func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
-	user, err := h.repo.GetUserByID(id)
+	user, err := h.repo.GetUserByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, err)
+		return
+	}
+
+	if httputil.NotModified(w, r, user.Version) {
 		return
 	}
 
+	httputil.SetETag(w, user.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
@@ -117,56 +152,149 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param id path int64 true "ID пользователя"
 // @Param user body UpdateUserRequest true "Новые данные"
+// @Param If-Match header string false "ETag версии, которую клиент изменяет"
 // @Success 200 {object} map[string]interface{}
-// @Failure 404 {string} string "User not found"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 412 {object} problem.Problem "Precondition failed"
 // @Router /users/{id} [put]
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
 	var req UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !validation.Decode(w, r, &req) {
 		return
 	}
 
-	user, err := h.repo.UpdateUser(id, req.Email, req.Name, req.Age)
+	version, err := httputil.ExpectedVersion(r, h.userVersion(r, id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, err)
 		return
 	}
 
+	user, err := h.repo.UpdateUser(r.Context(), id, req.Email, req.Name, *req.Age, version)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	httputil.SetETag(w, user.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
 
 // DeleteUser удаляет пользователя
 // @Summary Удалить пользователя
-// @Description Удалить пользователя (каскадно удаляет заказы, платежи, доставки)
+// @Description Удалить пользователя и запустить сагу, которая каскадно удаляет его заказы, платежи и доставки.
+// @Description Ход саги - GET /users/{id}/deletion. Повторный DELETE перезапускает сагу, исчерпавшую попытки.
 // @Tags users
+// @Produce json
 // @Param id path int64 true "ID пользователя"
-// @Success 204
-// @Failure 404 {string} string "User not found"
+// @Param If-Match header string false "ETag версии, которую клиент удаляет"
+// @Success 202 {object} models.UserDeletion
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 412 {object} problem.Problem "Precondition failed"
 // @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	version, err := httputil.ExpectedVersion(r, h.userVersion(r, id))
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		problem.Write(w, r, err)
+		return
+	}
+
+	// Пользователя может уже не быть, если его удалила прошлая попытка, -
+	// тогда её незавершённая сага перезапускается
+	exists, err := h.repo.UserExists(r.Context(), id)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+	if !exists && !h.deletionUnfinished(r, id) {
+		problem.Write(w, r, repository.ErrUserNotFound)
+		return
+	}
+
+	// Сага сохраняется до удаления пользователя: если сервис упадёт между
+	// ними, после перезапуска она удалит и пользователя
+	deletion, created, err := h.deletions.Begin(r.Context(), id)
+	if err != nil {
+		problem.Write(w, r, err)
+		return
+	}
+
+	err = h.repo.DeleteUser(r.Context(), id, version)
+	if err != nil && (created || errors.Is(err, repository.ErrVersionMismatch)) {
+		if created {
+			h.deletions.Abandon(r.Context(), id)
+		}
+		problem.Write(w, r, err)
+		return
+	}
+
+	h.deletions.Start(deletion)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%d/deletion", id))
+	writeDeletion(w, deletion, http.StatusAccepted)
+}
+
+// deletionUnfinished - у пользователя есть сага удаления, которая не дошла до конца
+func (h *UserHandler) deletionUnfinished(r *http.Request, id int64) bool {
+	deletion, err := h.deletions.Status(r.Context(), id)
+	return err == nil && deletion.Status != models.DeletionStatusCompleted
+}
+
+// GetUserDeletion отдаёт ход саги удаления пользователя
+// @Summary Ход удаления пользователя
+// @Description Статус саги удаления и каждого её шага: попытки, последняя ошибка, время следующей попытки
+// @Tags users
+// @Produce json
+// @Param id path int64 true "ID пользователя"
+// @Success 200 {object} models.UserDeletion
+// @Failure 404 {object} problem.Problem "User deletion not found"
+// @Router /users/{id}/deletion [get]
+func (h *UserHandler) GetUserDeletion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		problem.Write(w, r, problem.ErrInvalidID)
 		return
 	}
 
-	err = h.repo.DeleteUser(id)
+	deletion, err := h.deletions.Status(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeDeletion(w, deletion, http.StatusOK)
+}
+
+func writeDeletion(w http.ResponseWriter, deletion *models.UserDeletion, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(deletion)
+}
+
+// userVersion читает текущую версию пользователя для проверки If-Match
+func (h *UserHandler) userVersion(r *http.Request, id int64) func() (int64, error) {
+	return func() (int64, error) {
+		user, err := h.repo.GetUserByID(r.Context(), id)
+		if err != nil {
+			return 0, err
+		}
+		return user.Version, nil
+	}
 }
 
 // UserExists проверяет существование пользователя
@@ -180,11 +308,16 @@ func (h *UserHandler) UserExists(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, problem.ErrInvalidID)
+		return
+	}
+
+	exists, err := h.repo.UserExists(r.Context(), id)
+	if err != nil {
+		problem.Write(w, r, err)
 		return
 	}
 
-	exists := h.repo.UserExists(id)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"exists": exists})
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"platform/problem"
+
+	"users-service/internal/models"
+)
+
+const testUser = `{"email": "a@example.com", "name": "A", "age": 30}`
+
+func TestGetUserNotModified(t *testing.T) {
+	router := newTestRouter()
+	created := serve(router, http.MethodPost, "/users", testUser, nil)
+	etag := created.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag %q, want \"1\"", etag)
+	}
+
+	w := serve(router, http.MethodGet, "/users/1", "", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("status %d, body %q, want 304 without body", w.Code, w.Body)
+	}
+
+	w = serve(router, http.MethodGet, "/users/1", "", map[string]string{"If-None-Match": `"7"`})
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale If-None-Match: status %d, want 200", w.Code)
+	}
+	var user models.User
+	decode(t, w, &user)
+	if user.Version != 1 {
+		t.Fatalf("version %d, want 1", user.Version)
+	}
+}
+
+func TestUpdateUserPreconditionFailed(t *testing.T) {
+	router := newTestRouter()
+	serve(router, http.MethodPost, "/users", testUser, nil)
+
+	body := `{"email": "a@example.com", "name": "B", "age": 31}`
+	w := serve(router, http.MethodPut, "/users/1", body, map[string]string{"If-Match": `"1"`})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("status %d, ETag %q, want 200 and \"2\"", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Второй клиент читал версию 1
+	w = serve(router, http.MethodPut, "/users/1", body, map[string]string{"If-Match": `"1"`})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412", w.Code)
+	}
+	var p problem.Problem
+	decode(t, w, &p)
+	if p.Status != http.StatusPreconditionFailed || p.Code == "" {
+		t.Fatalf("problem %+v", p)
+	}
+}
+
+func TestCreateUserRequiresAge(t *testing.T) {
+	w := serve(newTestRouter(), http.MethodPost, "/users", `{"email": "a@example.com", "name": "A"}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+
+	var p problem.Problem
+	decode(t, w, &p)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "age" || p.Errors[0].Rule != "required" {
+		t.Fatalf("errors %+v, want age required", p.Errors)
+	}
+}
+
+func TestCreateUserAcceptsAgeZero(t *testing.T) {
+	router := newTestRouter()
+	body := `{"email": "baby@example.com", "name": "Baby", "age": 0}`
+
+	w := serve(router, http.MethodPost, "/users", body, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", w.Code, w.Body)
+	}
+	var user models.User
+	decode(t, w, &user)
+	if user.Age != 0 {
+		t.Fatalf("age %d, want 0", user.Age)
+	}
+
+	w = serve(router, http.MethodPut, "/users/1", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status %d, body %s", w.Code, w.Body)
+	}
+}
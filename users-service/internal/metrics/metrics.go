@@ -0,0 +1,33 @@
+// Package metrics - доменные метрики users-service. Метрики HTTP, клиентов
+// и хранилища собирает platform/metrics, отдаются все вместе на /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deletions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_deletions_total",
+		Help: "Finished user deletion sagas by status (completed, failed).",
+	}, []string{"status"})
+	stepAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_deletion_step_attempts_total",
+		Help: "User deletion step attempts by step and outcome (succeeded, failed).",
+	}, []string{"step", "outcome"})
+)
+
+// Deletion учитывает сагу удаления, завершившуюся со статусом status
+func Deletion(status string) {
+	deletions.WithLabelValues(status).Inc()
+}
+
+// StepAttempt учитывает попытку шага step; err != nil - попытка не удалась
+func StepAttempt(step string, err error) {
+	outcome := "succeeded"
+	if err != nil {
+		outcome = "failed"
+	}
+	stepAttempts.WithLabelValues(step, outcome).Inc()
+}
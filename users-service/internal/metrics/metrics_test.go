@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCounters(t *testing.T) {
+	StepAttempt("orders", errors.New("unavailable"))
+	StepAttempt("orders", nil)
+	Deletion("completed")
+
+	if got := testutil.ToFloat64(stepAttempts.WithLabelValues("orders", "failed")); got != 1 {
+		t.Errorf("failed attempts %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(stepAttempts.WithLabelValues("orders", "succeeded")); got != 1 {
+		t.Errorf("succeeded attempts %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(deletions.WithLabelValues("completed")); got != 1 {
+		t.Errorf("completed deletions %v, want 1", got)
+	}
+}
@@ -8,6 +8,13 @@ type User struct {
 	Email     string    `json:"email"`
 	Name      string    `json:"name"`
 	Age       int       `json:"age"`
+	Version   int64     `json:"version"` // растёт при каждом изменении, отдаётся как ETag
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// Clone возвращает независимую копию пользователя
+func (u *User) Clone() *User {
+	clone := *u
+	return &clone
+}
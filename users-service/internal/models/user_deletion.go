@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Статусы саги удаления пользователя
+const (
+	DeletionStatusRunning   = "running"
+	DeletionStatusCompleted = "completed"
+	DeletionStatusFailed    = "failed" // попытки исчерпаны, повторный DELETE запускает сагу заново
+)
+
+// Статусы шага саги
+const (
+	DeletionStepPending  = "pending"
+	DeletionStepRetrying = "retrying"
+	DeletionStepDone     = "done"
+	DeletionStepFailed   = "failed"
+)
+
+// DeletionStep - один шаг саги: удаление данных пользователя в одном сервисе
+type DeletionStep struct {
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	CompletedAt   *time.Time `json:"completedAt,omitempty"`
+}
+
+// UserDeletion - ход саги удаления пользователя. ID совпадает с ID
+// пользователя: у пользователя не больше одной саги.
+type UserDeletion struct {
+	ID          int64          `json:"id"`
+	Status      string         `json:"status"`
+	Steps       []DeletionStep `json:"steps"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	CompletedAt *time.Time     `json:"completedAt,omitempty"`
+}
+
+// NewUserDeletion создаёт сагу с шагами steps в исходном состоянии
+func NewUserDeletion(userID int64, steps []string, now time.Time) *UserDeletion {
+	deletion := &UserDeletion{
+		ID:        userID,
+		Status:    DeletionStatusRunning,
+		Steps:     make([]DeletionStep, len(steps)),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for i, name := range steps {
+		deletion.Steps[i] = DeletionStep{Name: name, Status: DeletionStepPending}
+	}
+	return deletion
+}
+
+// Finished - сага больше не выполняется: завершена или исчерпала попытки
+func (d *UserDeletion) Finished() bool {
+	return d.Status == DeletionStatusCompleted || d.Status == DeletionStatusFailed
+}
+
+// Clone возвращает независимую копию саги
+func (d *UserDeletion) Clone() *UserDeletion {
+	clone := *d
+	clone.Steps = append([]DeletionStep(nil), d.Steps...)
+	return &clone
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"platform/pagination"
+)
+
+// Общий набор проверок, который обязан проходить каждый бэкенд UserRepository
+
+func TestJSONUserRepositoryConformance(t *testing.T) {
+	runUserRepositoryConformance(t, func(t *testing.T) UserRepository {
+		repo, err := NewJSONUserRepository(filepath.Join(t.TempDir(), "users.json"))
+		if err != nil {
+			t.Fatalf("NewJSONUserRepository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestMemoryUserRepositoryConformance(t *testing.T) {
+	runUserRepositoryConformance(t, func(t *testing.T) UserRepository {
+		return NewMemoryUserRepository()
+	})
+}
+
+func TestTracedUserRepositoryConformance(t *testing.T) {
+	runUserRepositoryConformance(t, func(t *testing.T) UserRepository {
+		return NewTracedUserRepository(NewMemoryUserRepository())
+	})
+}
+
+func TestSQLiteUserRepositoryConformance(t *testing.T) {
+	runUserRepositoryConformance(t, func(t *testing.T) UserRepository {
+		repo, err := NewSQLiteUserRepository(filepath.Join(t.TempDir(), "users.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteUserRepository: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestJSONUserRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	repo, err := NewJSONUserRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONUserRepository: %v", err)
+	}
+	first, _ := repo.CreateUser(ctx, "a@example.com", "A", 20)
+	second, _ := repo.CreateUser(ctx, "b@example.com", "B", 30)
+	repo.UpdateUser(ctx, first.ID, "a@example.com", "A2", 21, 0)
+	repo.DeleteUser(ctx, second.ID, 0)
+
+	reopened, err := NewJSONUserRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := reopened.GetUserByID(ctx, first.ID)
+	if err != nil || got.Name != "A2" || got.Age != 21 || got.Version != 2 {
+		t.Fatalf("got %+v, %v after reopen", got, err)
+	}
+	if exists, _ := reopened.UserExists(ctx, second.ID); exists {
+		t.Fatalf("deleted user is back after reopen")
+	}
+}
+
+func TestJSONUserRepositoryKeepsStateOnStorageError(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewJSONUserRepository(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("NewJSONUserRepository: %v", err)
+	}
+	user, _ := repo.CreateUser(ctx, "a@example.com", "A", 20)
+	// Закрытое хранилище отклоняет запись в журнал
+	repo.storage.Close()
+
+	if _, err := repo.CreateUser(ctx, "b@example.com", "B", 30); err == nil {
+		t.Fatal("CreateUser succeeded with failing storage")
+	}
+	if users, _ := repo.GetAllUsers(ctx); len(users) != 1 {
+		t.Fatalf("%d users in memory, want only the persisted one", len(users))
+	}
+	if _, err := repo.UpdateUser(ctx, user.ID, "a@example.com", "A2", 21, 0); err == nil {
+		t.Fatal("UpdateUser succeeded with failing storage")
+	}
+	if err := repo.DeleteUser(ctx, user.ID, 0); err == nil {
+		t.Fatal("DeleteUser succeeded with failing storage")
+	}
+
+	got, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil || got.Name != "A" || got.Version != 1 {
+		t.Fatalf("got %+v, %v; want the user as persisted", got, err)
+	}
+}
+
+func runUserRepositoryConformance(t *testing.T, newRepo func(t *testing.T) UserRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, err := repo.CreateUser(ctx, "john@example.com", "John", 30)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if user.ID == 0 || user.Email != "john@example.com" || user.Name != "John" || user.Age != 30 {
+			t.Fatalf("unexpected user %+v", user)
+		}
+
+		got, err := repo.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got.ID != user.ID || got.CreatedAt.IsZero() {
+			t.Fatalf("unexpected user %+v", got)
+		}
+	})
+
+	t.Run("InvalidEmail", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, email := range []string{"", "john", "@example.com", "john@", "a@b@c"} {
+			if _, err := repo.CreateUser(ctx, email, "John", 30); err == nil {
+				t.Fatalf("email %q accepted", email)
+			}
+		}
+	})
+
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.CreateUser(ctx, "john@example.com", "John", 30); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if _, err := repo.CreateUser(ctx, "john@example.com", "Other", 40); err == nil {
+			t.Fatal("duplicate email accepted")
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.GetUserByID(ctx, 42); err == nil {
+			t.Fatal("expected error for missing user")
+		}
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.CreateUser(ctx, "a@example.com", "A", 1)
+		repo.CreateUser(ctx, "b@example.com", "B", 2)
+
+		users, err := repo.GetAllUsers(ctx)
+		if err != nil {
+			t.Fatalf("GetAllUsers: %v", err)
+		}
+		if len(users) != 2 {
+			t.Fatalf("got %d users, want 2", len(users))
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+			if _, err := repo.CreateUser(ctx, email, "U", 1); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+		}
+
+		q := pagination.ListQuery{Limit: 2, Sort: pagination.SortByID, Desc: true}
+		var got []int64
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatalf("too many pages: %v", got)
+			}
+			page, err := repo.ListUsers(ctx, q)
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if page.Total != 5 {
+				t.Fatalf("total %d, want 5", page.Total)
+			}
+			for _, user := range page.Items {
+				got = append(got, user.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			last := page.Items[len(page.Items)-1]
+			q.After = &pagination.Cursor{Sort: q.Sort, Desc: q.Desc, ID: last.ID}
+		}
+		want := []int64{5, 4, 3, 2, 1}
+		if len(got) != len(want) {
+			t.Fatalf("pages %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("pages %v, want %v", got, want)
+			}
+		}
+
+		future := time.Now().Add(time.Hour)
+		page, err := repo.ListUsers(ctx, pagination.ListQuery{Limit: 10, Sort: pagination.SortByCreatedAt, CreatedFrom: future})
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		if page.Total != 0 || len(page.Items) != 0 || page.NextCursor != "" {
+			t.Fatalf("filtered page %+v, want empty", page)
+		}
+		page, _ = repo.ListUsers(ctx, pagination.ListQuery{Limit: 10, Sort: pagination.SortByCreatedAt, CreatedTo: future})
+		if page.Total != 5 || len(page.Items) != 5 || page.Items[0].ID != 1 {
+			t.Fatalf("page by createdAt %+v", page)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, _ := repo.CreateUser(ctx, "a@example.com", "A", 1)
+		updated, err := repo.UpdateUser(ctx, user.ID, "new@example.com", "B", 2, 0)
+		if err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+		if updated.Email != "new@example.com" || updated.Name != "B" || updated.Age != 2 {
+			t.Fatalf("unexpected user %+v", updated)
+		}
+
+		if _, err := repo.UpdateUser(ctx, user.ID, "broken", "B", 2, 0); err == nil {
+			t.Fatal("invalid email accepted on update")
+		}
+		if _, err := repo.UpdateUser(ctx, 42, "x@example.com", "X", 1, 0); err == nil {
+			t.Fatal("expected error for missing user")
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, _ := repo.CreateUser(ctx, "v@example.com", "V", 1)
+		if user.Version != 1 {
+			t.Fatalf("new user version %d, want 1", user.Version)
+		}
+
+		updated, err := repo.UpdateUser(ctx, user.ID, "v@example.com", "V2", 2, 1)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("conditional update = %+v, %v; want version 2", updated, err)
+		}
+
+		// Устаревшая версия не перезаписывает чужое изменение
+		if _, err := repo.UpdateUser(ctx, user.ID, "v@example.com", "V3", 3, 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale update error %v, want ErrVersionMismatch", err)
+		}
+		if err := repo.DeleteUser(ctx, user.ID, 1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("stale delete error %v, want ErrVersionMismatch", err)
+		}
+		if got, _ := repo.GetUserByID(ctx, user.ID); got.Name != "V2" || got.Version != 2 {
+			t.Fatalf("user changed by stale write: %+v", got)
+		}
+
+		if err := repo.DeleteUser(ctx, user.ID, 2); err != nil {
+			t.Fatalf("conditional delete: %v", err)
+		}
+	})
+
+	t.Run("ConcurrentConditionalUpdates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, _ := repo.CreateUser(ctx, "c@example.com", "C", 1)
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.UpdateUser(ctx, user.ID, "c@example.com", "C2", 2, user.Version)
+				if err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				} else if !errors.Is(err, ErrVersionMismatch) {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != 1 {
+			t.Fatalf("%d updates of the same version succeeded, want 1", succeeded)
+		}
+	})
+
+	t.Run("DeleteAndExists", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user, _ := repo.CreateUser(ctx, "a@example.com", "A", 1)
+		if exists, err := repo.UserExists(ctx, user.ID); err != nil || !exists {
+			t.Fatalf("UserExists = %v, %v", exists, err)
+		}
+
+		if err := repo.DeleteUser(ctx, user.ID, 0); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+		if exists, err := repo.UserExists(ctx, user.ID); err != nil || exists {
+			t.Fatalf("UserExists after delete = %v, %v", exists, err)
+		}
+		if err := repo.DeleteUser(ctx, user.ID, 0); err == nil {
+			t.Fatal("expected error for second delete")
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"platform/storage"
+
+	"users-service/internal/models"
+)
+
+// JSONDeletionRepository - саги удаления в JSON-хранилище.
+// Блокировки устроены так же, как в JSONUserRepository.
+type JSONDeletionRepository struct {
+	mu        sync.RWMutex
+	persistMu sync.Mutex
+	storage   *storage.FileStorage
+	deletions map[int64]*models.UserDeletion
+}
+
+// NewJSONDeletionRepository открывает хранилище саг
+func NewJSONDeletionRepository(filePath string) (*JSONDeletionRepository, error) {
+	repo := &JSONDeletionRepository{
+		storage:   storage.NewFileStorage(filePath),
+		deletions: make(map[int64]*models.UserDeletion),
+	}
+
+	if err := repo.storage.EnsureFile(); err != nil {
+		return nil, err
+	}
+
+	var deletions []*models.UserDeletion
+	if err := repo.storage.LoadJSON(&deletions); err != nil {
+		return nil, err
+	}
+	for _, deletion := range deletions {
+		repo.deletions[deletion.ID] = deletion
+	}
+
+	return repo, nil
+}
+
+// SaveToFile сохраняет полный снимок саг в JSON
+func (r *JSONDeletionRepository) SaveToFile() error {
+	if r.storage == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	deletions := make([]*models.UserDeletion, 0, len(r.deletions))
+	for _, deletion := range r.deletions {
+		deletions = append(deletions, deletion.Clone())
+	}
+
+	r.persistMu.Lock()
+	r.mu.RUnlock()
+	defer r.persistMu.Unlock()
+
+	return r.storage.SaveJSON(deletions)
+}
+
+// Close дожидается записи в журнал и сворачивает его в снимок; изменения
+// после этого не сохраняются (storage.ErrClosed)
+func (r *JSONDeletionRepository) Close() error {
+	if r.storage == nil {
+		return nil
+	}
+
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	return r.storage.Close()
+}
+
+// SaveDeletion создаёт или заменяет сагу
+func (r *JSONDeletionRepository) SaveDeletion(ctx context.Context, deletion *models.UserDeletion) error {
+	stored := deletion.Clone()
+
+	r.mu.Lock()
+	r.deletions[stored.ID] = stored
+
+	r.persistMu.Lock()
+	r.mu.Unlock()
+	defer r.persistMu.Unlock()
+
+	if r.storage == nil {
+		return nil
+	}
+	return r.storage.Put(stored.ID, stored)
+}
+
+// GetDeletion получает сагу удаления пользователя
+func (r *JSONDeletionRepository) GetDeletion(ctx context.Context, userID int64) (*models.UserDeletion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deletion, exists := r.deletions[userID]
+	if !exists {
+		return nil, ErrDeletionNotFound
+	}
+
+	return deletion.Clone(), nil
+}
+
+// GetUnfinishedDeletions получает саги, которые ещё выполняются, в порядке ID
+func (r *JSONDeletionRepository) GetUnfinishedDeletions(ctx context.Context) ([]*models.UserDeletion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var unfinished []*models.UserDeletion
+	for _, deletion := range r.deletions {
+		if !deletion.Finished() {
+			unfinished = append(unfinished, deletion.Clone())
+		}
+	}
+	sort.Slice(unfinished, func(i, j int) bool { return unfinished[i].ID < unfinished[j].ID })
+
+	return unfinished, nil
+}
+
+// DeleteDeletion удаляет сагу
+func (r *JSONDeletionRepository) DeleteDeletion(ctx context.Context, userID int64) error {
+	r.mu.Lock()
+	if _, exists := r.deletions[userID]; !exists {
+		r.mu.Unlock()
+		return nil
+	}
+	delete(r.deletions, userID)
+
+	r.persistMu.Lock()
+	r.mu.Unlock()
+	defer r.persistMu.Unlock()
+
+	if r.storage == nil {
+		return nil
+	}
+	return r.storage.Delete(userID)
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"users-service/internal/models"
+)
+
+// Общий набор проверок для бэкендов DeletionRepository
+
+func TestJSONDeletionRepositoryConformance(t *testing.T) {
+	runDeletionRepositoryConformance(t, func(t *testing.T) DeletionRepository {
+		repo, err := NewJSONDeletionRepository(filepath.Join(t.TempDir(), "user_deletions.json"))
+		if err != nil {
+			t.Fatalf("NewJSONDeletionRepository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestMemoryDeletionRepositoryConformance(t *testing.T) {
+	runDeletionRepositoryConformance(t, func(t *testing.T) DeletionRepository {
+		return NewMemoryDeletionRepository()
+	})
+}
+
+func TestTracedDeletionRepositoryConformance(t *testing.T) {
+	runDeletionRepositoryConformance(t, func(t *testing.T) DeletionRepository {
+		return NewTracedDeletionRepository(NewMemoryDeletionRepository())
+	})
+}
+
+func TestSQLiteDeletionRepositoryConformance(t *testing.T) {
+	runDeletionRepositoryConformance(t, func(t *testing.T) DeletionRepository {
+		repo, err := NewSQLiteUserRepository(filepath.Join(t.TempDir(), "users.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteUserRepository: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo.Deletions()
+	})
+}
+
+func TestJSONDeletionRepositoryPersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "user_deletions.json")
+
+	repo, err := NewJSONDeletionRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONDeletionRepository: %v", err)
+	}
+	deletion := models.NewUserDeletion(1, []string{"user", "orders"}, time.Now())
+	repo.SaveDeletion(ctx, deletion)
+	deletion.Steps[0].Status = models.DeletionStepDone
+	repo.SaveDeletion(ctx, deletion)
+	repo.SaveDeletion(ctx, models.NewUserDeletion(2, []string{"user"}, time.Now()))
+	repo.DeleteDeletion(ctx, 2)
+
+	reopened, err := NewJSONDeletionRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := reopened.GetDeletion(ctx, 1)
+	if err != nil || got.Steps[0].Status != models.DeletionStepDone || got.Steps[1].Status != models.DeletionStepPending {
+		t.Fatalf("got %+v, %v after reopen", got, err)
+	}
+	if _, err := reopened.GetDeletion(ctx, 2); !errors.Is(err, ErrDeletionNotFound) {
+		t.Fatalf("deleted saga is back after reopen: %v", err)
+	}
+}
+
+func runDeletionRepositoryConformance(t *testing.T, newRepo func(t *testing.T) DeletionRepository) {
+	ctx := context.Background()
+	now := time.Date(2025, 12, 5, 6, 30, 0, 0, time.UTC)
+
+	t.Run("SaveAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+
+		deletion := models.NewUserDeletion(7, []string{"user", "orders"}, now)
+		if err := repo.SaveDeletion(ctx, deletion); err != nil {
+			t.Fatalf("SaveDeletion: %v", err)
+		}
+
+		got, err := repo.GetDeletion(ctx, 7)
+		if err != nil {
+			t.Fatalf("GetDeletion: %v", err)
+		}
+		if got.ID != 7 || got.Status != models.DeletionStatusRunning || len(got.Steps) != 2 ||
+			got.Steps[1].Name != "orders" || !got.CreatedAt.Equal(now) || got.CompletedAt != nil {
+			t.Fatalf("unexpected deletion %+v", got)
+		}
+
+		if _, err := repo.GetDeletion(ctx, 42); !errors.Is(err, ErrDeletionNotFound) {
+			t.Fatalf("missing deletion: error %v, want ErrDeletionNotFound", err)
+		}
+	})
+
+	t.Run("SaveReplaces", func(t *testing.T) {
+		repo := newRepo(t)
+
+		deletion := models.NewUserDeletion(1, []string{"user", "orders"}, now)
+		repo.SaveDeletion(ctx, deletion)
+
+		next := now.Add(time.Minute)
+		deletion.Steps[0] = models.DeletionStep{Name: "user", Status: models.DeletionStepDone, Attempts: 1, CompletedAt: &now}
+		deletion.Steps[1] = models.DeletionStep{Name: "orders", Status: models.DeletionStepRetrying, Attempts: 2,
+			LastError: "connection refused", NextAttemptAt: &next}
+		deletion.UpdatedAt = next
+		if err := repo.SaveDeletion(ctx, deletion); err != nil {
+			t.Fatalf("SaveDeletion: %v", err)
+		}
+
+		got, _ := repo.GetDeletion(ctx, 1)
+		orders := got.Steps[1]
+		if got.Steps[0].Status != models.DeletionStepDone || got.Steps[0].CompletedAt == nil ||
+			orders.Attempts != 2 || orders.LastError != "connection refused" || orders.NextAttemptAt == nil ||
+			!orders.NextAttemptAt.Equal(next) || !got.UpdatedAt.Equal(next) {
+			t.Fatalf("saga not replaced: %+v", got)
+		}
+
+		deletion.Status = models.DeletionStatusCompleted
+		deletion.CompletedAt = &next
+		repo.SaveDeletion(ctx, deletion)
+		if got, _ := repo.GetDeletion(ctx, 1); got.CompletedAt == nil || !got.CompletedAt.Equal(next) {
+			t.Fatalf("completedAt not stored: %+v", got)
+		}
+	})
+
+	t.Run("Unfinished", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for id, status := range map[int64]string{
+			1: models.DeletionStatusRunning,
+			2: models.DeletionStatusCompleted,
+			3: models.DeletionStatusFailed,
+			4: models.DeletionStatusRunning,
+		} {
+			deletion := models.NewUserDeletion(id, []string{"user"}, now)
+			deletion.Status = status
+			repo.SaveDeletion(ctx, deletion)
+		}
+
+		unfinished, err := repo.GetUnfinishedDeletions(ctx)
+		if err != nil {
+			t.Fatalf("GetUnfinishedDeletions: %v", err)
+		}
+		if len(unfinished) != 2 || unfinished[0].ID != 1 || unfinished[1].ID != 4 {
+			t.Fatalf("unfinished %+v, want sagas 1 and 4", unfinished)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+
+		repo.SaveDeletion(ctx, models.NewUserDeletion(1, []string{"user"}, now))
+		if err := repo.DeleteDeletion(ctx, 1); err != nil {
+			t.Fatalf("DeleteDeletion: %v", err)
+		}
+		if _, err := repo.GetDeletion(ctx, 1); !errors.Is(err, ErrDeletionNotFound) {
+			t.Fatalf("deleted saga still there: %v", err)
+		}
+		if err := repo.DeleteDeletion(ctx, 1); err != nil {
+			t.Fatalf("DeleteDeletion of missing saga: %v", err)
+		}
+	})
+
+	t.Run("ReturnsCopies", func(t *testing.T) {
+		repo := newRepo(t)
+
+		deletion := models.NewUserDeletion(1, []string{"user"}, now)
+		repo.SaveDeletion(ctx, deletion)
+		deletion.Steps[0].Status = "hacked"
+
+		got, _ := repo.GetDeletion(ctx, 1)
+		got.Steps[0].Status = "hacked"
+		if again, _ := repo.GetDeletion(ctx, 1); again.Steps[0].Status != models.DeletionStepPending {
+			t.Fatalf("stored saga changed through a pointer: %+v", again)
+		}
+	})
+}
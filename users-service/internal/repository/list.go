@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"platform/pagination"
+
+	"users-service/internal/models"
+)
+
+func userListKey(user *models.User) pagination.ListKey {
+	return pagination.ListKey{ID: user.ID, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt}
+}
@@ -0,0 +1,32 @@
+package repository
+
+import "users-service/internal/models"
+
+// MemoryUserRepository - репозиторий без записи на диск, для тестов и локального запуска
+type MemoryUserRepository struct {
+	JSONUserRepository
+}
+
+// NewMemoryUserRepository создаёт пустой репозиторий в памяти
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		JSONUserRepository: JSONUserRepository{
+			users:  make(map[int64]*models.User),
+			nextID: 1,
+		},
+	}
+}
+
+// MemoryDeletionRepository - саги удаления без записи на диск
+type MemoryDeletionRepository struct {
+	JSONDeletionRepository
+}
+
+// NewMemoryDeletionRepository создаёт пустое хранилище саг в памяти
+func NewMemoryDeletionRepository() *MemoryDeletionRepository {
+	return &MemoryDeletionRepository{
+		JSONDeletionRepository: JSONDeletionRepository{
+			deletions: make(map[int64]*models.UserDeletion),
+		},
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE json_imports;
+DROP INDEX idx_users_email;
+DROP TABLE users;
@@ -0,0 +1,17 @@
+CREATE TABLE users (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    email      TEXT    NOT NULL,
+    name       TEXT    NOT NULL,
+    age        INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT    NOT NULL,
+    updated_at TEXT    NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+-- Какие JSON-файлы уже перенесены импортёром
+CREATE TABLE json_imports (
+    source      TEXT PRIMARY KEY,
+    records     INTEGER NOT NULL,
+    imported_at TEXT    NOT NULL
+);
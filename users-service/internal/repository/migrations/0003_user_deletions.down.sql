@@ -0,0 +1,2 @@
+DROP INDEX idx_user_deletions_status;
+DROP TABLE user_deletions;
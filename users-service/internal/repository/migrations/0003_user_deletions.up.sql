@@ -0,0 +1,11 @@
+-- Ход саг удаления пользователей: шаги хранятся JSON-массивом
+CREATE TABLE user_deletions (
+    user_id      INTEGER PRIMARY KEY,
+    status       TEXT    NOT NULL,
+    steps        TEXT    NOT NULL,
+    created_at   TEXT    NOT NULL,
+    updated_at   TEXT    NOT NULL,
+    completed_at TEXT
+);
+
+CREATE INDEX idx_user_deletions_status ON user_deletions (status);
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+
+	"platform/pagination"
+	"platform/problem"
+	"platform/storage"
+
+	"users-service/internal/models"
+)
+
+var (
+	// ErrVersionMismatch - запись изменилась с тех пор, как клиент её прочитал
+	ErrVersionMismatch = storage.ErrVersionMismatch
+	// ErrUserNotFound - пользователя нет
+	ErrUserNotFound = problem.New(problem.NotFound, "user_not_found", "user not found")
+	// ErrEmailTaken - email занят другим пользователем
+	ErrEmailTaken = problem.New(problem.Conflict, "email_taken", "email already exists")
+	// ErrInvalidEmail - email не прошёл проверку
+	ErrInvalidEmail = problem.New(problem.Invalid, "invalid_email", "invalid email")
+	// ErrDeletionNotFound - для пользователя не запускалась сага удаления
+	ErrDeletionNotFound = problem.New(problem.NotFound, "deletion_not_found", "user deletion not found")
+)
+
+// UserRepository - хранилище пользователей, от которого зависят обработчики.
+// Реализации: JSONUserRepository (файл), MemoryUserRepository (память),
+// SQLiteUserRepository (встроенная база).
+type UserRepository interface {
+	CreateUser(ctx context.Context, email, name string, age int) (*models.User, error)
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	GetAllUsers(ctx context.Context) ([]*models.User, error)
+	// ListUsers возвращает страницу пользователей по фильтрам дат, сортировке и курсору
+	ListUsers(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.User], error)
+	// expectedVersion != 0 - изменить, только если версия пользователя совпадает,
+	// иначе ErrVersionMismatch
+	UpdateUser(ctx context.Context, id int64, email, name string, age int, expectedVersion int64) (*models.User, error)
+	DeleteUser(ctx context.Context, id int64, expectedVersion int64) error
+	UserExists(ctx context.Context, id int64) (bool, error)
+	// Close дописывает незавершённые изменения и освобождает хранилище
+	Close() error
+}
+
+var (
+	_ UserRepository = (*JSONUserRepository)(nil)
+	_ UserRepository = (*MemoryUserRepository)(nil)
+	_ UserRepository = (*SQLiteUserRepository)(nil)
+)
+
+// DeletionRepository - хранилище хода саг удаления пользователей, по которому
+// сага продолжается после перезапуска. Реализации: JSONDeletionRepository,
+// MemoryDeletionRepository, SQLiteDeletionRepository.
+type DeletionRepository interface {
+	// SaveDeletion создаёт или целиком заменяет сагу с тем же ID
+	SaveDeletion(ctx context.Context, deletion *models.UserDeletion) error
+	GetDeletion(ctx context.Context, userID int64) (*models.UserDeletion, error)
+	GetUnfinishedDeletions(ctx context.Context) ([]*models.UserDeletion, error)
+	DeleteDeletion(ctx context.Context, userID int64) error
+	// Close дописывает незавершённые изменения и освобождает хранилище
+	Close() error
+}
+
+var (
+	_ DeletionRepository = (*JSONDeletionRepository)(nil)
+	_ DeletionRepository = (*MemoryDeletionRepository)(nil)
+	_ DeletionRepository = (*SQLiteDeletionRepository)(nil)
+)
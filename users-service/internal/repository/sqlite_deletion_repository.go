@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"platform/storage"
+
+	"users-service/internal/models"
+)
+
+// SQLiteDeletionRepository - саги удаления в той же базе, что и пользователи
+type SQLiteDeletionRepository struct {
+	db *sql.DB
+}
+
+const deletionColumns = `user_id, status, steps, created_at, updated_at, completed_at`
+
+// Deletions возвращает хранилище саг удаления на соединении репозитория пользователей
+func (r *SQLiteUserRepository) Deletions() *SQLiteDeletionRepository {
+	return &SQLiteDeletionRepository{db: r.db}
+}
+
+// Close ничего не делает: соединение закрывает SQLiteUserRepository
+func (r *SQLiteDeletionRepository) Close() error {
+	return nil
+}
+
+// SaveDeletion создаёт или заменяет сагу
+func (r *SQLiteDeletionRepository) SaveDeletion(ctx context.Context, deletion *models.UserDeletion) error {
+	steps, err := json.Marshal(deletion.Steps)
+	if err != nil {
+		return err
+	}
+
+	var completedAt sql.NullString
+	if deletion.CompletedAt != nil {
+		completedAt = sql.NullString{String: storage.FormatTime(*deletion.CompletedAt), Valid: true}
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO user_deletions (`+deletionColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET status = excluded.status, steps = excluded.steps,
+		     created_at = excluded.created_at, updated_at = excluded.updated_at, completed_at = excluded.completed_at`,
+		deletion.ID, deletion.Status, string(steps),
+		storage.FormatTime(deletion.CreatedAt), storage.FormatTime(deletion.UpdatedAt), completedAt)
+	return err
+}
+
+// GetDeletion получает сагу удаления пользователя
+func (r *SQLiteDeletionRepository) GetDeletion(ctx context.Context, userID int64) (*models.UserDeletion, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+deletionColumns+` FROM user_deletions WHERE user_id = ?`, userID)
+
+	deletion, err := scanDeletion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeletionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// GetUnfinishedDeletions получает саги, которые ещё выполняются, в порядке ID
+func (r *SQLiteDeletionRepository) GetUnfinishedDeletions(ctx context.Context) ([]*models.UserDeletion, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+deletionColumns+` FROM user_deletions WHERE status = ? ORDER BY user_id`, models.DeletionStatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deletions []*models.UserDeletion
+	for rows.Next() {
+		deletion, err := scanDeletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, deletion)
+	}
+
+	return deletions, rows.Err()
+}
+
+// DeleteDeletion удаляет сагу
+func (r *SQLiteDeletionRepository) DeleteDeletion(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_deletions WHERE user_id = ?`, userID)
+	return err
+}
+
+func scanDeletion(row rowScanner) (*models.UserDeletion, error) {
+	var deletion models.UserDeletion
+	var steps, createdAt, updatedAt string
+	var completedAt sql.NullString
+
+	err := row.Scan(&deletion.ID, &deletion.Status, &steps, &createdAt, &updatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(steps), &deletion.Steps); err != nil {
+		return nil, fmt.Errorf("user deletion %d: decode steps: %w", deletion.ID, err)
+	}
+	if deletion.CreatedAt, err = storage.ParseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if deletion.UpdatedAt, err = storage.ParseTime(updatedAt); err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		t, err := storage.ParseTime(completedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		deletion.CompletedAt = &t
+	}
+
+	return &deletion, nil
+}
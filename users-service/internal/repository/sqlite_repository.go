@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"platform/pagination"
+	"platform/storage"
+
+	"users-service/internal/models"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations - версионированные миграции схемы SQLite
+func Migrations() fs.FS {
+	sub, _ := fs.Sub(migrationFiles, "migrations")
+	return sub
+}
+
+// SQLiteUserRepository - репозиторий пользователей во встроенной базе SQLite
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+const userColumns = `id, email, name, age, version, created_at, updated_at`
+
+// NewSQLiteUserRepository открывает базу и применяет недостающие миграции
+func NewSQLiteUserRepository(dsn string) (*SQLiteUserRepository, error) {
+	db, err := storage.OpenSQLite(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.Migrate(context.Background(), db, Migrations()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteUserRepository{db: db}, nil
+}
+
+// Close закрывает соединение с базой
+func (r *SQLiteUserRepository) Close() error {
+	return r.db.Close()
+}
+
+// CreateUser создаёт нового пользователя
+func (r *SQLiteUserRepository) CreateUser(ctx context.Context, email string, name string, age int) (*models.User, error) {
+	if err := validateEmail(email); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Email:     email,
+		Name:      name,
+		Age:       age,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		if err := checkEmailFree(ctx, tx, email, 0); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO users (email, name, age, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			user.Email, user.Name, user.Age, user.Version, storage.FormatTime(user.CreatedAt), storage.FormatTime(user.UpdatedAt))
+		if err != nil {
+			return err
+		}
+
+		user.ID, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserByID получает пользователя по ID
+func (r *SQLiteUserRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetAllUsers получает всех пользователей
+func (r *SQLiteUserRepository) GetAllUsers(ctx context.Context) ([]*models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// ListUsers возвращает страницу пользователей
+func (r *SQLiteUserRepository) ListUsers(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.User], error) {
+	filter, filterArgs, page, pageArgs := storage.ListSQL(q)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+filter, filterArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users`+page, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(users, q, total, userListKey), nil
+}
+
+// UpdateUser обновляет пользователя
+func (r *SQLiteUserRepository) UpdateUser(ctx context.Context, id int64, email, name string, age int, expectedVersion int64) (*models.User, error) {
+	err := storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		var current string
+		var version int64
+		err := tx.QueryRowContext(ctx, `SELECT email, version FROM users WHERE id = ?`, id).Scan(&current, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		// Если email менялся - валидируем
+		if email != current {
+			if err := validateEmail(email); err != nil {
+				return err
+			}
+			if err := checkEmailFree(ctx, tx, email, id); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE users SET email = ?, name = ?, age = ?, version = version + 1, updated_at = ? WHERE id = ?`,
+			email, name, age, storage.FormatTime(time.Now()), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetUserByID(ctx, id)
+}
+
+// DeleteUser удаляет пользователя
+func (r *SQLiteUserRepository) DeleteUser(ctx context.Context, id int64, expectedVersion int64) error {
+	return storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		var version int64
+		err := tx.QueryRowContext(ctx, `SELECT version FROM users WHERE id = ?`, id).Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && version != expectedVersion {
+			return ErrVersionMismatch
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+		return err
+	})
+}
+
+// UserExists проверяет существование пользователя
+func (r *SQLiteUserRepository) UserExists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, id).Scan(&exists)
+	return exists, err
+}
+
+// ImportJSON один раз переносит пользователей из JSON-хранилища в базу.
+// Повторный вызов для того же файла ничего не делает и возвращает 0.
+func (r *SQLiteUserRepository) ImportJSON(ctx context.Context, filePath string) (int, error) {
+	var imported bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM json_imports WHERE source = ?)`, filePath).Scan(&imported)
+	if err != nil || imported {
+		return 0, err
+	}
+
+	var users []*models.User
+	if err := storage.NewFileStorage(filePath).LoadJSON(&users); err != nil {
+		return 0, err
+	}
+
+	err = storage.InTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, user := range users {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				user.ID, user.Email, user.Name, user.Age, max(user.Version, 1), storage.FormatTime(user.CreatedAt), storage.FormatTime(user.UpdatedAt))
+			if err != nil {
+				return fmt.Errorf("import user %d: %w", user.ID, err)
+			}
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO json_imports (source, records, imported_at) VALUES (?, ?, ?)`,
+			filePath, len(users), storage.FormatTime(time.Now()))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(users), nil
+}
+
+// checkEmailFree проверяет, что email не занят другим пользователем
+func checkEmailFree(ctx context.Context, tx *sql.Tx, email string, exceptID int64) error {
+	var taken bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = ? AND id != ?)`, email, exceptID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var createdAt, updatedAt string
+
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Age, &user.Version, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.CreatedAt, err = storage.ParseTime(createdAt); err != nil {
+		return nil, err
+	}
+	if user.UpdatedAt, err = storage.ParseTime(updatedAt); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"platform/storage"
+)
+
+func TestSQLiteMigrationsUpDown(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := storage.LoadMigrations(Migrations())
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != latest {
+		t.Fatalf("schema version %d, want %d", v, latest)
+	}
+
+	// Повторный запуск ничего не делает
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+
+	if err := storage.MigrateDown(ctx, db, Migrations(), 0); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if v, _ := storage.SchemaVersion(ctx, db); v != 0 {
+		t.Fatalf("schema version %d after rollback, want 0", v)
+	}
+	var tables int
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'users'`).Scan(&tables)
+	if tables != 0 {
+		t.Fatal("users table survived rollback")
+	}
+
+	if err := storage.Migrate(ctx, db, Migrations()); err != nil {
+		t.Fatalf("Migrate after rollback: %v", err)
+	}
+}
+
+func TestSQLiteImportJSON(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "users.json")
+
+	source, err := NewJSONUserRepository(jsonPath)
+	if err != nil {
+		t.Fatalf("NewJSONUserRepository: %v", err)
+	}
+	first, _ := source.CreateUser(ctx, "a@example.com", "A", 20)
+	source.CreateUser(ctx, "b@example.com", "B", 30)
+	source.UpdateUser(ctx, first.ID, "a@example.com", "A2", 21, 0)
+
+	repo, err := NewSQLiteUserRepository(filepath.Join(dir, "users.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteUserRepository: %v", err)
+	}
+	defer repo.Close()
+
+	n, err := repo.ImportJSON(ctx, jsonPath)
+	if err != nil || n != 2 {
+		t.Fatalf("ImportJSON = %d, %v; want 2", n, err)
+	}
+
+	got, err := repo.GetUserByID(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if got.Name != "A2" || got.Age != 21 || got.Version != 2 || got.Email != "a@example.com" || !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("imported user %+v differs from %+v", got, first)
+	}
+
+	// Импорт однократный
+	if n, err := repo.ImportJSON(ctx, jsonPath); err != nil || n != 0 {
+		t.Fatalf("second ImportJSON = %d, %v; want 0", n, err)
+	}
+
+	// Новые записи не конфликтуют с перенесёнными ID
+	created, err := repo.CreateUser(ctx, "c@example.com", "C", 40)
+	if err != nil || created.ID <= 2 {
+		t.Fatalf("CreateUser after import = %+v, %v", created, err)
+	}
+}
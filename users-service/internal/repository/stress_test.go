@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"platform/storage/storagetest"
+)
+
+// Стресс-тесты рассчитаны на запуск с детектором гонок: go test -race ./...
+
+const stressUsers = 40
+
+func stressUserBackends() map[string]func(t *testing.T) UserRepository {
+	return map[string]func(t *testing.T) UserRepository{
+		"json": func(t *testing.T) UserRepository {
+			repo, err := NewJSONUserRepository(filepath.Join(t.TempDir(), "users.json"))
+			if err != nil {
+				t.Fatalf("NewJSONUserRepository: %v", err)
+			}
+			return repo
+		},
+		"memory": func(t *testing.T) UserRepository {
+			return NewMemoryUserRepository()
+		},
+		"sqlite": func(t *testing.T) UserRepository {
+			repo, err := NewSQLiteUserRepository(filepath.Join(t.TempDir(), "users.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteUserRepository: %v", err)
+			}
+			t.Cleanup(func() { repo.Close() })
+			return repo
+		},
+	}
+}
+
+func TestUserRepositoryConcurrentAccess(t *testing.T) {
+	for name, newRepo := range stressUserBackends() {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			var ids storagetest.IDs
+
+			storagetest.Run(t, stressUsers,
+				// Писатель: создаёт, обновляет и удаляет своих пользователей
+				func(w, i int) error {
+					email := fmt.Sprintf("w%d-u%d@example.com", w, i)
+					user, err := repo.CreateUser(ctx, email, "name", 20)
+					if err != nil {
+						return err
+					}
+					if err := ids.Add(user.ID); err != nil {
+						return err
+					}
+					if _, err := repo.UpdateUser(ctx, user.ID, email, "updated", 30, 0); err != nil {
+						return err
+					}
+					if i%5 == 0 {
+						return repo.DeleteUser(ctx, user.ID, 0)
+					}
+					return nil
+				},
+				// Конкурент за один и тот же email: из всех воркеров его получит только один
+				func(w, i int) error {
+					if i == 0 {
+						repo.CreateUser(ctx, "shared@example.com", "shared", 20)
+					}
+					return nil
+				},
+				// Читатель: параллельно листает пользователей и читает отдельные записи
+				func(w, i int) error {
+					users, err := repo.GetAllUsers(ctx)
+					if err != nil {
+						return err
+					}
+					for _, user := range users {
+						repo.GetUserByID(ctx, user.ID)
+						if _, err := repo.UserExists(ctx, user.ID); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			)
+
+			users, _ := repo.GetAllUsers(ctx)
+			want := storagetest.Workers*(stressUsers-stressUsers/5) + 1
+			if len(users) != want {
+				t.Fatalf("%d users, want %d", len(users), want)
+			}
+
+			shared := 0
+			for _, user := range users {
+				if user.Email == "shared@example.com" {
+					shared++
+					continue
+				}
+				if user.Name != "updated" || user.Age != 30 {
+					t.Fatalf("user %d not updated: %+v", user.ID, user)
+				}
+			}
+			if shared != 1 {
+				t.Fatalf("shared email owned by %d users, want 1", shared)
+			}
+		})
+	}
+}
+
+func TestJSONUserRepositoryConcurrentWritesSurviveReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	repo, err := NewJSONUserRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONUserRepository: %v", err)
+	}
+	repo.storage.SetCompactEvery(7) // компактификация посреди параллельной записи
+
+	storagetest.Run(t, stressUsers,
+		func(w, i int) error {
+			email := fmt.Sprintf("w%d-u%d@example.com", w, i)
+			user, err := repo.CreateUser(ctx, email, "name", 20)
+			if err != nil {
+				return err
+			}
+			repo.UpdateUser(ctx, user.ID, email, "updated", 30, 0)
+			return nil
+		},
+		// Полный снимок параллельно с журналом
+		func(w, i int) error {
+			if w > 0 || i >= 10 {
+				return nil
+			}
+			return repo.SaveToFile()
+		},
+	)
+
+	reopened, err := NewJSONUserRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	users, _ := reopened.GetAllUsers(ctx)
+	if len(users) != storagetest.Workers*stressUsers {
+		t.Fatalf("%d users after reload, want %d", len(users), storagetest.Workers*stressUsers)
+	}
+	for _, user := range users {
+		if user.Name != "updated" {
+			t.Fatalf("user %d: name %q after reload", user.ID, user.Name)
+		}
+	}
+}
+
+func TestUserRepositoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository()
+
+	created, _ := repo.CreateUser(ctx, "a@example.com", "A", 20)
+	created.Email = "hacked@example.com"
+	created.Name = "hacked"
+
+	got, _ := repo.GetUserByID(ctx, created.ID)
+	if got.Email != "a@example.com" || got.Name != "A" {
+		t.Fatalf("stored user changed through returned pointer: %+v", got)
+	}
+
+	all, _ := repo.GetAllUsers(ctx)
+	all[0].Name = "hacked"
+	if got, _ := repo.GetUserByID(ctx, created.ID); got.Name != "A" {
+		t.Fatalf("stored user changed through listed pointer: %+v", got)
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"platform/pagination"
+	"platform/storage"
+	"platform/tracing"
+
+	"users-service/internal/models"
+)
+
+// TracedUserRepository пишет span на каждый вызов хранилища next (storage.Traced)
+type TracedUserRepository struct {
+	storage.Traced[UserRepository]
+}
+
+var _ UserRepository = (*TracedUserRepository)(nil)
+
+// NewTracedUserRepository оборачивает хранилище next трассировкой
+func NewTracedUserRepository(next UserRepository) *TracedUserRepository {
+	return &TracedUserRepository{storage.NewTraced("UserRepository", next)}
+}
+
+func (r *TracedUserRepository) CreateUser(ctx context.Context, email, name string, age int) (user *models.User, err error) {
+	ctx, span := r.Start(ctx, "CreateUser")
+	defer tracing.End(span, &err)
+	return r.Next.CreateUser(ctx, email, name, age)
+}
+
+func (r *TracedUserRepository) GetUserByID(ctx context.Context, id int64) (user *models.User, err error) {
+	ctx, span := r.Start(ctx, "GetUserByID", attribute.Int64("user.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.GetUserByID(ctx, id)
+}
+
+func (r *TracedUserRepository) GetAllUsers(ctx context.Context) (users []*models.User, err error) {
+	ctx, span := r.Start(ctx, "GetAllUsers")
+	defer tracing.End(span, &err)
+	return r.Next.GetAllUsers(ctx)
+}
+
+func (r *TracedUserRepository) ListUsers(ctx context.Context, q pagination.ListQuery) (page *pagination.Page[*models.User], err error) {
+	ctx, span := r.Start(ctx, "ListUsers")
+	defer tracing.End(span, &err)
+	return r.Next.ListUsers(ctx, q)
+}
+
+func (r *TracedUserRepository) UpdateUser(ctx context.Context, id int64, email, name string, age int, expectedVersion int64) (user *models.User, err error) {
+	ctx, span := r.Start(ctx, "UpdateUser", attribute.Int64("user.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.UpdateUser(ctx, id, email, name, age, expectedVersion)
+}
+
+func (r *TracedUserRepository) DeleteUser(ctx context.Context, id int64, expectedVersion int64) (err error) {
+	ctx, span := r.Start(ctx, "DeleteUser", attribute.Int64("user.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.DeleteUser(ctx, id, expectedVersion)
+}
+
+func (r *TracedUserRepository) UserExists(ctx context.Context, id int64) (exists bool, err error) {
+	ctx, span := r.Start(ctx, "UserExists", attribute.Int64("user.id", id))
+	defer tracing.End(span, &err)
+	return r.Next.UserExists(ctx, id)
+}
+
+// TracedDeletionRepository - то же для хранилища хода саг удаления
+type TracedDeletionRepository struct {
+	storage.Traced[DeletionRepository]
+}
+
+var _ DeletionRepository = (*TracedDeletionRepository)(nil)
+
+// NewTracedDeletionRepository оборачивает хранилище next трассировкой
+func NewTracedDeletionRepository(next DeletionRepository) *TracedDeletionRepository {
+	return &TracedDeletionRepository{storage.NewTraced("DeletionRepository", next)}
+}
+
+func (r *TracedDeletionRepository) SaveDeletion(ctx context.Context, deletion *models.UserDeletion) (err error) {
+	ctx, span := r.Start(ctx, "SaveDeletion", attribute.Int64("user.id", deletion.ID), attribute.String("deletion.status", deletion.Status))
+	defer tracing.End(span, &err)
+	return r.Next.SaveDeletion(ctx, deletion)
+}
+
+func (r *TracedDeletionRepository) GetDeletion(ctx context.Context, userID int64) (deletion *models.UserDeletion, err error) {
+	ctx, span := r.Start(ctx, "GetDeletion", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.GetDeletion(ctx, userID)
+}
+
+func (r *TracedDeletionRepository) GetUnfinishedDeletions(ctx context.Context) (deletions []*models.UserDeletion, err error) {
+	ctx, span := r.Start(ctx, "GetUnfinishedDeletions")
+	defer tracing.End(span, &err)
+	return r.Next.GetUnfinishedDeletions(ctx)
+}
+
+func (r *TracedDeletionRepository) DeleteDeletion(ctx context.Context, userID int64) (err error) {
+	ctx, span := r.Start(ctx, "DeleteDeletion", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+	return r.Next.DeleteDeletion(ctx, userID)
+}
@@ -1,38 +1,46 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
+	"platform/pagination"
+	"platform/storage"
+
 	"users-service/internal/models"
-	"users-service/internal/utils"
 )
 
-// JSONUserRepository - репозиторий с JSON-хранилищем
+// JSONUserRepository - репозиторий с JSON-хранилищем.
+//
+// Блокировки: mu защищает карту и nextID (чтение под RLock, изменения под
+// Lock), persistMu упорядочивает запись в журнал. Изменение захватывает
+// persistMu до того, как отпустить mu, поэтому записи попадают в журнал в
+// том же порядке, что и в карту, а читатели не ждут fsync. Наружу отдаются
+// только копии пользователей.
 type JSONUserRepository struct {
-	mu       sync.RWMutex
-	storage  *utils.FileStorage
-	users    map[int64]*models.User
-	nextID   int64
-	filePath string
+	mu        sync.RWMutex
+	persistMu sync.Mutex
+	storage   *storage.FileStorage
+	users     map[int64]*models.User
+	nextID    int64
+	filePath  string
 }
 
-// NewJSONUserRepository создаёт новый репозиторий с JSON-хранилищем
+// NewJSONUserRepository создаёт новый репозиторий
 func NewJSONUserRepository(filePath string) (*JSONUserRepository, error) {
 	repo := &JSONUserRepository{
-		storage:  utils.NewFileStorage(filePath),
+		storage:  storage.NewFileStorage(filePath),
 		users:    make(map[int64]*models.User),
 		nextID:   1,
 		filePath: filePath,
 	}
 
-	// Создаём файл если его нет
 	if err := repo.storage.EnsureFile(); err != nil {
 		return nil, err
 	}
 
-	// Загружаем данные из файла
 	if err := repo.LoadFromFile(); err != nil {
 		return nil, err
 	}
@@ -40,20 +48,28 @@ func NewJSONUserRepository(filePath string) (*JSONUserRepository, error) {
 	return repo, nil
 }
 
-// LoadFromFile загружает пользователей из JSON файла
+// LoadFromFile загружает пользователей из JSON
 func (r *JSONUserRepository) LoadFromFile() error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+	if r.storage == nil {
+		return nil
+	}
 
 	var users []*models.User
 	if err := r.storage.LoadJSON(&users); err != nil {
 		return err
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.users = make(map[int64]*models.User)
 	r.nextID = 1
 
 	for _, user := range users {
+		// Записи, сохранённые до появления версий, начинают с первой
+		if user.Version == 0 {
+			user.Version = 1
+		}
 		r.users[user.ID] = user
 		if user.ID >= r.nextID {
 			r.nextID = user.ID + 1
@@ -63,155 +79,264 @@ func (r *JSONUserRepository) LoadFromFile() error {
 	return nil
 }
 
-// SaveToFile сохраняет пользователей в JSON файл
+// SaveToFile сохраняет полный снимок пользователей в JSON
 func (r *JSONUserRepository) SaveToFile() error {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+	if r.storage == nil {
+		return nil
+	}
 
+	r.mu.RLock()
 	users := make([]*models.User, 0, len(r.users))
 	for _, user := range r.users {
-		users = append(users, user)
+		users = append(users, user.Clone())
 	}
 
+	// Держим очередь журнала до конца записи снимка: иначе более позднее
+	// изменение попадёт в журнал и будет стёрто при его очистке
+	r.persistMu.Lock()
+	r.mu.RUnlock()
+	defer r.persistMu.Unlock()
+
 	return r.storage.SaveJSON(users)
 }
 
-// CreateUser создаёт нового пользователя
-func (r *JSONUserRepository) CreateUser(email string, name string, age int) (*models.User, error) {
-	// Валидация @ в email
-	if len(email) == 0 || email[0] == '@' || email[len(email)-1] == '@' {
-		return nil, fmt.Errorf("invalid email: must contain @ character in valid position")
+// Close дожидается записи в журнал и сворачивает его в снимок; изменения
+// после этого не сохраняются (storage.ErrClosed)
+func (r *JSONUserRepository) Close() error {
+	if r.storage == nil {
+		return nil
 	}
 
-	atCount := 0
-	atIndex := -1
-	for i, c := range email {
-		if c == '@' {
-			atCount++
-			atIndex = i
-		}
+	r.persistMu.Lock()
+	defer r.persistMu.Unlock()
+
+	return r.storage.Close()
+}
+
+// unlockAndPersist отпускает mu и дописывает updated в журнал. Если запись
+// не удалась, в карту возвращается previous (nil - пользователя не было), чтобы
+// память не расходилась с журналом.
+func (r *JSONUserRepository) unlockAndPersist(previous, updated *models.User) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
+
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	err := r.storage.Put(updated.ID, updated)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
 	}
 
-	if atCount != 1 {
-		return nil, fmt.Errorf("invalid email: must contain exactly one @ character")
+	// mu берётся после persistMu: изменения захватывают их в обратном порядке.
+	// Запись, которую успели изменить снова, не откатывается.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, ok := r.users[updated.ID]; ok && current.Version == updated.Version {
+		if previous == nil {
+			delete(r.users, updated.ID)
+		} else {
+			r.users[updated.ID] = previous
+		}
 	}
+	return err
+}
 
-	if atIndex == 0 || atIndex == len(email)-1 {
-		return nil, fmt.Errorf("invalid email: @ must not be at the beginning or end")
+// unlockAndPersistDelete отпускает mu и дописывает удаление в журнал; если
+// запись не удалась, удалённые пользователей возвращаются в карту
+func (r *JSONUserRepository) unlockAndPersistDelete(deleted ...*models.User) error {
+	r.persistMu.Lock()
+	r.mu.Unlock()
+
+	if r.storage == nil {
+		r.persistMu.Unlock()
+		return nil
+	}
+	ids := make([]int64, len(deleted))
+	for i, user := range deleted {
+		ids[i] = user.ID
+	}
+	err := r.storage.Delete(ids...)
+	r.persistMu.Unlock()
+	if err == nil {
+		return nil
 	}
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	for _, user := range deleted {
+		r.users[user.ID] = user
+	}
+	return err
+}
 
-	// Проверяем уникальность email
-	for _, u := range r.users {
-		if u.Email == email {
-			return nil, fmt.Errorf("email already exists")
-		}
+// CreateUser создаёт нового пользователя
+func (r *JSONUserRepository) CreateUser(ctx context.Context, email string, name string, age int) (*models.User, error) {
+	if err := validateEmail(email); err != nil {
+		return nil, err
 	}
 
+	now := time.Now()
 	user := &models.User{
-		ID:        r.nextID,
 		Email:     email,
 		Name:      name,
 		Age:       age,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
+	r.mu.Lock()
+
+	// Проверяем уникальность email
+	for _, u := range r.users {
+		if u.Email == email {
+			r.mu.Unlock()
+			return nil, ErrEmailTaken
+		}
+	}
+
+	user.ID = r.nextID
 	r.users[user.ID] = user
 	r.nextID++
+	created := user.Clone()
 
 	// Сохраняем в файл
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(nil, created); err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return created, nil
 }
 
 // GetUserByID получает пользователя по ID
-func (r *JSONUserRepository) GetUserByID(id int64) (*models.User, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONUserRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	user, exists := r.users[id]
 	if !exists {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	}
 
-	return user, nil
+	return user.Clone(), nil
 }
 
 // GetAllUsers получает всех пользователей
-func (r *JSONUserRepository) GetAllUsers() ([]*models.User, error) {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONUserRepository) GetAllUsers(ctx context.Context) ([]*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	users := make([]*models.User, 0, len(r.users))
 	for _, user := range r.users {
-		users = append(users, user)
+		users = append(users, user.Clone())
 	}
 
 	return users, nil
 }
 
+// ListUsers возвращает страницу пользователей
+func (r *JSONUserRepository) ListUsers(ctx context.Context, q pagination.ListQuery) (*pagination.Page[*models.User], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+
+	page := pagination.Paginate(users, q, userListKey)
+	for i, user := range page.Items {
+		page.Items[i] = user.Clone()
+	}
+	return page, nil
+}
+
 // UpdateUser обновляет пользователя
-func (r *JSONUserRepository) UpdateUser(id int64, email, name string, age int) (*models.User, error) {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONUserRepository) UpdateUser(ctx context.Context, id int64, email, name string, age int, expectedVersion int64) (*models.User, error) {
+	r.mu.Lock()
 
 	user, exists := r.users[id]
 	if !exists {
-		return nil, fmt.Errorf("user not found")
+		r.mu.Unlock()
+		return nil, ErrUserNotFound
 	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		r.mu.Unlock()
+		return nil, ErrVersionMismatch
+	}
+	previous := user.Clone()
 
 	// Если email менялся - валидируем
 	if email != user.Email {
-		atCount := 0
-		for _, c := range email {
-			if c == '@' {
-				atCount++
-			}
-		}
-		if atCount != 1 {
-			return nil, fmt.Errorf("invalid email: must contain exactly one @ character")
+		if err := validateEmail(email); err != nil {
+			r.mu.Unlock()
+			return nil, err
 		}
 	}
 
 	user.Email = email
 	user.Name = name
 	user.Age = age
+	user.Version++
 	user.UpdatedAt = time.Now()
+	updated := user.Clone()
 
 	// Сохраняем в файл
-	if err := r.SaveToFile(); err != nil {
+	if err := r.unlockAndPersist(previous, updated); err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return updated, nil
 }
 
 // DeleteUser удаляет пользователя
-func (r *JSONUserRepository) DeleteUser(id int64) error {
-	//r.mu.Lock()
-	//defer r.mu.Unlock()
+func (r *JSONUserRepository) DeleteUser(ctx context.Context, id int64, expectedVersion int64) error {
+	r.mu.Lock()
 
-	if _, exists := r.users[id]; !exists {
-		return fmt.Errorf("user not found")
+	user, exists := r.users[id]
+	if !exists {
+		r.mu.Unlock()
+		return ErrUserNotFound
+	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		r.mu.Unlock()
+		return ErrVersionMismatch
 	}
 
 	delete(r.users, id)
 
 	// Сохраняем в файл
-	return r.SaveToFile()
+	return r.unlockAndPersistDelete(user)
 }
 
 // UserExists проверяет существование пользователя
-func (r *JSONUserRepository) UserExists(id int64) bool {
-	//r.mu.RLock()
-	//defer r.mu.RUnlock()
+func (r *JSONUserRepository) UserExists(ctx context.Context, id int64) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	_, exists := r.users[id]
-	return exists
+	return exists, nil
+}
+
+// validateEmail проверяет, что в email ровно один @ и он не первый и не последний символ
+func validateEmail(email string) error {
+	if len(email) == 0 || email[0] == '@' || email[len(email)-1] == '@' {
+		return fmt.Errorf("%w: must contain @ character in valid position", ErrInvalidEmail)
+	}
+
+	atCount := 0
+	for _, c := range email {
+		if c == '@' {
+			atCount++
+		}
+	}
+
+	if atCount != 1 {
+		return fmt.Errorf("%w: must contain exactly one @ character", ErrInvalidEmail)
+	}
+
+	return nil
 }
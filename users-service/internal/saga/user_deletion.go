@@ -0,0 +1,283 @@
+// Package saga удаляет пользователя вместе с его данными в других сервисах.
+//
+// Каждый шаг - идемпотентное удаление в одном сервисе. Ход саги сохраняется в
+// DeletionRepository после каждой попытки, поэтому после перезапуска сага
+// продолжается с первого незавершённого шага. Неудачный шаг повторяется с
+// экспоненциальной паузой; когда попытки кончаются, сага становится failed и
+// ждёт повторного DELETE.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"platform/requestid"
+	"platform/tracing"
+
+	"users-service/internal/metrics"
+	"users-service/internal/models"
+	"users-service/internal/repository"
+)
+
+// Step - шаг саги. Run должен быть идемпотентным: после сбоя шаг выполняется повторно.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context, userID int64) error
+}
+
+// Config - политика повторов неудачных шагов
+type Config struct {
+	MaxAttempts int           // попыток на шаг, после них сага - failed
+	BaseDelay   time.Duration // пауза перед первым повтором, дальше удваивается
+	MaxDelay    time.Duration // потолок паузы
+}
+
+// DefaultConfig - 10 попыток с паузами от секунды до минуты, около пяти минут на шаг
+var DefaultConfig = Config{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+// Coordinator запускает саги удаления и следит, чтобы у пользователя
+// выполнялась не больше чем одна.
+//
+// mu упорядочивает сохранение хода саги с Begin: иначе перезапуск саги мог бы
+// проскочить между последней записью горутины и её выходом.
+type Coordinator struct {
+	store  repository.DeletionRepository
+	steps  []Step
+	config Config
+	now    func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[int64]bool
+}
+
+// NewCoordinator создаёт координатор саг с шагами steps в порядке выполнения
+func NewCoordinator(store repository.DeletionRepository, steps []Step, config Config) *Coordinator {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Coordinator{
+		store:  store,
+		steps:  steps,
+		config: config,
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+		active: make(map[int64]bool),
+	}
+}
+
+// Begin сохраняет сагу удаления пользователя, но не запускает её.
+// Выполняющаяся сага возвращается как есть, failed - перезапускается с
+// незавершённых шагов; created сообщает, что сага создана этим вызовом.
+func (c *Coordinator) Begin(ctx context.Context, userID int64) (deletion *models.UserDeletion, created bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deletion, err = c.store.GetDeletion(ctx, userID)
+	switch {
+	case errors.Is(err, repository.ErrDeletionNotFound) || err == nil && deletion.Status == models.DeletionStatusCompleted:
+		deletion = models.NewUserDeletion(userID, c.stepNames(), c.now())
+		created = true
+	case err != nil:
+		return nil, false, err
+	case deletion.Status == models.DeletionStatusRunning:
+		return deletion, false, nil
+	default:
+		deletion.Status = models.DeletionStatusRunning
+		deletion.CompletedAt = nil
+		deletion.UpdatedAt = c.now()
+		for i := range deletion.Steps {
+			if step := &deletion.Steps[i]; step.Status != models.DeletionStepDone {
+				*step = models.DeletionStep{Name: step.Name, Status: models.DeletionStepPending}
+			}
+		}
+	}
+
+	if err := c.store.SaveDeletion(ctx, deletion); err != nil {
+		return nil, false, err
+	}
+	return deletion, created, nil
+}
+
+// Abandon удаляет сагу, созданную Begin, если удалять оказалось нечего
+func (c *Coordinator) Abandon(ctx context.Context, userID int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.store.DeleteDeletion(ctx, userID)
+}
+
+// Start выполняет сагу в фоне. Повторный вызов для уже выполняющейся саги ничего не делает.
+func (c *Coordinator) Start(deletion *models.UserDeletion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.active[deletion.ID] || c.ctx.Err() != nil {
+		return
+	}
+	c.active[deletion.ID] = true
+
+	c.wg.Add(1)
+	go c.run(deletion.Clone())
+}
+
+// Resume запускает саги, прерванные остановкой сервиса
+func (c *Coordinator) Resume(ctx context.Context) (int, error) {
+	deletions, err := c.store.GetUnfinishedDeletions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, deletion := range deletions {
+		c.Start(deletion)
+	}
+	return len(deletions), nil
+}
+
+// Status возвращает ход саги удаления пользователя или repository.ErrDeletionNotFound
+func (c *Coordinator) Status(ctx context.Context, userID int64) (*models.UserDeletion, error) {
+	return c.store.GetDeletion(ctx, userID)
+}
+
+// Close останавливает саги и ждёт их горутины. Ход сохранён, после
+// перезапуска Resume продолжит с прерванного шага.
+func (c *Coordinator) Close() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+func (c *Coordinator) run(deletion *models.UserDeletion) {
+	defer c.wg.Done()
+
+	// Span всей саги: попытки шагов и вызовы соседних сервисов - его потомки
+	ctx, span := tracing.Start(c.ctx, "UserDeletion", attribute.Int64("user.id", deletion.ID))
+	defer span.End()
+
+	for i := range deletion.Steps {
+		step := &deletion.Steps[i]
+		if step.Status == models.DeletionStepDone {
+			continue
+		}
+
+		for {
+			err := c.runStep(ctx, step.Name, deletion.ID)
+			if c.ctx.Err() != nil {
+				c.release(deletion.ID)
+				return
+			}
+
+			now := c.now()
+			step.Attempts++
+			deletion.UpdatedAt = now
+
+			if err == nil {
+				step.Status = models.DeletionStepDone
+				step.LastError = ""
+				step.NextAttemptAt = nil
+				step.CompletedAt = &now
+				c.save(deletion, false)
+				break
+			}
+
+			step.LastError = err.Error()
+			if step.Attempts >= c.config.MaxAttempts {
+				step.Status = models.DeletionStepFailed
+				step.NextAttemptAt = nil
+				deletion.Status = models.DeletionStatusFailed
+				span.SetStatus(codes.Error, step.Name+": "+step.LastError)
+				c.save(deletion, true)
+				return
+			}
+
+			delay := c.delay(step.Attempts)
+			next := now.Add(delay)
+			step.Status = models.DeletionStepRetrying
+			step.NextAttemptAt = &next
+			c.save(deletion, false)
+
+			select {
+			case <-c.ctx.Done():
+				c.release(deletion.ID)
+				return
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	now := c.now()
+	deletion.Status = models.DeletionStatusCompleted
+	deletion.CompletedAt = &now
+	deletion.UpdatedAt = now
+	c.save(deletion, true)
+}
+
+// runStep выполняет попытку шага в span саги ctx. У попытки свой span и свой
+// идентификатор запроса: по нему вызовы соседних сервисов находятся в их журналах.
+func (c *Coordinator) runStep(ctx context.Context, name string, userID int64) (err error) {
+	for _, step := range c.steps {
+		if step.Name != name {
+			continue
+		}
+		ctx, span := tracing.Start(requestid.WithID(ctx, requestid.New()), "UserDeletion."+name)
+		defer tracing.End(span, &err)
+
+		err = step.Run(ctx, userID)
+		if c.ctx.Err() != nil {
+			return err // остановка сервиса - не попытка
+		}
+		metrics.StepAttempt(name, err)
+		if err != nil {
+			slog.WarnContext(ctx, "user deletion step failed", "user_id", userID, "step", name, "err", err)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown step %q", name)
+}
+
+// save сохраняет ход саги; final - горутина саги на этом завершается
+func (c *Coordinator) save(deletion *models.UserDeletion, final bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Ошибка записи не останавливает сагу: следующий шаг сохранит ход заново,
+	// а в худшем случае после перезапуска повторятся идемпотентные шаги
+	if err := c.store.SaveDeletion(context.Background(), deletion); err != nil {
+		slog.Error("save user deletion progress", "deletion_id", deletion.ID, "err", err)
+	}
+	if final {
+		metrics.Deletion(deletion.Status)
+		delete(c.active, deletion.ID)
+	}
+}
+
+func (c *Coordinator) release(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.active, userID)
+}
+
+// delay - пауза перед попыткой attempts+1: BaseDelay, 2*BaseDelay, ... не больше MaxDelay
+func (c *Coordinator) delay(attempts int) time.Duration {
+	delay := c.config.BaseDelay
+	for i := 1; i < attempts && delay < c.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, c.config.MaxDelay)
+}
+
+func (c *Coordinator) stepNames() []string {
+	names := make([]string, len(c.steps))
+	for i, step := range c.steps {
+		names[i] = step.Name
+	}
+	return names
+}
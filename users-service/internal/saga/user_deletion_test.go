@@ -0,0 +1,268 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"users-service/internal/models"
+	"users-service/internal/repository"
+)
+
+var fastRetries = Config{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// recorder запоминает вызовы шагов; fail[name] неудач подряд перед успехом
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+	fail  map[string]int
+}
+
+func (r *recorder) step(name string) Step {
+	return Step{Name: name, Run: func(ctx context.Context, userID int64) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.calls = append(r.calls, name)
+		if r.fail[name] > 0 {
+			r.fail[name]--
+			return errors.New(name + " unavailable")
+		}
+		return nil
+	}}
+}
+
+func (r *recorder) callsSnapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// waitFinished ждёт, пока сага завершится или исчерпает попытки
+func waitFinished(t *testing.T, c *Coordinator, userID int64) *models.UserDeletion {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deletion, err := c.Status(context.Background(), userID)
+		if err == nil && deletion.Finished() {
+			return deletion
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("user deletion %d did not finish", userID)
+	return nil
+}
+
+func begin(t *testing.T, c *Coordinator, userID int64) *models.UserDeletion {
+	t.Helper()
+
+	deletion, _, err := c.Begin(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	c.Start(deletion)
+	return deletion
+}
+
+func TestDeletionRunsStepsInOrder(t *testing.T) {
+	rec := &recorder{}
+	c := NewCoordinator(repository.NewMemoryDeletionRepository(),
+		[]Step{rec.step("user"), rec.step("orders"), rec.step("payments")}, fastRetries)
+	defer c.Close()
+
+	begin(t, c, 1)
+	deletion := waitFinished(t, c, 1)
+
+	if deletion.Status != models.DeletionStatusCompleted || deletion.CompletedAt == nil {
+		t.Fatalf("deletion %+v, want completed", deletion)
+	}
+	for _, step := range deletion.Steps {
+		if step.Status != models.DeletionStepDone || step.Attempts != 1 || step.CompletedAt == nil {
+			t.Fatalf("step %+v, want done after one attempt", step)
+		}
+	}
+	if calls := rec.callsSnapshot(); len(calls) != 3 || calls[0] != "user" || calls[2] != "payments" {
+		t.Fatalf("calls %v", calls)
+	}
+}
+
+func TestDeletionRetriesFailedStep(t *testing.T) {
+	rec := &recorder{fail: map[string]int{"orders": 2}}
+	c := NewCoordinator(repository.NewMemoryDeletionRepository(),
+		[]Step{rec.step("user"), rec.step("orders"), rec.step("payments")}, fastRetries)
+	defer c.Close()
+
+	begin(t, c, 1)
+	deletion := waitFinished(t, c, 1)
+
+	orders := deletion.Steps[1]
+	if deletion.Status != models.DeletionStatusCompleted || orders.Attempts != 3 || orders.LastError != "" || orders.NextAttemptAt != nil {
+		t.Fatalf("deletion %+v, want completed with 3 attempts of orders", deletion)
+	}
+}
+
+func TestDeletionFailsAfterMaxAttemptsAndRestarts(t *testing.T) {
+	rec := &recorder{fail: map[string]int{"payments": 5}}
+	c := NewCoordinator(repository.NewMemoryDeletionRepository(),
+		[]Step{rec.step("user"), rec.step("orders"), rec.step("payments"), rec.step("deliveries")}, fastRetries)
+	defer c.Close()
+
+	begin(t, c, 1)
+	failed := waitFinished(t, c, 1)
+
+	payments := failed.Steps[2]
+	if failed.Status != models.DeletionStatusFailed || payments.Status != models.DeletionStepFailed ||
+		payments.Attempts != 3 || payments.LastError != "payments unavailable" {
+		t.Fatalf("deletion %+v, want failed on payments", failed)
+	}
+	if failed.Steps[3].Status != models.DeletionStepPending {
+		t.Fatalf("step after the failed one ran: %+v", failed.Steps[3])
+	}
+
+	// Повторный запуск продолжает с незавершённого шага, выполненные не повторяются
+	restarted, created, err := c.Begin(context.Background(), 1)
+	if err != nil || created || restarted.Status != models.DeletionStatusRunning || restarted.Steps[2].Attempts != 0 {
+		t.Fatalf("Begin on failed saga = %+v, %v, %v", restarted, created, err)
+	}
+	c.Start(restarted)
+	deletion := waitFinished(t, c, 1)
+
+	if deletion.Status != models.DeletionStatusCompleted || deletion.Steps[0].Attempts != 1 {
+		t.Fatalf("deletion %+v, want completed without rerunning done steps", deletion)
+	}
+	userCalls := 0
+	for _, call := range rec.callsSnapshot() {
+		if call == "user" {
+			userCalls++
+		}
+	}
+	if userCalls != 1 {
+		t.Fatalf("user step ran %d times, want 1", userCalls)
+	}
+}
+
+func TestDeletionResumesAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "user_deletions.json")
+
+	store, err := repository.NewJSONDeletionRepository(path)
+	if err != nil {
+		t.Fatalf("NewJSONDeletionRepository: %v", err)
+	}
+
+	// Первый запуск: orders недоступен, сервис останавливается посреди повторов
+	rec := &recorder{fail: map[string]int{"orders": 1000}}
+	slow := Config{MaxAttempts: 1000, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	first := NewCoordinator(store, []Step{rec.step("user"), rec.step("orders")}, slow)
+	begin(t, first, 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deletion, _ := first.Status(ctx, 1)
+		if deletion.Steps[1].Attempts >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("orders step was never retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	first.Close()
+
+	// Второй запуск на том же файле: сага продолжается со шага orders
+	reopened, err := repository.NewJSONDeletionRepository(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	rec2 := &recorder{}
+	second := NewCoordinator(reopened, []Step{rec2.step("user"), rec2.step("orders")}, fastRetries)
+	defer second.Close()
+
+	resumed, err := second.Resume(ctx)
+	if err != nil || resumed != 1 {
+		t.Fatalf("Resume = %d, %v; want 1", resumed, err)
+	}
+	deletion := waitFinished(t, second, 1)
+
+	if deletion.Status != models.DeletionStatusCompleted {
+		t.Fatalf("deletion %+v, want completed", deletion)
+	}
+	if calls := rec2.callsSnapshot(); len(calls) != 1 || calls[0] != "orders" {
+		t.Fatalf("calls after restart %v, want only orders", calls)
+	}
+}
+
+func TestBeginReturnsRunningSaga(t *testing.T) {
+	ctx := context.Background()
+	c := NewCoordinator(repository.NewMemoryDeletionRepository(), []Step{{Name: "user", Run: func(context.Context, int64) error { return nil }}}, fastRetries)
+	defer c.Close()
+
+	first, created, _ := c.Begin(ctx, 1)
+	if !created {
+		t.Fatal("first Begin did not create a saga")
+	}
+	second, created, _ := c.Begin(ctx, 1)
+	if created || !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("second Begin replaced the running saga: %+v", second)
+	}
+
+	c.Abandon(ctx, 1)
+	if _, err := c.Status(ctx, 1); !errors.Is(err, repository.ErrDeletionNotFound) {
+		t.Fatalf("abandoned saga still there: %v", err)
+	}
+}
+
+func TestDelayBackoff(t *testing.T) {
+	c := NewCoordinator(repository.NewMemoryDeletionRepository(), nil,
+		Config{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second})
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range want {
+		if got := c.delay(i + 1); got != delay {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, delay)
+		}
+	}
+}
+
+func TestDeletionStepsShareTrace(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	rec := &recorder{fail: map[string]int{"orders": 1}}
+	c := NewCoordinator(repository.NewMemoryDeletionRepository(),
+		[]Step{rec.step("user"), rec.step("orders")}, fastRetries)
+	begin(t, c, 1)
+	waitFinished(t, c, 1)
+	c.Close() // ждём конца горутины саги и её span
+
+	ended := spans.Ended()
+	var names []string
+	for _, span := range ended {
+		names = append(names, span.Name())
+	}
+	want := []string{"UserDeletion.user", "UserDeletion.orders", "UserDeletion.orders", "UserDeletion"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("spans %v, want %v", names, want)
+	}
+	saga := ended[len(ended)-1].SpanContext()
+	for _, span := range ended[:len(ended)-1] {
+		if span.Parent().SpanID() != saga.SpanID() || span.SpanContext().TraceID() != saga.TraceID() {
+			t.Errorf("step span %s is not a child of the saga span", span.Name())
+		}
+	}
+	if ended[1].Status().Code != codes.Error {
+		t.Errorf("failed attempt has status %v", ended[1].Status())
+	}
+}